@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/app"
@@ -26,6 +28,14 @@ func main() {
 		logger.Fatal("Ошибка инициализации конфигурации", zap.Error(err))
 	}
 
+	// Команда управления миграциями схемы БД: shortener [flags] migrate up|down [N]|status
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(context.Background(), cfg.DatabaseDSN, args[1:], logger); err != nil {
+			logger.Fatal("Ошибка выполнения миграций", zap.Error(err))
+		}
+		return
+	}
+
 	// Создание и настройка приложения
 	application, err := app.NewApp(cfg)
 	if err != nil {
@@ -35,10 +45,10 @@ func main() {
 		logger.Fatal("Ошибка конфигурации приложения", zap.Error(err))
 	}
 
-	// Запуск сервера
-	server := application.GetServer()
+	// Запуск сервера; Serve блокируется до SIGINT/SIGTERM и корректно останавливает приложение
 	logger.Info("Сервер запускается", zap.String("address", cfg.ServerAddress))
-	if err := server.ListenAndServe(); err != nil {
-		logger.Fatal("Server failed to start", zap.Error(err))
+	if err := application.Serve(context.Background()); err != nil {
+		logger.Fatal("Ошибка работы сервера", zap.Error(err))
 	}
+	logger.Info("Сервер остановлен")
 }
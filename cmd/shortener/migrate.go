@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage/migrations"
+	_ "github.com/lib/pq" // Драйвер PostgreSQL для database/sql
+	"go.uber.org/zap"
+)
+
+// errMigrateUsage возвращается при некорректных аргументах команды migrate
+var errMigrateUsage = errors.New("usage: shortener [flags] migrate up | down [N] | status")
+
+// runMigrate выполняет команду migrate над базой данных dsn.
+// Поддерживаются подкоманды up, down [N] (по умолчанию откатывается одна миграция) и status.
+func runMigrate(ctx context.Context, dsn string, args []string, logger *zap.Logger) error {
+	if dsn == "" {
+		return errors.New("database DSN is not configured")
+	}
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("error opening database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return errMigrateUsage
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migration(s)\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
@@ -3,13 +3,24 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/grpcapi"
 	"github.com/InQaaaaGit/trunc_url.git/internal/handler"
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
 	"github.com/InQaaaaGit/trunc_url.git/internal/service"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -17,10 +28,34 @@ import (
 // App представляет основное приложение сервиса сокращения URL.
 // Инкапсулирует конфигурацию, HTTP роутер, логгер и обработчики запросов.
 type App struct {
-	config  *config.Config   // Конфигурация приложения
-	router  *chi.Mux         // HTTP роутер для обработки запросов
-	logger  *zap.Logger      // Логгер для записи событий приложения
-	handler *handler.Handler // Обработчики HTTP запросов
+	config  *config.Config     // Конфигурация приложения
+	router  *chi.Mux           // HTTP роутер для обработки запросов
+	logger  *zap.Logger        // Логгер для записи событий приложения
+	handler *handler.Handler   // Обработчики HTTP запросов
+	service service.URLService // Сервис, используемый обработчиками (для фоновых задач)
+	tracer  *tracing.Tracer    // Трассировщик приложения (nil — трассировка отключена)
+
+	stopJobs context.CancelFunc // Останавливает фоновые задачи, запущенные startBackgroundJobs
+	jobs     sync.WaitGroup     // Запущенные фоновые задачи
+}
+
+// defaultSecretKey — значение SecretKey по умолчанию из config.NewConfig
+const defaultSecretKey = "your-secret-key"
+
+// rateLimitPurgeInterval — интервал удаления наполнившихся корзин ограничителя частоты запросов
+const rateLimitPurgeInterval = time.Minute
+
+// defaultShutdownTimeout используется, если ShutdownTimeout в конфигурации не задан
+const defaultShutdownTimeout = 10 * time.Second
+
+// closableService — сервис, владеющий ресурсами, которые нужно освободить при остановке
+type closableService interface {
+	Close(ctx context.Context) error
+}
+
+// policyService — сервис с политикой URL назначения, файл правил которой перечитывается во время работы
+type policyService interface {
+	URLPolicy() *service.URLPolicy
 }
 
 // NewApp создает и инициализирует новый экземпляр приложения.
@@ -36,11 +71,40 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, fmt.Errorf("error creating logger: %w", err)
 	}
 
+	// Трассировщик устанавливается до создания сервиса, чтобы трассировались и миграции схемы
+	tracer, err := tracing.New(tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		ServiceName:  cfg.TracingServiceName,
+		FilePath:     cfg.TracingFile,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracer: %w", err)
+	}
+	tracing.SetTracer(tracer)
+
+	if err := validateSessionConfig(cfg, logger); err != nil {
+		return nil, err
+	}
+	if err := validateRateLimitConfig(cfg); err != nil {
+		return nil, err
+	}
+
 	service, err := service.NewURLService(cfg, logger)
 	if err != nil {
 		return nil, fmt.Errorf("error creating service: %w", err)
 	}
 
+	// Хранилище корзин проверяется на выбранном хранилище сервиса: без этого
+	// ограничение частоты запросов молча отключилось бы при переходе на другое хранилище
+	if _, err := handler.NewRateLimiter(service, cfg); err != nil {
+		if closer, ok := service.(closableService); ok {
+			closer.Close(context.Background())
+		}
+		return nil, fmt.Errorf("invalid rate limit: %w", err)
+	}
+
 	handler := handler.NewHandler(service, cfg, logger)
 
 	return &App{
@@ -48,77 +112,244 @@ func NewApp(cfg *config.Config) (*App, error) {
 		router:  chi.NewRouter(),
 		logger:  logger,
 		handler: handler,
+		service: service,
+		tracer:  tracer,
 	}, nil
 }
 
-// Run запускает HTTP сервер приложения.
-// Настраивает маршруты, создает HTTP сервер с таймаутами и начинает прослушивание запросов.
-// Блокирующий вызов - выполняется до остановки сервера.
+// validateSessionConfig проверяет параметры сессий и предупреждает о небезопасных значениях
+func validateSessionConfig(cfg *config.Config, logger *zap.Logger) error {
+	if _, err := middleware.ParseKeyring(cfg.SessionKeys, cfg.SecretKey); err != nil {
+		return fmt.Errorf("invalid session keys: %w", err)
+	}
+	sameSite, err := middleware.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return fmt.Errorf("invalid cookie settings: %w", err)
+	}
+
+	if cfg.SessionKeys == "" && cfg.SecretKey == defaultSecretKey {
+		logger.Warn("Sessions are signed with the default secret key; set SESSION_KEYS or SECRET_KEY")
+	}
+	if sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		logger.Warn("Browsers reject SameSite=None cookies without Secure; set COOKIE_SECURE")
+	}
+	return nil
+}
+
+// validateRateLimitConfig проверяет параметры ограничения частоты запросов
+func validateRateLimitConfig(cfg *config.Config) error {
+	if _, err := ratelimit.LimitsFromConfig(cfg); err != nil {
+		return fmt.Errorf("invalid rate limit: %w", err)
+	}
+	if _, err := middleware.NewClientIPResolver(cfg.TrustedProxies); err != nil {
+		return err
+	}
+	switch cfg.RateLimitBackend {
+	case "", ratelimit.BackendMemory:
+	case ratelimit.BackendPostgres:
+		if cfg.DatabaseDSN == "" {
+			return errors.New("rate limit backend postgres requires DATABASE_DSN")
+		}
+	default:
+		return fmt.Errorf("unknown rate limit backend %q", cfg.RateLimitBackend)
+	}
+	return nil
+}
+
+// Run настраивает маршруты, запускает фоновые задачи и HTTP сервер приложения.
+// Блокирующий вызов - выполняется до получения SIGINT/SIGTERM, после чего
+// приложение корректно останавливается (см. Serve).
 //
-// Возвращает ошибку, если сервер не может быть запущен или произошла ошибка во время работы.
+// Возвращает ошибку, если сервер не может быть запущен или остановка завершилась с ошибкой.
 func (a *App) Run() error {
-	a.setupRoutes()
+	if err := a.Configure(); err != nil {
+		return err
+	}
+	return a.Serve(context.Background())
+}
 
-	server := &http.Server{
-		Addr:         a.config.ServerAddress,
-		Handler:      a.router,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
+// Serve запускает HTTP сервер (и gRPC сервер, если задан GRPCAddress) и блокируется
+// до отмены ctx или получения SIGINT/SIGTERM. После сигнала серверы перестают
+// принимать соединения, дожидаются завершения обрабатываемых запросов и фоновых
+// задач удаления в пределах ShutdownTimeout и закрывают хранилище.
+//
+// Возвращает nil при корректной остановке.
+func (a *App) Serve(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	servers := []*http.Server{a.GetServer()}
+	if grpcServer := a.GetGRPCServer(); grpcServer != nil {
+		servers = append(servers, grpcServer)
+	}
+
+	serveErr := make(chan error, len(servers))
+	for i, server := range servers {
+		name := "HTTP"
+		if i > 0 {
+			name = "gRPC"
+		}
+		go func() {
+			a.logger.Info("Starting server", zap.String("protocol", name), zap.String("address", server.Addr))
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s server: %w", name, err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		// Один из серверов не запустился: останавливаем остальные и возвращаем исходную ошибку
+		shutdownCtx, cancel := a.shutdownContext()
+		defer cancel()
+		return errors.Join(err, a.shutdown(shutdownCtx, servers...))
+	case <-ctx.Done():
+	}
+
+	a.logger.Info("Shutting down server")
+	shutdownCtx, cancel := a.shutdownContext()
+	defer cancel()
+	return a.shutdown(shutdownCtx, servers...)
+}
+
+// shutdownContext возвращает контекст с ограничением времени остановки из конфигурации
+func (a *App) shutdownContext() (context.Context, context.CancelFunc) {
+	timeout := a.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// shutdown останавливает приложение в порядке зависимостей: сервер, фоновые задачи
+// приложения, затем сервис (очередь удаления, запись переходов) и хранилище.
+// Последними отправляются накопленные спаны трассировки.
+func (a *App) shutdown(ctx context.Context, servers ...*http.Server) error {
+	var errs []error
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error shutting down server %s: %w", server.Addr, err))
+		}
+	}
+
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
+	a.jobs.Wait()
+
+	if closer, ok := a.service.(closableService); ok {
+		if err := closer.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error closing service: %w", err))
+		}
+	}
+
+	if a.tracer != nil {
+		if err := a.tracer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error flushing traces: %w", err))
+		}
 	}
 
-	a.logger.Info("Starting server", zap.String("address", a.config.ServerAddress))
-	return server.ListenAndServe()
+	if err := errors.Join(errs...); err != nil {
+		a.logger.Error("Server stopped with errors", zap.Error(err))
+		return err
+	}
+	a.logger.Info("Server stopped")
+	return nil
 }
 
 // setupRoutes настраивает HTTP маршруты и middleware для приложения.
 // Регистрирует все эндпоинты API и применяет глобальные middleware
-// (логирование, сжатие, аутентификация).
+// (трассировка, логирование, метрики, сжатие, аутентификация).
 func (a *App) setupRoutes() {
 	// Middleware
+	a.router.Use(a.handler.WithTracing)
 	a.router.Use(a.handler.WithLogging)
+	a.router.Use(a.handler.WithMetrics)
 	a.router.Use(a.handler.WithGzip)
 	a.router.Use(a.handler.AuthMiddleware)
 
+	// Области действия, которые требуются от API ключа (запросы с кукой не ограничены)
+	read := a.handler.RequireScope(models.ScopeRead)
+	write := a.handler.RequireScope(models.ScopeWrite)
+	remove := a.handler.RequireScope(models.ScopeDelete)
+
+	// Ограничения частоты запросов по маршрутам
+	limitCreate := a.handler.RateLimit(ratelimit.RouteCreate)
+	limitBatch := a.handler.RateLimit(ratelimit.RouteBatch)
+	limitRedirect := a.handler.RateLimit(ratelimit.RouteRedirect)
+	limitDelete := a.handler.RateLimit(ratelimit.RouteDelete)
+
 	// Routes
-	a.router.Post("/", a.handler.HandleCreateURL)
-	a.router.Get("/{id}", a.handler.HandleRedirect)
-	a.router.Post("/api/shorten", a.handler.HandleShortenURL)
-	a.router.Post("/api/shorten/batch", a.handler.HandleShortenBatch)
+	a.router.With(write, limitCreate).Post("/", a.handler.HandleCreateURL)
+	redirect := a.router.With(limitRedirect)
+	redirect.Get("/{id}", a.handler.HandleRedirect)
+	redirect.Head("/{id}", a.handler.HandleRedirect)
+	redirect.Get("/api/qr/{id}", a.handler.HandleQRCode)
+	a.router.With(write, limitCreate).Post("/api/shorten", a.handler.HandleShortenURL)
+	a.router.With(write, limitBatch).Post("/api/shorten/batch", a.handler.HandleShortenBatch)
 	a.router.Get("/ping", a.handler.HandlePing)
-	a.router.Get("/api/user/urls", a.handler.HandleGetUserURLs)
-	a.router.Delete("/api/user/urls", a.handler.HandleDeleteUserURLs)
+	a.router.Get("/metrics", a.handler.HandleMetrics)
+	a.router.With(read).Get("/api/user/urls", a.handler.HandleGetUserURLs)
+	a.router.With(remove, limitDelete).Delete("/api/user/urls", a.handler.HandleDeleteUserURLs)
+	a.router.With(remove, limitDelete).Post("/api/user/urls/restore", a.handler.HandleRestoreUserURLs)
+	a.router.With(write, limitCreate).Patch("/api/user/urls/{id}", a.handler.HandleUpdateUserURL)
+	a.router.With(read).Get("/api/user/urls/{id}/revisions", a.handler.HandleGetURLRevisions)
+	a.router.With(read).Get("/api/user/urls/{id}/stats", a.handler.HandleGetURLStats)
+	a.router.With(read).Get("/api/user/urls/delete-jobs/{id}", a.handler.HandleGetDeleteJob)
+
+	// Управление API ключами доступно только из сессии с кукой
+	a.router.Post("/api/user/api-keys", a.handler.HandleCreateAPIKey)
+	a.router.Get("/api/user/api-keys", a.handler.HandleListAPIKeys)
+	a.router.Delete("/api/user/api-keys/{id}", a.handler.HandleRevokeAPIKey)
 }
 
-// Configure настраивает все слои приложения.
-// Альтернативный метод инициализации, который создает сервисы и регистрирует маршруты.
-// Похож на setupRoutes, но выполняет полную реинициализацию зависимостей.
+// Configure регистрирует маршруты приложения и запускает фоновые задачи.
+// Использует сервис и обработчики, созданные в NewApp, чтобы приложение
+// владело единственным экземпляром хранилища и могло корректно его закрыть.
 //
-// Возвращает ошибку при неудачной инициализации сервисного слоя.
+// Возвращает ошибку при неудачной инициализации.
 func (a *App) Configure() error {
-	// Инициализация сервисов и обработчиков
-	urlService, err := service.NewURLService(a.config, a.logger)
-	if err != nil {
-		return err
-	}
-	handler := handler.NewHandler(urlService, a.config, a.logger)
+	a.setupRoutes()
+	a.startBackgroundJobs(a.service)
+	return nil
+}
 
-	// Подключаем middleware
-	a.router.Use(handler.WithLogging)
-	a.router.Use(handler.WithGzip)
-	a.router.Use(handler.AuthMiddleware)
+// startBackgroundJobs запускает фоновые задачи сервиса,
+// в том числе периодическую очистку ссылок с истекшим сроком действия,
+// окончательное удаление ссылок, удаленных пользователями, и перезагрузку
+// файла правил политики URL назначения.
+// Задачи останавливаются при остановке приложения.
+func (a *App) startBackgroundJobs(urlService service.URLService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopJobs = cancel
 
-	// Регистрация маршрутов
-	a.router.Post("/", handler.HandleCreateURL)
-	a.router.Post("/api/shorten", handler.HandleShortenURL)
-	a.router.Post("/api/shorten/batch", handler.HandleShortenBatch)
-	a.router.Get("/{shortID}", handler.HandleRedirect)
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		service.RunExpiredURLReaper(ctx, urlService.GetStorage(), a.config.ExpiredURLsReapInterval, a.logger)
+	}()
 
-	// Добавляем хендлер для проверки доступности БД
-	a.router.Get("/ping", handler.HandlePing)
-	a.router.Get("/api/user/urls", handler.HandleGetUserURLs)
-	a.router.Delete("/api/user/urls", handler.HandleDeleteUserURLs)
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		service.RunDeletedURLPurger(ctx, urlService.GetStorage(),
+			a.config.DeletedURLsRetention, a.config.DeletedURLsPurgeInterval, a.logger)
+	}()
 
-	return nil
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		a.handler.Limiter().Run(ctx, rateLimitPurgeInterval, a.logger)
+	}()
+
+	if policies, ok := urlService.(policyService); ok {
+		a.jobs.Add(1)
+		go func() {
+			defer a.jobs.Done()
+			service.RunURLPolicyReloader(ctx, policies.URLPolicy(), a.config.URLPolicyReloadInterval, a.logger)
+		}()
+	}
 }
 
 // GetServer создает и возвращает настроенный HTTP сервер.
@@ -135,3 +366,14 @@ func (a *App) GetServer() *http.Server {
 		IdleTimeout:  120 * time.Second,
 	}
 }
+
+// GetGRPCServer создает gRPC сервер, работающий по HTTP/2 без TLS на GRPCAddress.
+// Сервер использует тот же сервис, что и HTTP обработчики.
+//
+// Возвращает nil, если адрес gRPC сервера не задан.
+func (a *App) GetGRPCServer() *http.Server {
+	if a.config.GRPCAddress == "" {
+		return nil
+	}
+	return grpcapi.NewServer(a.service, a.config, a.logger).HTTPServer(a.config.GRPCAddress)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -202,3 +203,34 @@ func TestApp_RunContext(t *testing.T) {
 	err = server.Shutdown(ctx)
 	assert.NoError(t, err)
 }
+
+func TestApp_ServeGracefulShutdown(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress:   "127.0.0.1:0",
+		BaseURL:         "http://localhost:8080",
+		FileStoragePath: filepath.Join(t.TempDir(), "urls.json"),
+		SecretKey:       "test-secret",
+		ShutdownTimeout: time.Second,
+	}
+
+	app, err := NewApp(cfg)
+	require.NoError(t, err)
+	require.NoError(t, app.Configure())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Serve(ctx)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after context cancellation")
+	}
+
+	// После остановки хранилище закрыто
+	assert.Error(t, app.service.CheckConnection(context.Background()))
+}
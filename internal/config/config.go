@@ -4,6 +4,7 @@ package config
 
 import (
 	"flag"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -17,11 +18,72 @@ type Config struct {
 	FileStoragePath string `env:"FILE_STORAGE_PATH"` // Путь к файлу для хранения URL (например, "urls.json")
 	DatabaseDSN     string `env:"DATABASE_DSN"`      // Строка подключения к базе данных PostgreSQL
 	SecretKey       string `env:"SECRET_KEY"`        // Секретный ключ для подписи аутентификационных кук
+	GRPCAddress     string `env:"GRPC_ADDRESS"`      // Адрес для запуска gRPC-сервера (пустая строка — gRPC отключен)
+
+	// Параметры сессий пользователей
+	SessionKeys         string        `env:"SESSION_KEYS"`          // Ключи подписи сессий "kid:secret,..." (первый — активный; пусто — SecretKey)
+	SessionTTL          time.Duration `env:"SESSION_TTL"`           // Время жизни сессии
+	SessionAcceptLegacy bool          `env:"SESSION_ACCEPT_LEGACY"` // Принимать куки старого формата "userID.hmac" и заменять их на JWT
+	CookieSecure        bool          `env:"COOKIE_SECURE"`         // Флаг Secure куки сессии
+	CookieHTTPOnly      bool          `env:"COOKIE_HTTP_ONLY"`      // Флаг HttpOnly куки сессии
+	CookieSameSite      string        `env:"COOKIE_SAME_SITE"`      // Атрибут SameSite куки сессии: lax, strict, none
+
+	// Параметры ограничения частоты запросов: "N/unit[:burst]", unit — s, m или h; пусто — без ограничения
+	RateLimitBackend  string `env:"RATE_LIMIT_BACKEND"`  // Хранилище корзин: memory или postgres
+	RateLimitCreate   string `env:"RATE_LIMIT_CREATE"`   // Создание ссылки (POST / и /api/shorten)
+	RateLimitBatch    string `env:"RATE_LIMIT_BATCH"`    // Пакетное создание ссылок
+	RateLimitRedirect string `env:"RATE_LIMIT_REDIRECT"` // Переход по короткой ссылке
+	RateLimitDelete   string `env:"RATE_LIMIT_DELETE"`   // Удаление ссылок пользователя
+	TrustedProxies    string `env:"TRUSTED_PROXIES"`     // Доверенные прокси (IP или CIDR через запятую) для X-Forwarded-For
 
 	// Параметры для batch deletion
 	BatchDeleteMaxWorkers          int `env:"BATCH_DELETE_MAX_WORKERS"`          // Максимальное количество воркеров для параллельного удаления
 	BatchDeleteBatchSize           int `env:"BATCH_DELETE_BATCH_SIZE"`           // Размер батча для обработки URL
 	BatchDeleteSequentialThreshold int `env:"BATCH_DELETE_SEQUENTIAL_THRESHOLD"` // Порог для переключения на последовательное удаление
+
+	// Параметры генерации коротких идентификаторов
+	ShortIDStrategy     string `env:"SHORT_ID_STRATEGY"`      // Стратегия генерации: hash, random, counter, hashids
+	ShortIDLength       int    `env:"SHORT_ID_LENGTH"`        // Длина (для hashids — минимальная длина) идентификатора
+	ShortIDSalt         string `env:"SHORT_ID_SALT"`          // Соль для стратегии hashids
+	ShortIDCounterStart uint64 `env:"SHORT_ID_COUNTER_START"` // Стартовое значение счетчика для стратегий counter и hashids
+
+	// Удалять параметры отслеживания (utm_*, fbclid, gclid и др.) из канонической формы URL,
+	// по которой ищутся дубликаты
+	DropTrackingParams bool `env:"DROP_TRACKING_PARAMS"`
+
+	// Параметры политики URL назначения (разрешенные схемы, списки хостов)
+	URLPolicyFile           string        `env:"URL_POLICY_FILE"`            // Файл правил в формате JSON (пусто — правила по умолчанию)
+	URLPolicyReloadInterval time.Duration `env:"URL_POLICY_RELOAD_INTERVAL"` // Интервал проверки изменений файла правил (0 — не перечитывать)
+
+	// Интервал фоновой очистки ссылок с истекшим сроком действия (0 — очистка отключена)
+	ExpiredURLsReapInterval time.Duration `env:"EXPIRED_URLS_REAP_INTERVAL"`
+
+	// Параметры перенаправления по короткой ссылке
+	RedirectStatus      int           `env:"REDIRECT_STATUS"`        // HTTP код перенаправления по умолчанию: 301, 302, 307 или 308
+	RedirectCacheMaxAge time.Duration `env:"REDIRECT_CACHE_MAX_AGE"` // Время кеширования постоянных перенаправлений (301, 308)
+
+	// Параметры окончательного удаления ссылок, удаленных пользователем
+	DeletedURLsRetention     time.Duration `env:"DELETED_URLS_RETENTION"`      // Срок, в течение которого удаленную ссылку можно восстановить (0 — бессрочно)
+	DeletedURLsPurgeInterval time.Duration `env:"DELETED_URLS_PURGE_INTERVAL"` // Интервал окончательного удаления ссылок после этого срока
+
+	// Параметры аналитики переходов
+	ClickBufferSize    int           `env:"CLICK_BUFFER_SIZE"`    // Размер буфера событий переходов
+	ClickFlushInterval time.Duration `env:"CLICK_FLUSH_INTERVAL"` // Интервал сохранения накопленных событий
+
+	// Параметры очереди асинхронного удаления
+	DeleteJobWorkers      int           `env:"DELETE_JOB_WORKERS"`       // Количество воркеров очереди удаления
+	DeleteJobMaxAttempts  int           `env:"DELETE_JOB_MAX_ATTEMPTS"`  // Максимальное количество попыток выполнения задачи
+	DeleteJobRetryBackoff time.Duration `env:"DELETE_JOB_RETRY_BACKOFF"` // Начальная задержка перед повторной попыткой
+
+	// Максимальное время ожидания завершения запросов и фоновых задач при остановке сервера
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT"`
+
+	// Параметры трассировки
+	TracingExporter     string  `env:"TRACING_EXPORTER"`            // Экспортер спанов: none, stdout, file, otlp
+	TracingFile         string  `env:"TRACING_FILE"`                // Файл для экспортера file
+	TracingOTLPEndpoint string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // Адрес OTLP/HTTP коллектора
+	TracingServiceName  string  `env:"OTEL_SERVICE_NAME"`           // Имя сервиса в трассах
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO"`        // Доля записываемых трасс (от 0 до 1)
 }
 
 // NewConfig создает и инициализирует новую конфигурацию приложения.
@@ -37,10 +99,50 @@ func NewConfig() (*Config, error) {
 		DatabaseDSN:     "",
 		SecretKey:       "your-secret-key", // Значение по умолчанию, лучше изменить
 
+		SessionTTL:          30 * 24 * time.Hour,
+		SessionAcceptLegacy: true,
+		CookieHTTPOnly:      true,
+		CookieSameSite:      "lax",
+
+		RateLimitBackend:  "memory",
+		RateLimitCreate:   "60/m",
+		RateLimitBatch:    "10/m",
+		RateLimitRedirect: "600/m",
+		RateLimitDelete:   "30/m",
+
 		// Значения по умолчанию для batch deletion
 		BatchDeleteMaxWorkers:          3,
 		BatchDeleteBatchSize:           5,
 		BatchDeleteSequentialThreshold: 5,
+
+		// Значения по умолчанию для генерации идентификаторов
+		ShortIDStrategy: "hash",
+		ShortIDLength:   8,
+
+		URLPolicyReloadInterval: 30 * time.Second,
+
+		ExpiredURLsReapInterval: time.Minute,
+
+		RedirectStatus:      307,
+		RedirectCacheMaxAge: 24 * time.Hour,
+
+		DeletedURLsRetention:     30 * 24 * time.Hour,
+		DeletedURLsPurgeInterval: time.Hour,
+
+		ClickBufferSize:    1024,
+		ClickFlushInterval: time.Second,
+
+		DeleteJobWorkers:      2,
+		DeleteJobMaxAttempts:  5,
+		DeleteJobRetryBackoff: time.Second,
+
+		ShutdownTimeout: 10 * time.Second,
+
+		TracingExporter:     "none",
+		TracingFile:         "traces.jsonl",
+		TracingOTLPEndpoint: "http://localhost:4318",
+		TracingServiceName:  "shortener",
+		TracingSampleRatio:  1,
 	}
 
 	// Определяем флаги
@@ -49,12 +151,59 @@ func NewConfig() (*Config, error) {
 	flag.StringVar(&cfg.FileStoragePath, "f", cfg.FileStoragePath, "путь к файлу для хранения URL")
 	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "строка подключения к базе данных PostgreSQL")
 	flag.StringVar(&cfg.SecretKey, "s", cfg.SecretKey, "секретный ключ для подписи кук")
+	flag.StringVar(&cfg.GRPCAddress, "grpc-address", cfg.GRPCAddress, "адрес запуска gRPC-сервера (пусто — отключен)")
+
+	// Флаги для настройки сессий
+	flag.StringVar(&cfg.SessionKeys, "session-keys", cfg.SessionKeys, "ключи подписи сессий в формате kid:secret,... (первый — активный)")
+	flag.DurationVar(&cfg.SessionTTL, "session-ttl", cfg.SessionTTL, "время жизни сессии")
+	flag.BoolVar(&cfg.SessionAcceptLegacy, "session-accept-legacy", cfg.SessionAcceptLegacy, "принимать куки старого формата userID.hmac")
+	flag.BoolVar(&cfg.CookieSecure, "cookie-secure", cfg.CookieSecure, "выставлять флаг Secure куки сессии")
+	flag.BoolVar(&cfg.CookieHTTPOnly, "cookie-http-only", cfg.CookieHTTPOnly, "выставлять флаг HttpOnly куки сессии")
+	flag.StringVar(&cfg.CookieSameSite, "cookie-same-site", cfg.CookieSameSite, "атрибут SameSite куки сессии (lax, strict, none)")
+
+	// Флаги для настройки ограничения частоты запросов
+	flag.StringVar(&cfg.RateLimitBackend, "rate-limit-backend", cfg.RateLimitBackend, "хранилище ограничителя частоты запросов (memory, postgres)")
+	flag.StringVar(&cfg.RateLimitCreate, "rate-limit-create", cfg.RateLimitCreate, "ограничение создания ссылок, N/unit[:burst]")
+	flag.StringVar(&cfg.RateLimitBatch, "rate-limit-batch", cfg.RateLimitBatch, "ограничение пакетного создания ссылок, N/unit[:burst]")
+	flag.StringVar(&cfg.RateLimitRedirect, "rate-limit-redirect", cfg.RateLimitRedirect, "ограничение переходов по ссылкам, N/unit[:burst]")
+	flag.StringVar(&cfg.RateLimitDelete, "rate-limit-delete", cfg.RateLimitDelete, "ограничение удаления ссылок, N/unit[:burst]")
+	flag.StringVar(&cfg.TrustedProxies, "trusted-proxies", cfg.TrustedProxies, "доверенные прокси (IP или CIDR через запятую)")
 
 	// Флаги для настройки batch deletion
 	flag.IntVar(&cfg.BatchDeleteMaxWorkers, "batch-max-workers", cfg.BatchDeleteMaxWorkers, "максимальное количество воркеров для параллельного удаления URL")
 	flag.IntVar(&cfg.BatchDeleteBatchSize, "batch-size", cfg.BatchDeleteBatchSize, "размер батча для обработки URL")
 	flag.IntVar(&cfg.BatchDeleteSequentialThreshold, "batch-sequential-threshold", cfg.BatchDeleteSequentialThreshold, "порог для переключения на последовательное удаление URL")
 
+	// Флаги для настройки генерации коротких идентификаторов
+	flag.StringVar(&cfg.ShortIDStrategy, "id-strategy", cfg.ShortIDStrategy, "стратегия генерации коротких идентификаторов (hash, random, counter, hashids)")
+	flag.IntVar(&cfg.ShortIDLength, "id-length", cfg.ShortIDLength, "длина короткого идентификатора")
+	flag.StringVar(&cfg.ShortIDSalt, "id-salt", cfg.ShortIDSalt, "соль для стратегии hashids")
+	flag.Uint64Var(&cfg.ShortIDCounterStart, "id-counter-start", cfg.ShortIDCounterStart, "стартовое значение счетчика для стратегий counter и hashids")
+	flag.BoolVar(&cfg.DropTrackingParams, "drop-tracking-params", cfg.DropTrackingParams, "не различать URL, отличающиеся только параметрами отслеживания (utm_* и др.)")
+	flag.StringVar(&cfg.URLPolicyFile, "url-policy-file", cfg.URLPolicyFile, "файл правил политики URL назначения (JSON)")
+	flag.DurationVar(&cfg.URLPolicyReloadInterval, "url-policy-reload-interval", cfg.URLPolicyReloadInterval, "интервал проверки изменений файла правил политики URL (0 — не перечитывать)")
+
+	flag.DurationVar(&cfg.ExpiredURLsReapInterval, "expired-reap-interval", cfg.ExpiredURLsReapInterval, "интервал очистки ссылок с истекшим сроком действия (0 — отключено)")
+	flag.IntVar(&cfg.RedirectStatus, "redirect-status", cfg.RedirectStatus, "HTTP код перенаправления по умолчанию: 301, 302, 307 или 308")
+	flag.DurationVar(&cfg.RedirectCacheMaxAge, "redirect-cache-max-age", cfg.RedirectCacheMaxAge, "время кеширования постоянных перенаправлений")
+	flag.DurationVar(&cfg.DeletedURLsRetention, "deleted-retention", cfg.DeletedURLsRetention, "срок, в течение которого удаленную ссылку можно восстановить (0 — бессрочно)")
+	flag.DurationVar(&cfg.DeletedURLsPurgeInterval, "deleted-purge-interval", cfg.DeletedURLsPurgeInterval, "интервал окончательного удаления ссылок после срока восстановления")
+
+	flag.IntVar(&cfg.ClickBufferSize, "click-buffer-size", cfg.ClickBufferSize, "размер буфера событий переходов")
+	flag.DurationVar(&cfg.ClickFlushInterval, "click-flush-interval", cfg.ClickFlushInterval, "интервал сохранения событий переходов")
+
+	flag.IntVar(&cfg.DeleteJobWorkers, "delete-workers", cfg.DeleteJobWorkers, "количество воркеров очереди удаления")
+	flag.IntVar(&cfg.DeleteJobMaxAttempts, "delete-max-attempts", cfg.DeleteJobMaxAttempts, "максимальное количество попыток задачи удаления")
+	flag.DurationVar(&cfg.DeleteJobRetryBackoff, "delete-retry-backoff", cfg.DeleteJobRetryBackoff, "начальная задержка повторной попытки удаления")
+
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "максимальное время корректной остановки сервера")
+
+	flag.StringVar(&cfg.TracingExporter, "tracing-exporter", cfg.TracingExporter, "экспортер трасс (none, stdout, file, otlp)")
+	flag.StringVar(&cfg.TracingFile, "tracing-file", cfg.TracingFile, "файл для экспортера трасс file")
+	flag.StringVar(&cfg.TracingOTLPEndpoint, "otlp-endpoint", cfg.TracingOTLPEndpoint, "адрес OTLP/HTTP коллектора трасс")
+	flag.StringVar(&cfg.TracingServiceName, "tracing-service-name", cfg.TracingServiceName, "имя сервиса в трассах")
+	flag.Float64Var(&cfg.TracingSampleRatio, "tracing-sample-ratio", cfg.TracingSampleRatio, "доля записываемых трасс (от 0 до 1)")
+
 	// Парсим флаги
 	flag.Parse()
 
@@ -0,0 +1,146 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Client вызывает методы сервиса shortener.v1.Shortener по HTTP/2 без TLS.
+// Токен пользователя, выданный сервером, сохраняется и передается в последующих вызовах.
+type Client struct {
+	target string
+	http   *http.Client
+
+	apiKey string // API ключ для метаданных authorization (пусто — аутентификация токеном)
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewClient создает клиента для сервера по адресу addr (host:port).
+// token — токен пользователя из метаданных user-token или кука user_id; может быть пустым.
+func NewClient(addr, token string) *Client {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &Client{
+		target: "http://" + addr,
+		http:   &http.Client{Transport: &http.Transport{Protocols: &protocols}},
+		token:  token,
+	}
+}
+
+// NewAPIKeyClient создает клиента, аутентифицирующегося API ключом.
+func NewAPIKeyClient(addr, apiKey string) *Client {
+	c := NewClient(addr, "")
+	c.apiKey = apiKey
+	return c
+}
+
+// Token возвращает текущий токен пользователя
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Shorten вызывает метод Shorten
+func (c *Client) Shorten(ctx context.Context, req *ShortenRequest) (*ShortenResponse, error) {
+	resp := &ShortenResponse{}
+	return resp, c.invoke(ctx, "Shorten", req, resp)
+}
+
+// ShortenBatch вызывает метод ShortenBatch
+func (c *Client) ShortenBatch(ctx context.Context, req *ShortenBatchRequest) (*ShortenBatchResponse, error) {
+	resp := &ShortenBatchResponse{}
+	return resp, c.invoke(ctx, "ShortenBatch", req, resp)
+}
+
+// Expand вызывает метод Expand
+func (c *Client) Expand(ctx context.Context, req *ExpandRequest) (*ExpandResponse, error) {
+	resp := &ExpandResponse{}
+	return resp, c.invoke(ctx, "Expand", req, resp)
+}
+
+// ListUserURLs вызывает метод ListUserURLs
+func (c *Client) ListUserURLs(ctx context.Context, req *ListUserURLsRequest) (*ListUserURLsResponse, error) {
+	resp := &ListUserURLsResponse{}
+	return resp, c.invoke(ctx, "ListUserURLs", req, resp)
+}
+
+// DeleteUserURLs вызывает метод DeleteUserURLs
+func (c *Client) DeleteUserURLs(ctx context.Context, req *DeleteUserURLsRequest) (*DeleteUserURLsResponse, error) {
+	resp := &DeleteUserURLsResponse{}
+	return resp, c.invoke(ctx, "DeleteUserURLs", req, resp)
+}
+
+// Ping вызывает метод Ping
+func (c *Client) Ping(ctx context.Context) error {
+	return c.invoke(ctx, "Ping", &PingRequest{}, &PingResponse{})
+}
+
+// invoke выполняет унарный вызов метода name.
+// Ошибки сервера возвращаются как *Status.
+func (c *Client) invoke(ctx context.Context, name string, req, resp message) error {
+	payload := req.marshal()
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.target+"/"+ServiceName+"/"+name, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", contentTypeGRPC)
+	httpReq.Header.Set("TE", "trailers")
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	} else if token := c.Token(); token != "" {
+		httpReq.Header.Set(UserTokenMetadata, token)
+	}
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if token := httpResp.Header.Get(UserTokenMetadata); token != "" {
+		c.mu.Lock()
+		c.token = token
+		c.mu.Unlock()
+	}
+	if httpResp.StatusCode != http.StatusOK || !strings.HasPrefix(httpResp.Header.Get("Content-Type"), contentTypeGRPC) {
+		return statusError(CodeUnknown, "unexpected HTTP status %d", httpResp.StatusCode)
+	}
+
+	// Trailers-only ответ: статус передан в заголовках
+	if code := httpResp.Header.Get(grpcStatusHeader); code != "" {
+		if st := parseStatus(code, httpResp.Header.Get(grpcMessageHeader)); st.Code != CodeOK {
+			return st
+		}
+	}
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if code := httpResp.Trailer.Get(grpcStatusHeader); code != "" {
+		if st := parseStatus(code, httpResp.Trailer.Get(grpcMessageHeader)); st.Code != CodeOK {
+			return st
+		}
+	}
+
+	if len(body) < frameHeaderSize {
+		return statusError(CodeInternal, "missing response message")
+	}
+	length := binary.BigEndian.Uint32(body[1:frameHeaderSize])
+	if uint32(len(body)-frameHeaderSize) < length {
+		return statusError(CodeInternal, "truncated response message")
+	}
+	return resp.unmarshal(body[frameHeaderSize : frameHeaderSize+int(length)])
+}
@@ -0,0 +1,345 @@
+package grpcapi
+
+// Сообщения сервиса shortener.v1.Shortener (см. api/proto/shortener/v1/shortener.proto).
+// Номера полей в marshal/unmarshal должны совпадать с proto файлом.
+
+// message — сообщение protobuf
+type message interface {
+	marshal() []byte
+	unmarshal(data []byte) error
+}
+
+// ShortenRequest — запрос Shorten
+type ShortenRequest struct {
+	URL        string
+	Alias      string
+	TTLSeconds int64
+}
+
+func (m *ShortenRequest) marshal() []byte {
+	var e encoder
+	e.string(1, m.URL)
+	e.string(2, m.Alias)
+	e.int64(3, m.TTLSeconds)
+	return e.buf
+}
+
+func (m *ShortenRequest) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.URL, err = f.asString()
+		case 2:
+			m.Alias, err = f.asString()
+		case 3:
+			m.TTLSeconds, err = f.asInt64()
+		}
+		return err
+	})
+}
+
+// ShortenResponse — ответ Shorten
+type ShortenResponse struct {
+	Result        string
+	AlreadyExists bool
+}
+
+func (m *ShortenResponse) marshal() []byte {
+	var e encoder
+	e.string(1, m.Result)
+	e.bool(2, m.AlreadyExists)
+	return e.buf
+}
+
+func (m *ShortenResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.Result, err = f.asString()
+		case 2:
+			m.AlreadyExists, err = f.asBool()
+		}
+		return err
+	})
+}
+
+// BatchItem — элемент запроса ShortenBatch
+type BatchItem struct {
+	CorrelationID string
+	OriginalURL   string
+	Alias         string
+	TTLSeconds    int64
+}
+
+func (m *BatchItem) marshal() []byte {
+	var e encoder
+	e.string(1, m.CorrelationID)
+	e.string(2, m.OriginalURL)
+	e.string(3, m.Alias)
+	e.int64(4, m.TTLSeconds)
+	return e.buf
+}
+
+func (m *BatchItem) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.CorrelationID, err = f.asString()
+		case 2:
+			m.OriginalURL, err = f.asString()
+		case 3:
+			m.Alias, err = f.asString()
+		case 4:
+			m.TTLSeconds, err = f.asInt64()
+		}
+		return err
+	})
+}
+
+// ShortenBatchRequest — запрос ShortenBatch
+type ShortenBatchRequest struct {
+	Items []*BatchItem
+}
+
+func (m *ShortenBatchRequest) marshal() []byte {
+	var e encoder
+	for _, item := range m.Items {
+		e.message(1, item)
+	}
+	return e.buf
+}
+
+func (m *ShortenBatchRequest) unmarshal(data []byte) error {
+	return decode(data, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		item := &BatchItem{}
+		if err := f.asMessage(item); err != nil {
+			return err
+		}
+		m.Items = append(m.Items, item)
+		return nil
+	})
+}
+
+// BatchResult — элемент ответа ShortenBatch
+type BatchResult struct {
+	CorrelationID string
+	ShortURL      string
+}
+
+func (m *BatchResult) marshal() []byte {
+	var e encoder
+	e.string(1, m.CorrelationID)
+	e.string(2, m.ShortURL)
+	return e.buf
+}
+
+func (m *BatchResult) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.CorrelationID, err = f.asString()
+		case 2:
+			m.ShortURL, err = f.asString()
+		}
+		return err
+	})
+}
+
+// ShortenBatchResponse — ответ ShortenBatch
+type ShortenBatchResponse struct {
+	Items []*BatchResult
+}
+
+func (m *ShortenBatchResponse) marshal() []byte {
+	var e encoder
+	for _, item := range m.Items {
+		e.message(1, item)
+	}
+	return e.buf
+}
+
+func (m *ShortenBatchResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		item := &BatchResult{}
+		if err := f.asMessage(item); err != nil {
+			return err
+		}
+		m.Items = append(m.Items, item)
+		return nil
+	})
+}
+
+// ExpandRequest — запрос Expand
+type ExpandRequest struct {
+	ShortID string
+}
+
+func (m *ExpandRequest) marshal() []byte {
+	var e encoder
+	e.string(1, m.ShortID)
+	return e.buf
+}
+
+func (m *ExpandRequest) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		if f.num == 1 {
+			m.ShortID, err = f.asString()
+		}
+		return err
+	})
+}
+
+// ExpandResponse — ответ Expand
+type ExpandResponse struct {
+	OriginalURL string
+}
+
+func (m *ExpandResponse) marshal() []byte {
+	var e encoder
+	e.string(1, m.OriginalURL)
+	return e.buf
+}
+
+func (m *ExpandResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		if f.num == 1 {
+			m.OriginalURL, err = f.asString()
+		}
+		return err
+	})
+}
+
+// ListUserURLsRequest — запрос ListUserURLs
+type ListUserURLsRequest struct{}
+
+func (m *ListUserURLsRequest) marshal() []byte { return nil }
+
+func (m *ListUserURLsRequest) unmarshal(data []byte) error {
+	return decode(data, func(field) error { return nil })
+}
+
+// UserURL — ссылка пользователя
+type UserURL struct {
+	ShortURL    string
+	OriginalURL string
+}
+
+func (m *UserURL) marshal() []byte {
+	var e encoder
+	e.string(1, m.ShortURL)
+	e.string(2, m.OriginalURL)
+	return e.buf
+}
+
+func (m *UserURL) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.ShortURL, err = f.asString()
+		case 2:
+			m.OriginalURL, err = f.asString()
+		}
+		return err
+	})
+}
+
+// ListUserURLsResponse — ответ ListUserURLs
+type ListUserURLsResponse struct {
+	URLs []*UserURL
+}
+
+func (m *ListUserURLsResponse) marshal() []byte {
+	var e encoder
+	for _, u := range m.URLs {
+		e.message(1, u)
+	}
+	return e.buf
+}
+
+func (m *ListUserURLsResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		u := &UserURL{}
+		if err := f.asMessage(u); err != nil {
+			return err
+		}
+		m.URLs = append(m.URLs, u)
+		return nil
+	})
+}
+
+// DeleteUserURLsRequest — запрос DeleteUserURLs
+type DeleteUserURLsRequest struct {
+	ShortIDs []string
+}
+
+func (m *DeleteUserURLsRequest) marshal() []byte {
+	var e encoder
+	e.repeatedString(1, m.ShortIDs)
+	return e.buf
+}
+
+func (m *DeleteUserURLsRequest) unmarshal(data []byte) error {
+	return decode(data, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		id, err := f.asString()
+		if err != nil {
+			return err
+		}
+		m.ShortIDs = append(m.ShortIDs, id)
+		return nil
+	})
+}
+
+// DeleteUserURLsResponse — ответ DeleteUserURLs
+type DeleteUserURLsResponse struct {
+	JobID  string
+	Status string
+}
+
+func (m *DeleteUserURLsResponse) marshal() []byte {
+	var e encoder
+	e.string(1, m.JobID)
+	e.string(2, m.Status)
+	return e.buf
+}
+
+func (m *DeleteUserURLsResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			m.JobID, err = f.asString()
+		case 2:
+			m.Status, err = f.asString()
+		}
+		return err
+	})
+}
+
+// PingRequest — запрос Ping
+type PingRequest struct{}
+
+func (m *PingRequest) marshal() []byte { return nil }
+
+func (m *PingRequest) unmarshal(data []byte) error {
+	return decode(data, func(field) error { return nil })
+}
+
+// PingResponse — ответ Ping
+type PingResponse struct{}
+
+func (m *PingResponse) marshal() []byte { return nil }
+
+func (m *PingResponse) unmarshal(data []byte) error {
+	return decode(data, func(field) error { return nil })
+}
@@ -0,0 +1,429 @@
+// Package grpcapi реализует gRPC API сервиса сокращения URL
+// (сервис shortener.v1.Shortener, см. api/proto/shortener/v1/shortener.proto).
+//
+// Сервер работает поверх net/http по HTTP/2 без TLS (h2c) и реализует
+// протокол gRPC для унарных вызовов: сообщения protobuf с 5-байтовым
+// префиксом длины, статус в трейлерах grpc-status/grpc-message и
+// ограничение времени выполнения из заголовка grpc-timeout.
+package grpcapi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/service"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// ServiceName — полное имя gRPC сервиса
+const ServiceName = "shortener.v1.Shortener"
+
+// UserTokenMetadata — ключ метаданных с подписанным идентификатором пользователя.
+// Значение совпадает с кукой user_id HTTP API.
+const UserTokenMetadata = "user-token"
+
+// Параметры протокола
+const (
+	contentTypeGRPC   = "application/grpc"
+	frameHeaderSize   = 5
+	maxMessageSize    = 4 << 20 // Как в grpc-go по умолчанию
+	grpcStatusHeader  = "Grpc-Status"
+	grpcMessageHeader = "Grpc-Message"
+)
+
+// method обрабатывает тело унарного запроса и возвращает ответное сообщение
+type method func(ctx context.Context, userID string, body []byte) (message, error)
+
+// Server реализует gRPC сервис shortener.v1.Shortener поверх service.URLService.
+type Server struct {
+	service  service.URLService
+	cfg      *config.Config
+	logger   *zap.Logger
+	sessions *middleware.Sessions
+	methods  map[string]method
+}
+
+// NewServer создает gRPC сервер с обработчиками всех методов сервиса.
+func NewServer(svc service.URLService, cfg *config.Config, logger *zap.Logger) *Server {
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	if err != nil {
+		logger.Error("Invalid session configuration, using ephemeral key", zap.Error(err))
+		keyring, _ := middleware.ParseKeyring("", "")
+		sessions = middleware.NewSessions(keyring, cfg.SessionTTL)
+	}
+
+	s := &Server{service: svc, cfg: cfg, logger: logger, sessions: sessions}
+	s.methods = map[string]method{
+		"Shorten":        unary(s.shorten),
+		"ShortenBatch":   unary(s.shortenBatch),
+		"Expand":         unary(s.expand),
+		"ListUserURLs":   unary(s.listUserURLs),
+		"DeleteUserURLs": unary(s.deleteUserURLs),
+		"Ping":           unary(s.ping),
+	}
+	return s
+}
+
+// unary разбирает запрос типа T и вызывает обработчик fn
+func unary[T any, P interface {
+	*T
+	message
+}](fn func(ctx context.Context, userID string, req P) (message, error)) method {
+	return func(ctx context.Context, userID string, body []byte) (message, error) {
+		req := P(new(T))
+		if err := req.unmarshal(body); err != nil {
+			return nil, statusError(CodeInvalidArgument, "invalid request message: %v", err)
+		}
+		return fn(ctx, userID, req)
+	}
+}
+
+// HTTPServer возвращает http.Server, обслуживающий gRPC по HTTP/2 без TLS на addr.
+// HTTP/1.1 принимается только для ответа о неподдерживаемой версии протокола.
+func (s *Server) HTTPServer(addr string) *http.Server {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
+
+// ServeHTTP обрабатывает унарный gRPC вызов
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != contentTypeGRPC && !strings.HasPrefix(ct, contentTypeGRPC+"+") && !strings.HasPrefix(ct, contentTypeGRPC+";") {
+		http.Error(w, "Invalid Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	fullMethod := strings.TrimPrefix(r.URL.Path, "/")
+	ctx := tracing.Extract(r.Context(), r.Header)
+	ctx, span := tracing.StartWithKind(ctx, fullMethod, tracing.SpanKindServer,
+		tracing.String("rpc.system", "grpc"),
+		tracing.String("rpc.method", fullMethod),
+	)
+	defer span.End()
+
+	resp, err := s.handle(ctx, w, r, fullMethod)
+
+	st := &Status{Code: CodeOK}
+	if err != nil {
+		st = toStatus(err)
+		if st.Code == CodeInternal || st.Code == CodeUnknown {
+			s.logger.Error("gRPC call failed", zap.String("method", fullMethod), zap.Error(err))
+		}
+		span.RecordError(err)
+	}
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(st.Code)))
+
+	s.writeResponse(w, resp, st)
+
+	s.logger.Info("gRPC call processed",
+		zap.String("method", fullMethod),
+		zap.Int("code", int(st.Code)),
+		zap.Duration("latency", time.Since(start)),
+	)
+}
+
+// handle аутентифицирует вызов, читает запрос и выполняет метод
+func (s *Server) handle(ctx context.Context, w http.ResponseWriter, r *http.Request, fullMethod string) (message, error) {
+	service, name, ok := strings.Cut(fullMethod, "/")
+	handler, exists := s.methods[name]
+	if !ok || service != ServiceName || !exists {
+		return nil, statusError(CodeUnimplemented, "unknown method %s", fullMethod)
+	}
+
+	if timeout := r.Header.Get("Grpc-Timeout"); timeout != "" {
+		d, err := parseTimeout(timeout)
+		if err != nil {
+			return nil, statusError(CodeInvalidArgument, "invalid grpc-timeout: %v", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	body, err := readMessage(r.Body, r.Header.Get("Grpc-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	var userID string
+	if header := r.Header.Get("Authorization"); header != "" {
+		key, err := s.authenticateAPIKey(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		if scope := methodScopes[name]; scope != "" && !key.HasScope(scope) {
+			return nil, statusError(CodePermissionDenied, "API key lacks the %s scope", scope)
+		}
+		userID = key.UserID
+		ctx = context.WithValue(ctx, middleware.ContextKeyAuthMethod, middleware.AuthMethodAPIKey)
+		ctx = context.WithValue(ctx, middleware.ContextKeyScopes, key.Scopes)
+	} else {
+		userID = s.authenticate(w, r)
+	}
+	ctx = context.WithValue(ctx, middleware.ContextKeyUserID, userID)
+
+	return handler(ctx, userID, body)
+}
+
+// methodScopes — области действия API ключа, необходимые для вызова методов
+var methodScopes = map[string]string{
+	"Shorten":        models.ScopeWrite,
+	"ShortenBatch":   models.ScopeWrite,
+	"ListUserURLs":   models.ScopeRead,
+	"DeleteUserURLs": models.ScopeDelete,
+}
+
+// authenticate проверяет токен пользователя из метаданных так же, как AuthMiddleware
+// проверяет куку user_id: при отсутствии, неверной подписи или истекшем сроке
+// создается новый пользователь. Новый или перевыпущенный токен возвращается
+// в заголовке ответа.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) string {
+	if token := r.Header.Get(UserTokenMetadata); token != "" {
+		if session, err := s.sessions.Parse(token); err == nil {
+			if s.sessions.NeedsRenewal(session) {
+				s.issueToken(w, session.UserID)
+			}
+			return session.UserID
+		}
+	}
+	userID := middleware.GenerateUserID()
+	s.issueToken(w, userID)
+	return userID
+}
+
+// issueToken выпускает токен сессии пользователя и передает его в заголовке ответа
+func (s *Server) issueToken(w http.ResponseWriter, userID string) {
+	token, err := s.sessions.Issue(userID)
+	if err != nil {
+		s.logger.Error("Error issuing session token", zap.Error(err))
+		return
+	}
+	w.Header().Set(UserTokenMetadata, token)
+}
+
+// authenticateAPIKey проверяет API ключ из метаданных authorization ("Bearer <key>")
+func (s *Server) authenticateAPIKey(ctx context.Context, header string) (models.APIKey, error) {
+	token, ok := middleware.BearerToken(header)
+	if !ok {
+		return models.APIKey{}, statusError(CodeUnauthenticated, "invalid authorization metadata")
+	}
+	key, err := s.service.AuthenticateAPIKey(ctx, token)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		return models.APIKey{}, statusError(CodeUnauthenticated, "invalid API key")
+	}
+	return key, err
+}
+
+// readMessage читает единственное сообщение унарного запроса
+func readMessage(body io.Reader, encoding string) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, statusError(CodeInvalidArgument, "missing request message")
+	}
+	switch {
+	case header[0] == 0:
+	case header[0] != 1:
+		return nil, statusError(CodeInternal, "invalid compressed flag %d", header[0])
+	case encoding == "" || encoding == "identity":
+		// Сжатое сообщение без алгоритма сжатия — ошибка протокола
+		return nil, statusError(CodeInternal, "compressed flag set without grpc-encoding")
+	default:
+		return nil, statusError(CodeUnimplemented, "compression %q is not supported", encoding)
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxMessageSize {
+		return nil, statusError(CodeResourceExhausted, "request message larger than %d bytes", maxMessageSize)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, statusError(CodeInvalidArgument, "truncated request message")
+	}
+
+	// Унарный вызов содержит ровно одно сообщение
+	var extra [1]byte
+	if n, _ := body.Read(extra[:]); n > 0 {
+		return nil, statusError(CodeUnimplemented, "streaming requests are not supported")
+	}
+	return data, nil
+}
+
+// writeResponse отправляет ответное сообщение и статус вызова.
+// При ошибке статус передается в заголовках ответа без тела (trailers-only).
+func (s *Server) writeResponse(w http.ResponseWriter, resp message, st *Status) {
+	w.Header().Set("Content-Type", contentTypeGRPC)
+	// Сервер принимает только несжатые сообщения
+	w.Header().Set("Grpc-Accept-Encoding", "identity")
+
+	if st.Code != CodeOK || resp == nil {
+		if st.Code == CodeOK {
+			st = statusError(CodeInternal, "empty response")
+		}
+		w.Header().Set(grpcStatusHeader, strconv.Itoa(int(st.Code)))
+		w.Header().Set(grpcMessageHeader, encodeGRPCMessage(st.Message))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	payload := resp.marshal()
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(frame); err != nil {
+		s.logger.Error("Error writing gRPC response", zap.Error(err))
+		return
+	}
+	w.Header().Set(http.TrailerPrefix+grpcStatusHeader, strconv.Itoa(int(CodeOK)))
+}
+
+// parseTimeout разбирает значение grpc-timeout (например, 100m или 5S)
+func parseTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, errors.New("bad length")
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("bad value")
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, errors.New("bad unit")
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// shorten соответствует POST /api/shorten. Если URL уже сокращен,
+// возвращается существующая ссылка с признаком AlreadyExists (в HTTP API — 409).
+func (s *Server) shorten(ctx context.Context, _ string, req *ShortenRequest) (message, error) {
+	if req.URL == "" {
+		return nil, statusError(CodeInvalidArgument, "empty URL")
+	}
+	expiresAt, err := service.ResolveExpiry(nil, req.TTLSeconds, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	shortID, err := s.service.CreateShortURLWithOptions(ctx, req.URL, models.ShortenOptions{
+		Alias:     req.Alias,
+		ExpiresAt: expiresAt,
+	})
+	resp := &ShortenResponse{Result: s.cfg.BaseURL + "/" + shortID}
+	if err != nil {
+		if errors.Is(err, storage.ErrOriginalURLConflict) {
+			resp.AlreadyExists = true
+			return resp, nil
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// shortenBatch соответствует POST /api/shorten/batch
+func (s *Server) shortenBatch(ctx context.Context, _ string, req *ShortenBatchRequest) (message, error) {
+	batch := make([]models.BatchRequestEntry, 0, len(req.Items))
+	for _, item := range req.Items {
+		batch = append(batch, models.BatchRequestEntry{
+			CorrelationID: item.CorrelationID,
+			OriginalURL:   item.OriginalURL,
+			Alias:         item.Alias,
+			TTL:           item.TTLSeconds,
+		})
+	}
+
+	results, err := s.service.CreateShortURLsBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ShortenBatchResponse{Items: make([]*BatchResult, 0, len(results))}
+	for _, result := range results {
+		resp.Items = append(resp.Items, &BatchResult{CorrelationID: result.CorrelationID, ShortURL: result.ShortURL})
+	}
+	return resp, nil
+}
+
+// expand соответствует GET /{id}, но не выполняет перенаправление
+// и не учитывается в статистике переходов
+func (s *Server) expand(ctx context.Context, _ string, req *ExpandRequest) (message, error) {
+	if req.ShortID == "" {
+		return nil, statusError(CodeInvalidArgument, "empty short ID")
+	}
+	originalURL, err := s.service.GetOriginalURL(ctx, req.ShortID)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpandResponse{OriginalURL: originalURL}, nil
+}
+
+// listUserURLs соответствует GET /api/user/urls
+func (s *Server) listUserURLs(ctx context.Context, userID string, _ *ListUserURLsRequest) (message, error) {
+	urls, err := s.service.GetUserURLs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ListUserURLsResponse{URLs: make([]*UserURL, 0, len(urls))}
+	for _, u := range urls {
+		resp.URLs = append(resp.URLs, &UserURL{ShortURL: u.ShortURL, OriginalURL: u.OriginalURL})
+	}
+	return resp, nil
+}
+
+// deleteUserURLs соответствует DELETE /api/user/urls: удаление ставится в очередь
+func (s *Server) deleteUserURLs(ctx context.Context, userID string, req *DeleteUserURLsRequest) (message, error) {
+	if len(req.ShortIDs) == 0 {
+		return nil, statusError(CodeInvalidArgument, "empty URL list")
+	}
+	job, err := s.service.EnqueueDeletion(ctx, req.ShortIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteUserURLsResponse{JobID: job.ID, Status: job.Status}, nil
+}
+
+// ping соответствует GET /ping
+func (s *Server) ping(ctx context.Context, _ string, _ *PingRequest) (message, error) {
+	if err := s.service.CheckConnection(ctx); err != nil {
+		s.logger.Error("Storage is unavailable", zap.Error(err))
+		return nil, statusError(CodeUnavailable, "storage is unavailable")
+	}
+	return &PingResponse{}, nil
+}
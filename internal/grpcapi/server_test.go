@@ -0,0 +1,314 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// startServer запускает gRPC сервер поверх in-memory хранилища и возвращает его адрес
+func startServer(t *testing.T) (string, *config.Config) {
+	t.Helper()
+
+	cfg := &config.Config{
+		BaseURL:                        "http://localhost:8080",
+		SecretKey:                      "test-secret",
+		BatchDeleteMaxWorkers:          3,
+		BatchDeleteBatchSize:           5,
+		BatchDeleteSequentialThreshold: 5,
+	}
+	svc, err := service.NewURLService(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewServer(svc, cfg, zap.NewNop()).HTTPServer(listener.Addr().String())
+	go server.Serve(listener)
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		if closer, ok := svc.(interface{ Close(context.Context) error }); ok {
+			closer.Close(ctx)
+		}
+	})
+	return listener.Addr().String(), cfg
+}
+
+func TestServer_ShortenAndExpand(t *testing.T) {
+	addr, cfg := startServer(t)
+	client := NewClient(addr, "")
+	ctx := context.Background()
+
+	resp, err := client.Shorten(ctx, &ShortenRequest{URL: "https://example.com/grpc", Alias: "grpc-link"})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.BaseURL+"/grpc-link", resp.Result)
+	assert.False(t, resp.AlreadyExists)
+
+	// Токен выдан сервером и проходит ту же проверку, что и кука user_id
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	session, err := sessions.Parse(client.Token())
+	require.NoError(t, err)
+	assert.NotEmpty(t, session.UserID)
+
+	again, err := client.Shorten(ctx, &ShortenRequest{URL: "https://example.com/grpc"})
+	require.NoError(t, err)
+	assert.True(t, again.AlreadyExists)
+	assert.Equal(t, resp.Result, again.Result)
+
+	expanded, err := client.Expand(ctx, &ExpandRequest{ShortID: "grpc-link"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/grpc", expanded.OriginalURL)
+
+	_, err = client.Expand(ctx, &ExpandRequest{ShortID: "missing"})
+	assert.Equal(t, CodeNotFound, StatusCode(err))
+
+	_, err = client.Shorten(ctx, &ShortenRequest{})
+	assert.Equal(t, CodeInvalidArgument, StatusCode(err))
+
+	_, err = client.Shorten(ctx, &ShortenRequest{URL: "https://example.com/other", Alias: "grpc-link"})
+	assert.Equal(t, CodeAlreadyExists, StatusCode(err))
+
+	require.NoError(t, client.Ping(ctx))
+}
+
+func TestServer_UserURLs(t *testing.T) {
+	addr, cfg := startServer(t)
+	ctx := context.Background()
+
+	// Токен эквивалентен куке сессии user_id
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	token, err := sessions.Issue("grpc-user")
+	require.NoError(t, err)
+	client := NewClient(addr, token)
+
+	batch, err := client.ShortenBatch(ctx, &ShortenBatchRequest{Items: []*BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/a"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/b"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Items, 2)
+	assert.Equal(t, "1", batch.Items[0].CorrelationID)
+	assert.Equal(t, token, client.Token(), "valid token must be kept")
+
+	list, err := client.ListUserURLs(ctx, &ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.URLs, 2)
+
+	// Другой пользователь не видит чужие ссылки
+	other, err := NewClient(addr, "").ListUserURLs(ctx, &ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, other.URLs)
+
+	_, err = client.DeleteUserURLs(ctx, &DeleteUserURLsRequest{})
+	assert.Equal(t, CodeInvalidArgument, StatusCode(err))
+
+	shortID := strings.TrimPrefix(batch.Items[0].ShortURL, cfg.BaseURL+"/")
+	deleted, err := client.DeleteUserURLs(ctx, &DeleteUserURLsRequest{ShortIDs: []string{shortID}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, deleted.JobID)
+	assert.NotEmpty(t, deleted.Status)
+}
+
+func TestServer_APIKey(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080", SecretKey: "test-secret"}
+	svc, err := service.NewURLService(cfg, zap.NewNop())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, token, err := svc.CreateAPIKey(ctx, "ci-bot", "ci", []string{models.ScopeWrite})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := NewServer(svc, cfg, zap.NewNop()).HTTPServer(listener.Addr().String())
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Shutdown(ctx)
+		svc.(interface{ Close(context.Context) error }).Close(ctx)
+	})
+
+	client := NewAPIKeyClient(listener.Addr().String(), token)
+	_, err = client.Shorten(ctx, &ShortenRequest{URL: "https://example.com/ci"})
+	require.NoError(t, err)
+	assert.Empty(t, client.Token(), "API key calls must not mint a user")
+
+	urls, err := svc.GetUserURLs(ctx, "ci-bot")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	_, err = client.ListUserURLs(ctx, &ListUserURLsRequest{})
+	assert.Equal(t, CodePermissionDenied, StatusCode(err))
+
+	_, err = NewAPIKeyClient(listener.Addr().String(), "tu_unknown").Expand(ctx, &ExpandRequest{ShortID: "x"})
+	assert.Equal(t, CodeUnauthenticated, StatusCode(err))
+}
+
+func TestServer_Protocol(t *testing.T) {
+	addr, _ := startServer(t)
+	ctx := context.Background()
+
+	client := NewClient(addr, "")
+	err := client.invoke(ctx, "Unknown", &PingRequest{}, &PingResponse{})
+	assert.Equal(t, CodeUnimplemented, StatusCode(err))
+
+	// Без HTTP/2 gRPC недоступен
+	resp, err := http.Post("http://"+addr+"/"+ServiceName+"/Ping", contentTypeGRPC, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusHTTPVersionNotSupported, resp.StatusCode)
+}
+
+// rawCall выполняет gRPC вызов поверх h2c без клиента пакета, чтобы проверять протокол по спецификации
+func rawCall(t *testing.T, addr, path string, header http.Header, body []byte) (*http.Response, []byte) {
+	t.Helper()
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+path, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentTypeGRPC)
+	req.Header.Set("TE", "trailers")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, data
+}
+
+func TestServer_WireConformance(t *testing.T) {
+	addr, _ := startServer(t)
+	pingPath := "/" + ServiceName + "/Ping"
+	emptyFrame := []byte{0, 0, 0, 0, 0}
+
+	t.Run("unary response", func(t *testing.T) {
+		resp, body := rawCall(t, addr, pingPath, nil, emptyFrame)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, contentTypeGRPC, resp.Header.Get("Content-Type"))
+		// Пустой PingResponse — кадр из флага сжатия и нулевой длины
+		assert.Equal(t, emptyFrame, body)
+		assert.Equal(t, "0", resp.Trailer.Get(grpcStatusHeader))
+		assert.Empty(t, resp.Header.Get(grpcStatusHeader))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		body       []byte
+		wantCode   Code
+		wantAccept bool
+	}{
+		{
+			name:     "compressed flag without encoding",
+			path:     pingPath,
+			body:     []byte{1, 0, 0, 0, 0},
+			wantCode: CodeInternal,
+		},
+		{
+			name:     "invalid compressed flag",
+			path:     pingPath,
+			body:     []byte{2, 0, 0, 0, 0},
+			wantCode: CodeInternal,
+		},
+		{
+			name:       "unsupported encoding",
+			path:       pingPath,
+			header:     http.Header{"Grpc-Encoding": {"gzip"}},
+			body:       []byte{1, 0, 0, 0, 0},
+			wantCode:   CodeUnimplemented,
+			wantAccept: true,
+		},
+		{
+			name:     "malformed message",
+			path:     "/" + ServiceName + "/Expand",
+			body:     []byte{0, 0, 0, 0, 3, 0x0a, 0x01, 0xff},
+			wantCode: CodeInvalidArgument,
+		},
+		{
+			name:     "unknown method",
+			path:     "/" + ServiceName + "/Удалить",
+			body:     emptyFrame,
+			wantCode: CodeUnimplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := rawCall(t, addr, tt.path, tt.header, tt.body)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			// Ошибка передается в ответе trailers-only: статус в заголовках, тела нет
+			assert.Equal(t, strconv.Itoa(int(tt.wantCode)), resp.Header.Get(grpcStatusHeader))
+			assert.Empty(t, body)
+			if tt.wantAccept {
+				assert.Equal(t, "identity", resp.Header.Get("Grpc-Accept-Encoding"))
+			}
+
+			// grpc-message содержит только печатные ASCII символы и декодируется как percent-encoding
+			msg := resp.Header.Get(grpcMessageHeader)
+			require.NotEmpty(t, msg)
+			for _, c := range []byte(msg) {
+				assert.True(t, c >= 0x20 && c <= 0x7e, "non-printable byte %#x in grpc-message", c)
+			}
+			_, err := url.PathUnescape(msg)
+			assert.NoError(t, err)
+		})
+	}
+
+	resp, _ := rawCall(t, addr, "/"+ServiceName+"/Удалить", nil, emptyFrame)
+	assert.Contains(t, decodeGRPCMessage(resp.Header.Get(grpcMessageHeader)), "Удалить")
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "100m", want: 100 * time.Millisecond},
+		{value: "5S", want: 5 * time.Second},
+		{value: "1H", want: time.Hour},
+		{value: "10x", wantErr: true},
+		{value: "m", wantErr: true},
+		{value: "1234567890S", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimeout(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/service"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+)
+
+// Code — код статуса gRPC
+type Code int
+
+// Коды статусов gRPC, используемые сервисом
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeDeadlineExceeded   Code = 4
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeUnauthenticated    Code = 16
+)
+
+// Status — ошибка RPC с кодом статуса gRPC
+type Status struct {
+	Code    Code
+	Message string
+}
+
+// Error реализует интерфейс error
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", s.Code, s.Message)
+}
+
+// statusError создает ошибку со статусом code
+func statusError(code Code, format string, args ...any) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// StatusCode возвращает код статуса ошибки RPC; для nil — CodeOK, для прочих ошибок — CodeUnknown
+func StatusCode(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	var st *Status
+	if errors.As(err, &st) {
+		return st.Code
+	}
+	return CodeUnknown
+}
+
+// toStatus преобразует ошибку сервиса в статус gRPC так же,
+// как HTTP обработчики преобразуют ее в код ответа
+func toStatus(err error) *Status {
+	var st *Status
+	var violation *service.PolicyViolation
+	switch {
+	case errors.As(err, &st):
+		return st
+	case errors.As(err, &violation):
+		return statusError(CodeInvalidArgument, "URL rejected by policy rule %s: %s", violation.Rule, violation.Message)
+	case errors.Is(err, context.DeadlineExceeded):
+		return statusError(CodeDeadlineExceeded, "deadline exceeded")
+	case errors.Is(err, context.Canceled):
+		return statusError(CodeCanceled, "request canceled")
+	case errors.Is(err, service.ErrInvalidExpiry):
+		return statusError(CodeInvalidArgument, "invalid expiry: set a positive ttl")
+	case errors.Is(err, service.ErrInvalidAlias):
+		return statusError(CodeInvalidArgument, "invalid alias: use 3-32 characters [A-Za-z0-9_-]")
+	case errors.Is(err, service.ErrReservedAlias):
+		return statusError(CodeInvalidArgument, "alias is reserved")
+	case errors.Is(err, service.ErrAliasTaken):
+		return statusError(CodeAlreadyExists, "alias already taken")
+	case errors.Is(err, storage.ErrURLNotFound):
+		return statusError(CodeNotFound, "URL not found")
+	case errors.Is(err, storage.ErrURLDeleted):
+		return statusError(CodeNotFound, "URL is deleted")
+	case errors.Is(err, storage.ErrURLExpired):
+		return statusError(CodeNotFound, "URL has expired")
+	case errors.Is(err, service.ErrDeleteJobsUnsupported):
+		return statusError(CodeUnimplemented, "deletion is not supported by storage")
+	default:
+		return statusError(CodeInternal, "internal server error")
+	}
+}
+
+// encodeGRPCMessage кодирует grpc-message: непечатные символы и '%' передаются в percent-encoding
+func encodeGRPCMessage(msg string) string {
+	var out []byte
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			out = append(out, c)
+			continue
+		}
+		out = append(out, '%', "0123456789ABCDEF"[c>>4], "0123456789ABCDEF"[c&15])
+	}
+	return string(out)
+}
+
+// decodeGRPCMessage декодирует значение grpc-message
+func decodeGRPCMessage(msg string) string {
+	decoded, err := url.PathUnescape(msg)
+	if err != nil {
+		return msg
+	}
+	return decoded
+}
+
+// parseStatus разбирает значения grpc-status и grpc-message
+func parseStatus(code, msg string) *Status {
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return statusError(CodeUnknown, "invalid grpc-status %q", code)
+	}
+	return &Status{Code: Code(n), Message: decodeGRPCMessage(msg)}
+}
@@ -0,0 +1,203 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Типы полей в бинарном формате protobuf
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireStart   = 3 // Начало группы (устаревший формат proto2)
+	wireEnd     = 4 // Конец группы
+	wireFixed32 = 5
+)
+
+// errTruncated возвращается при разборе обрезанного сообщения
+var errTruncated = errors.New("protobuf: truncated message")
+
+// encoder записывает поля сообщения protobuf.
+// Нулевые значения скалярных полей не записываются, как в proto3.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *encoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytes(field, []byte(v))
+}
+
+// repeatedString записывает каждый элемент, включая пустые строки
+func (e *encoder) repeatedString(field int, vs []string) {
+	for _, v := range vs {
+		e.bytes(field, []byte(v))
+	}
+}
+
+func (e *encoder) bytes(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if !v {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = append(e.buf, 1)
+}
+
+// message записывает вложенное сообщение
+func (e *encoder) message(field int, m message) {
+	e.bytes(field, m.marshal())
+}
+
+// field — разобранное поле сообщения
+type field struct {
+	num      int
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+// maxFieldNumber — наибольший допустимый номер поля protobuf
+const maxFieldNumber = 1<<29 - 1
+
+// decode вызывает fn для каждого поля сообщения data.
+// Поля fixed32/fixed64 и группы пропускаются: в API сервиса они не используются,
+// но могут встретиться как неизвестные поля сообщений более новой версии схемы.
+func decode(data []byte, fn func(f field) error) error {
+	for len(data) > 0 {
+		f, rest, err := readField(data)
+		if err != nil {
+			return err
+		}
+		data = rest
+		if f.wireType == wireEnd {
+			return fmt.Errorf("protobuf: unexpected end group %d", f.num)
+		}
+		if f.wireType != wireVarint && f.wireType != wireBytes {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readField читает одно поле из data и возвращает его вместе с остатком данных.
+// Значения полей fixed32, fixed64 и групп пропускаются.
+func readField(data []byte) (field, []byte, error) {
+	key, n := binary.Uvarint(data)
+	if n <= 0 {
+		return field{}, nil, errTruncated
+	}
+	data = data[n:]
+
+	f := field{num: int(key >> 3), wireType: int(key & 7)}
+	if key>>3 == 0 || key>>3 > maxFieldNumber {
+		return field{}, nil, fmt.Errorf("protobuf: invalid field number %d", key>>3)
+	}
+
+	switch f.wireType {
+	case wireVarint:
+		f.varint, n = binary.Uvarint(data)
+		if n <= 0 {
+			return field{}, nil, errTruncated
+		}
+		return f, data[n:], nil
+	case wireBytes:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return field{}, nil, errTruncated
+		}
+		f.bytes = data[n : n+int(length)]
+		return f, data[n+int(length):], nil
+	case wireFixed64:
+		if len(data) < 8 {
+			return field{}, nil, errTruncated
+		}
+		return f, data[8:], nil
+	case wireFixed32:
+		if len(data) < 4 {
+			return field{}, nil, errTruncated
+		}
+		return f, data[4:], nil
+	case wireStart:
+		// Пропускаем поля группы до парного конца группы с тем же номером
+		for {
+			inner, rest, err := readField(data)
+			if err != nil {
+				return field{}, nil, err
+			}
+			data = rest
+			if inner.wireType == wireEnd {
+				if inner.num != f.num {
+					return field{}, nil, fmt.Errorf("protobuf: mismatched end group %d", inner.num)
+				}
+				return f, data, nil
+			}
+		}
+	case wireEnd:
+		// Конец группы возвращается вызывающему разбор группы; вне группы это ошибка
+		return f, data, nil
+	default:
+		return field{}, nil, fmt.Errorf("protobuf: unsupported wire type %d", f.wireType)
+	}
+}
+
+// asString возвращает значение строкового поля.
+// Строки proto3 обязаны быть в UTF-8, поэтому другие байты отклоняются, как в protobuf-go.
+func (f field) asString() (string, error) {
+	if f.wireType != wireBytes {
+		return "", fmt.Errorf("protobuf: field %d: expected string", f.num)
+	}
+	if !utf8.Valid(f.bytes) {
+		return "", fmt.Errorf("protobuf: field %d: string contains invalid UTF-8", f.num)
+	}
+	return string(f.bytes), nil
+}
+
+// asInt64 возвращает значение поля int64
+func (f field) asInt64() (int64, error) {
+	if f.wireType != wireVarint {
+		return 0, fmt.Errorf("protobuf: field %d: expected varint", f.num)
+	}
+	return int64(f.varint), nil
+}
+
+// asBool возвращает значение логического поля
+func (f field) asBool() (bool, error) {
+	if f.wireType != wireVarint {
+		return false, fmt.Errorf("protobuf: field %d: expected varint", f.num)
+	}
+	return f.varint != 0, nil
+}
+
+// asMessage разбирает вложенное сообщение в m
+func (f field) asMessage(m message) error {
+	if f.wireType != wireBytes {
+		return fmt.Errorf("protobuf: field %d: expected message", f.num)
+	}
+	return m.unmarshal(f.bytes)
+}
@@ -0,0 +1,260 @@
+package grpcapi
+
+import (
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessagesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   message
+		out  message
+	}{
+		{
+			name: "shorten request",
+			in:   &ShortenRequest{URL: "https://example.com", Alias: "my-link", TTLSeconds: 3600},
+			out:  &ShortenRequest{},
+		},
+		{
+			name: "shorten response",
+			in:   &ShortenResponse{Result: "http://localhost:8080/abc", AlreadyExists: true},
+			out:  &ShortenResponse{},
+		},
+		{
+			name: "batch request",
+			in: &ShortenBatchRequest{Items: []*BatchItem{
+				{CorrelationID: "1", OriginalURL: "https://a.example"},
+				{CorrelationID: "2", OriginalURL: "https://b.example", Alias: "bbb", TTLSeconds: 60},
+			}},
+			out: &ShortenBatchRequest{},
+		},
+		{
+			name: "user urls",
+			in:   &ListUserURLsResponse{URLs: []*UserURL{{ShortURL: "s", OriginalURL: "o"}}},
+			out:  &ListUserURLsResponse{},
+		},
+		{
+			name: "delete request keeps empty ids",
+			in:   &DeleteUserURLsRequest{ShortIDs: []string{"a", "", "c"}},
+			out:  &DeleteUserURLsRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.out.unmarshal(tt.in.marshal()))
+			assert.Equal(t, tt.in, tt.out)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// Неизвестные поля (в том числе fixed32/fixed64) пропускаются
+	var e encoder
+	e.string(1, "https://example.com")
+	e.tag(7, wireFixed64)
+	e.buf = append(e.buf, make([]byte, 8)...)
+	e.tag(8, wireFixed32)
+	e.buf = append(e.buf, make([]byte, 4)...)
+	e.int64(9, 42)
+
+	var req ExpandRequest
+	require.NoError(t, req.unmarshal(e.buf))
+	assert.Equal(t, "https://example.com", req.ShortID)
+
+	// Обрезанное сообщение
+	assert.ErrorIs(t, req.unmarshal(e.buf[:5]), errTruncated)
+
+	// Неверный тип поля
+	var resp ShortenResponse
+	assert.Error(t, resp.unmarshal([]byte{0x08, 0x01}))
+}
+
+// hexBytes декодирует hex строку с пробелами между байтами
+func hexBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	require.NoError(t, err)
+	return b
+}
+
+func TestWireGoldenVectors(t *testing.T) {
+	// Байты посчитаны вручную по спецификации кодирования protobuf
+	// (https://protobuf.dev/programming-guides/encoding/), а не полученным кодеком
+	tests := []struct {
+		name string
+		msg  message
+		out  message
+		want string
+	}{
+		{
+			name: "varint 150",
+			msg:  &ShortenRequest{TTLSeconds: 150},
+			out:  &ShortenRequest{},
+			want: "18 96 01",
+		},
+		{
+			name: "string and varint",
+			msg:  &ShortenRequest{URL: "testing", TTLSeconds: 1},
+			out:  &ShortenRequest{},
+			want: "0a 07 74 65 73 74 69 6e 67 18 01",
+		},
+		{
+			// Отрицательный int64 кодируется десятью байтами дополнительного кода
+			name: "negative int64",
+			msg:  &ShortenRequest{TTLSeconds: -2},
+			out:  &ShortenRequest{},
+			want: "18 fe ff ff ff ff ff ff ff ff 01",
+		},
+		{
+			name: "bool",
+			msg:  &ShortenResponse{Result: "r", AlreadyExists: true},
+			out:  &ShortenResponse{},
+			want: "0a 01 72 10 01",
+		},
+		{
+			name: "UTF-8 string",
+			msg:  &ExpandResponse{OriginalURL: "я"},
+			out:  &ExpandResponse{},
+			want: "0a 02 d1 8f",
+		},
+		{
+			name: "repeated embedded messages",
+			msg: &ShortenBatchResponse{Items: []*BatchResult{
+				{CorrelationID: "1", ShortURL: "a"},
+				{ShortURL: "b"},
+			}},
+			out:  &ShortenBatchResponse{},
+			want: "0a 06 0a 01 31 12 01 61 0a 03 12 01 62",
+		},
+		{
+			name: "repeated string with empty element",
+			msg:  &DeleteUserURLsRequest{ShortIDs: []string{"x", ""}},
+			out:  &DeleteUserURLsRequest{},
+			want: "0a 01 78 0a 00",
+		},
+		{
+			name: "default values are not encoded",
+			msg:  &BatchItem{},
+			out:  &BatchItem{},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := hexBytes(t, tt.want)
+			got := tt.msg.marshal()
+			assert.Equal(t, hex.EncodeToString(want), hex.EncodeToString(got))
+			require.NoError(t, tt.out.unmarshal(want))
+			assert.Equal(t, tt.msg, tt.out)
+		})
+	}
+
+	// Длина больше 127 кодируется двумя байтами varint
+	long := strings.Repeat("a", 200)
+	encoded := (&ExpandRequest{ShortID: long}).marshal()
+	assert.Equal(t, hexBytes(t, "0a c8 01"), encoded[:3])
+}
+
+func TestWireDecodeConformance(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    ExpandRequest
+		wantErr bool
+	}{
+		// Неизвестная группа (wire type 3/4) пропускается целиком, вместе с вложенными полями
+		{name: "unknown group", data: "13 08 01 1a 01 78 14 0a 01 61", want: ExpandRequest{ShortID: "a"}},
+		{name: "nested groups", data: "13 1b 1c 14 0a 01 61", want: ExpandRequest{ShortID: "a"}},
+		{name: "mismatched end group", data: "13 1c", wantErr: true},
+		{name: "unterminated group", data: "13 08 01", wantErr: true},
+		{name: "stray end group", data: "14", wantErr: true},
+		// Последнее значение скалярного поля побеждает
+		{name: "repeated scalar", data: "0a 01 61 0a 01 62", want: ExpandRequest{ShortID: "b"}},
+		{name: "invalid UTF-8", data: "0a 01 ff", wantErr: true},
+		{name: "field number zero", data: "02 00", wantErr: true},
+		{name: "unsupported wire type", data: "0e", wantErr: true},
+		{name: "truncated varint", data: "08 96", wantErr: true},
+		{name: "length beyond message", data: "0a 05 61", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ExpandRequest
+			err := got.unmarshal(hexBytes(t, tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMessagesMatchProto(t *testing.T) {
+	// Каждое поле сообщения в Go задано отдельно: его тег должен совпадать
+	// с номером и типом поля в proto файле
+	fields := map[string]message{
+		"ShortenRequest.url":              &ShortenRequest{URL: "x"},
+		"ShortenRequest.alias":            &ShortenRequest{Alias: "x"},
+		"ShortenRequest.ttl_seconds":      &ShortenRequest{TTLSeconds: 1},
+		"ShortenResponse.result":          &ShortenResponse{Result: "x"},
+		"ShortenResponse.already_exists":  &ShortenResponse{AlreadyExists: true},
+		"BatchItem.correlation_id":        &BatchItem{CorrelationID: "x"},
+		"BatchItem.original_url":          &BatchItem{OriginalURL: "x"},
+		"BatchItem.alias":                 &BatchItem{Alias: "x"},
+		"BatchItem.ttl_seconds":           &BatchItem{TTLSeconds: 1},
+		"ShortenBatchRequest.items":       &ShortenBatchRequest{Items: []*BatchItem{{}}},
+		"BatchResult.correlation_id":      &BatchResult{CorrelationID: "x"},
+		"BatchResult.short_url":           &BatchResult{ShortURL: "x"},
+		"ShortenBatchResponse.items":      &ShortenBatchResponse{Items: []*BatchResult{{}}},
+		"ExpandRequest.short_id":          &ExpandRequest{ShortID: "x"},
+		"ExpandResponse.original_url":     &ExpandResponse{OriginalURL: "x"},
+		"UserURL.short_url":               &UserURL{ShortURL: "x"},
+		"UserURL.original_url":            &UserURL{OriginalURL: "x"},
+		"ListUserURLsResponse.urls":       &ListUserURLsResponse{URLs: []*UserURL{{}}},
+		"DeleteUserURLsRequest.short_ids": &DeleteUserURLsRequest{ShortIDs: []string{"x"}},
+		"DeleteUserURLsResponse.job_id":   &DeleteUserURLsResponse{JobID: "x"},
+		"DeleteUserURLsResponse.status":   &DeleteUserURLsResponse{Status: "x"},
+	}
+
+	proto, err := os.ReadFile("../../api/proto/shortener/v1/shortener.proto")
+	require.NoError(t, err)
+	messageRe := regexp.MustCompile(`(?s)message (\w+) \{(.*?)\}`)
+	fieldRe := regexp.MustCompile(`(?m)^\s*(repeated )?(\w+) (\w+) = (\d+);`)
+
+	seen := make(map[string]bool)
+	for _, m := range messageRe.FindAllStringSubmatch(string(proto), -1) {
+		for _, f := range fieldRe.FindAllStringSubmatch(m[2], -1) {
+			name := m[1] + "." + f[3]
+			seen[name] = true
+			msg, ok := fields[name]
+			if !assert.True(t, ok, "proto field %s is not covered", name) {
+				continue
+			}
+
+			num, err := strconv.Atoi(f[4])
+			require.NoError(t, err)
+			wireType := wireBytes
+			if f[2] == "int64" || f[2] == "bool" {
+				wireType = wireVarint
+			}
+			var e encoder
+			e.tag(num, wireType)
+			assert.Equal(t, e.buf, msg.marshal()[:len(e.buf)], name)
+		}
+	}
+	for name := range fields {
+		assert.True(t, seen[name], "%s is not declared in the proto file", name)
+	}
+}
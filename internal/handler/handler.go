@@ -6,16 +6,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
 	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
 	"github.com/InQaaaaGit/trunc_url.git/internal/service"
 	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 )
@@ -26,6 +33,16 @@ const (
 	emptyURLMessage    = "empty URL"
 	invalidURLMessage  = "Invalid URL"
 	urlNotFoundMessage = "URL not found"
+
+	invalidAliasMessage    = "Invalid alias: use 3-32 characters [A-Za-z0-9_-]"
+	reservedAliasMessage   = "Alias is reserved"
+	aliasTakenMessage      = "Alias already taken"
+	invalidExpiryMessage   = "Invalid expiry: set either a future expires_at or a positive ttl of at most 100 years"
+	invalidMetadataMessage = "Invalid metadata: title is limited to 200 and description to 2000 characters"
+
+	invalidRedirectStatusMessage = "Invalid redirect status: use 301, 302, 307 or 308"
+
+	sessionCookieName = "user_id"
 )
 
 // URLService определяет интерфейс для работы с URL сервисом.
@@ -48,21 +65,83 @@ type URLService interface {
 // Handler структура для обработки HTTP запросов.
 // Содержит зависимости: сервис URL, конфигурацию и логгер.
 type Handler struct {
-	service service.URLService
-	cfg     *config.Config
-	logger  *zap.Logger
+	service  service.URLService
+	cfg      *config.Config
+	logger   *zap.Logger
+	sessions *middleware.Sessions
+	sameSite http.SameSite
+
+	limiter   *ratelimit.Limiter
+	clientIPs *middleware.ClientIPResolver
 }
 
 // NewHandler создает новый экземпляр Handler с переданными зависимостями.
 // Принимает сервис URL, конфигурацию и логгер.
 func NewHandler(service service.URLService, cfg *config.Config, logger *zap.Logger) *Handler {
+	// NewApp проверяет параметры сессий заранее; ошибка здесь возможна только
+	// при создании Handler напрямую, и тогда сессии подписываются временным ключом
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	if err != nil {
+		logger.Error("Invalid session configuration, using ephemeral key", zap.Error(err))
+		keyring, _ := middleware.ParseKeyring("", "")
+		sessions = middleware.NewSessions(keyring, cfg.SessionTTL)
+	}
+	sameSite, err := middleware.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		logger.Error("Invalid cookie SameSite, using lax", zap.Error(err))
+		sameSite = http.SameSiteLaxMode
+	}
+
+	limiter, err := NewRateLimiter(service, cfg)
+	if err != nil {
+		logger.Error("Invalid rate limit configuration, rate limiting disabled", zap.Error(err))
+		limiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), nil)
+	}
+	clientIPs, err := middleware.NewClientIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		logger.Error("Invalid trusted proxies, ignoring X-Forwarded-For", zap.Error(err))
+		clientIPs, _ = middleware.NewClientIPResolver("")
+	}
+
 	return &Handler{
-		service: service,
-		cfg:     cfg,
-		logger:  logger,
+		service:   service,
+		cfg:       cfg,
+		logger:    logger,
+		sessions:  sessions,
+		sameSite:  sameSite,
+		limiter:   limiter,
+		clientIPs: clientIPs,
 	}
 }
 
+// NewRateLimiter создает ограничитель частоты запросов по конфигурации.
+// Хранилище postgres — это хранилище сервиса, которое должно реализовывать ratelimit.Store.
+// Если сервис перешел на другое хранилище (например, база данных недоступна), возвращает ошибку.
+func NewRateLimiter(svc service.URLService, cfg *config.Config) (*ratelimit.Limiter, error) {
+	limits, err := ratelimit.LimitsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.RateLimitBackend {
+	case "", ratelimit.BackendMemory:
+		return ratelimit.NewLimiter(ratelimit.NewMemoryStore(), limits), nil
+	case ratelimit.BackendPostgres:
+		store, ok := storage.Unwrap(svc.GetStorage()).(ratelimit.Store)
+		if !ok {
+			return nil, errors.New("storage does not support rate limits")
+		}
+		return ratelimit.NewLimiter(store, limits), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.RateLimitBackend)
+	}
+}
+
+// Limiter возвращает ограничитель частоты запросов обработчика
+func (h *Handler) Limiter() *ratelimit.Limiter {
+	return h.limiter
+}
+
 // HandleCreateURL обрабатывает POST запрос для создания короткого URL
 func (h *Handler) HandleCreateURL(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -109,6 +188,9 @@ func (h *Handler) HandleCreateURL(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		if h.writePolicyViolation(w, err) {
+			return
+		}
 		h.logger.Error("Error creating short URL", zap.Error(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -121,14 +203,21 @@ func (h *Handler) HandleCreateURL(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleRedirect обрабатывает GET запрос для перенаправления по короткому URL
+// HandleRedirect обрабатывает GET и HEAD запросы для перенаправления по короткому URL.
+// Код ответа задается ссылкой или конфигурацией (301, 302, 307, 308). Постоянные
+// перенаправления разрешено кешировать, временные — нет. HEAD запросы, которые
+// отправляют, например, программы проверки ссылок, не учитываются как переходы.
+//
+// Суффикс "+" (/{id}+) или параметр preview=1 открывают страницу предпросмотра
+// с адресом назначения вместо перехода. Ссылки с флагом interstitial всегда
+// открываются через эту страницу.
 func (h *Handler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	shortID := strings.Trim(r.URL.Path, "/")
+	shortID, preview := isPreviewRequest(r, strings.Trim(r.URL.Path, "/"))
 	if shortID == "" {
 		http.Error(w, "Empty shortID", http.StatusBadRequest)
 		return
@@ -137,36 +226,224 @@ func (h *Handler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Attempting to get original URL", zap.String("short_id", shortID))
 
 	ctx := r.Context()
-	originalURL, err := h.service.GetOriginalURL(ctx, shortID)
+	redirect, err := h.service.ResolveRedirect(ctx, shortID)
 	if err != nil {
 		if errors.Is(err, storage.ErrURLNotFound) {
+			metrics.RedirectsTotal.Inc(metrics.RedirectMiss)
 			http.Error(w, urlNotFoundMessage, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, storage.ErrURLDeleted) {
+			metrics.RedirectsTotal.Inc(metrics.RedirectGone)
 			http.Error(w, "URL is deleted", http.StatusGone)
 			return
 		}
+		if errors.Is(err, storage.ErrURLExpired) {
+			metrics.RedirectsTotal.Inc(metrics.RedirectExpired)
+			http.Error(w, "URL has expired", http.StatusGone)
+			return
+		}
 		h.logger.Error("Error getting original URL", zap.Error(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	h.logger.Info("Setting Location header", zap.String("location", originalURL))
-	w.Header().Set("Location", originalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	if preview {
+		// Явный предпросмотр не является переходом и не учитывается в статистике
+		h.writePreview(w, shortID, redirect)
+		return
+	}
+
+	metrics.RedirectsTotal.Inc(metrics.RedirectHit)
+	if r.Method == http.MethodGet {
+		h.service.RecordClick(shortID, models.ClickInfo{
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			ClientIP:  h.clientIPs.ClientIP(r),
+		})
+	}
+
+	if redirect.Interstitial {
+		// Показ обязательной страницы предпросмотра учитывается как переход
+		h.writePreview(w, shortID, redirect)
+		return
+	}
+
+	h.logger.Info("Setting Location header", zap.String("location", redirect.OriginalURL))
+	w.Header().Set("Location", redirect.OriginalURL)
+	w.Header().Set("Cache-Control", redirectCacheControl(redirect, h.cfg.RedirectCacheMaxAge, time.Now()))
+	w.WriteHeader(redirect.Status)
+}
+
+// redirectCacheControl возвращает значение заголовка Cache-Control для перенаправления в момент now.
+// Временные перенаправления не кешируются, чтобы переходы доходили до сервиса и учитывались
+// в статистике. Постоянное перенаправление ссылки, которую может изменить владелец,
+// кешируется только браузером с проверкой при каждом переходе, иначе изменение адреса
+// не дошло бы до клиентов. Остальные постоянные перенаправления кешируются на maxAge,
+// но не дольше, чем осталось до истечения срока действия ссылки.
+func redirectCacheControl(redirect models.Redirect, maxAge time.Duration, now time.Time) string {
+	if !service.IsPermanentRedirect(redirect.Status) {
+		return "private, no-store"
+	}
+	if redirect.Editable {
+		return "private, no-cache"
+	}
+	if !redirect.ExpiresAt.IsZero() {
+		maxAge = min(maxAge, redirect.ExpiresAt.Sub(now))
+	}
+	if maxAge < time.Second {
+		return "private, no-store"
+	}
+	return fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second))
+}
+
+// HandleGetURLStats обрабатывает GET запрос статистики переходов по ссылке пользователя
+func (h *Handler) HandleGetURLStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shortID := chi.URLParam(r, "id")
+	if shortID == "" {
+		http.Error(w, "Empty shortID", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetURLStats(r.Context(), shortID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, urlNotFoundMessage, http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Error getting URL stats", zap.String("short_id", shortID), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		h.logger.Error("Error writing JSON response for URL stats", zap.Error(err))
+	}
+}
+
+// HandleUpdateUserURL обрабатывает PATCH запрос на изменение оригинального URL,
+// заголовка или описания ссылки пользователя
+func (h *Handler) HandleUpdateUserURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shortID := chi.URLParam(r, "id")
+	if shortID == "" {
+		http.Error(w, "Empty shortID", http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeJSON) {
+		http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+
+	var update models.URLUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.logger.Error("Error closing request body", zap.Error(err))
+		}
+	}()
+
+	updated, err := h.service.UpdateUserURL(r.Context(), shortID, userID, update)
+	if err != nil {
+		if h.writePolicyViolation(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidURLUpdate):
+			http.Error(w, "Invalid update: set a valid original_url, title or description", http.StatusBadRequest)
+		case errors.Is(err, service.ErrInvalidMetadata):
+			http.Error(w, invalidMetadataMessage, http.StatusBadRequest)
+		case errors.Is(err, storage.ErrURLNotFound):
+			http.Error(w, urlNotFoundMessage, http.StatusNotFound)
+		case errors.Is(err, storage.ErrURLDeleted), errors.Is(err, storage.ErrURLExpired):
+			http.Error(w, "URL is no longer available", http.StatusGone)
+		case errors.Is(err, storage.ErrOriginalURLConflict):
+			http.Error(w, "Another short URL already points to this URL", http.StatusConflict)
+		default:
+			h.logger.Error("Error updating URL", zap.String("short_id", shortID), zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		h.logger.Error("Error writing JSON response for URL update", zap.Error(err))
+	}
+}
+
+// HandleGetURLRevisions обрабатывает запрос истории версий ссылки пользователя
+func (h *Handler) HandleGetURLRevisions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shortID := chi.URLParam(r, "id")
+	if shortID == "" {
+		http.Error(w, "Empty shortID", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.service.GetURLRevisions(r.Context(), shortID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, urlNotFoundMessage, http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Error getting URL revisions", zap.String("short_id", shortID), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		h.logger.Error("Error writing JSON response for URL revisions", zap.Error(err))
+	}
 }
 
 // ShortenRequest представляет запрос на создание короткого URL через API.
 // Используется в JSON API эндпоинте /api/shorten.
 type ShortenRequest struct {
-	URL string `json:"url"` // Оригинальный URL для сокращения
+	URL         string     `json:"url"`                   // Оригинальный URL для сокращения
+	Alias       string     `json:"alias,omitempty"`       // Пользовательский короткий идентификатор (необязательно)
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`  // Абсолютный момент истечения срока действия (необязательно)
+	TTL         int64      `json:"ttl,omitempty"`         // Время жизни ссылки в секундах (необязательно)
+	Title       string     `json:"title,omitempty"`       // Заголовок ссылки (необязательно)
+	Description string     `json:"description,omitempty"` // Описание ссылки (необязательно)
+	// HTTP код перенаправления: 301, 302, 307 или 308 (необязательно)
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// Всегда показывать страницу предпросмотра перед переходом (необязательно)
+	Interstitial bool `json:"interstitial,omitempty"`
+	// Вернуть QR код короткого URL в ответе (необязательно)
+	QR bool `json:"qr,omitempty"`
 }
 
 // ShortenResponse представляет ответ с сокращенным URL.
 // Возвращается в JSON API эндпоинте /api/shorten.
 type ShortenResponse struct {
-	Result string `json:"result"` // Сокращенный URL
+	Result string `json:"result"`       // Сокращенный URL
+	QR     string `json:"qr,omitempty"` // QR код сокращенного URL в виде data URI PNG, если запрошен
 }
 
 // HandleShortenURL обрабатывает POST запрос для создания короткого URL в формате JSON
@@ -198,14 +475,41 @@ func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expiresAt, err := service.ResolveExpiry(req.ExpiresAt, req.TTL, time.Now())
+	if err != nil {
+		http.Error(w, invalidExpiryMessage, http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
-	shortID, err := h.service.CreateShortURL(ctx, req.URL)
+	shortID, err := h.service.CreateShortURLWithOptions(ctx, req.URL, models.ShortenOptions{
+		Alias:       req.Alias,
+		ExpiresAt:   expiresAt,
+		Title:       req.Title,
+		Description: req.Description,
+
+		RedirectStatus: req.RedirectStatus,
+		Interstitial:   req.Interstitial,
+	})
 	shortURL := h.cfg.BaseURL + "/" + shortID
 	response := ShortenResponse{
 		Result: shortURL,
 	}
+	// QR код нужен и для новой ссылки, и для уже существующей (ответ 409)
+	if req.QR && (err == nil || errors.Is(err, storage.ErrOriginalURLConflict)) {
+		qr, qrErr := qrDataURI(shortURL)
+		if qrErr != nil {
+			h.logger.Error("Error rendering QR code in /api/shorten", zap.Error(qrErr))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response.QR = qr
+	}
 
 	if err != nil {
+		if h.writeCreateError(w, err) {
+			return
+		}
 		if errors.Is(err, storage.ErrOriginalURLConflict) {
 			h.logger.Info("URL already exists (conflict) in /api/shorten", zap.String("original_url", req.URL), zap.String("short_url", shortURL))
 			w.Header().Set("Content-Type", contentTypeJSON)
@@ -261,6 +565,9 @@ func (h *Handler) HandleShortenBatch(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	respBatch, err := h.service.CreateShortURLsBatch(ctx, reqBatch)
 	if err != nil {
+		if h.writeCreateError(w, err) {
+			return
+		}
 		h.logger.Error("Error processing batch", zap.Error(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -274,6 +581,68 @@ func (h *Handler) HandleShortenBatch(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// PolicyViolationResponse — тело ответа 422 для URL, запрещенного политикой URL назначения
+type PolicyViolationResponse struct {
+	Error         string `json:"error"`                    // Всегда "url_policy_violation"
+	Rule          string `json:"rule"`                     // Сработавшее правило: scheme, deny_host, private_ip и др.
+	Pattern       string `json:"pattern,omitempty"`        // Шаблон списка хостов, с которым совпал хост
+	Message       string `json:"message"`                  // Пояснение
+	URL           string `json:"url"`                      // Отклоненный URL
+	CorrelationID string `json:"correlation_id,omitempty"` // Запись пакетного запроса с отклоненным URL
+}
+
+// writePolicyViolation отвечает 422 с описанием сработавшего правила, если err — нарушение
+// политики URL назначения. Возвращает true, если ответ был записан.
+func (h *Handler) writePolicyViolation(w http.ResponseWriter, err error) bool {
+	var violation *service.PolicyViolation
+	if !errors.As(err, &violation) {
+		return false
+	}
+	h.logger.Info("URL rejected by policy", zap.String("url", violation.URL), zap.String("rule", violation.Rule))
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(PolicyViolationResponse{
+		Error:         "url_policy_violation",
+		Rule:          violation.Rule,
+		Pattern:       violation.Pattern,
+		Message:       violation.Message,
+		URL:           violation.URL,
+		CorrelationID: violation.CorrelationID,
+	}); err != nil {
+		h.logger.Error("Error writing policy violation response", zap.Error(err))
+	}
+	return true
+}
+
+// writeCreateError отвечает клиенту, если err связана с параметрами создания ссылки.
+// Некорректный или зарезервированный алиас и некорректный срок действия — 400, занятый алиас — 409,
+// URL, запрещенный политикой, — 422.
+// Возвращает true, если ответ был записан.
+func (h *Handler) writeCreateError(w http.ResponseWriter, err error) bool {
+	if h.writePolicyViolation(w, err) {
+		return true
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidExpiry):
+		http.Error(w, invalidExpiryMessage, http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidAlias):
+		http.Error(w, invalidAliasMessage, http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidMetadata):
+		http.Error(w, invalidMetadataMessage, http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidRedirectStatus):
+		http.Error(w, invalidRedirectStatusMessage, http.StatusBadRequest)
+	case errors.Is(err, service.ErrReservedAlias):
+		http.Error(w, reservedAliasMessage, http.StatusBadRequest)
+	case errors.Is(err, service.ErrAliasTaken):
+		h.logger.Info("Alias already taken", zap.Error(err))
+		http.Error(w, aliasTakenMessage, http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
 // HandlePing обрабатывает запрос на проверку соединения с базой данных
 func (h *Handler) HandlePing(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -310,12 +679,39 @@ func (h *Handler) WithLogging(next http.Handler) http.Handler {
 	})
 }
 
+// WithTracing создает серверный спан трассировки для каждого запроса
+func (h *Handler) WithTracing(next http.Handler) http.Handler {
+	return middleware.TracingMiddleware(next)
+}
+
+// WithMetrics учитывает количество и длительность запросов в метриках Prometheus
+func (h *Handler) WithMetrics(next http.Handler) http.Handler {
+	return middleware.MetricsMiddleware(next)
+}
+
+// HandleMetrics отдает метрики приложения в текстовом формате Prometheus
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics.Default.Handler().ServeHTTP(w, r)
+}
+
 // WithGzip добавляет поддержку gzip сжатия
 func (h *Handler) WithGzip(next http.Handler) http.Handler {
 	return middleware.GzipMiddleware(next)
 }
 
-// HandleGetUserURLs обрабатывает GET запрос для получения всех URL пользователя
+// HandleGetUserURLs обрабатывает GET запрос списка URL пользователя.
+// Параметры запроса:
+//   - limit: размер страницы (по умолчанию service.DefaultUserURLsPageSize);
+//   - cursor: курсор следующей страницы из заголовка Link предыдущего ответа;
+//   - q: подстрока короткого или оригинального URL;
+//   - domain: домен оригинального URL, включая поддомены;
+//   - created_after, created_before: границы времени создания (RFC 3339 или YYYY-MM-DD);
+//   - sort: created_at, short_url или original_url; префикс "-" задает обратный порядок;
+//   - deleted: true — выбрать удаленные, но еще не удаленные окончательно ссылки.
+//
+// Тело ответа — массив URL страницы. Общее количество URL, удовлетворяющих фильтрам,
+// возвращается в заголовке X-Total-Count, а ссылка на следующую страницу — в заголовке
+// Link с rel="next".
 func (h *Handler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
 	if !ok || userID == "" {
@@ -323,25 +719,96 @@ func (h *Handler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urls, err := h.service.GetUserURLs(r.Context(), userID)
+	query, err := parseUserURLQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.ListUserURLs(r.Context(), userID, query)
+	if errors.Is(err, service.ErrInvalidUserURLQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Error getting user URLs", zap.Error(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if len(urls) == 0 {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		next := *r.URL
+		params := next.Query()
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		w.Header().Set("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+
+	if len(page.URLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	w.Header().Set("Content-Type", contentTypeJSON)
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(urls); err != nil {
+	if err := json.NewEncoder(w).Encode(page.URLs); err != nil {
 		h.logger.Error("Error writing JSON response for user URLs", zap.Error(err))
 	}
 }
 
+// parseUserURLQuery разбирает параметры выборки списка URL пользователя
+func parseUserURLQuery(params url.Values) (models.UserURLQuery, error) {
+	query := models.UserURLQuery{
+		Search: params.Get("q"),
+		Domain: strings.TrimSpace(params.Get("domain")),
+		Cursor: params.Get("cursor"),
+		Sort:   strings.TrimPrefix(params.Get("sort"), "-"),
+		Desc:   strings.HasPrefix(params.Get("sort"), "-"),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return models.UserURLQuery{}, errors.New("invalid limit: expected a positive integer")
+		}
+		query.Limit = n
+	}
+
+	if deleted := params.Get("deleted"); deleted != "" {
+		d, err := strconv.ParseBool(deleted)
+		if err != nil {
+			return models.UserURLQuery{}, errors.New("invalid deleted: expected a boolean")
+		}
+		query.Deleted = d
+	}
+
+	var err error
+	if query.CreatedAfter, err = parseQueryTime(params.Get("created_after")); err != nil {
+		return models.UserURLQuery{}, fmt.Errorf("invalid created_after: %w", err)
+	}
+	if query.CreatedBefore, err = parseQueryTime(params.Get("created_before")); err != nil {
+		return models.UserURLQuery{}, fmt.Errorf("invalid created_before: %w", err)
+	}
+	return query, nil
+}
+
+// parseQueryTime разбирает время в формате RFC 3339 или дату YYYY-MM-DD (полночь UTC).
+// Пустая строка соответствует нулевому времени.
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC 3339 time or YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
 // HandleDeleteUserURLs обрабатывает DELETE запрос для удаления URL пользователя
 func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -377,57 +844,352 @@ func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Асинхронное удаление URL
-	go func() {
-		ctx := context.Background() // Используем новый контекст для фоновой операции
-		if err := h.service.BatchDeleteURLs(ctx, shortURLs, userID); err != nil {
-			h.logger.Error("Error deleting URLs",
-				zap.String("userID", userID),
-				zap.Strings("shortURLs", shortURLs),
-				zap.Error(err))
-		} else {
-			h.logger.Info("URLs deleted successfully",
-				zap.String("userID", userID),
-				zap.Int("count", len(shortURLs)))
+	// Удаление выполняется асинхронно очередью задач; клиент может отслеживать
+	// статус задачи по возвращенному идентификатору
+	job, err := h.service.EnqueueDeletion(r.Context(), shortURLs, userID)
+	if err != nil {
+		h.logger.Error("Error enqueuing URL deletion",
+			zap.String("userID", userID),
+			zap.Int("count", len(shortURLs)),
+			zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("Location", "/api/user/urls/delete-jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		h.logger.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+// HandleRestoreUserURLs обрабатывает POST запрос восстановления удаленных URL пользователя.
+// Тело запроса — массив коротких URL, ответ содержит те из них, что были восстановлены.
+func (h *Handler) HandleRestoreUserURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, contentTypeJSON) {
+		http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+
+	var shortURLs []string
+	if err := json.NewDecoder(r.Body).Decode(&shortURLs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.logger.Error("Error closing request body", zap.Error(err))
 		}
 	}()
 
-	// Возвращаем 202 Accepted немедленно
-	w.WriteHeader(http.StatusAccepted)
+	if len(shortURLs) == 0 {
+		http.Error(w, "Empty URL list", http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.service.RestoreURLs(r.Context(), shortURLs, userID)
+	if err != nil {
+		h.logger.Error("Error restoring URLs",
+			zap.String("userID", userID),
+			zap.Int("count", len(shortURLs)),
+			zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(models.RestoreResponse{Restored: restored}); err != nil {
+		h.logger.Error("Error encoding response", zap.Error(err))
+	}
 }
 
-// AuthMiddleware проверяет аутентификационную куку
+// HandleGetDeleteJob обрабатывает GET запрос статуса задачи удаления URL
+func (h *Handler) HandleGetDeleteJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextKeyUserID).(string)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.service.GetDeletionJob(r.Context(), chi.URLParam(r, "id"), userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeleteJobNotFound) {
+			http.Error(w, "Delete job not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Error getting delete job", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		h.logger.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+// AuthMiddleware аутентифицирует запрос.
+// Запрос с заголовком Authorization: Bearer аутентифицируется API ключом; неверный
+// или отозванный ключ отклоняется с кодом 401. Без заголовка проверяется кука сессии
+// user_id (JWT), а при ее отсутствии, неверной подписи или истекшем сроке создается
+// новый пользователь.
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("user_id")
-		var userID string
-
-		switch {
-		case err == http.ErrNoCookie:
-			userID = middleware.GenerateUserID()
-			newCookie := http.Cookie{
-				Name:  "user_id",
-				Value: middleware.SignUserID(userID, h.cfg.SecretKey),
-				Path:  "/",
-			}
-			http.SetCookie(w, &newCookie)
-		case err != nil:
-			http.Error(w, "Internal server error reading cookie", http.StatusInternalServerError)
+		if header := r.Header.Get("Authorization"); header != "" {
+			h.authenticateAPIKey(w, r, header, next)
 			return
-		default: // err == nil, cookie exists
-			var valid bool
-			userID, valid = middleware.ValidateUserID(cookie.Value, h.cfg.SecretKey)
-			if !valid || userID == "" {
-				userID = middleware.GenerateUserID()
-				newCookie := http.Cookie{
-					Name:  "user_id",
-					Value: middleware.SignUserID(userID, h.cfg.SecretKey),
-					Path:  "/",
-				}
-				http.SetCookie(w, &newCookie)
-			}
 		}
+
+		userID, method := h.authenticateSession(w, r)
 		ctx := context.WithValue(r.Context(), middleware.ContextKeyUserID, userID)
+		ctx = context.WithValue(ctx, middleware.ContextKeyAuthMethod, method)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticateSession возвращает пользователя из куки сессии и способ аутентификации.
+// Куку, подписанную неактивным ключом, старого формата или прожившую половину срока,
+// перевыпускает.
+func (h *Handler) authenticateSession(w http.ResponseWriter, r *http.Request) (string, string) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		session, err := h.sessions.Parse(cookie.Value)
+		if err == nil {
+			if h.sessions.NeedsRenewal(session) {
+				h.setSessionCookie(w, session.UserID)
+			}
+			return session.UserID, middleware.AuthMethodCookie
+		}
+		h.logger.Debug("Rejected session cookie", zap.Error(err))
+	}
+
+	userID := middleware.GenerateUserID()
+	h.setSessionCookie(w, userID)
+	return userID, middleware.AuthMethodNewUser
+}
+
+// setSessionCookie выпускает токен сессии пользователя и устанавливает куку
+func (h *Handler) setSessionCookie(w http.ResponseWriter, userID string) {
+	token, err := h.sessions.Issue(userID)
+	if err != nil {
+		h.logger.Error("Error issuing session token", zap.Error(err))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(h.sessions.TTL().Seconds()),
+		HttpOnly: h.cfg.CookieHTTPOnly,
+		Secure:   h.cfg.CookieSecure,
+		SameSite: h.sameSite,
+	})
+}
+
+// authenticateAPIKey аутентифицирует запрос API ключом из заголовка Authorization
+func (h *Handler) authenticateAPIKey(w http.ResponseWriter, r *http.Request, header string, next http.Handler) {
+	token, ok := middleware.BearerToken(header)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		http.Error(w, "Invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	key, err := h.service.AuthenticateAPIKey(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		h.logger.Error("Error authenticating API key", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), middleware.ContextKeyUserID, key.UserID)
+	ctx = context.WithValue(ctx, middleware.ContextKeyAuthMethod, middleware.AuthMethodAPIKey)
+	ctx = context.WithValue(ctx, middleware.ContextKeyScopes, key.Scopes)
+	ctx = context.WithValue(ctx, middleware.ContextKeyAPIKeyID, key.ID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireScope возвращает middleware, отклоняющий с кодом 403 запросы с API ключом,
+// которому не разрешена область действия scope. Запросы с кукой пропускаются.
+func (h *Handler) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !middleware.HasScope(r.Context(), scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="insufficient_scope", scope="`+scope+`"`)
+				http.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimit возвращает middleware, ограничивающий частоту запросов к маршруту route
+// (ratelimit.Route*). Корзина выбирается по API ключу, а для остальных запросов —
+// по IP адресу клиента. Превышение ограничения отклоняется с кодом 429 и заголовком
+// Retry-After. Если хранилище корзин недоступно, запрос пропускается.
+// Должен подключаться после AuthMiddleware.
+func (h *Handler) RateLimit(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := h.limiter.Limit(route)
+			if !limit.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decision, err := h.limiter.Allow(r.Context(), route, h.rateLimitKey(r))
+			if err != nil {
+				h.logger.Error("Error checking rate limit", zap.String("route", route), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			if !decision.Allowed {
+				metrics.RateLimitedTotal.Inc(route)
+				retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey возвращает ключ корзины клиента запроса
+func (h *Handler) rateLimitKey(r *http.Request) string {
+	switch middleware.AuthMethod(r.Context()) {
+	case middleware.AuthMethodAPIKey:
+		keyID, _ := r.Context().Value(middleware.ContextKeyAPIKeyID).(string)
+		return "key:" + keyID
+	default:
+		// Кука пользователя выдается любому запросу без куки, поэтому ни ее отсутствие,
+		// ни ID пользователя из нее не годятся: сменой куки корзину можно было бы сбросить
+		return "ip:" + h.clientIPs.ClientIP(r)
+	}
+}
+
+// APIKeyCreateRequest представляет запрос на создание API ключа.
+// Используется в JSON API эндпоинте POST /api/user/api-keys.
+type APIKeyCreateRequest struct {
+	Name   string   `json:"name"`   // Описание ключа
+	Scopes []string `json:"scopes"` // Области действия: read, write, delete
+}
+
+// APIKeyCreateResponse представляет созданный API ключ.
+// Открытое значение ключа возвращается только в этом ответе.
+type APIKeyCreateResponse struct {
+	models.APIKey
+	Key string `json:"key"` // Значение для заголовка Authorization: Bearer
+}
+
+// sessionUserID возвращает пользователя запроса, если запрос аутентифицирован
+// действующей кукой. Управлять API ключами можно только из такой сессии:
+// запросы с API ключом и запросы без куки отклоняются.
+func (h *Handler) sessionUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	switch method := middleware.AuthMethod(r.Context()); {
+	case method == middleware.AuthMethodAPIKey:
+		http.Error(w, "API keys cannot manage API keys", http.StatusForbidden)
+		return "", false
+	case method != middleware.AuthMethodCookie || userID == "":
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return userID, true
+}
+
+// HandleCreateAPIKey обрабатывает POST запрос создания API ключа пользователя
+func (h *Handler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeJSON) {
+		http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+
+	var req APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	key, token, err := h.service.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidScope):
+			http.Error(w, "Invalid scopes: use one or more of read, write, delete", http.StatusBadRequest)
+		case errors.Is(err, service.ErrInvalidAPIKeyName):
+			http.Error(w, "Invalid name: use at most 255 characters", http.StatusBadRequest)
+		default:
+			h.logger.Error("Error creating API key", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(APIKeyCreateResponse{APIKey: key, Key: token}); err != nil {
+		h.logger.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+// HandleListAPIKeys обрабатывает GET запрос списка API ключей пользователя
+func (h *Handler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Error listing API keys", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		h.logger.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+// HandleRevokeAPIKey обрабатывает DELETE запрос отзыва API ключа пользователя
+func (h *Handler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(r.Context(), chi.URLParam(r, "id"), userID); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Error revoking API key", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -10,27 +10,39 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/config"
 	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
 	"github.com/InQaaaaGit/trunc_url.git/internal/service"
 	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
 // mockURLService реализует интерфейс service.URLService для тестов
 type mockURLService struct {
 	createShortURLFunc       func(ctx context.Context, originalURL string) (string, error)
+	createWithOptionsFunc    func(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error)
 	getOriginalURLFunc       func(ctx context.Context, shortURL string) (string, error)
+	resolveRedirectFunc      func(ctx context.Context, shortURL string) (models.Redirect, error)
 	createShortURLsBatchFunc func(ctx context.Context, batch []models.BatchRequestEntry) ([]models.BatchResponseEntry, error)
 	getStorageFunc           func() storage.URLStorage
 	checkConnectionFunc      func(ctx context.Context) error
 	getUserURLsFunc          func(ctx context.Context, userID string) ([]models.UserURL, error)
+	listUserURLsFunc         func(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error)
+	getURLStatsFunc          func(ctx context.Context, shortURL, userID string) (models.ClickStats, error)
+	updateUserURLFunc        func(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error)
+	getURLRevisionsFunc      func(ctx context.Context, shortURL, userID string) ([]models.URLRevision, error)
+	clicks                   []string
 	urls                     map[string]string
 	deletedURLs              map[string]bool
+	deleteJobs               map[string]models.DeleteJob
+	apiKeys                  map[string]models.APIKey // API ключи по открытому значению
 }
 
 func (m *mockURLService) CreateShortURL(ctx context.Context, originalURL string) (string, error) {
@@ -40,6 +52,13 @@ func (m *mockURLService) CreateShortURL(ctx context.Context, originalURL string)
 	return "", errors.New("not implemented")
 }
 
+func (m *mockURLService) CreateShortURLWithOptions(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error) {
+	if m.createWithOptionsFunc != nil {
+		return m.createWithOptionsFunc(ctx, originalURL, opts)
+	}
+	return m.CreateShortURL(ctx, originalURL)
+}
+
 func (m *mockURLService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
 	if m.getOriginalURLFunc != nil {
 		return m.getOriginalURLFunc(ctx, shortURL)
@@ -53,6 +72,17 @@ func (m *mockURLService) GetOriginalURL(ctx context.Context, shortURL string) (s
 	return "", storage.ErrURLNotFound
 }
 
+func (m *mockURLService) ResolveRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	if m.resolveRedirectFunc != nil {
+		return m.resolveRedirectFunc(ctx, shortURL)
+	}
+	originalURL, err := m.GetOriginalURL(ctx, shortURL)
+	if err != nil {
+		return models.Redirect{}, err
+	}
+	return models.Redirect{OriginalURL: originalURL, Status: http.StatusTemporaryRedirect}, nil
+}
+
 func (m *mockURLService) CreateShortURLsBatch(ctx context.Context, batch []models.BatchRequestEntry) ([]models.BatchResponseEntry, error) {
 	if m.createShortURLsBatchFunc != nil {
 		return m.createShortURLsBatchFunc(ctx, batch)
@@ -81,6 +111,27 @@ func (m *mockURLService) GetUserURLs(ctx context.Context, userID string) ([]mode
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockURLService) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	if m.listUserURLsFunc != nil {
+		return m.listUserURLsFunc(ctx, userID, query)
+	}
+	return models.UserURLPage{}, errors.New("not implemented")
+}
+
+func (m *mockURLService) UpdateUserURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	if m.updateUserURLFunc != nil {
+		return m.updateUserURLFunc(ctx, shortURL, userID, update)
+	}
+	return models.UserURL{}, errors.New("not implemented")
+}
+
+func (m *mockURLService) GetURLRevisions(ctx context.Context, shortURL, userID string) ([]models.URLRevision, error) {
+	if m.getURLRevisionsFunc != nil {
+		return m.getURLRevisionsFunc(ctx, shortURL, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
 func (m *mockURLService) BatchDeleteURLs(ctx context.Context, shortURLs []string, userID string) error {
 	for _, shortURL := range shortURLs {
 		m.deletedURLs[shortURL] = true
@@ -88,6 +139,93 @@ func (m *mockURLService) BatchDeleteURLs(ctx context.Context, shortURLs []string
 	return nil
 }
 
+func (m *mockURLService) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	restored := []string{}
+	for _, shortURL := range shortURLs {
+		if m.deletedURLs[shortURL] {
+			delete(m.deletedURLs, shortURL)
+			restored = append(restored, shortURL)
+		}
+	}
+	return restored, nil
+}
+
+func (m *mockURLService) EnqueueDeletion(ctx context.Context, shortURLs []string, userID string) (models.DeleteJob, error) {
+	if m.deleteJobs == nil {
+		m.deleteJobs = make(map[string]models.DeleteJob)
+	}
+	job := models.DeleteJob{
+		ID:        fmt.Sprintf("job%d", len(m.deleteJobs)+1),
+		UserID:    userID,
+		ShortURLs: shortURLs,
+		Status:    models.DeleteJobPending,
+	}
+	m.deleteJobs[job.ID] = job
+	return job, nil
+}
+
+func (m *mockURLService) GetDeletionJob(ctx context.Context, id, userID string) (models.DeleteJob, error) {
+	job, ok := m.deleteJobs[id]
+	if !ok || job.UserID != userID {
+		return models.DeleteJob{}, storage.ErrDeleteJobNotFound
+	}
+	return job, nil
+}
+
+func (m *mockURLService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (models.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", service.ErrInvalidScope
+	}
+	if m.apiKeys == nil {
+		m.apiKeys = make(map[string]models.APIKey)
+	}
+	token := fmt.Sprintf("tu_key%d", len(m.apiKeys)+1)
+	key := models.APIKey{ID: fmt.Sprintf("key%d", len(m.apiKeys)+1), UserID: userID, Name: name, Scopes: scopes}
+	m.apiKeys[token] = key
+	return key, token, nil
+}
+
+func (m *mockURLService) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	keys := make([]models.APIKey, 0)
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockURLService) RevokeAPIKey(ctx context.Context, id, userID string) error {
+	for token, key := range m.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			now := time.Now()
+			key.RevokedAt = &now
+			m.apiKeys[token] = key
+			return nil
+		}
+	}
+	return storage.ErrAPIKeyNotFound
+}
+
+func (m *mockURLService) AuthenticateAPIKey(ctx context.Context, token string) (models.APIKey, error) {
+	key, ok := m.apiKeys[token]
+	if !ok || key.Revoked() {
+		return models.APIKey{}, service.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+func (m *mockURLService) RecordClick(shortURL string, info models.ClickInfo) {
+	m.clicks = append(m.clicks, shortURL)
+}
+
+func (m *mockURLService) GetURLStats(ctx context.Context, shortURL, userID string) (models.ClickStats, error) {
+	if m.getURLStatsFunc != nil {
+		return m.getURLStatsFunc(ctx, shortURL, userID)
+	}
+	return models.ClickStats{}, errors.New("not implemented")
+}
+
 // mockDatabaseChecker реализует интерфейсы storage.URLStorage и storage.DatabaseChecker для тестов
 type mockDatabaseChecker struct {
 	saveFunc                  func(ctx context.Context, shortURL, originalURL, userID string) error
@@ -105,6 +243,10 @@ func (m *mockDatabaseChecker) Save(ctx context.Context, shortURL, originalURL, u
 	return errors.New("not implemented")
 }
 
+func (m *mockDatabaseChecker) SaveEntry(ctx context.Context, entry storage.BatchEntry) error {
+	return m.Save(ctx, entry.ShortURL, entry.OriginalURL, entry.UserID)
+}
+
 func (m *mockDatabaseChecker) Get(ctx context.Context, shortURL string) (string, error) {
 	if m.getFunc != nil {
 		return m.getFunc(ctx, shortURL)
@@ -112,6 +254,11 @@ func (m *mockDatabaseChecker) Get(ctx context.Context, shortURL string) (string,
 	return "", errors.New("not implemented")
 }
 
+func (m *mockDatabaseChecker) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	originalURL, err := m.Get(ctx, shortURL)
+	return models.Redirect{OriginalURL: originalURL}, err
+}
+
 func (m *mockDatabaseChecker) SaveBatch(ctx context.Context, batch []storage.BatchEntry) error {
 	if m.saveBatchFunc != nil {
 		return m.saveBatchFunc(ctx, batch)
@@ -140,6 +287,26 @@ func (m *mockDatabaseChecker) GetUserURLs(ctx context.Context, userID string) ([
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockDatabaseChecker) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	return models.UserURLPage{}, errors.New("not implemented")
+}
+
+func (m *mockDatabaseChecker) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	return models.UserURL{}, errors.New("not implemented")
+}
+
+func (m *mockDatabaseChecker) GetURLOwner(ctx context.Context, shortURL string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (m *mockDatabaseChecker) GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockDatabaseChecker) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (m *mockDatabaseChecker) BatchDelete(ctx context.Context, shortURLs []string, userID string) error {
 	return nil
 }
@@ -160,6 +327,10 @@ func (m *mockStorage) Save(ctx context.Context, shortURL, originalURL, userID st
 	return errors.New("not implemented")
 }
 
+func (m *mockStorage) SaveEntry(ctx context.Context, entry storage.BatchEntry) error {
+	return m.Save(ctx, entry.ShortURL, entry.OriginalURL, entry.UserID)
+}
+
 func (m *mockStorage) Get(ctx context.Context, shortURL string) (string, error) {
 	if m.getFunc != nil {
 		return m.getFunc(ctx, shortURL)
@@ -167,6 +338,11 @@ func (m *mockStorage) Get(ctx context.Context, shortURL string) (string, error)
 	return "", errors.New("not implemented")
 }
 
+func (m *mockStorage) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	originalURL, err := m.Get(ctx, shortURL)
+	return models.Redirect{OriginalURL: originalURL}, err
+}
+
 func (m *mockStorage) SaveBatch(ctx context.Context, batch []storage.BatchEntry) error {
 	if m.saveBatchFunc != nil {
 		return m.saveBatchFunc(ctx, batch)
@@ -188,6 +364,26 @@ func (m *mockStorage) GetUserURLs(ctx context.Context, userID string) ([]models.
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockStorage) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	return models.UserURLPage{}, errors.New("not implemented")
+}
+
+func (m *mockStorage) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	return models.UserURL{}, errors.New("not implemented")
+}
+
+func (m *mockStorage) GetURLOwner(ctx context.Context, shortURL string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (m *mockStorage) GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockStorage) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (m *mockStorage) BatchDelete(ctx context.Context, shortURLs []string, userID string) error {
 	return nil
 }
@@ -277,6 +473,249 @@ func TestHandleCreateURL(t *testing.T) {
 	}
 }
 
+func TestHandleShortenURLAlias(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Alias accepted",
+			body:           `{"url":"https://example.com","alias":"promo"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/promo"}`,
+		},
+		{
+			name:           "Alias taken",
+			body:           `{"url":"https://example.com","alias":"promo"}`,
+			serviceErr:     service.ErrAliasTaken,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   aliasTakenMessage,
+		},
+		{
+			name:           "Reserved alias",
+			body:           `{"url":"https://example.com","alias":"api"}`,
+			serviceErr:     service.ErrReservedAlias,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   reservedAliasMessage,
+		},
+		{
+			name:           "Expiry in the past",
+			body:           `{"url":"https://example.com","alias":"promo","expires_at":"2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   invalidExpiryMessage,
+		},
+		{
+			name:           "Invalid alias",
+			body:           `{"url":"https://example.com","alias":"a b"}`,
+			serviceErr:     service.ErrInvalidAlias,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   invalidAliasMessage,
+		},
+		{
+			name:           "Metadata too long",
+			body:           `{"url":"https://example.com","alias":"promo","title":"long"}`,
+			serviceErr:     service.ErrInvalidMetadata,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   invalidMetadataMessage,
+		},
+		{
+			name:           "Unsupported redirect status",
+			body:           `{"url":"https://example.com","alias":"promo","redirect_status":303}`,
+			serviceErr:     service.ErrInvalidRedirectStatus,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   invalidRedirectStatusMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAlias string
+			mockService := &mockURLService{
+				createWithOptionsFunc: func(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error) {
+					gotAlias = opts.Alias
+					if tt.serviceErr != nil {
+						return "", tt.serviceErr
+					}
+					return opts.Alias, nil
+				},
+			}
+			cfg := &config.Config{BaseURL: "http://localhost:8080"}
+			h := NewHandler(mockService, cfg, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.HandleShortenURL(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()))
+			if tt.expectedStatus != http.StatusBadRequest || tt.serviceErr != nil {
+				assert.NotEmpty(t, gotAlias)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateUserURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		contentType    string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "Updated", userID: "user1", body: `{"original_url":"https://example.com/v2","title":"Новая"}`, expectedStatus: http.StatusOK},
+		{name: "Unauthorized", body: `{"original_url":"https://example.com/v2"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid Content-Type", userID: "user1", contentType: "text/plain", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid body", userID: "user1", body: `{`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid update", userID: "user1", body: `{}`, serviceErr: service.ErrInvalidURLUpdate, expectedStatus: http.StatusBadRequest},
+		{name: "Not owner", userID: "user2", body: `{"title":"x"}`, serviceErr: storage.ErrURLNotFound, expectedStatus: http.StatusNotFound},
+		{name: "Deleted", userID: "user1", body: `{"title":"x"}`, serviceErr: storage.ErrURLDeleted, expectedStatus: http.StatusGone},
+		{name: "Conflict", userID: "user1", body: `{"original_url":"https://example.com/other"}`, serviceErr: storage.ErrOriginalURLConflict, expectedStatus: http.StatusConflict},
+		{name: "Rejected by policy", userID: "user1", body: `{"original_url":"file:///etc/passwd"}`, serviceErr: &service.PolicyViolation{Rule: service.PolicyRuleScheme}, expectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUpdate models.URLUpdate
+			mockService := &mockURLService{
+				updateUserURLFunc: func(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+					gotUpdate = update
+					if tt.serviceErr != nil {
+						return models.UserURL{}, tt.serviceErr
+					}
+					return models.UserURL{ShortURL: "http://localhost:8080/" + shortURL, OriginalURL: *update.OriginalURL, Title: *update.Title}, nil
+				},
+			}
+			h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/promo", strings.NewReader(tt.body))
+			contentType := contentTypeJSON
+			if tt.contentType != "" {
+				contentType = tt.contentType
+			}
+			req.Header.Set("Content-Type", contentType)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "promo")
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			if tt.userID != "" {
+				ctx = context.WithValue(ctx, middleware.ContextKeyUserID, tt.userID)
+			}
+			w := httptest.NewRecorder()
+
+			h.HandleUpdateUserURL(w, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Nil(t, gotUpdate.Description, "omitted fields must stay unchanged")
+				assert.JSONEq(t, `{"short_url":"http://localhost:8080/promo","original_url":"https://example.com/v2","title":"Новая"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPolicyViolationResponse(t *testing.T) {
+	violation := &service.PolicyViolation{
+		Rule:    service.PolicyRuleDenyHost,
+		Pattern: "*.evil.example",
+		Message: `host "www.evil.example" is denied`,
+		URL:     "https://www.evil.example",
+	}
+	tests := []struct {
+		name         string
+		target       string
+		contentType  string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "Plain text",
+			target:       "/",
+			contentType:  contentTypePlain,
+			body:         "https://www.evil.example",
+			expectedBody: `{"error":"url_policy_violation","rule":"deny_host","pattern":"*.evil.example","message":"host \"www.evil.example\" is denied","url":"https://www.evil.example"}`,
+		},
+		{
+			name:         "JSON",
+			target:       "/api/shorten",
+			contentType:  contentTypeJSON,
+			body:         `{"url":"https://www.evil.example"}`,
+			expectedBody: `{"error":"url_policy_violation","rule":"deny_host","pattern":"*.evil.example","message":"host \"www.evil.example\" is denied","url":"https://www.evil.example"}`,
+		},
+		{
+			name:         "Batch",
+			target:       "/api/shorten/batch",
+			contentType:  contentTypeJSON,
+			body:         `[{"correlation_id":"7","original_url":"https://www.evil.example"}]`,
+			expectedBody: `{"error":"url_policy_violation","rule":"deny_host","pattern":"*.evil.example","message":"host \"www.evil.example\" is denied","url":"https://www.evil.example","correlation_id":"7"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				createShortURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+					return "", violation
+				},
+				createShortURLsBatchFunc: func(ctx context.Context, batch []models.BatchRequestEntry) ([]models.BatchResponseEntry, error) {
+					batchViolation := *violation
+					batchViolation.CorrelationID = batch[0].CorrelationID
+					return nil, fmt.Errorf("batch: %w", &batchViolation)
+				},
+			}
+			h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+			r := chi.NewRouter()
+			r.Post("/", h.HandleCreateURL)
+			r.Post("/api/shorten", h.HandleShortenURL)
+			r.Post("/api/shorten/batch", h.HandleShortenBatch)
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.Equal(t, contentTypeJSON, w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandleGetURLRevisions(t *testing.T) {
+	replacedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockService := &mockURLService{
+		getURLRevisionsFunc: func(ctx context.Context, shortURL, userID string) ([]models.URLRevision, error) {
+			if userID != "user1" {
+				return nil, storage.ErrURLNotFound
+			}
+			return []models.URLRevision{{OriginalURL: "https://example.com/v1", ReplacedAt: replacedAt}}, nil
+		},
+	}
+	h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/promo/revisions", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "promo")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.ContextKeyUserID, userID)
+		w := httptest.NewRecorder()
+		h.HandleGetURLRevisions(w, req.WithContext(ctx))
+		return w
+	}
+
+	w := request("user1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"original_url":"https://example.com/v1","replaced_at":"2025-03-01T12:00:00Z"}]`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, request("user2").Code)
+}
+
 func TestHandleRedirect(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -285,6 +724,8 @@ func TestHandleRedirect(t *testing.T) {
 		mockService    *mockURLService
 		expectedStatus int
 		expectedURL    string
+		expectedCache  string
+		expectedClicks int
 	}{
 		{
 			name:     "Valid short URL",
@@ -297,6 +738,79 @@ func TestHandleRedirect(t *testing.T) {
 			},
 			expectedStatus: http.StatusTemporaryRedirect,
 			expectedURL:    "https://example.com",
+			expectedCache:  "private, no-store",
+			expectedClicks: 1,
+		},
+		{
+			name:     "Permanent redirect",
+			method:   http.MethodGet,
+			shortURL: "perm",
+			mockService: &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					return models.Redirect{OriginalURL: "https://example.com", Status: http.StatusPermanentRedirect}, nil
+				},
+			},
+			expectedStatus: http.StatusPermanentRedirect,
+			expectedURL:    "https://example.com",
+			expectedCache:  "public, max-age=3600",
+			expectedClicks: 1,
+		},
+		{
+			name:     "Permanent redirect of editable URL",
+			method:   http.MethodGet,
+			shortURL: "perm",
+			mockService: &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					return models.Redirect{OriginalURL: "https://example.com", Status: http.StatusMovedPermanently, Editable: true}, nil
+				},
+			},
+			expectedStatus: http.StatusMovedPermanently,
+			expectedURL:    "https://example.com",
+			expectedCache:  "private, no-cache",
+			expectedClicks: 1,
+		},
+		{
+			name:     "Permanent redirect of expiring URL",
+			method:   http.MethodGet,
+			shortURL: "perm",
+			mockService: &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					expiresAt := time.Now().Add(10*time.Minute + 500*time.Millisecond)
+					return models.Redirect{OriginalURL: "https://example.com", Status: http.StatusPermanentRedirect, ExpiresAt: expiresAt}, nil
+				},
+			},
+			expectedStatus: http.StatusPermanentRedirect,
+			expectedURL:    "https://example.com",
+			expectedCache:  "public, max-age=600",
+			expectedClicks: 1,
+		},
+		{
+			name:     "Permanent redirect of URL about to expire",
+			method:   http.MethodGet,
+			shortURL: "perm",
+			mockService: &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					expiresAt := time.Now().Add(500 * time.Millisecond)
+					return models.Redirect{OriginalURL: "https://example.com", Status: http.StatusPermanentRedirect, ExpiresAt: expiresAt}, nil
+				},
+			},
+			expectedStatus: http.StatusPermanentRedirect,
+			expectedURL:    "https://example.com",
+			expectedCache:  "private, no-store",
+			expectedClicks: 1,
+		},
+		{
+			name:     "HEAD is not counted as click",
+			method:   http.MethodHead,
+			shortURL: "abc123",
+			mockService: &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					return models.Redirect{OriginalURL: "https://example.com", Status: http.StatusFound}, nil
+				},
+			},
+			expectedStatus: http.StatusFound,
+			expectedURL:    "https://example.com",
+			expectedCache:  "private, no-store",
 		},
 		{
 			name:           "Invalid method",
@@ -316,6 +830,17 @@ func TestHandleRedirect(t *testing.T) {
 			},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:     "Expired URL",
+			method:   http.MethodGet,
+			shortURL: "expired1",
+			mockService: &mockURLService{
+				getOriginalURLFunc: func(ctx context.Context, shortURL string) (string, error) {
+					return "", storage.ErrURLExpired
+				},
+			},
+			expectedStatus: http.StatusGone,
+		},
 		{
 			name:     "Service error",
 			method:   http.MethodGet,
@@ -331,12 +856,13 @@ func TestHandleRedirect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg := &config.Config{BaseURL: "http://localhost:8080"}
+			cfg := &config.Config{BaseURL: "http://localhost:8080", RedirectCacheMaxAge: time.Hour}
 			logger, _ := zap.NewDevelopment()
 			h := NewHandler(tt.mockService, cfg, logger)
 
 			r := chi.NewRouter()
 			r.Get("/{id}", h.HandleRedirect)
+			r.Head("/{id}", h.HandleRedirect)
 
 			req := httptest.NewRequest(tt.method, "/"+tt.shortURL, nil)
 			w := httptest.NewRecorder()
@@ -346,7 +872,9 @@ func TestHandleRedirect(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, w.Code)
 			if tt.expectedURL != "" {
 				assert.Equal(t, tt.expectedURL, w.Header().Get("Location"))
+				assert.Equal(t, tt.expectedCache, w.Header().Get("Cache-Control"))
 			}
+			assert.Len(t, tt.mockService.clicks, tt.expectedClicks)
 		})
 	}
 }
@@ -440,6 +968,139 @@ func TestHandleDeleteUserURLs(t *testing.T) {
 	if w.Code != http.StatusAccepted {
 		t.Errorf("Expected status %d, got %d", http.StatusAccepted, w.Code)
 	}
+
+	// Ответ содержит идентификатор задачи удаления
+	var job models.DeleteJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, models.DeleteJobPending, job.Status)
+	assert.Equal(t, "/api/user/urls/delete-jobs/"+job.ID, w.Header().Get("Location"))
+	assert.Equal(t, []string{"test123"}, mockService.deleteJobs[job.ID].ShortURLs)
+}
+
+func TestHandleRestoreUserURLs(t *testing.T) {
+	mockService := &mockURLService{
+		deletedURLs: map[string]bool{"gone1": true, "gone2": true},
+	}
+	h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Restore", body: `["gone1", "missing"]`, contentType: "application/json", expectedStatus: http.StatusOK, expectedBody: `{"restored":["gone1"]}`},
+		{name: "Nothing restored", body: `["missing"]`, contentType: "application/json", expectedStatus: http.StatusOK, expectedBody: `{"restored":[]}`},
+		{name: "Empty list", body: `[]`, contentType: "application/json", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid body", body: `{"id": "gone2"}`, contentType: "application/json", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid Content-Type", body: `["gone2"]`, contentType: "text/plain", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, "user1"))
+			w := httptest.NewRecorder()
+
+			h.HandleRestoreUserURLs(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+	assert.True(t, mockService.deletedURLs["gone2"])
+}
+
+func TestHandleGetDeleteJob(t *testing.T) {
+	mockService := &mockURLService{
+		deleteJobs: map[string]models.DeleteJob{
+			"job1": {ID: "job1", UserID: "user123", ShortURLs: []string{"abc"}, Status: models.DeleteJobCompleted, Attempts: 1},
+		},
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Get("/api/user/urls/delete-jobs/{id}", h.HandleGetDeleteJob)
+
+	tests := []struct {
+		name           string
+		jobID          string
+		userID         string
+		expectedStatus int
+	}{
+		{name: "Owner gets job", jobID: "job1", userID: "user123", expectedStatus: http.StatusOK},
+		{name: "Foreign job", jobID: "job1", userID: "user456", expectedStatus: http.StatusNotFound},
+		{name: "Unknown job", jobID: "job2", userID: "user123", expectedStatus: http.StatusNotFound},
+		{name: "No user", jobID: "job1", userID: "", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/delete-jobs/"+tt.jobID, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, tt.userID))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var job models.DeleteJob
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+				assert.Equal(t, models.DeleteJobCompleted, job.Status)
+				assert.Equal(t, 1, job.Attempts)
+			}
+		})
+	}
+}
+
+func TestHandleGetURLStats(t *testing.T) {
+	mockService := &mockURLService{
+		getURLStatsFunc: func(ctx context.Context, shortURL, userID string) (models.ClickStats, error) {
+			if shortURL != "abc123" || userID != "user123" {
+				return models.ClickStats{}, storage.ErrURLNotFound
+			}
+			return models.ClickStats{ShortURL: shortURL, TotalClicks: 5}, nil
+		},
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Get("/api/user/urls/{id}/stats", h.HandleGetURLStats)
+
+	tests := []struct {
+		name           string
+		shortURL       string
+		userID         string
+		expectedStatus int
+	}{
+		{name: "Owner gets stats", shortURL: "abc123", userID: "user123", expectedStatus: http.StatusOK},
+		{name: "Foreign URL", shortURL: "abc123", userID: "user456", expectedStatus: http.StatusNotFound},
+		{name: "No user", shortURL: "abc123", userID: "", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tt.shortURL+"/stats", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, tt.userID))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var stats models.ClickStats
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+				assert.Equal(t, int64(5), stats.TotalClicks)
+			}
+		})
+	}
 }
 
 func TestHandleRedirectDeletedURL(t *testing.T) {
@@ -467,6 +1128,381 @@ func TestHandleRedirectDeletedURL(t *testing.T) {
 	}
 }
 
+func TestAuthMiddlewareAPIKey(t *testing.T) {
+	mockService := &mockURLService{
+		apiKeys: map[string]models.APIKey{
+			"tu_reader":  {ID: "k1", UserID: "bot", Scopes: []string{models.ScopeRead}},
+			"tu_revoked": {ID: "k2", UserID: "bot", Scopes: []string{models.ScopeRead}, RevokedAt: &time.Time{}},
+		},
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080", SecretKey: "test-secret"}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Use(h.AuthMiddleware)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+		w.Write([]byte(userID))
+	}
+	r.With(h.RequireScope(models.ScopeRead)).Get("/read", echo)
+	r.With(h.RequireScope(models.ScopeWrite)).Post("/write", echo)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		authorization  string
+		expectedStatus int
+		expectedUser   string
+	}{
+		{name: "Valid key with scope", method: http.MethodGet, path: "/read", authorization: "Bearer tu_reader", expectedStatus: http.StatusOK, expectedUser: "bot"},
+		{name: "Scheme is case-insensitive", method: http.MethodGet, path: "/read", authorization: "bearer tu_reader", expectedStatus: http.StatusOK, expectedUser: "bot"},
+		{name: "Missing scope", method: http.MethodPost, path: "/write", authorization: "Bearer tu_reader", expectedStatus: http.StatusForbidden},
+		{name: "Unknown key", method: http.MethodGet, path: "/read", authorization: "Bearer tu_unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "Revoked key", method: http.MethodGet, path: "/read", authorization: "Bearer tu_revoked", expectedStatus: http.StatusUnauthorized},
+		{name: "Not a bearer token", method: http.MethodGet, path: "/read", authorization: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusUnauthorized},
+		{name: "Cookie flow is unrestricted", method: http.MethodPost, path: "/write", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedUser != "" {
+				assert.Equal(t, tt.expectedUser, w.Body.String())
+			}
+			if tt.authorization != "" {
+				assert.Empty(t, w.Result().Cookies(), "API key requests must not mint a user")
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSession(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:             "http://localhost:8080",
+		SecretKey:           "test-secret",
+		SessionKeys:         "k2:new-secret,k1:old-secret",
+		SessionTTL:          time.Hour,
+		SessionAcceptLegacy: true,
+		CookieSecure:        true,
+		CookieHTTPOnly:      true,
+		CookieSameSite:      "strict",
+	}
+	h := NewHandler(&mockURLService{}, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Use(h.AuthMiddleware)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+		w.Write([]byte(middleware.AuthMethod(r.Context()) + ":" + userID))
+	})
+
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	current, err := sessions.Issue("user-current")
+	require.NoError(t, err)
+	oldKeyring, err := middleware.ParseKeyring("k1:old-secret", "")
+	require.NoError(t, err)
+	rotated, err := middleware.NewSessions(oldKeyring, time.Hour).Issue("user-rotated")
+	require.NoError(t, err)
+	foreign, err := middleware.NewSessionsFromConfig(&config.Config{SessionKeys: "k2:other-secret"})
+	require.NoError(t, err)
+	forged, err := foreign.Issue("user-forged")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		cookie        string
+		expectedBody  string
+		expectRenewal bool
+	}{
+		{name: "Valid session", cookie: current, expectedBody: "cookie:user-current"},
+		{name: "Rotated key is accepted and reissued", cookie: rotated, expectedBody: "cookie:user-rotated", expectRenewal: true},
+		{name: "Legacy cookie is upgraded", cookie: middleware.SignUserID("user-legacy", cfg.SecretKey), expectedBody: "cookie:user-legacy", expectRenewal: true},
+		{name: "Forged signature", cookie: forged, expectRenewal: true},
+		{name: "No cookie", expectRenewal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "user_id", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			} else {
+				assert.True(t, strings.HasPrefix(w.Body.String(), middleware.AuthMethodNewUser+":"))
+			}
+
+			cookies := w.Result().Cookies()
+			if !tt.expectRenewal {
+				assert.Empty(t, cookies)
+				return
+			}
+			require.Len(t, cookies, 1)
+			cookie := cookies[0]
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+			assert.Equal(t, 3600, cookie.MaxAge)
+
+			session, err := sessions.Parse(cookie.Value)
+			require.NoError(t, err)
+			assert.Equal(t, "k2", session.KeyID)
+			assert.True(t, strings.HasSuffix(w.Body.String(), ":"+session.UserID), "cookie must carry the request user")
+		})
+	}
+}
+
+func TestHandleGetUserURLs(t *testing.T) {
+	var gotQuery models.UserURLQuery
+	mockService := &mockURLService{
+		listUserURLsFunc: func(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+			gotQuery = query
+			if query.Cursor == "bad" {
+				return models.UserURLPage{}, service.ErrInvalidUserURLQuery
+			}
+			if query.Search == "none" {
+				return models.UserURLPage{URLs: []models.UserURL{}}, nil
+			}
+			return models.UserURLPage{
+				URLs:       []models.UserURL{{ShortURL: "http://localhost:8080/abc", OriginalURL: "https://example.com"}},
+				Total:      3,
+				NextCursor: "next-page",
+			}, nil
+		},
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedQuery  models.UserURLQuery
+		expectedLink   string
+	}{
+		{
+			name:           "Filters and sorting",
+			target:         "/api/user/urls?limit=1&q=exa&domain=example.com&created_after=2025-01-01&created_before=2025-02-01T10:00:00Z&sort=-original_url",
+			expectedStatus: http.StatusOK,
+			expectedQuery: models.UserURLQuery{
+				Search:        "exa",
+				Domain:        "example.com",
+				CreatedAfter:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				CreatedBefore: time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC),
+				Sort:          models.SortOriginalURL,
+				Desc:          true,
+				Limit:         1,
+			},
+			expectedLink: `</api/user/urls?created_after=2025-01-01&created_before=2025-02-01T10%3A00%3A00Z&cursor=next-page&domain=example.com&limit=1&q=exa&sort=-original_url>; rel="next"`,
+		},
+		{name: "Empty page", target: "/api/user/urls?q=none", expectedStatus: http.StatusNoContent, expectedQuery: models.UserURLQuery{Search: "none"}},
+		{name: "Invalid limit", target: "/api/user/urls?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "Deleted URLs", target: "/api/user/urls?deleted=true", expectedStatus: http.StatusOK, expectedQuery: models.UserURLQuery{Deleted: true}},
+		{name: "Invalid deleted", target: "/api/user/urls?deleted=maybe", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid date", target: "/api/user/urls?created_after=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid cursor", target: "/api/user/urls?cursor=bad", expectedStatus: http.StatusBadRequest, expectedQuery: models.UserURLQuery{Cursor: "bad"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotQuery = models.UserURLQuery{}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, "user1"))
+			w := httptest.NewRecorder()
+
+			h.HandleGetUserURLs(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedQuery, gotQuery)
+			if tt.expectedLink != "" {
+				assert.Equal(t, tt.expectedLink, w.Header().Get("Link"))
+				assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+				assert.JSONEq(t, `[{"short_url":"http://localhost:8080/abc","original_url":"https://example.com"}]`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	mockService := &mockURLService{
+		apiKeys: map[string]models.APIKey{
+			"tu_writer": {ID: "k1", UserID: "bot", Scopes: []string{models.ScopeWrite}},
+		},
+	}
+	cfg := &config.Config{
+		BaseURL:         "http://localhost:8080",
+		SecretKey:       "test-secret",
+		RateLimitCreate: "1/m:2",
+		TrustedProxies:  "10.0.0.0/8",
+	}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Use(h.AuthMiddleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) }
+	r.With(h.RateLimit(ratelimit.RouteCreate)).Post("/", ok)
+	r.With(h.RateLimit(ratelimit.RouteRedirect)).Get("/{id}", ok)
+
+	do := func(method, path, remoteAddr, forwardedFor, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Клиенты без куки различаются по IP, в том числе за доверенным прокси
+	for i := 0; i < 2; i++ {
+		w := do(http.MethodPost, "/", "10.0.0.1:1234", "198.51.100.1", "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	}
+	w := do(http.MethodPost, "/", "10.0.0.2:1234", "198.51.100.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = do(http.MethodPost, "/", "10.0.0.1:1234", "198.51.100.2", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Сменой куки корзину не сбросить: кука выдается бесплатно, поэтому ключом остается IP
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		token, err := sessions.Issue(fmt.Sprintf("rotated-%d", i))
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "203.0.113.50:1234"
+		req.AddCookie(&http.Cookie{Name: "user_id", Value: token})
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if i < 2 {
+			assert.Equal(t, http.StatusCreated, w.Code)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		}
+	}
+
+	// API ключ имеет собственную корзину
+	for i := 0; i < 2; i++ {
+		w = do(http.MethodPost, "/", "10.0.0.1:1234", "198.51.100.1", "Bearer tu_writer")
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	w = do(http.MethodPost, "/", "203.0.113.9:1234", "", "Bearer tu_writer")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Маршрут без ограничения
+	for i := 0; i < 5; i++ {
+		w = do(http.MethodGet, "/abc", "10.0.0.1:1234", "198.51.100.1", "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	cfg := &config.Config{RateLimitBackend: ratelimit.BackendPostgres, RateLimitCreate: "1/m:2"}
+
+	// Сервис перешел на другое хранилище: обертка реализует все интерфейсы,
+	// но корзины хранить негде
+	wrapped := storage.NewInstrumentedStorage(storage.NewMemoryStorage(zap.NewNop()), "memory")
+	svc := &mockURLService{getStorageFunc: func() storage.URLStorage { return wrapped }}
+	_, err := NewRateLimiter(svc, cfg)
+	assert.Error(t, err)
+
+	cfg.RateLimitBackend = ratelimit.BackendMemory
+	limiter, err := NewRateLimiter(svc, cfg)
+	require.NoError(t, err)
+	assert.True(t, limiter.Limit(ratelimit.RouteCreate).Enabled())
+}
+
+func TestHandleAPIKeys(t *testing.T) {
+	mockService := &mockURLService{}
+	cfg := &config.Config{BaseURL: "http://localhost:8080", SecretKey: "test-secret"}
+	h := NewHandler(mockService, cfg, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Use(h.AuthMiddleware)
+	r.Post("/api/user/api-keys", h.HandleCreateAPIKey)
+	r.Get("/api/user/api-keys", h.HandleListAPIKeys)
+	r.Delete("/api/user/api-keys/{id}", h.HandleRevokeAPIKey)
+
+	sessions, err := middleware.NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	token, err := sessions.Issue("user123")
+	require.NoError(t, err)
+	session := &http.Cookie{Name: "user_id", Value: token}
+	do := func(method, path, body string, cookie *http.Cookie, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Без действующей сессии ключ создать нельзя
+	w := do(http.MethodPost, "/api/user/api-keys", `{"name":"ci","scopes":["read"]}`, nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(http.MethodPost, "/api/user/api-keys", `{"name":"ci","scopes":[]}`, session, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/api/user/api-keys", `{"name":"ci","scopes":["read","write"]}`, session, "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created APIKeyCreateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Key)
+	assert.Equal(t, "ci", created.Name)
+
+	// Ключ не может управлять ключами
+	w = do(http.MethodGet, "/api/user/api-keys", "", nil, "Bearer "+created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodGet, "/api/user/api-keys", "", session, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key, "list must not expose key values")
+	var keys []models.APIKey
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	assert.Len(t, keys, 1)
+
+	w = do(http.MethodDelete, "/api/user/api-keys/unknown", "", session, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = do(http.MethodDelete, "/api/user/api-keys/"+created.ID, "", session, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Отозванный ключ больше не принимается
+	w = do(http.MethodGet, "/api/user/api-keys", "", nil, "Bearer "+created.Key)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 var _ service.URLService = (*mockURLService)(nil)
 var _ storage.URLStorage = (*mockStorage)(nil)
 var _ storage.URLStorage = (*mockDatabaseChecker)(nil)
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"go.uber.org/zap"
+)
+
+// previewSuffix — суффикс короткого URL, открывающий страницу предпросмотра вместо перехода
+const previewSuffix = "+"
+
+//go:embed templates/preview.html
+var previewFS embed.FS
+
+// previewTemplate — страница предпросмотра ссылки. Страница не загружает внешних ресурсов:
+// стили встроены, а Content-Security-Policy запрещает все остальное.
+var previewTemplate = template.Must(template.ParseFS(previewFS, "templates/preview.html"))
+
+// previewCSP запрещает странице предпросмотра загружать что-либо, кроме встроенных стилей
+const previewCSP = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+
+// previewPage — данные шаблона страницы предпросмотра
+type previewPage struct {
+	ShortURL    string
+	OriginalURL string
+	Title       string
+	Description string
+	CreatedAt   string // Время создания в UTC; пусто, если неизвестно
+}
+
+// isPreviewRequest определяет, запрошен ли предпросмотр ссылки: суффиксом "+"
+// у короткого идентификатора или параметром preview=1. Возвращает идентификатор без суффикса.
+func isPreviewRequest(r *http.Request, shortID string) (string, bool) {
+	if trimmed, ok := strings.CutSuffix(shortID, previewSuffix); ok {
+		return trimmed, true
+	}
+	return shortID, r.URL.Query().Get("preview") == "1"
+}
+
+// writePreview отвечает страницей предпросмотра ссылки shortID
+func (h *Handler) writePreview(w http.ResponseWriter, shortID string, redirect models.Redirect) {
+	page := previewPage{
+		ShortURL:    h.cfg.BaseURL + "/" + shortID,
+		OriginalURL: redirect.OriginalURL,
+		Title:       redirect.Title,
+		Description: redirect.Description,
+	}
+	if !redirect.CreatedAt.IsZero() {
+		page.CreatedAt = redirect.CreatedAt.UTC().Format("02.01.2006 15:04 UTC")
+	}
+
+	var buf bytes.Buffer
+	if err := previewTemplate.Execute(&buf, page); err != nil {
+		h.logger.Error("Error rendering preview page", zap.String("short_id", shortID), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", previewCSP)
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		h.logger.Error("Error writing preview page", zap.Error(err))
+	}
+}
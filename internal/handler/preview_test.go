@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHandleRedirectPreview(t *testing.T) {
+	links := map[string]models.Redirect{
+		"docs": {
+			OriginalURL: "https://example.com/docs?a=1&b=2",
+			Status:      http.StatusTemporaryRedirect,
+			Title:       `<script>alert("x")</script>`,
+			Description: "Справочник по API",
+			CreatedAt:   time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC),
+		},
+		"promo": {OriginalURL: "https://example.com/promo", Status: http.StatusFound, Interstitial: true},
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedBody   []string
+		expectedClicks int
+	}{
+		{
+			name:           "Plus suffix",
+			target:         "/docs+",
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				"https://example.com/docs?a=1&amp;b=2",
+				"&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;",
+				"Справочник по API",
+				"01.03.2025 12:30 UTC",
+				"http://localhost:8080/docs",
+			},
+		},
+		{name: "Query parameter", target: "/docs?preview=1", expectedStatus: http.StatusOK, expectedBody: []string{"https://example.com/docs"}},
+		{name: "Redirect without preview", target: "/docs", expectedStatus: http.StatusTemporaryRedirect, expectedClicks: 1},
+		{name: "Always interstitial", target: "/promo", expectedStatus: http.StatusOK, expectedBody: []string{"https://example.com/promo"}, expectedClicks: 1},
+		{name: "Missing link", target: "/missing+", expectedStatus: http.StatusBadRequest},
+		{name: "Empty ID", target: "/+", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					if redirect, ok := links[shortURL]; ok {
+						return redirect, nil
+					}
+					return models.Redirect{}, storage.ErrURLNotFound
+				},
+			}
+			h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+			r := chi.NewRouter()
+			r.Get("/{id}", h.HandleRedirect)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Len(t, mockService.clicks, tt.expectedClicks)
+			if len(tt.expectedBody) > 0 {
+				assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Equal(t, previewCSP, w.Header().Get("Content-Security-Policy"))
+				assert.Empty(t, w.Header().Get("Location"))
+				for _, want := range tt.expectedBody {
+					assert.Contains(t, w.Body.String(), want)
+				}
+				assert.NotContains(t, w.Body.String(), "<script>")
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/qrcode"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Параметры QR кода по умолчанию и их допустимые границы
+const (
+	qrDefaultSize   = 256
+	qrMinSize       = 64
+	qrMaxSize       = 2048
+	qrDefaultMargin = 4 // Свободная зона в четыре модуля по спецификации
+	qrMaxMargin     = 16
+
+	qrDefaultLevel = qrcode.LevelM
+
+	qrFormatPNG = "png"
+	qrFormatSVG = "svg"
+
+	invalidQRMessage = "Invalid QR parameters: format is png or svg, size is 64-2048, ec is L, M, Q or H, margin is 0-16"
+)
+
+// qrCacheControl — QR код короткой ссылки не меняется, пока жива ссылка
+const qrCacheControl = "public, max-age=86400"
+
+// qrOptions — параметры отрисовки QR кода
+type qrOptions struct {
+	format string
+	size   int
+	level  qrcode.Level
+	margin int
+}
+
+// parseQROptions разбирает параметры QR кода из строки запроса:
+// format (png или svg), size (сторона изображения в пикселях),
+// ec (уровень коррекции ошибок L, M, Q, H) и margin (свободная зона в модулях)
+func parseQROptions(r *http.Request) (qrOptions, bool) {
+	query := r.URL.Query()
+	opts := qrOptions{format: qrFormatPNG, size: qrDefaultSize, level: qrDefaultLevel, margin: qrDefaultMargin}
+
+	if format := query.Get("format"); format != "" {
+		opts.format = strings.ToLower(format)
+		if opts.format != qrFormatPNG && opts.format != qrFormatSVG {
+			return qrOptions{}, false
+		}
+	}
+	if raw := query.Get("size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return qrOptions{}, false
+		}
+		opts.size = size
+	}
+	if raw := query.Get("ec"); raw != "" {
+		level, err := qrcode.ParseLevel(raw)
+		if err != nil {
+			return qrOptions{}, false
+		}
+		opts.level = level
+	}
+	if raw := query.Get("margin"); raw != "" {
+		margin, err := strconv.Atoi(raw)
+		if err != nil || margin < 0 || margin > qrMaxMargin {
+			return qrOptions{}, false
+		}
+		opts.margin = margin
+	}
+	return opts, true
+}
+
+// renderQR кодирует text в QR код и отрисовывает его в формате opts.format.
+// Возвращает изображение и его Content-Type.
+func renderQR(text string, opts qrOptions) ([]byte, string, error) {
+	code, err := qrcode.Encode([]byte(text), opts.level)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.format == qrFormatSVG {
+		image, err := code.SVG(opts.size, opts.margin)
+		return image, "image/svg+xml", err
+	}
+	image, err := code.PNG(opts.size, opts.margin)
+	return image, "image/png", err
+}
+
+// qrDataURI возвращает QR код короткого URL с параметрами по умолчанию в виде data URI PNG
+func qrDataURI(shortURL string) (string, error) {
+	image, contentType, err := renderQR(shortURL, qrOptions{
+		format: qrFormatPNG, size: qrDefaultSize, level: qrDefaultLevel, margin: qrDefaultMargin,
+	})
+	if err != nil {
+		return "", err
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(image), nil
+}
+
+// HandleQRCode обрабатывает GET запрос QR кода короткой ссылки /api/qr/{id}.
+// QR код содержит полный короткий URL (BaseURL/{id}) и отдается в PNG или SVG.
+// Для несуществующей ссылки возвращается 404, для удаленной или истекшей — 410.
+func (h *Handler) HandleQRCode(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "id")
+	if shortID == "" {
+		http.Error(w, "Empty shortID", http.StatusBadRequest)
+		return
+	}
+
+	opts, ok := parseQROptions(r)
+	if !ok {
+		http.Error(w, invalidQRMessage, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.service.ResolveRedirect(r.Context(), shortID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrURLNotFound):
+			http.Error(w, urlNotFoundMessage, http.StatusNotFound)
+		case errors.Is(err, storage.ErrURLDeleted):
+			http.Error(w, "URL is deleted", http.StatusGone)
+		case errors.Is(err, storage.ErrURLExpired):
+			http.Error(w, "URL has expired", http.StatusGone)
+		default:
+			h.logger.Error("Error getting URL for QR code", zap.String("short_id", shortID), zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	image, contentType, err := renderQR(h.cfg.BaseURL+"/"+shortID, opts)
+	if err != nil {
+		if errors.Is(err, qrcode.ErrSizeTooSmall) {
+			http.Error(w, "QR code does not fit the requested size", http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("Error rendering QR code", zap.String("short_id", shortID), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", qrCacheControl)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(image); err != nil {
+		h.logger.Error("Error writing QR code", zap.Error(err))
+	}
+}
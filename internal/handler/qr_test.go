@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHandleQRCode(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedType   string
+		expectedSize   int
+	}{
+		{name: "Default PNG", target: "/api/qr/abc123", expectedStatus: http.StatusOK, expectedType: "image/png", expectedSize: 256},
+		{name: "PNG with parameters", target: "/api/qr/abc123?size=512&ec=h&margin=0", expectedStatus: http.StatusOK, expectedType: "image/png", expectedSize: 512},
+		{name: "SVG", target: "/api/qr/abc123?format=svg&size=128", expectedStatus: http.StatusOK, expectedType: "image/svg+xml"},
+		{name: "Unknown format", target: "/api/qr/abc123?format=gif", expectedStatus: http.StatusBadRequest},
+		{name: "Size too large", target: "/api/qr/abc123?size=4096", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid level", target: "/api/qr/abc123?ec=X", expectedStatus: http.StatusBadRequest},
+		{name: "Negative margin", target: "/api/qr/abc123?margin=-1", expectedStatus: http.StatusBadRequest},
+		{name: "Code does not fit", target: "/api/qr/abc123?size=64&margin=16&ec=H", expectedStatus: http.StatusBadRequest},
+		{name: "Missing link", target: "/api/qr/missing", expectedStatus: http.StatusNotFound},
+		{name: "Deleted link", target: "/api/qr/gone", expectedStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				resolveRedirectFunc: func(ctx context.Context, shortURL string) (models.Redirect, error) {
+					switch shortURL {
+					case "abc123":
+						return models.Redirect{OriginalURL: "https://example.com"}, nil
+					case "gone":
+						return models.Redirect{}, storage.ErrURLDeleted
+					default:
+						return models.Redirect{}, storage.ErrURLNotFound
+					}
+				},
+			}
+			h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+			r := chi.NewRouter()
+			r.Get("/api/qr/{id}", h.HandleQRCode)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Empty(t, mockService.clicks)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			assert.Equal(t, qrCacheControl, w.Header().Get("Cache-Control"))
+			if tt.expectedType == "image/png" {
+				img, err := png.Decode(w.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedSize, img.Bounds().Dx())
+			} else {
+				assert.Contains(t, w.Body.String(), `<svg xmlns="http://www.w3.org/2000/svg"`)
+			}
+		})
+	}
+}
+
+func TestHandleShortenURLWithQR(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectQR       bool
+	}{
+		{name: "QR requested", body: `{"url":"https://example.com","qr":true}`, expectedStatus: http.StatusCreated, expectQR: true},
+		{name: "QR for existing URL", body: `{"url":"https://example.com","qr":true}`, serviceErr: storage.ErrOriginalURLConflict, expectedStatus: http.StatusConflict, expectQR: true},
+		{name: "QR not requested", body: `{"url":"https://example.com"}`, expectedStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				createWithOptionsFunc: func(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error) {
+					return "abc123", tt.serviceErr
+				},
+			}
+			h := NewHandler(mockService, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.HandleShortenURL(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var resp ShortenResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "http://localhost:8080/abc123", resp.Result)
+			if !tt.expectQR {
+				assert.Empty(t, resp.QR)
+				return
+			}
+
+			encoded, ok := strings.CutPrefix(resp.QR, "data:image/png;base64,")
+			require.True(t, ok, resp.QR)
+			image, err := base64.StdEncoding.DecodeString(encoded)
+			require.NoError(t, err)
+			_, err = png.Decode(bytes.NewReader(image))
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+// Default — реестр метрик приложения, отдаваемый по /metrics
+var Default = NewRegistry()
+
+// Результаты перенаправления для RedirectsTotal
+const (
+	RedirectHit     = "hit"     // Ссылка найдена, выполнен редирект
+	RedirectMiss    = "miss"    // Ссылка не найдена
+	RedirectGone    = "gone"    // Ссылка удалена
+	RedirectExpired = "expired" // Срок действия ссылки истек
+)
+
+// Метрики приложения
+var (
+	// HTTPRequestsTotal — количество HTTP запросов по методу, шаблону маршрута chi и статусу ответа
+	HTTPRequestsTotal = Default.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests by method, route pattern and status.",
+		"method", "route", "status")
+
+	// HTTPRequestDuration — длительность обработки HTTP запросов
+	HTTPRequestDuration = Default.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds by method, route pattern and status.",
+		nil, "method", "route", "status")
+
+	// RedirectsTotal — количество обращений к коротким ссылкам по результату
+	RedirectsTotal = Default.NewCounterVec("shortener_redirects_total",
+		"Total number of short URL redirects by result (hit, miss, gone, expired).",
+		"result")
+
+	// StorageOperationDuration — длительность операций хранилища по типу хранилища и методу
+	StorageOperationDuration = Default.NewHistogramVec("storage_operation_duration_seconds",
+		"Storage operation latency in seconds by backend and method.",
+		nil, "backend", "method")
+
+	// StorageOperationErrors — количество операций хранилища, завершившихся ошибкой
+	StorageOperationErrors = Default.NewCounterVec("storage_operation_errors_total",
+		"Total number of failed storage operations by backend and method.",
+		"backend", "method")
+
+	// DeletionQueueDepth — количество задач удаления, ожидающих воркера
+	DeletionQueueDepth = Default.NewGauge("deletion_queue_depth",
+		"Number of delete jobs waiting for a deletion worker.")
+
+	// RateLimitedTotal — количество запросов, отклоненных ограничителем частоты, по маршруту
+	RateLimitedTotal = Default.NewCounterVec("rate_limited_requests_total",
+		"Total number of requests rejected by the rate limiter by route (create, batch, redirect, delete).",
+		"route")
+)
@@ -0,0 +1,355 @@
+// Package metrics реализует сбор метрик приложения и их публикацию
+// в текстовом формате Prometheus (exposition format 0.0.4).
+// Поддерживаются счетчики, гистограммы и gauge с метками.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets — границы гистограммы по умолчанию (в секундах), совпадают с клиентом Prometheus
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// contentType — тип содержимого текстового формата Prometheus
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelSeparator разделяет значения меток в ключе серии
+const labelSeparator = "\xff"
+
+// collector — метрика, которую можно записать в текстовом формате
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry хранит зарегистрированные метрики и отдает их по HTTP.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []collector
+	names      map[string]struct{}
+}
+
+// NewRegistry создает пустой реестр метрик.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+// register добавляет метрику в реестр; повторная регистрация имени — ошибка программиста
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.names[name]; exists {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.names[name] = struct{}{}
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler возвращает HTTP обработчик, отдающий все метрики реестра.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		bw := bufio.NewWriter(w)
+		r.mu.RLock()
+		for _, c := range r.collectors {
+			c.write(bw)
+		}
+		r.mu.RUnlock()
+		bw.Flush()
+	})
+}
+
+// desc описывает имя, справку и метки метрики
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// writeHeader пишет строки HELP и TYPE метрики
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// key формирует ключ серии по значениям меток
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// formatLabels форматирует метки серии; extra добавляется последней парой (например, le)
+func (d desc) formatLabels(values []string, extraName, extraValue string) string {
+	if len(d.labels) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, label, escapeLabel(values[i]))
+	}
+	if extraName != "" {
+		if len(d.labels) > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, extraName, extraValue)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// atomicFloat — float64 с атомарными операциями
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// CounterVec — монотонно возрастающий счетчик с метками.
+type CounterVec struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  atomicFloat
+}
+
+// NewCounterVec регистрирует счетчик с метками labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc увеличивает счетчик серии с указанными значениями меток на 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает счетчик серии на v (v должно быть неотрицательным).
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.get(labelValues).value.add(v)
+}
+
+// Value возвращает текущее значение серии (для тестов и диагностики).
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	return c.get(labelValues).value.load()
+}
+
+func (c *CounterVec) get(values []string) *counterSeries {
+	key := c.key(values)
+	c.mu.RLock()
+	s, ok := c.series[key]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok = c.series[key]; !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	return s
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(s.values, "", ""), formatFloat(s.value.load()))
+	}
+}
+
+// Gauge — значение без меток, которое может увеличиваться и уменьшаться.
+type Gauge struct {
+	desc
+	value atomicFloat
+}
+
+// NewGauge регистрирует gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name: name, help: help, kind: "gauge"}}
+	r.register(name, g)
+	return g
+}
+
+// Set устанавливает значение gauge.
+func (g *Gauge) Set(v float64) { g.value.set(v) }
+
+// Add изменяет значение gauge на v (v может быть отрицательным).
+func (g *Gauge) Add(v float64) { g.value.add(v) }
+
+// Value возвращает текущее значение gauge.
+func (g *Gauge) Value() float64 { return g.value.load() }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value.load()))
+}
+
+// HistogramVec — гистограмма наблюдений с метками.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.RWMutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []atomic.Uint64 // Количество наблюдений по каждой границе (не накопительное)
+	count  atomic.Uint64
+	sum    atomicFloat
+}
+
+// NewHistogramVec регистрирует гистограмму с границами buckets (nil — DefBuckets) и метками labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe добавляет наблюдение v в серию с указанными значениями меток.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues)
+	// Наблюдение попадает в первую границу, не меньшую v; большие значения учитываются только в +Inf
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i].Add(1)
+	}
+	s.count.Add(1)
+	s.sum.add(v)
+}
+
+// Count возвращает количество наблюдений серии (для тестов и диагностики).
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	return h.get(labelValues).count.Load()
+}
+
+func (h *HistogramVec) get(values []string) *histogramSeries {
+	key := h.key(values)
+	h.mu.RLock()
+	s, ok := h.series[key]
+	h.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok = h.series[key]; !ok {
+		s = &histogramSeries{
+			values: append([]string(nil), values...),
+			counts: make([]atomic.Uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	return s
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.values, "le", formatFloat(bound)), cumulative)
+		}
+		count := s.count.Load()
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.values, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(s.values, "", ""), formatFloat(s.sum.load()))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(s.values, "", ""), count)
+	}
+}
+
+// sortedKeys возвращает ключи серий в детерминированном порядке
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat форматирует значение по правилам текстового формата Prometheus
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp экранирует текст справки
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// labelEscaper экранирует значение метки. Текстовый формат допускает только
+// последовательности \\, \" и \n, поэтому %q, экранирующий также табуляцию
+// и непечатаемые символы, здесь не подходит.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel экранирует значение метки и заменяет невалидный UTF-8
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(strings.ToValidUTF8(s, "�"))
+}
@@ -0,0 +1,369 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("test_requests_total", "Total requests.", "method", "path")
+	latency := reg.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	depth := reg.NewGauge("test_depth", "Queue depth.")
+
+	requests.Inc("GET", "/b")
+	requests.Add(2, "GET", "/a")
+	requests.Inc("POST", `/"quoted"`)
+	latency.Observe(0.05, "save")
+	latency.Observe(0.5, "save")
+	latency.Observe(5, "save")
+	depth.Set(3)
+	depth.Add(-1)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+
+	expected := `# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a"} 2
+test_requests_total{method="GET",path="/b"} 1
+test_requests_total{method="POST",path="/\"quoted\""} 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{op="save",le="0.1"} 1
+test_latency_seconds_bucket{op="save",le="1"} 2
+test_latency_seconds_bucket{op="save",le="+Inf"} 3
+test_latency_seconds_sum{op="save"} 5.55
+test_latency_seconds_count{op="save"} 3
+# HELP test_depth Queue depth.
+# TYPE test_depth gauge
+test_depth 2
+`
+	assert.Equal(t, expected, string(body))
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(reg *Registry)
+	}{
+		{
+			name: "Duplicate metric name",
+			fn: func(reg *Registry) {
+				reg.NewGauge("dup", "first")
+				reg.NewGauge("dup", "second")
+			},
+		},
+		{
+			name: "Wrong label count",
+			fn: func(reg *Registry) {
+				reg.NewCounterVec("c", "counter", "a", "b").Inc("only-one")
+			},
+		},
+		{
+			name: "Negative counter increment",
+			fn: func(reg *Registry) {
+				reg.NewCounterVec("c", "counter").Add(-1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Panics(t, func() { tt.fn(NewRegistry()) })
+		})
+	}
+}
+
+func TestRegistryExpositionConformance(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("conf_requests_total", "Requests with \\ backslash\nand newline.", "path")
+	latency := reg.NewHistogramVec("conf_latency_seconds", "Latency.", []float64{0.001, 0.5, 2}, "op")
+	reg.NewGauge("conf_depth", "Depth.").Set(math.Inf(-1))
+
+	// Значения меток со всеми символами, которые нужно экранировать или нельзя экранировать
+	for _, value := range []string{`"quoted"`, `back\slash`, "new\nline", "tab\there", "ctrl\x01", "юникод", "bad\xffutf8", ""} {
+		requests.Inc(value)
+	}
+	latency.Observe(0.0005, "a")
+	latency.Observe(1, "a")
+	latency.Observe(3, "b")
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	samples := lintExposition(t, rec.Body.String())
+
+	// Табуляция и управляющие символы выводятся как есть, а не через \t и \x
+	assert.Equal(t, 1.0, samples["conf_requests_total{path=\"tab\there\"}"])
+	assert.Equal(t, 1.0, samples["conf_requests_total{path=\"ctrl\x01\"}"])
+	assert.Equal(t, 1.0, samples[`conf_requests_total{path="new\nline"}`])
+	assert.Equal(t, 1.0, samples[`conf_requests_total{path="back\\slash"}`])
+	assert.Equal(t, 1.0, samples[`conf_latency_seconds_bucket{op="a",le="0.001"}`])
+	assert.True(t, math.IsInf(samples["conf_depth"], -1))
+
+	t.Run("application registry", func(t *testing.T) {
+		HTTPRequestsTotal.Inc(http.MethodGet, "/{id}", "307")
+		HTTPRequestDuration.Observe(0.01, http.MethodGet, "/{id}", "307")
+		StorageOperationErrors.Inc("file", "Save")
+
+		rec := httptest.NewRecorder()
+		Default.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		lintExposition(t, rec.Body.String())
+	})
+}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// lintExposition проверяет вывод по правилам текстового формата Prometheus 0.0.4
+// в объеме проверок promtool check metrics и возвращает значения серий по строке
+// серии, как она записана в выводе
+func lintExposition(t *testing.T, body string) map[string]float64 {
+	t.Helper()
+
+	samples := make(map[string]float64)
+	types := make(map[string]string)
+	helps := make(map[string]bool)
+	finished := make(map[string]bool) // Семейства, серии которых уже закончились
+	var family string                 // Текущее семейство
+	type histogram struct {
+		lastLe, lastCount, inf float64
+		hasInf, hasSum         bool
+		count                  float64
+		hasCount               bool
+	}
+	histograms := make(map[string]*histogram) // По семейству и меткам без le
+
+	startFamily := func(name string, line int) {
+		if name == family {
+			return
+		}
+		require.False(t, finished[name], "line %d: family %s is not contiguous", line, name)
+		if family != "" {
+			finished[family] = true
+		}
+		family = name
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	require.True(t, strings.HasSuffix(body, "\n"), "exposition must end with a newline")
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		require.True(t, utf8.ValidString(line), "line %d: invalid UTF-8", lineNo)
+		require.NotEmpty(t, line, "line %d: empty line", lineNo)
+
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line[2:], " ", 3)
+			require.GreaterOrEqual(t, len(fields), 2, "line %d: malformed comment", lineNo)
+			name := fields[1]
+			require.Regexp(t, metricNameRe, name, "line %d", lineNo)
+			startFamily(name, lineNo)
+			switch fields[0] {
+			case "HELP":
+				require.False(t, helps[name], "line %d: duplicate HELP for %s", lineNo, name)
+				helps[name] = true
+				if len(fields) == 3 {
+					checkEscapes(t, fields[2], "n", lineNo)
+				}
+			case "TYPE":
+				require.Len(t, fields, 3, "line %d", lineNo)
+				require.Empty(t, types[name], "line %d: duplicate TYPE for %s", lineNo, name)
+				require.Contains(t, []string{"counter", "gauge", "histogram", "summary", "untyped"}, fields[2], "line %d", lineNo)
+				require.False(t, hasSamples(samples, name), "line %d: TYPE after samples", lineNo)
+				types[name] = fields[2]
+				if fields[2] == "counter" {
+					assert.True(t, strings.HasSuffix(name, "_total"), "line %d: counter %s should end with _total", lineNo, name)
+				}
+			}
+			continue
+		}
+
+		series, valueText, found := strings.Cut(line, " ")
+		require.True(t, found, "line %d: sample without value", lineNo)
+		require.NotContains(t, valueText, " ", "line %d: timestamps are not used", lineNo)
+		value := parseSampleValue(t, valueText, lineNo)
+
+		name, labels := series, map[string]string{}
+		var labelOrder []string
+		if i := strings.IndexByte(series, '{'); i >= 0 {
+			name = series[:i]
+			labels, labelOrder = parseLabels(t, series[i:], lineNo)
+		}
+		require.Regexp(t, metricNameRe, name, "line %d", lineNo)
+		_, duplicate := samples[series]
+		require.False(t, duplicate, "line %d: duplicate series %s", lineNo, series)
+		samples[series] = value
+
+		base := name
+		if types[family] == "histogram" {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if strings.TrimSuffix(name, suffix) == family {
+					base = family
+				}
+			}
+		}
+		require.Equal(t, family, base, "line %d: sample %s outside of its family", lineNo, name)
+		require.NotEmpty(t, types[family], "line %d: sample before TYPE", lineNo)
+
+		if types[family] == "counter" {
+			assert.GreaterOrEqual(t, value, 0.0, "line %d: negative counter", lineNo)
+		}
+		if types[family] != "histogram" {
+			continue
+		}
+
+		var keyParts []string
+		for _, label := range labelOrder {
+			if label != "le" {
+				keyParts = append(keyParts, label+"="+labels[label])
+			}
+		}
+		key := family + "{" + strings.Join(keyParts, ",") + "}"
+		h := histograms[key]
+		if h == nil {
+			h = &histogram{lastLe: math.Inf(-1)}
+			histograms[key] = h
+		}
+		switch name {
+		case family + "_bucket":
+			le, ok := labels["le"]
+			require.True(t, ok, "line %d: bucket without le", lineNo)
+			require.Equal(t, "le", labelOrder[len(labelOrder)-1], "line %d: le must be the last label", lineNo)
+			bound := parseSampleValue(t, le, lineNo)
+			require.Greater(t, bound, h.lastLe, "line %d: buckets must be sorted by le", lineNo)
+			require.GreaterOrEqual(t, value, h.lastCount, "line %d: buckets must be cumulative", lineNo)
+			h.lastLe, h.lastCount = bound, value
+			if math.IsInf(bound, 1) {
+				h.inf, h.hasInf = value, true
+			}
+		case family + "_sum":
+			h.hasSum = true
+		case family + "_count":
+			h.count, h.hasCount = value, true
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	for key, h := range histograms {
+		assert.True(t, h.hasInf, "%s: missing +Inf bucket", key)
+		assert.True(t, h.hasSum, "%s: missing _sum", key)
+		if assert.True(t, h.hasCount, "%s: missing _count", key) {
+			assert.Equal(t, h.inf, h.count, "%s: _count must equal the +Inf bucket", key)
+		}
+	}
+	for name := range types {
+		assert.True(t, helps[name], "%s: missing HELP", name)
+	}
+	return samples
+}
+
+// hasSamples сообщает, разобраны ли уже серии семейства name
+func hasSamples(samples map[string]float64, name string) bool {
+	for series := range samples {
+		if series == name || strings.HasPrefix(series, name+"{") || strings.HasPrefix(series, name+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSampleValue разбирает значение серии: число Go или +Inf, -Inf, NaN
+func parseSampleValue(t *testing.T, text string, lineNo int) float64 {
+	t.Helper()
+	switch text {
+	case "+Inf":
+		return math.Inf(1)
+	case "-Inf":
+		return math.Inf(-1)
+	case "NaN":
+		return math.NaN()
+	}
+	require.NotContains(t, []string{"Inf", "inf", "+inf", "-inf", "nan"}, text, "line %d", lineNo)
+	value, err := strconv.ParseFloat(text, 64)
+	require.NoError(t, err, "line %d: invalid value %q", lineNo, text)
+	return value
+}
+
+// parseLabels разбирает набор меток {name="value",...}; в значениях допустимы
+// только последовательности \\, \" и \n
+func parseLabels(t *testing.T, text string, lineNo int) (map[string]string, []string) {
+	t.Helper()
+	require.True(t, strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}"), "line %d: malformed labels", lineNo)
+	rest := text[1 : len(text)-1]
+
+	labels := make(map[string]string)
+	var order []string
+	for rest != "" {
+		name, after, found := strings.Cut(rest, "=")
+		require.True(t, found, "line %d: label without value", lineNo)
+		require.Regexp(t, labelNameRe, name, "line %d", lineNo)
+		require.False(t, strings.HasPrefix(name, "__"), "line %d: reserved label %s", lineNo, name)
+		require.True(t, strings.HasPrefix(after, `"`), "line %d: unquoted label value", lineNo)
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(after) && after[i] != '"'; i++ {
+			c := after[i]
+			require.NotEqual(t, byte('\n'), c, "line %d: raw newline in label value", lineNo)
+			if c == '\\' {
+				i++
+				require.Less(t, i, len(after), "line %d: dangling escape", lineNo)
+				switch after[i] {
+				case '\\', '"':
+					value.WriteByte(after[i])
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					require.Failf(t, "invalid escape", "line %d: \\%c in label value", lineNo, after[i])
+				}
+				continue
+			}
+			value.WriteByte(c)
+		}
+		require.Less(t, i, len(after), "line %d: unterminated label value", lineNo)
+
+		_, duplicate := labels[name]
+		require.False(t, duplicate, "line %d: duplicate label %s", lineNo, name)
+		labels[name] = value.String()
+		order = append(order, name)
+
+		rest = after[i+1:]
+		if rest != "" {
+			require.True(t, strings.HasPrefix(rest, ","), "line %d: labels must be separated by commas", lineNo)
+			rest = rest[1:]
+		}
+	}
+	return labels, order
+}
+
+// checkEscapes проверяет, что в text встречаются только последовательности из allowed
+func checkEscapes(t *testing.T, text, allowed string, lineNo int) {
+	t.Helper()
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			continue
+		}
+		i++
+		require.Less(t, i, len(text), "line %d: dangling escape", lineNo)
+		require.True(t, text[i] == '\\' || strings.ContainsRune(allowed, rune(text[i])),
+			fmt.Sprintf("line %d: invalid escape \\%c", lineNo, text[i]))
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+)
+
+// AuthContextKey — тип ключей сведений об аутентификации в контексте
+type AuthContextKey string
+
+// Ключи сведений об аутентификации запроса
+const (
+	// ContextKeyAuthMethod — способ аутентификации запроса (AuthMethod*)
+	ContextKeyAuthMethod AuthContextKey = "authMethod"
+	// ContextKeyScopes — области действия API ключа, которым аутентифицирован запрос
+	ContextKeyScopes AuthContextKey = "scopes"
+	// ContextKeyAPIKeyID — идентификатор API ключа, которым аутентифицирован запрос
+	ContextKeyAPIKeyID AuthContextKey = "apiKeyID"
+)
+
+// Способы аутентификации запроса
+const (
+	AuthMethodCookie  = "cookie"   // Действующая подписанная кука
+	AuthMethodNewUser = "new_user" // Кука отсутствовала или неверна, создан новый пользователь
+	AuthMethodAPIKey  = "api_key"  // API ключ в заголовке Authorization
+)
+
+// AuthMethod возвращает способ аутентификации запроса или пустую строку
+func AuthMethod(ctx context.Context) string {
+	method, _ := ctx.Value(ContextKeyAuthMethod).(string)
+	return method
+}
+
+// HasScope сообщает, разрешено ли запросу действие scope.
+// Ограничения действуют только для запросов с API ключом:
+// пользователю, аутентифицированному кукой, доступны все действия.
+func HasScope(ctx context.Context, scope string) bool {
+	if AuthMethod(ctx) != AuthMethodAPIKey {
+		return true
+	}
+	scopes, _ := ctx.Value(ContextKeyScopes).([]string)
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// BearerToken извлекает токен из значения заголовка Authorization вида "Bearer <token>"
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
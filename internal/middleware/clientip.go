@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver определяет IP адрес клиента с учетом доверенных прокси.
+// Заголовок X-Forwarded-For учитывается, только если запрос пришел от доверенного прокси:
+// адреса в заголовке просматриваются справа налево, и первый недоверенный адрес
+// считается адресом клиента. Иначе используется адрес соединения.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver создает ClientIPResolver по списку доверенных прокси
+// через запятую; элементы — IP адреса или подсети в нотации CIDR.
+func NewClientIPResolver(trustedProxies string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, item := range strings.Split(trustedProxies, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: expected IP or CIDR", item)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+// ClientIP возвращает IP адрес клиента запроса r
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !c.isTrusted(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Неразборчивый адрес мог добавить кто угодно: дальше цепочке не доверяем
+			return remote
+		}
+		if !c.isTrusted(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+// isTrusted сообщает, входит ли адрес в список доверенных прокси
+func (c *ClientIPResolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expectedIP   string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", expectedIP: "203.0.113.7"},
+		{name: "untrusted peer header is ignored", remoteAddr: "203.0.113.7:5000", forwardedFor: []string{"1.2.3.4"}, expectedIP: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"1.2.3.4, 198.51.100.1, 192.168.1.1"}, expectedIP: "198.51.100.1"},
+		{name: "multiple headers", remoteAddr: "192.168.1.1:5000", forwardedFor: []string{"1.2.3.4", "198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "spoofed garbage stops the walk", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"1.2.3.4, bogus"}, expectedIP: "10.1.2.3"},
+		{name: "only trusted hops", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"10.9.9.9"}, expectedIP: "10.9.9.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.expectedIP, resolver.ClientIP(req))
+		})
+	}
+
+	_, err = NewClientIPResolver("not-an-ip")
+	assert.Error(t, err)
+}
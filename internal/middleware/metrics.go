@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute — значение метки route для запросов, не совпавших ни с одним маршрутом
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware учитывает количество и длительность HTTP запросов.
+// В метку route попадает шаблон маршрута chi (например, /{id}), а не сам путь,
+// чтобы количество серий не зависело от количества коротких ссылок.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		labels := []string{r.Method, routePattern(r), strconv.Itoa(responseStatus(ww))}
+		metrics.HTTPRequestsTotal.Inc(labels...)
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// routePattern возвращает шаблон маршрута chi, совпавшего с запросом.
+// Шаблон известен только после того, как chi выполнил сопоставление,
+// поэтому функция вызывается после обработки запроса.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return unmatchedRoute
+}
+
+// responseStatus возвращает статус ответа; обработчик, не вызвавший WriteHeader, отвечает 200
+func responseStatus(ww chimiddleware.WrapResponseWriter) int {
+	if status := ww.Status(); status != 0 {
+		return status
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		route  string
+		status string
+	}{
+		{name: "Route pattern instead of path", path: "/abc123", route: "/{id}", status: "307"},
+		{name: "Implicit 200 status", path: "/ok", route: "/ok", status: "200"},
+		{name: "Unmatched route", path: "/no/such/route", route: unmatchedRoute, status: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := metrics.HTTPRequestsTotal.Value(http.MethodGet, tt.route, tt.status)
+			countBefore := metrics.HTTPRequestDuration.Count(http.MethodGet, tt.route, tt.status)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, before+1, metrics.HTTPRequestsTotal.Value(http.MethodGet, tt.route, tt.status))
+			assert.Equal(t, countBefore+1, metrics.HTTPRequestDuration.Count(http.MethodGet, tt.route, tt.status))
+		})
+	}
+}
@@ -21,7 +21,9 @@ func GenerateUserID() string {
 	return uuid.New().String()
 }
 
-// SignUserID подписывает ID пользователя и возвращает строку "userID.signature"
+// SignUserID подписывает ID пользователя и возвращает строку "userID.signature".
+//
+// Deprecated: подпись не ограничена по времени; новые сессии выпускает Sessions.
 func SignUserID(userID string, secretKey string) string {
 	h := hmac.New(sha256.New, []byte(secretKey))
 	h.Write([]byte(userID))
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+)
+
+// Ошибки проверки токена сессии
+var (
+	// ErrInvalidSession возвращается, если токен поврежден или подпись неверна
+	ErrInvalidSession = errors.New("invalid session token")
+	// ErrSessionExpired возвращается, если срок действия токена истек
+	ErrSessionExpired = errors.New("session token expired")
+	// ErrUnknownSessionKey возвращается, если токен подписан ключом, которого нет в наборе
+	ErrUnknownSessionKey = errors.New("unknown session key")
+)
+
+// DefaultSessionKeyID — идентификатор ключа, построенного из SecretKey,
+// когда набор ключей сессий не задан
+const DefaultSessionKeyID = "default"
+
+// LegacySessionKeyID — идентификатор ключа в сессиях, восстановленных из кук
+// старого формата "userID.hmac" (см. SignUserID)
+const LegacySessionKeyID = "legacy"
+
+// DefaultSessionTTL — время жизни сессии, если оно не задано в конфигурации
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// Keyring — набор ключей подписи сессий. Новые токены подписываются активным ключом,
+// а проверяются любым ключом набора: это позволяет заменить ключ, не завершая
+// сессии, подписанные предыдущим.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// ParseKeyring разбирает набор ключей вида "kid1:secret1,kid2:secret2".
+// Первый ключ списка становится активным. Пустая строка задает единственный
+// ключ DefaultSessionKeyID с секретом fallbackSecret, а если и он пуст — со случайным
+// секретом, действующим до перезапуска процесса.
+func ParseKeyring(spec, fallbackSecret string) (*Keyring, error) {
+	if strings.TrimSpace(spec) == "" {
+		if fallbackSecret == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("session keys: generating secret: %w", err)
+			}
+			fallbackSecret = string(secret)
+		}
+		return &Keyring{
+			active: DefaultSessionKeyID,
+			keys:   map[string][]byte{DefaultSessionKeyID: []byte(fallbackSecret)},
+		}, nil
+	}
+
+	kr := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range strings.Split(spec, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("session keys: expected kid:secret, got %q", item)
+		}
+		if _, exists := kr.keys[kid]; exists {
+			return nil, fmt.Errorf("session keys: duplicate key id %q", kid)
+		}
+		kr.keys[kid] = []byte(secret)
+		if kr.active == "" {
+			kr.active = kid
+		}
+	}
+	return kr, nil
+}
+
+// ActiveKeyID возвращает идентификатор ключа, которым подписываются новые токены
+func (kr *Keyring) ActiveKeyID() string {
+	return kr.active
+}
+
+// Session — проверенная сессия пользователя
+type Session struct {
+	UserID    string
+	KeyID     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Sessions выпускает и проверяет токены сессий пользователей в формате JWT (HS256)
+// с полями sub (идентификатор пользователя), iat, exp и kid в заголовке.
+type Sessions struct {
+	keyring      *Keyring
+	ttl          time.Duration
+	legacySecret string // Секрет кук старого формата; пусто — такие куки отклоняются
+	now          func() time.Time
+}
+
+// NewSessions создает Sessions; ttl <= 0 заменяется на DefaultSessionTTL
+func NewSessions(keyring *Keyring, ttl time.Duration) *Sessions {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &Sessions{keyring: keyring, ttl: ttl, now: time.Now}
+}
+
+// NewSessionsFromConfig создает Sessions по параметрам SessionKeys, SecretKey, SessionTTL
+// и SessionAcceptLegacy конфигурации
+func NewSessionsFromConfig(cfg *config.Config) (*Sessions, error) {
+	keyring, err := ParseKeyring(cfg.SessionKeys, cfg.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	sessions := NewSessions(keyring, cfg.SessionTTL)
+	if cfg.SessionAcceptLegacy {
+		sessions.legacySecret = cfg.SecretKey
+	}
+	return sessions, nil
+}
+
+// TTL возвращает время жизни выпускаемых токенов
+func (s *Sessions) TTL() time.Duration {
+	return s.ttl
+}
+
+// jwtHeader — заголовок JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// sessionClaims — полезная нагрузка JWT сессии
+type sessionClaims struct {
+	Sub string `json:"sub"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+// Issue выпускает токен сессии пользователя userID, подписанный активным ключом
+func (s *Sessions) Issue(userID string) (string, error) {
+	now := s.now()
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT", Kid: s.keyring.active})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(sessionClaims{Sub: userID, Iat: now.Unix(), Exp: now.Add(s.ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signingInput + "." + sign(signingInput, s.keyring.keys[s.keyring.active]), nil
+}
+
+// Parse проверяет подпись и срок действия токена и возвращает сессию.
+// Если разрешены куки старого формата, принимает и их: такая сессия имеет
+// ключ LegacySessionKeyID и всегда требует перевыпуска.
+func (s *Sessions) Parse(token string) (Session, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		if s.legacySecret != "" {
+			if userID, valid := ValidateUserID(token, s.legacySecret); valid && userID != "" {
+				return Session{UserID: userID, KeyID: LegacySessionKeyID}, nil
+			}
+		}
+		return Session{}, ErrInvalidSession
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Session{}, ErrInvalidSession
+	}
+	secret, ok := s.keyring.keys[header.Kid]
+	if !ok {
+		return Session{}, ErrUnknownSessionKey
+	}
+	expected := sign(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return Session{}, ErrInvalidSession
+	}
+
+	var claims sessionClaims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims.Sub == "" || claims.Exp == 0 {
+		return Session{}, ErrInvalidSession
+	}
+	session := Session{
+		UserID:    claims.Sub,
+		KeyID:     header.Kid,
+		IssuedAt:  time.Unix(claims.Iat, 0),
+		ExpiresAt: time.Unix(claims.Exp, 0),
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		return Session{}, ErrSessionExpired
+	}
+	return session, nil
+}
+
+// NeedsRenewal сообщает, нужно ли перевыпустить токен: он подписан неактивным
+// ключом (в том числе имеет старый формат) или прошла половина срока его действия
+func (s *Sessions) NeedsRenewal(session Session) bool {
+	if session.KeyID != s.keyring.active {
+		return true
+	}
+	return s.now().After(session.ExpiresAt.Add(-s.ttl / 2))
+}
+
+// ParseSameSite преобразует значение атрибута SameSite из конфигурации (lax, strict, none).
+// Пустая строка соответствует lax.
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("invalid SameSite value %q: expected lax, strict or none", value)
+	}
+}
+
+// sign возвращает подпись HMAC-SHA256 в кодировке base64url
+func sign(input string, secret []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(input))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// decodeSegment декодирует сегмент JWT в v
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
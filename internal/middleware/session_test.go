@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		fallback   string
+		wantActive string
+		wantErr    bool
+	}{
+		{name: "fallback secret", spec: "", fallback: "secret", wantActive: DefaultSessionKeyID},
+		{name: "ephemeral secret", spec: " ", wantActive: DefaultSessionKeyID},
+		{name: "first key is active", spec: "k2:new, k1:old", fallback: "secret", wantActive: "k2"},
+		{name: "missing secret", spec: "k1:", wantErr: true},
+		{name: "missing separator", spec: "k1", wantErr: true},
+		{name: "duplicate key id", spec: "k1:a,k1:b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := ParseKeyring(tt.spec, tt.fallback)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantActive, kr.ActiveKeyID())
+		})
+	}
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	newSessions := func(spec string) *Sessions {
+		kr, err := ParseKeyring(spec, "")
+		require.NoError(t, err)
+		s := NewSessions(kr, time.Hour)
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	old := newSessions("k1:old")
+	rotated := newSessions("k2:new,k1:old")
+
+	token, err := old.Issue("user-1")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(token, "."), 3)
+
+	// После ротации токен старого ключа действителен, но требует перевыпуска
+	session, err := rotated.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", session.UserID)
+	assert.Equal(t, "k1", session.KeyID)
+	assert.Equal(t, now, session.IssuedAt.UTC())
+	assert.Equal(t, now.Add(time.Hour), session.ExpiresAt.UTC())
+	assert.True(t, rotated.NeedsRenewal(session))
+	assert.False(t, old.NeedsRenewal(session))
+
+	// Удаленный из набора ключ больше не принимается
+	_, err = newSessions("k2:new").Parse(token)
+	assert.ErrorIs(t, err, ErrUnknownSessionKey)
+
+	// Подделанная подпись и измененная нагрузка
+	_, err = newSessions("k1:other").Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	parts := strings.Split(token, ".")
+	_, err = old.Parse(parts[0] + "." + parts[0] + "." + parts[2])
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	// Перевыпуск после половины срока и истечение срока
+	now = now.Add(31 * time.Minute)
+	session, err = old.Parse(token)
+	require.NoError(t, err)
+	assert.True(t, old.NeedsRenewal(session))
+	now = now.Add(30 * time.Minute)
+	_, err = old.Parse(token)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
+
+func TestSessionsLegacy(t *testing.T) {
+	cfg := &config.Config{SecretKey: "secret", SessionAcceptLegacy: true}
+	sessions, err := NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+
+	session, err := sessions.Parse(SignUserID("user-1", "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", session.UserID)
+	assert.True(t, sessions.NeedsRenewal(session))
+
+	_, err = sessions.Parse(SignUserID("user-1", "other"))
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	cfg.SessionAcceptLegacy = false
+	strict, err := NewSessionsFromConfig(cfg)
+	require.NoError(t, err)
+	_, err = strict.Parse(SignUserID("user-1", "secret"))
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
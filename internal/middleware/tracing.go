@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// TracingMiddleware создает серверный спан для каждого HTTP запроса.
+// Родительский контекст берется из заголовка W3C traceparent, поэтому трасса
+// вызывающего сервиса продолжается в сокращателе. Имя спана содержит
+// шаблон маршрута chi, а ответы со статусом 5xx помечаются как ошибка.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.StartWithKind(ctx, r.Method, tracing.SpanKindServer,
+			tracing.String("http.method", r.Method),
+			tracing.String("http.target", r.URL.Path),
+		)
+		defer span.End()
+
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := routePattern(r)
+		status := responseStatus(ww)
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			tracing.String("http.route", route),
+			tracing.Int("http.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.RecordError(errorStatus(status))
+		}
+	})
+}
+
+// errorStatus — ошибка, описывающая HTTP статус ответа
+type errorStatus int
+
+func (s errorStatus) Error() string {
+	return http.StatusText(int(s))
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name        string
+		traceparent string
+		wantTraceID string
+	}{
+		{name: "Continues incoming trace", traceparent: incoming, wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "Ignores invalid traceparent", traceparent: "garbage"},
+		{name: "Starts new trace", traceparent: ""},
+	}
+
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(io.Discard, "test"), 1)
+	tracing.SetTracer(tracer)
+	defer func() {
+		tracing.SetTracer(nil)
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got tracing.SpanContext
+			r := chi.NewRouter()
+			r.Use(TracingMiddleware)
+			r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				got = tracing.SpanContextFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			if tt.traceparent != "" {
+				req.Header.Set(tracing.TraceparentHeader, tt.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.True(t, got.IsValid())
+			assert.NotEqual(t, "00f067aa0ba902b7", got.SpanID.String(), "handler must see the server span, not the remote parent")
+			if tt.wantTraceID != "" {
+				assert.Equal(t, tt.wantTraceID, got.TraceID.String())
+			} else {
+				assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID.String())
+			}
+		})
+	}
+}
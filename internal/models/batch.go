@@ -1,10 +1,15 @@
 package models
 
+import "time"
+
 // BatchRequestEntry представляет одну запись в запросе на пакетное сокращение URL.
 // Используется в API эндпоинте /api/shorten/batch для массовой обработки URL.
 type BatchRequestEntry struct {
-	CorrelationID string `json:"correlation_id"` // Уникальный идентификатор для связи запроса и ответа
-	OriginalURL   string `json:"original_url"`   // Оригинальный URL для сокращения
+	CorrelationID string     `json:"correlation_id"`       // Уникальный идентификатор для связи запроса и ответа
+	OriginalURL   string     `json:"original_url"`         // Оригинальный URL для сокращения
+	Alias         string     `json:"alias,omitempty"`      // Пользовательский короткий идентификатор (необязательно)
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // Абсолютный момент истечения срока действия (необязательно)
+	TTL           int64      `json:"ttl,omitempty"`        // Время жизни ссылки в секундах (необязательно)
 }
 
 // BatchResponseEntry представляет одну запись в ответе на пакетное сокращение.
@@ -2,13 +2,193 @@
 // Пакет определяет основные модели для передачи данных между слоями приложения.
 package models
 
+import "time"
+
 // UserURL представляет структуру для URL пользователя в API ответах.
 // Используется для возврата списка сокращенных URL пользователя.
 type UserURL struct {
-	ShortURL    string `json:"short_url"`    // Полный сокращенный URL (с базовым адресом)
-	OriginalURL string `json:"original_url"` // Оригинальный URL
+	ShortURL    string     `json:"short_url"`             // Полный сокращенный URL (с базовым адресом)
+	OriginalURL string     `json:"original_url"`          // Оригинальный URL
+	Title       string     `json:"title,omitempty"`       // Заголовок, заданный пользователем
+	Description string     `json:"description,omitempty"` // Описание, заданное пользователем
+	CreatedAt   *time.Time `json:"created_at,omitempty"`  // Время создания (nil для записей, сохраненных до его появления)
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`  // Время последнего изменения
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`  // Время удаления (nil — ссылка не удалена)
+	// Код перенаправления, заданный при создании (0 — код по умолчанию из конфигурации)
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// Переход всегда выполняется через страницу предпросмотра
+	Interstitial bool `json:"interstitial,omitempty"`
+}
+
+// Redirect описывает переход по короткой ссылке: адрес назначения, код ответа
+// и сведения о ссылке для страницы предпросмотра.
+type Redirect struct {
+	OriginalURL  string    // Оригинальный URL
+	Status       int       // HTTP код перенаправления (0 — код по умолчанию из конфигурации)
+	Interstitial bool      // Показывать страницу предпросмотра вместо перенаправления
+	Title        string    // Заголовок, заданный пользователем
+	Description  string    // Описание, заданное пользователем
+	CreatedAt    time.Time // Время создания (нулевое значение — неизвестно)
+	ExpiresAt    time.Time // Момент истечения срока действия (нулевое значение — бессрочно)
+	// Ссылку может изменить владелец, поэтому ее адрес назначения не постоянен
+	Editable bool
+}
+
+// Поля сортировки списка ссылок пользователя
+const (
+	SortCreatedAt   = "created_at"
+	SortShortURL    = "short_url"
+	SortOriginalURL = "original_url"
+)
+
+// UserURLQuery задает фильтры, сортировку и страницу списка ссылок пользователя.
+// Нулевое значение соответствует всем ссылкам в порядке создания.
+type UserURLQuery struct {
+	Search        string    // Подстрока короткого или оригинального URL (без учета регистра)
+	Domain        string    // Домен оригинального URL, включая поддомены
+	CreatedAfter  time.Time // Создана не раньше (нулевое значение — без ограничения)
+	CreatedBefore time.Time // Создана раньше (нулевое значение — без ограничения)
+	Sort          string    // Поле сортировки (Sort*); пустое значение — SortCreatedAt
+	Desc          bool      // Сортировка по убыванию
+	Limit         int       // Размер страницы (0 — без ограничения)
+	Cursor        string    // Курсор, возвращенный предыдущей страницей
+	Deleted       bool      // Выбирать удаленные ссылки вместо действующих
+}
+
+// UserURLPage — страница списка ссылок пользователя
+type UserURLPage struct {
+	URLs       []UserURL // Ссылки страницы
+	Total      int       // Количество ссылок, удовлетворяющих фильтрам, на всех страницах
+	NextCursor string    // Курсор следующей страницы; пусто, если страница последняя
+}
+
+// URLUpdate описывает изменение ссылки пользователя.
+// Используется в API эндпоинте PATCH /api/user/urls/{id}; поля со значением nil не изменяются.
+type URLUpdate struct {
+	OriginalURL *string `json:"original_url,omitempty"` // Новый оригинальный URL
+	Title       *string `json:"title,omitempty"`        // Новый заголовок (пустая строка удаляет его)
+	Description *string `json:"description,omitempty"`  // Новое описание (пустая строка удаляет его)
+	// Каноническая форма нового OriginalURL для поиска дубликатов; заполняется сервисом
+	CanonicalURL string `json:"-"`
+}
+
+// URLRevision — предыдущая версия ссылки, сохраняемая при каждом ее изменении.
+// Возвращается в API эндпоинте /api/user/urls/{id}/revisions.
+type URLRevision struct {
+	OriginalURL string    `json:"original_url"`          // Оригинальный URL версии
+	Title       string    `json:"title,omitempty"`       // Заголовок версии
+	Description string    `json:"description,omitempty"` // Описание версии
+	ReplacedAt  time.Time `json:"replaced_at"`           // Время, когда версия была заменена
 }
 
 // DeleteRequest представляет запрос на удаление URL.
 // Содержит массив коротких URL для удаления.
 type DeleteRequest []string
+
+// RestoreResponse представляет ответ на запрос восстановления удаленных URL.
+// Содержит короткие URL, с которых снята пометка удаления.
+type RestoreResponse struct {
+	Restored []string `json:"restored"` // Восстановленные короткие URL
+}
+
+// ShortenOptions содержит необязательные параметры создания короткого URL.
+// Нулевое значение соответствует созданию URL со сгенерированным идентификатором.
+type ShortenOptions struct {
+	Alias       string    // Пользовательский короткий идентификатор (vanity URL)
+	ExpiresAt   time.Time // Момент истечения срока действия ссылки (нулевое значение — бессрочно)
+	Title       string    // Заголовок ссылки (необязательно)
+	Description string    // Описание ссылки (необязательно)
+	// HTTP код перенаправления: 301, 302, 307 или 308 (0 — код по умолчанию из конфигурации)
+	RedirectStatus int
+	Interstitial   bool // Всегда показывать страницу предпросмотра перед переходом
+}
+
+// ClickInfo содержит сведения о переходе по короткой ссылке, получаемые из HTTP запроса.
+type ClickInfo struct {
+	Referrer  string // Значение заголовка Referer
+	UserAgent string // Значение заголовка User-Agent
+	ClientIP  string // IP адрес клиента (хранится только в виде хеша)
+}
+
+// ClickEvent представляет одно событие перехода по короткой ссылке.
+type ClickEvent struct {
+	ShortURL  string    `json:"short_url"`            // Короткий идентификатор URL
+	Timestamp time.Time `json:"timestamp"`            // Время перехода
+	Referrer  string    `json:"referrer,omitempty"`   // Источник перехода
+	UserAgent string    `json:"user_agent,omitempty"` // User-Agent клиента
+	IPHash    string    `json:"ip_hash,omitempty"`    // Хеш IP адреса клиента
+}
+
+// ClickStats содержит агрегированную статистику переходов по короткой ссылке.
+// Возвращается в API эндпоинте /api/user/urls/{id}/stats.
+type ClickStats struct {
+	ShortURL       string           `json:"short_url"`               // Короткий идентификатор URL
+	TotalClicks    int64            `json:"total_clicks"`            // Общее количество переходов
+	UniqueVisitors int64            `json:"unique_visitors"`         // Количество уникальных посетителей (по хешу IP)
+	LastClickAt    *time.Time       `json:"last_click_at,omitempty"` // Время последнего перехода
+	Referrers      map[string]int64 `json:"referrers"`               // Количество переходов по хосту источника
+	Daily          map[string]int64 `json:"daily"`                   // Количество переходов по дням (YYYY-MM-DD, UTC)
+}
+
+// Статусы задачи удаления URL
+const (
+	DeleteJobPending   = "pending"   // Задача ожидает обработки (в том числе повторной попытки)
+	DeleteJobRunning   = "running"   // Задача обрабатывается
+	DeleteJobCompleted = "completed" // URL удалены
+	DeleteJobFailed    = "failed"    // Исчерпаны все попытки удаления
+)
+
+// DeleteJob описывает асинхронную задачу удаления URL пользователя.
+// Возвращается в API эндпоинте /api/user/urls/delete-jobs/{id}.
+type DeleteJob struct {
+	ID            string     `json:"id"`                        // Идентификатор задачи
+	UserID        string     `json:"-"`                         // Владелец удаляемых URL
+	ShortURLs     []string   `json:"short_urls"`                // Удаляемые короткие идентификаторы
+	Status        string     `json:"status"`                    // Статус задачи (DeleteJob*)
+	Attempts      int        `json:"attempts"`                  // Количество выполненных попыток
+	LastError     string     `json:"last_error,omitempty"`      // Ошибка последней неудачной попытки
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // Время следующей попытки после ошибки
+	CreatedAt     time.Time  `json:"created_at"`                // Время постановки в очередь
+	UpdatedAt     time.Time  `json:"updated_at"`                // Время последнего изменения статуса
+}
+
+// Finished сообщает, завершена ли задача (успешно или окончательной ошибкой).
+func (j DeleteJob) Finished() bool {
+	return j.Status == DeleteJobCompleted || j.Status == DeleteJobFailed
+}
+
+// Области действия API ключей
+const (
+	ScopeRead   = "read"   // Чтение ссылок пользователя, статистики и задач удаления
+	ScopeWrite  = "write"  // Создание ссылок
+	ScopeDelete = "delete" // Удаление ссылок
+)
+
+// APIKey описывает API ключ для аутентификации межсервисных клиентов.
+// Сам ключ не хранится: хранилище содержит только его хеш.
+// Возвращается в API эндпоинте /api/user/api-keys.
+type APIKey struct {
+	ID        string     `json:"id"`                   // Идентификатор ключа
+	UserID    string     `json:"-"`                    // Пользователь, от имени которого действует ключ
+	Name      string     `json:"name"`                 // Описание ключа, заданное пользователем
+	Prefix    string     `json:"prefix"`               // Начало ключа для его опознания в списке
+	Hash      string     `json:"-"`                    // SHA-256 хеш ключа
+	Scopes    []string   `json:"scopes"`               // Области действия (Scope*)
+	CreatedAt time.Time  `json:"created_at"`           // Время создания
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Время отзыва (nil — ключ действует)
+}
+
+// Revoked сообщает, отозван ли ключ.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// HasScope сообщает, разрешена ли ключу область действия scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
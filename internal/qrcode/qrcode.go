@@ -0,0 +1,474 @@
+// Package qrcode реализует кодирование данных в QR коды (ISO/IEC 18004) и их
+// отрисовку в PNG и SVG без внешних зависимостей.
+// Данные кодируются в байтовом режиме; версия символа (1–40) выбирается
+// минимальной, вмещающей данные при заданном уровне коррекции ошибок.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level — уровень коррекции ошибок: доля поврежденных кодовых слов,
+// которую можно восстановить
+type Level int
+
+// Уровни коррекции ошибок
+const (
+	LevelL Level = iota // ~7%
+	LevelM              // ~15%
+	LevelQ              // ~25%
+	LevelH              // ~30%
+)
+
+// formatBits — код уровня коррекции в информации о формате
+var formatBits = [4]int{LevelL: 1, LevelM: 0, LevelQ: 3, LevelH: 2}
+
+// ErrInvalidLevel возвращается ParseLevel для неизвестного уровня коррекции
+var ErrInvalidLevel = errors.New("qrcode: invalid error correction level")
+
+// ErrDataTooLong возвращается, если данные не помещаются в QR код версии 40
+var ErrDataTooLong = errors.New("qrcode: data too long")
+
+// ParseLevel разбирает уровень коррекции ошибок: L, M, Q или H (без учета регистра)
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return LevelL, nil
+	case "M":
+		return LevelM, nil
+	case "Q":
+		return LevelQ, nil
+	case "H":
+		return LevelH, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, s)
+	}
+}
+
+// String возвращает обозначение уровня коррекции
+func (l Level) String() string {
+	return [...]string{"L", "M", "Q", "H"}[l]
+}
+
+// Code — QR код: квадратная матрица темных и светлых модулей
+type Code struct {
+	version  int
+	size     int
+	modules  []bool // Цвет модулей построчно; true — темный
+	function []bool // Модули функциональных узоров, не подлежащие маскированию
+}
+
+// Encode кодирует data в QR код с уровнем коррекции level.
+// Возвращает ErrDataTooLong, если данные не помещаются в символ версии 40.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, ErrInvalidLevel
+	}
+
+	version := 1
+	for ; ; version++ {
+		if version > 40 {
+			return nil, fmt.Errorf("%w: %d bytes", ErrDataTooLong, len(data))
+		}
+		if 4+charCountBits(version)+8*len(data) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	codewords := encodeSegment(data, version, level)
+	code := newCode(version)
+	code.drawFunctionPatterns(level)
+	code.drawCodewords(addErrorCorrection(codewords, version, level))
+
+	// Выбираем маску с наименьшим штрафом; маска обратима, поэтому
+	// ее повторное наложение восстанавливает исходную матрицу
+	bestMask, bestPenalty := 0, -1
+	for mask := range 8 {
+		code.applyMask(mask)
+		code.drawFormatBits(level, mask)
+		if penalty := code.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask)
+	}
+	code.applyMask(bestMask)
+	code.drawFormatBits(level, bestMask)
+	code.function = nil
+	return code, nil
+}
+
+// Size возвращает длину стороны QR кода в модулях (без свободной зоны)
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Version возвращает версию символа (1–40)
+func (c *Code) Version() int {
+	return c.version
+}
+
+// Black сообщает, темный ли модуль в столбце x и строке y.
+// Модули за пределами символа считаются светлыми.
+func (c *Code) Black(x, y int) bool {
+	return x >= 0 && x < c.size && y >= 0 && y < c.size && c.modules[y*c.size+x]
+}
+
+// charCountBits возвращает длину поля количества символов байтового режима
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encodeSegment формирует кодовые слова данных: индикатор байтового режима,
+// длину, сами данные, терминатор и байты-заполнители до емкости версии
+func encodeSegment(data []byte, version int, level Level) []byte {
+	capacity := numDataCodewords(version, level)
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity*8; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, capacity)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return codewords
+}
+
+// addErrorCorrection делит данные на блоки, дополняет каждый кодовыми словами
+// коррекции ошибок и чередует блоки в порядке размещения в символе
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockECCLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	// Короткие блоки содержат на одно кодовое слово данных меньше; чтобы чередовать
+	// блоки одним циклом, в них резервируется пропускаемая позиция
+	divisor := rsDivisor(blockECCLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		block := make([]byte, shortBlockLen+1)
+		copy(block, data[k:k+dataLen])
+		copy(block[len(block)-blockECCLen:], rsRemainder(data[k:k+dataLen], divisor))
+		k += dataLen
+		blocks[i] = block
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range shortBlockLen + 1 {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// newCode создает пустую матрицу версии version
+func newCode(version int) *Code {
+	size := version*4 + 17
+	return &Code{
+		version:  version,
+		size:     size,
+		modules:  make([]bool, size*size),
+		function: make([]bool, size*size),
+	}
+}
+
+// setFunction задает цвет модуля функционального узора
+func (c *Code) setFunction(x, y int, black bool) {
+	c.modules[y*c.size+x] = black
+	c.function[y*c.size+x] = true
+}
+
+// drawFunctionPatterns рисует поисковые, синхронизирующие и выравнивающие узоры
+// и резервирует места информации о формате и версии
+func (c *Code) drawFunctionPatterns(level Level) {
+	for i := range c.size {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.size-4, 3)
+	c.drawFinderPattern(3, c.size-4)
+
+	positions := alignmentPatternPositions(c.version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Углы, занятые поисковыми узорами
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	c.drawFormatBits(level, 0)
+	c.drawVersion()
+}
+
+// drawFinderPattern рисует поисковый узор с центром (x, y) вместе с его светлой рамкой
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignmentPattern рисует выравнивающий узор с центром (x, y)
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits рисует обе копии информации о формате: уровень коррекции и маску,
+// защищенные кодом БЧХ (15, 5)
+func (c *Code) drawFormatBits(level Level, mask int) {
+	bits := formatInfo(level, mask)
+
+	// Первая копия — вокруг левого верхнего поискового узора
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Вторая копия — у правого верхнего и левого нижнего поисковых узоров
+	for i := range 8 {
+		c.setFunction(c.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.size-8, true) // Всегда темный модуль
+}
+
+// drawVersion рисует информацию о версии (для версий 7 и выше),
+// защищенную кодом Голея (18, 6)
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+	bits := versionInfo(c.version)
+
+	for i := range 18 {
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// formatInfo возвращает 15 бит информации о формате: код уровня коррекции и номер маски
+// с кодом БЧХ, наложенные на маску 0x5412
+func formatInfo(level Level, mask int) int {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionInfo возвращает 18 бит информации о версии с кодом Голея
+func versionInfo(version int) int {
+	rem := version
+	for range 12 {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// drawCodewords размещает кодовые слова зигзагом парами столбцов справа налево,
+// пропуская функциональные модули
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Столбец вертикального синхронизирующего узора пропускается
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.size {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if c.function[y*c.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				c.modules[y*c.size+x] = codewords[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask инвертирует модули данных по шаблону маски mask (0–7)
+func (c *Code) applyMask(mask int) {
+	for y := range c.size {
+		for x := range c.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y*c.size+x] {
+				c.modules[y*c.size+x] = !c.modules[y*c.size+x]
+			}
+		}
+	}
+}
+
+// finderLikePattern — последовательность модулей, похожая на поисковый узор (1:1:3:1:1)
+var finderLikePattern = []bool{true, false, true, true, true, false, true}
+
+// penalty вычисляет штраф матрицы по правилам выбора маски: длинные серии модулей
+// одного цвета, блоки 2×2, узоры, похожие на поисковые, и дисбаланс темных модулей
+func (c *Code) penalty() int {
+	result := 0
+	dark := 0
+	for i := range c.size {
+		row := func(j int) bool { return c.modules[i*c.size+j] }
+		col := func(j int) bool { return c.modules[j*c.size+i] }
+		for _, line := range []func(int) bool{row, col} {
+			result += c.runPenalty(line) + c.finderPenalty(line)
+		}
+		for j := range c.size {
+			if row(j) {
+				dark++
+			}
+		}
+	}
+
+	for y := 0; y < c.size-1; y++ {
+		for x := 0; x < c.size-1; x++ {
+			color := c.Black(x, y)
+			if color == c.Black(x+1, y) && color == c.Black(x, y+1) && color == c.Black(x+1, y+1) {
+				result += 3
+			}
+		}
+	}
+
+	total := c.size * c.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + max(k, 0)*10
+}
+
+// runPenalty штрафует серии из пяти и более модулей одного цвета в линии
+func (c *Code) runPenalty(line func(int) bool) int {
+	result := 0
+	run := 1
+	for j := 1; j <= c.size; j++ {
+		if j < c.size && line(j) == line(j-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += 3 + run - 5
+		}
+		run = 1
+	}
+	return result
+}
+
+// finderPenalty штрафует узоры 1:1:3:1:1 со светлой зоной в четыре модуля с любой стороны.
+// Модули за пределами символа считаются светлыми (свободная зона).
+func (c *Code) finderPenalty(line func(int) bool) int {
+	at := func(j int) bool { return j >= 0 && j < c.size && line(j) }
+	lightRun := func(from, to int) bool {
+		for j := from; j < to; j++ {
+			if at(j) {
+				return false
+			}
+		}
+		return true
+	}
+
+	result := 0
+	for start := 0; start+len(finderLikePattern) <= c.size; start++ {
+		matches := true
+		for k, black := range finderLikePattern {
+			if at(start+k) != black {
+				matches = false
+				break
+			}
+		}
+		end := start + len(finderLikePattern)
+		if matches && (lightRun(start-4, start) || lightRun(end, end+4)) {
+			result += 40
+		}
+	}
+	return result
+}
+
+// bitBuffer — последовательность битов, дописываемых старшим битом вперед
+type bitBuffer []bool
+
+// append дописывает n младших битов value
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+// bit возвращает i-й бит x
+func bit(x, i int) bool {
+	return (x>>i)&1 == 1
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,245 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// Пример «HELLO WORLD» версии 1-M из спецификации
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsDivisor(10)))
+}
+
+func TestTables(t *testing.T) {
+	// Емкость байтового режима по таблицам спецификации
+	capacities := []struct {
+		version int
+		level   Level
+		bytes   int
+	}{
+		{1, LevelL, 17}, {1, LevelM, 14}, {1, LevelQ, 11}, {1, LevelH, 7},
+		{2, LevelL, 32}, {10, LevelL, 271}, {40, LevelL, 2953}, {40, LevelH, 1273},
+	}
+	for _, c := range capacities {
+		assert.Equal(t, c.bytes, (numDataCodewords(c.version, c.level)*8-4-charCountBits(c.version))/8,
+			"version %d-%s", c.version, c.level)
+	}
+
+	assert.Nil(t, alignmentPatternPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPatternPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPatternPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPatternPositions(32))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPatternPositions(40))
+
+	assert.Equal(t, 0b111011111000100, formatInfo(LevelL, 0))
+	assert.Equal(t, 0b101010000010010, formatInfo(LevelM, 0))
+	assert.Equal(t, 0x07C94, versionInfo(7))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   Level
+		version int
+	}{
+		{name: "short link", data: "http://localhost:8080/abc123", level: LevelM, version: 3},
+		{name: "full version 1", data: strings.Repeat("a", 17), level: LevelL, version: 1},
+		{name: "next version", data: strings.Repeat("a", 18), level: LevelL, version: 2},
+		{name: "version information", data: strings.Repeat("x", 100), level: LevelH, version: 10},
+		{name: "long count field", data: strings.Repeat("0123456789", 30), level: LevelQ, version: 16},
+		{name: "maximum", data: strings.Repeat("z", 2953), level: LevelL, version: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode([]byte(tt.data), tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, code.Version())
+			assert.Equal(t, tt.version*4+17, code.Size())
+
+			data, level := decode(t, code)
+			assert.Equal(t, tt.level, level)
+			assert.Equal(t, tt.data, string(data))
+		})
+	}
+
+	t.Run("too long", func(t *testing.T) {
+		_, err := Encode(bytes.Repeat([]byte("z"), 2954), LevelL)
+		assert.ErrorIs(t, err, ErrDataTooLong)
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, s := range []string{"L", "m", "Q", "h"} {
+		level, err := ParseLevel(s)
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToUpper(s), level.String())
+	}
+	_, err := ParseLevel("X")
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode([]byte("http://localhost:8080/abc123"), LevelM)
+	require.NoError(t, err)
+
+	t.Run("png", func(t *testing.T) {
+		data, err := code.PNG(256, 4)
+		require.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Equal(t, 256, img.Bounds().Dy())
+
+		// 37 модулей по 6 пикселей, отступ (256 - 29*6) / 2 = 41
+		scale, offset := 6, 41
+		for y := range code.Size() {
+			for x := range code.Size() {
+				r, _, _, _ := img.At(offset+x*scale+scale/2, offset+y*scale+scale/2).RGBA()
+				assert.Equal(t, code.Black(x, y), r == 0, "module (%d, %d)", x, y)
+			}
+		}
+		r, _, _, _ := img.At(0, 0).RGBA()
+		assert.NotZero(t, r, "quiet zone must be light")
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		data, err := code.SVG(200, 2)
+		require.NoError(t, err)
+		svg := string(data)
+		assert.Contains(t, svg, `width="200" height="200" viewBox="0 0 33 33"`)
+		// Верхняя строка начинается поисковым узором из семи темных модулей
+		assert.Contains(t, svg, `d="M2,2h7v1h-7z`)
+	})
+
+	t.Run("too small", func(t *testing.T) {
+		_, err := code.PNG(32, 4)
+		assert.ErrorIs(t, err, ErrSizeTooSmall)
+		_, err = code.SVG(32, 4)
+		assert.ErrorIs(t, err, ErrSizeTooSmall)
+	})
+}
+
+// decode читает данные из QR кода: определяет уровень коррекции и маску по информации
+// о формате, снимает маску, собирает кодовые слова, проверяет коды Рида — Соломона
+// каждого блока и разбирает сегмент байтового режима
+func decode(t *testing.T, code *Code) ([]byte, Level) {
+	t.Helper()
+
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= btoi(code.Black(8, i)) << i
+	}
+	format |= btoi(code.Black(8, 7))<<6 | btoi(code.Black(8, 8))<<7 | btoi(code.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= btoi(code.Black(14-i, 8)) << i
+	}
+	level, mask := Level(-1), -1
+	for l := LevelL; l <= LevelH; l++ {
+		for m := range 8 {
+			if formatInfo(l, m) == format {
+				level, mask = l, m
+			}
+		}
+	}
+	require.NotEqual(t, -1, mask, "unknown format information %015b", format)
+
+	version := (code.Size() - 17) / 4
+	ref := newCode(version)
+	ref.drawFunctionPatterns(level)
+	ref.drawFormatBits(level, mask)
+	for i, black := range code.modules {
+		if ref.function[i] {
+			require.Equal(t, ref.modules[i], black, "function module %d", i)
+		} else {
+			ref.modules[i] = black
+		}
+	}
+	ref.applyMask(mask)
+
+	// Зигзаг справа налево парами столбцов
+	var bits bitBuffer
+	for right := ref.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range ref.size {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = ref.size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if !ref.function[y*ref.size+x] {
+					bits = append(bits, ref.modules[y*ref.size+x])
+				}
+			}
+		}
+	}
+	rawCodewords := numRawDataModules(version) / 8
+	codewords := make([]byte, rawCodewords)
+	for i := range codewords {
+		for j := range 8 {
+			codewords[i] = codewords[i]<<1 | byte(btoi(bits[i*8+j]))
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortDataLen+1; i++ {
+		for j := range blocks {
+			if i < shortDataLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for range eccLen {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	require.Equal(t, rawCodewords, k)
+
+	var data bitBuffer
+	for j, block := range blocks {
+		dataLen := len(block) - eccLen
+		require.Equal(t, block[dataLen:], rsRemainder(block[:dataLen], rsDivisor(eccLen)), "block %d", j)
+		for _, b := range block[:dataLen] {
+			data.append(int(b), 8)
+		}
+	}
+
+	read := func(n int) int {
+		value := 0
+		for range n {
+			value = value<<1 | btoi(data[0])
+			data = data[1:]
+		}
+		return value
+	}
+	require.Equal(t, 0x4, read(4), "byte mode indicator")
+	result := make([]byte, read(charCountBits(version)))
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+	return result, level
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package qrcode
+
+// rsDivisor возвращает порождающий многочлен кода Рида — Соломона степени degree
+// над GF(2^8) с примитивным многочленом x^8 + x^4 + x^3 + x^2 + 1.
+// Коэффициенты хранятся от старшего к младшему; старший коэффициент (всегда 1) опущен.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	// Произведение (x - r^0)(x - r^1)...(x - r^{degree-1}), где r = 0x02
+	var root byte = 1
+	for range degree {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder возвращает кодовые слова коррекции ошибок для data:
+// остаток от деления многочлена данных на divisor
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply умножает элементы поля GF(2^8) по модулю 0x11D
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
@@ -0,0 +1,82 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrSizeTooSmall возвращается, если в изображение заданного размера
+// не помещается хотя бы один пиксель на модуль
+var ErrSizeTooSmall = errors.New("qrcode: image size too small")
+
+// palette — черно-белая палитра PNG: индекс 0 — светлый модуль, 1 — темный
+var palette = color.Palette{color.White, color.Black}
+
+// PNG отрисовывает код в квадратное изображение PNG со стороной size пикселей
+// и свободной зоной шириной margin модулей.
+// Модули масштабируются на целое число пикселей, остаток распределяется по краям.
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	total := c.size + 2*margin
+	scale := size / total
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: %dpx for %d modules", ErrSizeTooSmall, size, total)
+	}
+	offset := (size - scale*c.size) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), palette)
+	for y := range c.size {
+		for x := range c.size {
+			if !c.Black(x, y) {
+				continue
+			}
+			for py := range scale {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := range scale {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("qrcode: encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG отрисовывает код в векторное изображение со стороной size пикселей
+// и свободной зоной шириной margin модулей.
+// Темные модули каждой строки объединяются в прямоугольники одного контура.
+func (c *Code) SVG(size, margin int) ([]byte, error) {
+	total := c.size + 2*margin
+	if size < total {
+		return nil, fmt.Errorf("%w: %dpx for %d modules", ErrSizeTooSmall, size, total)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, total, total)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#FFFFFF"/>`+"\n")
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y := range c.size {
+		for x := 0; x < c.size; x++ {
+			if !c.Black(x, y) {
+				continue
+			}
+			start := x
+			for x < c.size && c.Black(x, y) {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d,%dh%dv1h-%dz", start+margin, y+margin, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит корзины в памяти процесса. Ограничения действуют
+// только в пределах одного экземпляра сервиса.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]Bucket
+}
+
+// NewMemoryStore создает пустое хранилище корзин в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]Bucket)}
+}
+
+// TakeRateLimitToken пополняет корзину key и пытается взять из нее токен
+func (s *MemoryStore) TakeRateLimitToken(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = NewBucket(limit, now)
+	}
+	bucket, decision := bucket.Take(limit, now)
+	s.buckets[key] = bucket
+	return decision, nil
+}
+
+// PurgeRateLimits удаляет корзины, не обновлявшиеся с момента before
+func (s *MemoryStore) PurgeRateLimits(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(before) {
+			delete(s.buckets, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
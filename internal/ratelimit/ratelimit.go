@@ -0,0 +1,219 @@
+// Package ratelimit реализует ограничение частоты запросов по алгоритму token bucket.
+// Состояние корзин хранится в подключаемом хранилище: в памяти процесса (MemoryStore)
+// или в общей базе данных, чтобы ограничения действовали для всех реплик сервиса.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"go.uber.org/zap"
+)
+
+// Маршруты с отдельными ограничениями
+const (
+	RouteCreate   = "create"   // Создание одной ссылки (POST / и POST /api/shorten)
+	RouteBatch    = "batch"    // Пакетное создание ссылок
+	RouteRedirect = "redirect" // Переход по короткой ссылке
+	RouteDelete   = "delete"   // Удаление ссылок пользователя
+)
+
+// Хранилища корзин (параметр RateLimitBackend)
+const (
+	BackendMemory   = "memory"   // В памяти процесса
+	BackendPostgres = "postgres" // В базе данных PostgreSQL, общей для реплик
+)
+
+// Limit — параметры корзины: скорость пополнения в токенах в секунду и емкость.
+// Нулевой Limit означает отсутствие ограничения.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled сообщает, ограничивает ли Limit запросы
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// ParseLimit разбирает ограничение вида "N/unit" или "N/unit:burst", где unit — s, m или h
+// (например, "60/m" или "10/s:20"). Без burst емкость корзины равна N.
+// Пустая строка и "0" означают отсутствие ограничения.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return Limit{}, nil
+	}
+
+	spec, burstStr, hasBurst := strings.Cut(value, ":")
+	countStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected N/unit[:burst]", value)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", value)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", value)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", value)
+		}
+	}
+	return Limit{Rate: float64(count) / period.Seconds(), Burst: burst}, nil
+}
+
+// LimitsFromConfig разбирает ограничения маршрутов из конфигурации
+func LimitsFromConfig(cfg *config.Config) (map[string]Limit, error) {
+	specs := map[string]string{
+		RouteCreate:   cfg.RateLimitCreate,
+		RouteBatch:    cfg.RateLimitBatch,
+		RouteRedirect: cfg.RateLimitRedirect,
+		RouteDelete:   cfg.RateLimitDelete,
+	}
+	limits := make(map[string]Limit, len(specs))
+	for route, spec := range specs {
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", route, err)
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// refillTime возвращает время, за которое пустая корзина наполняется полностью
+func (l Limit) refillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Decision — результат попытки взять токен
+type Decision struct {
+	Allowed    bool          // Запрос разрешен
+	Remaining  int           // Количество оставшихся целых токенов
+	RetryAfter time.Duration // Через сколько появится токен (для отклоненного запроса)
+}
+
+// Bucket — состояние корзины токенов
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewBucket возвращает полную корзину для ограничения limit
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+}
+
+// Take пополняет корзину за время с последнего обновления и пытается взять один токен.
+// Возвращает новое состояние корзины и решение.
+func (b Bucket) Take(limit Limit, now time.Time) (Bucket, Decision) {
+	if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed.Seconds()*limit.Rate)
+		b.UpdatedAt = now
+	}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return b, Decision{Allowed: true, Remaining: int(b.Tokens)}
+	}
+	wait := (1 - b.Tokens) / limit.Rate
+	return b, Decision{RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second)))}
+}
+
+// Store хранит состояние корзин
+type Store interface {
+	// TakeRateLimitToken атомарно пополняет корзину key и пытается взять из нее токен.
+	// Отсутствующая корзина считается полной.
+	TakeRateLimitToken(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+
+	// PurgeRateLimits удаляет корзины, не обновлявшиеся с момента before.
+	// Возвращает количество удаленных корзин.
+	PurgeRateLimits(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Limiter применяет ограничения маршрутов к ключам клиентов
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+	now    func() time.Time
+}
+
+// NewLimiter создает Limiter с ограничениями limits по маршрутам (Route*).
+// Маршруты без ограничения или с нулевым Limit не ограничиваются.
+func NewLimiter(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits, now: time.Now}
+}
+
+// Limit возвращает ограничение маршрута route
+func (l *Limiter) Limit(route string) Limit {
+	return l.limits[route]
+}
+
+// Allow берет токен из корзины клиента key на маршруте route.
+// Для маршрута без ограничения запрос всегда разрешен.
+func (l *Limiter) Allow(ctx context.Context, route, key string) (Decision, error) {
+	limit := l.limits[route]
+	if !limit.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+	return l.store.TakeRateLimitToken(ctx, route+":"+key, limit, l.now())
+}
+
+// Purge удаляет корзины, которые успели наполниться полностью: их удаление
+// не меняет поведения ограничителя. Возвращает количество удаленных корзин.
+func (l *Limiter) Purge(ctx context.Context) (int64, error) {
+	var idle time.Duration
+	for _, limit := range l.limits {
+		if limit.Enabled() && limit.refillTime() > idle {
+			idle = limit.refillTime()
+		}
+	}
+	return l.store.PurgeRateLimits(ctx, l.now().Add(-idle))
+}
+
+// Run периодически удаляет наполнившиеся корзины (см. Purge).
+// Блокирует выполнение до отмены ctx; при неположительном interval сразу возвращает управление.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := l.Purge(ctx)
+			if err != nil {
+				logger.Error("Error purging rate limit buckets", zap.Error(err))
+				continue
+			}
+			if purged > 0 {
+				logger.Debug("Rate limit buckets purged", zap.Int64("count", purged))
+			}
+		}
+	}
+}
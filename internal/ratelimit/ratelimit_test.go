@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "", want: Limit{}},
+		{value: "0", want: Limit{}},
+		{value: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{value: "60/m", want: Limit{Rate: 1, Burst: 60}},
+		{value: "3600/h:5", want: Limit{Rate: 1, Burst: 5}},
+		{value: "10", wantErr: true},
+		{value: "10/d", wantErr: true},
+		{value: "-1/s", wantErr: true},
+		{value: "10/s:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := NewBucket(limit, now)
+
+	var decision Decision
+	for want := 2; want >= 0; want-- {
+		bucket, decision = bucket.Take(limit, now)
+		require.True(t, decision.Allowed)
+		assert.Equal(t, want, decision.Remaining)
+	}
+
+	bucket, decision = bucket.Take(limit, now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+
+	// Через 500 мс появляется ровно один токен
+	bucket, decision = bucket.Take(limit, now.Add(500*time.Millisecond))
+	assert.True(t, decision.Allowed)
+
+	// Корзина не наполняется больше емкости
+	_, decision = bucket.Take(limit, now.Add(time.Hour))
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Remaining)
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, map[string]Limit{
+		RouteCreate: {Rate: 1, Burst: 1},
+		RouteBatch:  {Rate: 0.1, Burst: 1},
+	})
+	limiter.now = func() time.Time { return now }
+
+	decision, err := limiter.Allow(ctx, RouteCreate, "user:a")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = limiter.Allow(ctx, RouteCreate, "user:a")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	// Корзины раздельны по клиентам и маршрутам
+	decision, _ = limiter.Allow(ctx, RouteCreate, "user:b")
+	assert.True(t, decision.Allowed)
+	decision, _ = limiter.Allow(ctx, RouteBatch, "user:a")
+	assert.True(t, decision.Allowed)
+
+	// Маршрут без ограничения
+	for range 5 {
+		decision, _ = limiter.Allow(ctx, RouteRedirect, "user:a")
+		assert.True(t, decision.Allowed)
+	}
+
+	// Удаляются только корзины, простоявшие дольше самого долгого пополнения (10 с)
+	now = now.Add(5 * time.Second)
+	purged, err := limiter.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	now = now.Add(6 * time.Second)
+	purged, err = limiter.Purge(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, purged)
+}
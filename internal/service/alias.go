@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Ограничения на длину пользовательского идентификатора
+const (
+	minAliasLength = 3
+	maxAliasLength = 32
+)
+
+// ErrInvalidAlias возвращается, если алиас содержит недопустимые символы или имеет недопустимую длину
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// ErrReservedAlias возвращается, если алиас совпадает с зарезервированным словом
+var ErrReservedAlias = errors.New("alias is reserved")
+
+// ErrAliasTaken возвращается, если алиас уже занят другой ссылкой
+var ErrAliasTaken = errors.New("alias already taken")
+
+// aliasPattern описывает допустимый набор символов алиаса
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases содержит слова, которые нельзя занять алиасом:
+// они совпадают с маршрутами сервиса или могут ввести пользователей в заблуждение.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"admin":   {},
+	"health":  {},
+	"metrics": {},
+	"static":  {},
+	"debug":   {},
+	"login":   {},
+	"logout":  {},
+	"user":    {},
+	"users":   {},
+	"urls":    {},
+}
+
+// ValidateAlias проверяет пользовательский короткий идентификатор.
+// Допускаются латинские буквы, цифры, '-' и '_' длиной от minAliasLength до maxAliasLength символов.
+// Зарезервированные слова сравниваются без учета регистра.
+func ValidateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength || !aliasPattern.MatchString(alias) {
+		return ErrInvalidAlias
+	}
+	if _, reserved := reservedAliases[strings.ToLower(alias)]; reserved {
+		return ErrReservedAlias
+	}
+	return nil
+}
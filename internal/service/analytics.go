@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Параметры буферизации событий переходов по умолчанию
+const (
+	defaultClickBufferSize    = 1024
+	defaultClickFlushInterval = time.Second
+	clickBatchSize            = 100
+	clickFlushTimeout         = 5 * time.Second
+)
+
+// ClickTracker асинхронно записывает события переходов в хранилище аналитики.
+// События складываются в буферизованный канал и сохраняются фоновой горутиной пакетами,
+// поэтому запись перехода не увеличивает время ответа на редирект.
+// Если буфер переполнен, событие отбрасывается.
+type ClickTracker struct {
+	store         storage.AnalyticsStorage
+	logger        *zap.Logger
+	events        chan models.ClickEvent
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.RWMutex // Защищает закрытие канала events от конкурентной записи
+	closed bool
+}
+
+// NewClickTracker создает ClickTracker и запускает фоновую запись событий.
+// Нулевые bufferSize и flushInterval заменяются значениями по умолчанию.
+func NewClickTracker(store storage.AnalyticsStorage, logger *zap.Logger, bufferSize int, flushInterval time.Duration) *ClickTracker {
+	if bufferSize <= 0 {
+		bufferSize = defaultClickBufferSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultClickFlushInterval
+	}
+
+	t := &ClickTracker{
+		store:         store,
+		logger:        logger,
+		events:        make(chan models.ClickEvent, bufferSize),
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Track ставит событие в очередь на запись, не блокируя вызывающую горутину.
+// Возвращает false, если событие отброшено из-за переполнения буфера или остановки трекера.
+func (t *ClickTracker) Track(event models.ClickEvent) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return false
+	}
+
+	select {
+	case t.events <- event:
+		return true
+	default:
+		t.logger.Warn("Click buffer is full, dropping event", zap.String("short_url", event.ShortURL))
+		return false
+	}
+}
+
+// Close прекращает прием событий и дожидается записи накопленных событий
+// или отмены ctx.
+func (t *ClickTracker) Close(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.events)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run накапливает события и сохраняет их пакетами по размеру или по таймеру
+func (t *ClickTracker) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ClickEvent, 0, clickBatchSize)
+	for {
+		select {
+		case event, ok := <-t.events:
+			if !ok {
+				t.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= clickBatchSize {
+				t.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				t.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush сохраняет пакет событий в хранилище
+func (t *ClickTracker) flush(batch []models.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+	defer cancel()
+
+	if err := t.store.SaveClicks(ctx, batch); err != nil {
+		t.logger.Error("Error saving click events", zap.Int("count", len(batch)), zap.Error(err))
+	}
+}
+
+// hashClientIP возвращает HMAC-SHA256 хеш IP адреса клиента, чтобы не хранить адрес в открытом виде
+func hashClientIP(ip, secretKey string) string {
+	if ip == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, []byte(secretKey))
+	h.Write([]byte(ip))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// Формат API ключа: apiKeyTokenPrefix + base64url(apiKeySecretBytes случайных байт)
+const (
+	apiKeyTokenPrefix  = "tu_"
+	apiKeySecretBytes  = 32
+	apiKeyDisplayChars = 8 // Количество символов секрета, показываемых в списке ключей
+	maxAPIKeyNameLen   = 255
+)
+
+// ErrAPIKeysUnsupported возвращается, если хранилище не поддерживает API ключи
+var ErrAPIKeysUnsupported = errors.New("API keys are not supported by storage")
+
+// ErrInvalidAPIKey возвращается, если API ключ не существует или отозван
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrInvalidScope возвращается, если набор областей действия ключа пуст или содержит неизвестные значения
+var ErrInvalidScope = errors.New("invalid API key scope")
+
+// ErrInvalidAPIKeyName возвращается, если имя ключа слишком длинное
+var ErrInvalidAPIKeyName = errors.New("invalid API key name")
+
+// knownScopes перечисляет области действия в каноническом порядке
+var knownScopes = []string{models.ScopeRead, models.ScopeWrite, models.ScopeDelete}
+
+// HashAPIKey возвращает хеш API ключа, под которым ключ хранится в хранилище
+func HashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes проверяет области действия и возвращает их без повторов в каноническом порядке
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		requested[strings.ToLower(strings.TrimSpace(scope))] = true
+	}
+
+	normalized := make([]string, 0, len(requested))
+	for _, scope := range knownScopes {
+		if requested[scope] {
+			normalized = append(normalized, scope)
+			delete(requested, scope)
+		}
+	}
+	if len(requested) > 0 {
+		return nil, ErrInvalidScope
+	}
+	return normalized, nil
+}
+
+// apiKeyStorage возвращает хранилище API ключей
+func (s *URLServiceImpl) apiKeyStorage() (storage.APIKeyStorage, error) {
+	keys, ok := s.storage.(storage.APIKeyStorage)
+	if !ok {
+		return nil, ErrAPIKeysUnsupported
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a new API key for userID with the given scopes.
+// The returned token is shown to the caller once; only its hash is persisted.
+func (s *URLServiceImpl) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (models.APIKey, string, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateAPIKey")
+	defer span.End()
+
+	keys, err := s.apiKeyStorage()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	name = strings.TrimSpace(name)
+	if len(name) > maxAPIKeyNameLen {
+		return models.APIKey{}, "", ErrInvalidAPIKeyName
+	}
+	scopes, err = normalizeScopes(scopes)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	idBytes := make([]byte, 16)
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("error generating API key ID: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("error generating API key: %w", err)
+	}
+	token := apiKeyTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := models.APIKey{
+		ID:        hex.EncodeToString(idBytes),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(apiKeyTokenPrefix)+apiKeyDisplayChars],
+		Hash:      HashAPIKey(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := keys.SaveAPIKey(ctx, key); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("error saving API key: %w", err)
+	}
+
+	s.logger.Info("API key created",
+		zap.String("userID", userID),
+		zap.String("key_id", key.ID),
+		zap.Strings("scopes", scopes))
+	return key, token, nil
+}
+
+// ListAPIKeys returns all API keys of userID, including revoked ones.
+func (s *URLServiceImpl) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "URLService.ListAPIKeys")
+	defer span.End()
+
+	keys, err := s.apiKeyStorage()
+	if err != nil {
+		return nil, err
+	}
+	return keys.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey revokes an API key owned by userID.
+// Returns storage.ErrAPIKeyNotFound if the key does not exist or belongs to another user.
+func (s *URLServiceImpl) RevokeAPIKey(ctx context.Context, id, userID string) error {
+	ctx, span := tracing.Start(ctx, "URLService.RevokeAPIKey")
+	defer span.End()
+
+	keys, err := s.apiKeyStorage()
+	if err != nil {
+		return err
+	}
+	if err := keys.RevokeAPIKey(ctx, id, userID, time.Now()); err != nil {
+		return err
+	}
+
+	s.logger.Info("API key revoked", zap.String("userID", userID), zap.String("key_id", id))
+	return nil
+}
+
+// AuthenticateAPIKey resolves a bearer token to its API key.
+// Returns ErrInvalidAPIKey if the token is unknown or the key has been revoked.
+func (s *URLServiceImpl) AuthenticateAPIKey(ctx context.Context, token string) (models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "URLService.AuthenticateAPIKey")
+	defer span.End()
+
+	keys, err := s.apiKeyStorage()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if !strings.HasPrefix(token, apiKeyTokenPrefix) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := keys.GetAPIKeyByHash(ctx, HashAPIKey(token))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if key.Revoked() {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Canonical order", scopes: []string{"delete", "read"}, want: []string{"read", "delete"}},
+		{name: "Duplicates and case", scopes: []string{"Write", "write", " read "}, want: []string{"read", "write"}},
+		{name: "Empty", scopes: nil, wantErr: true},
+		{name: "Unknown scope", scopes: []string{"read", "admin"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeScopes(tt.scopes)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidScope)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	store := storage.NewMemoryStorage(zap.NewNop())
+	svc := newURLServiceImpl(store, &config.Config{BaseURL: "http://localhost:8080"}, zap.NewNop(), nil, nil)
+	ctx := context.Background()
+	t.Cleanup(func() { svc.Close(ctx) })
+
+	key, token, err := svc.CreateAPIKey(ctx, "user1", " ci bot ", []string{"write", "read"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, apiKeyTokenPrefix))
+	assert.True(t, strings.HasPrefix(token, key.Prefix))
+	assert.Equal(t, "ci bot", key.Name)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopeWrite}, key.Scopes)
+
+	// Хранится только хеш ключа
+	stored, err := store.GetAPIKeyByHash(ctx, HashAPIKey(token))
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, token)
+
+	got, err := svc.AuthenticateAPIKey(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", got.UserID)
+
+	_, err = svc.AuthenticateAPIKey(ctx, token+"x")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, err = svc.AuthenticateAPIKey(ctx, "user1.signature")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	assert.ErrorIs(t, svc.RevokeAPIKey(ctx, key.ID, "user2"), storage.ErrAPIKeyNotFound)
+	require.NoError(t, svc.RevokeAPIKey(ctx, key.ID, "user1"))
+
+	_, err = svc.AuthenticateAPIKey(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	keys, err := svc.ListAPIKeys(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, keys[0].Revoked())
+
+	_, _, err = svc.CreateAPIKey(ctx, "user1", "", []string{"admin"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultPorts — порты схем, которые не указываются в канонической форме URL
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// trackingParams — параметры отслеживания переходов, кроме параметров с префиксом utm_
+var trackingParams = map[string]struct{}{
+	"fbclid":  {},
+	"gclid":   {},
+	"yclid":   {},
+	"msclkid": {},
+}
+
+// CanonicalizeURL приводит URL к канонической форме, по которой ищутся дубликаты:
+// схема и хост в нижнем регистре, хост в ASCII форме (IDNA), без порта по умолчанию,
+// путь без сегментов "." и "..", параметры запроса отсортированы по имени.
+// Если dropTracking установлен, удаляются параметры отслеживания (utm_*, fbclid, gclid и др.).
+// Фрагмент сохраняется. Форма используется только для сравнения — переход выполняется
+// по URL в том виде, в каком его ввел пользователь.
+func CanonicalizeURL(rawURL string, dropTracking bool) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" {
+		return u.String(), nil
+	}
+
+	if u.Host != "" {
+		host, err := canonicalHost(u.Hostname())
+		if err != nil {
+			return "", err
+		}
+		port := u.Port()
+		if port == defaultPorts[u.Scheme] {
+			port = ""
+		}
+		switch {
+		case port != "":
+			u.Host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			u.Host = "[" + host + "]"
+		default:
+			u.Host = host
+		}
+	}
+
+	u.Path = removeDotSegments(u.Path)
+	u.RawPath = removeDotSegments(u.RawPath)
+	if u.Path == "" && u.Host != "" {
+		u.Path = "/"
+	}
+
+	u.RawQuery = canonicalQuery(u.RawQuery, dropTracking)
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// canonicalHost переводит хост в нижний регистр и кодирует метки
+// с символами вне ASCII в Punycode (xn--); завершающая точка удаляется
+func canonicalHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncode(label)
+		if err != nil {
+			return "", fmt.Errorf("host %q: %w", host, err)
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// removeDotSegments удаляет из пути сегменты "." и ".." (RFC 3986, раздел 5.2.4)
+func removeDotSegments(path string) string {
+	if path == "" {
+		return ""
+	}
+	segments := strings.Split(path, "/")
+	result := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			// Первый пустой сегмент абсолютного пути — корень, выше него подняться нельзя
+			if len(result) > 1 || (len(result) == 1 && result[0] != "") {
+				result = result[:len(result)-1]
+			}
+		default:
+			result = append(result, segment)
+			continue
+		}
+		if last {
+			// "/a/." и "/a/b/.." указывают на каталог и сохраняют завершающий слеш
+			result = append(result, "")
+		}
+	}
+	return strings.Join(result, "/")
+}
+
+// canonicalQuery сортирует параметры запроса по имени и при dropTracking
+// удаляет параметры отслеживания. Запрос, который не удается разобрать, не изменяется.
+func canonicalQuery(rawQuery string, dropTracking bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	if dropTracking {
+		for name := range values {
+			if isTrackingParam(name) {
+				delete(values, name)
+			}
+		}
+	}
+	// Encode сортирует параметры по имени, сохраняя порядок значений одного параметра
+	return values.Encode()
+}
+
+// isTrackingParam сообщает, является ли параметр запроса параметром отслеживания переходов
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "utm_") {
+		return true
+	}
+	_, ok := trackingParams[name]
+	return ok
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Параметры Punycode (RFC 3492, раздел 5)
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// punycodeEncode кодирует метку домена в Punycode (RFC 3492) без префикса xn--
+func punycodeEncode(label string) (string, error) {
+	runes := []rune(label)
+	var out strings.Builder
+	for _, r := range runes {
+		if r < 0x80 {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := punycodeInitialN, 0, punycodeInitialBias
+	for handled < len(runes) {
+		// Наименьший еще не закодированный символ
+		m := math.MaxInt32
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		if (m - n) > (math.MaxInt32-delta)/(handled+1) {
+			return "", fmt.Errorf("punycode overflow")
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := min(max(k-bias, punycodeTMin), punycodeTMax)
+				if q < t {
+					break
+				}
+				out.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			out.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// punycodeAdapt пересчитывает смещение после кодирования очередного символа
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > (punycodeBase-punycodeTMin)*punycodeTMax/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+// punycodeDigit возвращает символ цифры Punycode: a–z для 0–25, 0–9 для 26–35
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// canonicalURL возвращает каноническую форму originalURL для поиска дубликатов
+// с учетом конфигурации. URL, который не удается разобрать, сравнивается как есть.
+func (s *URLServiceImpl) canonicalURL(originalURL string) string {
+	canonical, err := CanonicalizeURL(originalURL, s.config.DropTrackingParams)
+	if err != nil {
+		return originalURL
+	}
+	return canonical
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		rawURL       string
+		dropTracking bool
+		want         string
+	}{
+		{name: "already canonical", rawURL: "https://example.com/path?a=1", want: "https://example.com/path?a=1"},
+		{name: "case of scheme and host", rawURL: "HTTP://Example.COM/Path", want: "http://example.com/Path"},
+		{name: "empty path", rawURL: "http://example.com", want: "http://example.com/"},
+		{name: "default http port", rawURL: "http://example.com:80/", want: "http://example.com/"},
+		{name: "default https port", rawURL: "https://example.com:443/a", want: "https://example.com/a"},
+		{name: "non-default port", rawURL: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "port of another scheme", rawURL: "https://example.com:80/", want: "https://example.com:80/"},
+		{name: "trailing dot in host", rawURL: "https://example.com./a", want: "https://example.com/a"},
+		{name: "dot segments", rawURL: "https://example.com/a/./b/../c", want: "https://example.com/a/c"},
+		{name: "dot segments above root", rawURL: "https://example.com/../../a", want: "https://example.com/a"},
+		{name: "trailing dot segment", rawURL: "https://example.com/a/b/..", want: "https://example.com/a/"},
+		{name: "sorted query", rawURL: "https://example.com/?b=2&a=1&a=0", want: "https://example.com/?a=1&a=0&b=2"},
+		{name: "empty query", rawURL: "https://example.com/?", want: "https://example.com/"},
+		{name: "tracking kept", rawURL: "https://example.com/?utm_source=x&id=1", want: "https://example.com/?id=1&utm_source=x"},
+		{name: "tracking dropped", rawURL: "https://example.com/?UTM_Source=x&id=1&fbclid=y", dropTracking: true, want: "https://example.com/?id=1"},
+		{name: "only tracking dropped", rawURL: "https://example.com/a?utm_medium=email", dropTracking: true, want: "https://example.com/a"},
+		{name: "fragment kept", rawURL: "https://example.com/a#Section", want: "https://example.com/a#Section"},
+		{name: "IDNA host", rawURL: "https://Пример.испытание/путь", want: "https://xn--e1afmkfd.xn--80akhbyknj4f/%D0%BF%D1%83%D1%82%D1%8C"},
+		{name: "mixed label", rawURL: "https://bücher.example/", want: "https://xn--bcher-kva.example/"},
+		{name: "IPv6 literal", rawURL: "http://[2001:DB8::1]:80/", want: "http://[2001:db8::1]/"},
+		{name: "opaque URL", rawURL: "MAILTO:user@example.com", want: "mailto:user@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeURL(tt.rawURL, tt.dropTracking)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := CanonicalizeURL("http://[::1", false)
+	assert.Error(t, err)
+}
+
+func TestPunycodeEncode(t *testing.T) {
+	// Примеры из RFC 3492, раздел 7.1, и распространенные домены
+	tests := map[string]string{
+		"пример":    "e1afmkfd",
+		"испытание": "80akhbyknj4f",
+		"münchen":   "mnchen-3ya",
+		"他们为什么不说中文": "ihqwcrb4cv8a8dqg056pqjye",
+		"почемужеонинеговорятпорусски": "b1abfaaepdrnnbgefbadotcwatmq2g4l",
+	}
+	for label, want := range tests {
+		got, err := punycodeEncode(label)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, label)
+	}
+}
+
+func TestCreateShortURLDeduplicatesEquivalentURLs(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-canonical")
+	shortURL, err := service.CreateShortURL(ctx, "http://Example.com")
+	require.NoError(t, err)
+
+	for _, equivalent := range []string{"http://example.com/", "http://example.com:80", "HTTP://EXAMPLE.COM/./"} {
+		got, err := service.CreateShortURL(ctx, equivalent)
+		assert.ErrorIs(t, err, storage.ErrOriginalURLConflict, equivalent)
+		assert.Equal(t, shortURL, got, equivalent)
+	}
+
+	// Переход выполняется по URL в том виде, в каком его ввел пользователь
+	originalURL, err := service.GetOriginalURL(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, "http://Example.com", originalURL)
+
+	_, err = service.CreateShortURL(ctx, "http://example.com/other")
+	assert.NoError(t, err)
+
+	t.Run("batch", func(t *testing.T) {
+		resp, err := service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+			{CorrelationID: "1", OriginalURL: "https://batch.example.com/a?y=2&x=1"},
+			{CorrelationID: "2", OriginalURL: "https://BATCH.example.com:443/a?x=1&y=2"},
+			{CorrelationID: "3", OriginalURL: "http://example.com:80/"},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp, 3)
+		assert.Equal(t, resp[0].ShortURL, resp[1].ShortURL)
+		assert.Equal(t, shortURL, resp[2].ShortURL)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		other, err := service.CreateShortURL(ctx, "https://update.example.com/v1")
+		require.NoError(t, err)
+
+		destination := "http://EXAMPLE.com:80/"
+		_, err = service.UpdateUserURL(ctx, other, "user-canonical", models.URLUpdate{OriginalURL: &destination})
+		assert.ErrorIs(t, err, storage.ErrOriginalURLConflict)
+	})
+
+	t.Run("tracking parameters", func(t *testing.T) {
+		service.config.DropTrackingParams = true
+		defer func() { service.config.DropTrackingParams = false }()
+
+		first, err := service.CreateShortURL(ctx, "https://news.example.com/post?utm_source=mail")
+		require.NoError(t, err)
+		second, err := service.CreateShortURL(ctx, "https://news.example.com/post?utm_source=twitter&utm_medium=social")
+		assert.ErrorIs(t, err, storage.ErrOriginalURLConflict)
+		assert.Equal(t, first, second)
+	})
+}
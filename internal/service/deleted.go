@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"go.uber.org/zap"
+)
+
+// RunDeletedURLPurger периодически окончательно удаляет из хранилища ссылки, помеченные
+// удаленными раньше, чем retention назад: после этого их уже нельзя восстановить.
+// Блокирует выполнение до отмены ctx. Если хранилище не реализует storage.DeletedURLPurger,
+// либо retention или interval неположительны, сразу возвращает управление.
+func RunDeletedURLPurger(ctx context.Context, store storage.URLStorage, retention, interval time.Duration, logger *zap.Logger) {
+	purger, ok := store.(storage.DeletedURLPurger)
+	if !ok || retention <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := purger.PurgeDeleted(ctx, now.Add(-retention))
+			if err != nil {
+				logger.Error("Error purging deleted URLs", zap.Error(err))
+				continue
+			}
+			if purged > 0 {
+				logger.Info("Deleted URLs purged", zap.Int64("count", purged))
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRunDeletedURLPurger(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(zap.NewNop())
+	require.NoError(t, store.Save(ctx, "gone", "https://example.com/gone", "user1"))
+	require.NoError(t, store.BatchDelete(ctx, []string{"gone"}, "user1"))
+
+	// Пока не истек срок хранения, ссылку можно восстановить
+	runCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	RunDeletedURLPurger(runCtx, store, time.Hour, 5*time.Millisecond, zap.NewNop())
+	cancel()
+	_, err := store.Get(ctx, "gone")
+	assert.ErrorIs(t, err, storage.ErrURLDeleted)
+
+	runCtx, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	RunDeletedURLPurger(runCtx, store, time.Nanosecond, 5*time.Millisecond, zap.NewNop())
+	cancel()
+	_, err = store.Get(ctx, "gone")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// Нулевой срок хранения отключает очистку
+	done := make(chan struct{})
+	go func() {
+		RunDeletedURLPurger(ctx, store, 0, time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger with zero retention did not return")
+	}
+}
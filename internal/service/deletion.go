@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Параметры очереди удаления по умолчанию
+const (
+	defaultDeleteJobWorkers      = 2
+	defaultDeleteJobMaxAttempts  = 5
+	defaultDeleteJobRetryBackoff = time.Second
+	maxDeleteJobRetryBackoff     = 5 * time.Minute
+	deleteJobQueueSize           = 1024
+	deleteJobSaveTimeout         = 5 * time.Second
+)
+
+// ErrDeletionQueueClosed возвращается при постановке задачи в остановленную очередь
+var ErrDeletionQueueClosed = errors.New("deletion queue is closed")
+
+// DeleteFunc выполняет удаление URL пользователя (например, URLServiceImpl.BatchDeleteURLs).
+type DeleteFunc func(ctx context.Context, shortURLs []string, userID string) error
+
+// DeletionQueue обрабатывает задачи асинхронного удаления URL пулом воркеров.
+// Состояние задач сохраняется в хранилище, поэтому незавершенные задачи
+// возобновляются после перезапуска. Неудачные попытки повторяются
+// с экспоненциальной задержкой, пока не будет исчерпан лимит попыток.
+type DeletionQueue struct {
+	store       storage.DeleteJobStorage
+	deleteFn    DeleteFunc
+	logger      *zap.Logger
+	maxAttempts int
+	backoff     time.Duration
+
+	jobs   chan models.DeleteJob
+	stop   chan struct{}      // Закрывается при остановке очереди
+	ctx    context.Context    // Контекст выполнения задач
+	cancel context.CancelFunc // Прерывает выполняющиеся задачи, если остановка не уложилась в срок
+	wg     sync.WaitGroup
+	depth  atomic.Int64 // Количество задач, ожидающих воркера
+
+	closeOnce sync.Once
+}
+
+// NewDeletionQueue создает очередь удаления, запускает workers воркеров
+// и возобновляет незавершенные задачи из хранилища.
+// Нулевые параметры заменяются значениями по умолчанию.
+func NewDeletionQueue(store storage.DeleteJobStorage, deleteFn DeleteFunc, logger *zap.Logger, workers, maxAttempts int, backoff time.Duration) *DeletionQueue {
+	if workers <= 0 {
+		workers = defaultDeleteJobWorkers
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultDeleteJobMaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = defaultDeleteJobRetryBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &DeletionQueue{
+		store:       store,
+		deleteFn:    deleteFn,
+		logger:      logger,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		jobs:        make(chan models.DeleteJob, deleteJobQueueSize),
+		stop:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	// Незавершенные задачи ставятся в очередь до приема новых, чтобы
+	// только что созданная задача не была подхвачена повторно
+	q.resume()
+
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+
+	return q
+}
+
+// Enqueue сохраняет новую задачу удаления и ставит ее в очередь.
+// Возвращает сохраненную задачу со статусом models.DeleteJobPending.
+func (q *DeletionQueue) Enqueue(ctx context.Context, userID string, shortURLs []string) (models.DeleteJob, error) {
+	select {
+	case <-q.stop:
+		return models.DeleteJob{}, ErrDeletionQueueClosed
+	default:
+	}
+
+	id, err := newDeleteJobID()
+	if err != nil {
+		return models.DeleteJob{}, err
+	}
+
+	now := time.Now().UTC()
+	job := models.DeleteJob{
+		ID:        id,
+		UserID:    userID,
+		ShortURLs: shortURLs,
+		Status:    models.DeleteJobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := q.store.SaveDeleteJob(ctx, job); err != nil {
+		return models.DeleteJob{}, fmt.Errorf("error saving delete job: %w", err)
+	}
+
+	q.submit(job)
+	return job, nil
+}
+
+// Close прекращает прием задач и дожидается завершения выполняющихся задач или отмены ctx.
+// Задачи, не успевшие выполниться, остаются в хранилище и будут возобновлены при следующем запуске.
+func (q *DeletionQueue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		// Задачи, оставшиеся в очереди, больше не ожидают воркера в этом процессе
+		q.addDepth(-q.depth.Load())
+		return nil
+	case <-ctx.Done():
+		// Прерываем выполняющиеся задачи: они останутся незавершенными в хранилище
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// submit передает задачу воркерам, не блокируя вызывающую горутину при заполненной очереди
+func (q *DeletionQueue) submit(job models.DeleteJob) {
+	q.addDepth(1)
+
+	select {
+	case q.jobs <- job:
+		return
+	default:
+	}
+
+	go func() {
+		select {
+		case q.jobs <- job:
+		case <-q.stop:
+		}
+	}()
+}
+
+// addDepth изменяет глубину очереди и соответствующую метрику
+func (q *DeletionQueue) addDepth(delta int64) {
+	q.depth.Add(delta)
+	metrics.DeletionQueueDepth.Add(float64(delta))
+}
+
+// resume ставит в очередь незавершенные задачи, сохраненные до перезапуска
+func (q *DeletionQueue) resume() {
+	ctx, cancel := context.WithTimeout(q.ctx, deleteJobSaveTimeout)
+	defer cancel()
+
+	jobs, err := q.store.ListUnfinishedDeleteJobs(ctx)
+	if err != nil {
+		q.logger.Error("Error loading unfinished delete jobs", zap.Error(err))
+		return
+	}
+	if len(jobs) > 0 {
+		q.logger.Info("Resuming unfinished delete jobs", zap.Int("count", len(jobs)))
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		if job.NextAttemptAt != nil && job.NextAttemptAt.After(now) {
+			q.retryAfter(job, job.NextAttemptAt.Sub(now))
+			continue
+		}
+		q.submit(job)
+	}
+}
+
+// worker обрабатывает задачи до остановки очереди
+func (q *DeletionQueue) worker() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case job := <-q.jobs:
+			q.addDepth(-1)
+			q.process(job)
+		}
+	}
+}
+
+// process выполняет одну попытку удаления и сохраняет ее результат
+func (q *DeletionQueue) process(job models.DeleteJob) {
+	job.Status = models.DeleteJobRunning
+	job.Attempts++
+	job.NextAttemptAt = nil
+	job.UpdatedAt = time.Now().UTC()
+	q.save(job)
+
+	err := q.deleteFn(q.ctx, job.ShortURLs, job.UserID)
+	if err != nil && q.ctx.Err() != nil {
+		// Очередь остановлена посреди попытки: задача возобновится после перезапуска
+		return
+	}
+
+	job.UpdatedAt = time.Now().UTC()
+	switch {
+	case err == nil:
+		job.Status = models.DeleteJobCompleted
+		job.LastError = ""
+		q.logger.Info("Delete job completed",
+			zap.String("job_id", job.ID),
+			zap.String("userID", job.UserID),
+			zap.Int("count", len(job.ShortURLs)))
+	case job.Attempts >= q.maxAttempts:
+		job.Status = models.DeleteJobFailed
+		job.LastError = err.Error()
+		q.logger.Error("Delete job failed",
+			zap.String("job_id", job.ID),
+			zap.Int("attempts", job.Attempts),
+			zap.Error(err))
+	default:
+		delay := q.retryDelay(job.Attempts)
+		nextAttemptAt := job.UpdatedAt.Add(delay)
+		job.Status = models.DeleteJobPending
+		job.LastError = err.Error()
+		job.NextAttemptAt = &nextAttemptAt
+		q.logger.Warn("Delete job attempt failed, will retry",
+			zap.String("job_id", job.ID),
+			zap.Int("attempt", job.Attempts),
+			zap.Duration("retry_in", delay),
+			zap.Error(err))
+		q.save(job)
+		q.retryAfter(job, delay)
+		return
+	}
+	q.save(job)
+}
+
+// retryDelay возвращает экспоненциальную задержку перед попыткой attempt+1
+func (q *DeletionQueue) retryDelay(attempt int) time.Duration {
+	delay := q.backoff
+	for i := 1; i < attempt && delay < maxDeleteJobRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxDeleteJobRetryBackoff {
+		delay = maxDeleteJobRetryBackoff
+	}
+	return delay
+}
+
+// retryAfter повторно ставит задачу в очередь через delay, если очередь не остановлена
+func (q *DeletionQueue) retryAfter(job models.DeleteJob, delay time.Duration) {
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			q.submit(job)
+		case <-q.stop:
+		}
+	}()
+}
+
+// save сохраняет состояние задачи; ошибка только логируется, так как задача
+// в худшем случае будет выполнена повторно после перезапуска, а удаление идемпотентно
+func (q *DeletionQueue) save(job models.DeleteJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), deleteJobSaveTimeout)
+	defer cancel()
+
+	if err := q.store.SaveDeleteJob(ctx, job); err != nil {
+		q.logger.Error("Error saving delete job state",
+			zap.String("job_id", job.ID),
+			zap.String("status", job.Status),
+			zap.Error(err))
+	}
+}
+
+// newDeleteJobID генерирует случайный идентификатор задачи
+func newDeleteJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating delete job ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// waitForJobStatus ожидает, пока задача перейдет в статус status
+func waitForJobStatus(t *testing.T, store storage.DeleteJobStorage, id, status string) models.DeleteJob {
+	t.Helper()
+	var job models.DeleteJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = store.GetDeleteJob(context.Background(), id)
+		return err == nil && job.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestDeletionQueue(t *testing.T) {
+	errTransient := errors.New("transient error")
+
+	tests := []struct {
+		name           string
+		failures       int32 // Количество неудачных попыток перед успехом
+		maxAttempts    int
+		expectedStatus string
+		expectedTries  int
+	}{
+		{name: "Success on first attempt", failures: 0, maxAttempts: 3, expectedStatus: models.DeleteJobCompleted, expectedTries: 1},
+		{name: "Success after retries", failures: 2, maxAttempts: 3, expectedStatus: models.DeleteJobCompleted, expectedTries: 3},
+		{name: "Fails after max attempts", failures: 10, maxAttempts: 3, expectedStatus: models.DeleteJobFailed, expectedTries: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStorage(zap.NewNop())
+			var calls atomic.Int32
+			deleteFn := func(ctx context.Context, shortURLs []string, userID string) error {
+				if calls.Add(1) <= tt.failures {
+					return errTransient
+				}
+				return nil
+			}
+
+			queue := NewDeletionQueue(store, deleteFn, zap.NewNop(), 1, tt.maxAttempts, time.Millisecond)
+			defer queue.Close(context.Background())
+
+			job, err := queue.Enqueue(context.Background(), "user1", []string{"abc"})
+			require.NoError(t, err)
+			assert.Equal(t, models.DeleteJobPending, job.Status)
+
+			job = waitForJobStatus(t, store, job.ID, tt.expectedStatus)
+			assert.Equal(t, tt.expectedTries, job.Attempts)
+			assert.Equal(t, "user1", job.UserID)
+			if tt.expectedStatus == models.DeleteJobFailed {
+				assert.Equal(t, errTransient.Error(), job.LastError)
+			} else {
+				assert.Empty(t, job.LastError)
+			}
+		})
+	}
+}
+
+func TestDeletionQueueResumesUnfinishedJobs(t *testing.T) {
+	store := storage.NewMemoryStorage(zap.NewNop())
+	ctx := context.Background()
+
+	// Задача, прерванная остановкой сервиса
+	require.NoError(t, store.SaveDeleteJob(ctx, models.DeleteJob{
+		ID:        "job1",
+		UserID:    "user1",
+		ShortURLs: []string{"abc"},
+		Status:    models.DeleteJobRunning,
+		Attempts:  1,
+	}))
+
+	deleted := make(chan []string, 1)
+	queue := NewDeletionQueue(store, func(ctx context.Context, shortURLs []string, userID string) error {
+		deleted <- shortURLs
+		return nil
+	}, zap.NewNop(), 1, 3, time.Millisecond)
+	defer queue.Close(ctx)
+
+	job := waitForJobStatus(t, store, "job1", models.DeleteJobCompleted)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, []string{"abc"}, <-deleted)
+}
+
+func TestDeletionQueueClose(t *testing.T) {
+	store := storage.NewMemoryStorage(zap.NewNop())
+	queue := NewDeletionQueue(store, func(ctx context.Context, shortURLs []string, userID string) error {
+		return nil
+	}, zap.NewNop(), 1, 3, time.Millisecond)
+
+	require.NoError(t, queue.Close(context.Background()))
+
+	_, err := queue.Enqueue(context.Background(), "user1", []string{"abc"})
+	assert.ErrorIs(t, err, ErrDeletionQueueClosed)
+}
+
+func TestGetDeletionJob(t *testing.T) {
+	s, cleanup := setupTestService(t)
+	defer cleanup()
+	defer s.Close(context.Background())
+
+	store := s.GetStorage().(*storage.InstrumentedStorage)
+
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, "abc", "https://example.com", "user1"))
+
+	job, err := s.EnqueueDeletion(ctx, []string{"abc"}, "user1")
+	require.NoError(t, err)
+
+	waitForJobStatus(t, store, job.ID, models.DeleteJobCompleted)
+	_, err = store.Get(ctx, "abc")
+	assert.ErrorIs(t, err, storage.ErrURLDeleted)
+
+	got, err := s.GetDeletionJob(ctx, job.ID, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, models.DeleteJobCompleted, got.Status)
+
+	_, err = s.GetDeletionJob(ctx, job.ID, "user2")
+	assert.ErrorIs(t, err, storage.ErrDeleteJobNotFound)
+}
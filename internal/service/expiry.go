@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidExpiry возвращается при некорректном сроке действия ссылки:
+// одновременно заданы expires_at и ttl, ttl неположителен или больше MaxTTLSeconds,
+// или момент истечения уже прошел.
+var ErrInvalidExpiry = errors.New("invalid expiry")
+
+// MaxTTLSeconds — наибольшее время жизни ссылки в секундах (100 лет).
+// Ограничение не дает now + ttl переполнить time.Duration.
+const MaxTTLSeconds = 100 * 365 * 24 * 60 * 60
+
+// ResolveExpiry вычисляет момент истечения срока действия ссылки по параметрам запроса.
+// expiresAt задает абсолютный момент, ttlSeconds — время жизни в секундах относительно now.
+// Если ни один параметр не задан, возвращается нулевое время (бессрочная ссылка).
+// Вычисленный момент истечения всегда позже now.
+func ResolveExpiry(expiresAt *time.Time, ttlSeconds int64, now time.Time) (time.Time, error) {
+	var resolved time.Time
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return time.Time{}, ErrInvalidExpiry
+	case expiresAt != nil:
+		resolved = *expiresAt
+	case ttlSeconds < 0, ttlSeconds > MaxTTLSeconds:
+		return time.Time{}, ErrInvalidExpiry
+	case ttlSeconds > 0:
+		resolved = now.Add(time.Duration(ttlSeconds) * time.Second)
+	default:
+		return time.Time{}, nil
+	}
+
+	if !resolved.After(now) {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	return resolved.UTC(), nil
+}
+
+// RunExpiredURLReaper периодически удаляет из хранилища ссылки с истекшим сроком действия.
+// Блокирует выполнение до отмены ctx. Если хранилище не реализует storage.ExpiredURLPurger
+// или interval неположителен, сразу возвращает управление.
+func RunExpiredURLReaper(ctx context.Context, store storage.URLStorage, interval time.Duration, logger *zap.Logger) {
+	purger, ok := store.(storage.ExpiredURLPurger)
+	if !ok || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := purger.PurgeExpired(ctx, now)
+			if err != nil {
+				logger.Error("Error purging expired URLs", zap.Error(err))
+				continue
+			}
+			if purged > 0 {
+				logger.Info("Expired URLs purged", zap.Int64("count", purged))
+			}
+		}
+	}
+}
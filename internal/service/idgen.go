@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+)
+
+// Поддерживаемые стратегии генерации коротких идентификаторов
+const (
+	IDStrategyHash    = "hash"    // Префикс SHA-256 хеша оригинального URL (по умолчанию)
+	IDStrategyRandom  = "random"  // Криптографически случайная base62 строка
+	IDStrategyCounter = "counter" // Монотонный счетчик в base62
+	IDStrategyHashids = "hashids" // Обфусцированный обратимый счетчик в стиле Hashids
+)
+
+// defaultShortIDLength — длина короткого идентификатора по умолчанию
+const defaultShortIDLength = 8
+
+// base62Alphabet — алфавит для base62 кодирования
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidShortID возвращается при попытке декодировать некорректный идентификатор
+var ErrInvalidShortID = errors.New("invalid short ID")
+
+// IDGenerator определяет стратегию генерации коротких идентификаторов.
+type IDGenerator interface {
+	// Generate возвращает кандидата в короткие идентификаторы для originalURL.
+	// attempt — номер попытки (0 для первой), увеличивается сервисом при коллизии,
+	// чтобы детерминированные стратегии могли выдать другой идентификатор.
+	Generate(originalURL string, attempt int) (string, error)
+}
+
+// NewIDGenerator создает генератор идентификаторов по настройкам конфигурации.
+// Пустая стратегия соответствует IDStrategyHash, нулевая длина — defaultShortIDLength.
+func NewIDGenerator(cfg *config.Config) (IDGenerator, error) {
+	length := cfg.ShortIDLength
+	if length <= 0 {
+		length = defaultShortIDLength
+	}
+
+	switch cfg.ShortIDStrategy {
+	case "", IDStrategyHash:
+		return NewHashIDGenerator(length), nil
+	case IDStrategyRandom:
+		return NewRandomIDGenerator(length), nil
+	case IDStrategyCounter:
+		return NewCounterIDGenerator(cfg.ShortIDCounterStart), nil
+	case IDStrategyHashids:
+		return NewHashidsGenerator(cfg.ShortIDSalt, length, cfg.ShortIDCounterStart), nil
+	default:
+		return nil, fmt.Errorf("unknown short ID strategy: %q", cfg.ShortIDStrategy)
+	}
+}
+
+// HashIDGenerator формирует идентификатор из префикса SHA-256 хеша оригинального URL.
+// Один и тот же URL всегда получает один и тот же идентификатор.
+type HashIDGenerator struct {
+	length int
+}
+
+// NewHashIDGenerator создает HashIDGenerator с заданной длиной идентификатора.
+func NewHashIDGenerator(length int) *HashIDGenerator {
+	// base64 от 32 байт SHA-256 дает 44 символа, последний из которых — паддинг
+	if length > 43 {
+		length = 43
+	}
+	return &HashIDGenerator{length: length}
+}
+
+// Generate возвращает префикс хеша URL. При attempt > 0 к URL добавляется соль
+// в виде номера попытки, что дает другой детерминированный идентификатор.
+func (g *HashIDGenerator) Generate(originalURL string, attempt int) (string, error) {
+	input := originalURL
+	if attempt > 0 {
+		input = fmt.Sprintf("%s#%d", originalURL, attempt)
+	}
+	hash := sha256.Sum256([]byte(input))
+	return base64.URLEncoding.EncodeToString(hash[:])[:g.length], nil
+}
+
+// RandomIDGenerator формирует криптографически случайные base62 идентификаторы.
+// Подходит для приватных ссылок, которые не должны угадываться.
+type RandomIDGenerator struct {
+	length int
+}
+
+// NewRandomIDGenerator создает RandomIDGenerator с заданной длиной идентификатора.
+func NewRandomIDGenerator(length int) *RandomIDGenerator {
+	return &RandomIDGenerator{length: length}
+}
+
+// Generate возвращает новую случайную строку независимо от URL и номера попытки.
+func (g *RandomIDGenerator) Generate(_ string, _ int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(base62Alphabet)))
+	var sb strings.Builder
+	sb.Grow(g.length)
+	for i := 0; i < g.length; i++ {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", fmt.Errorf("error generating random short ID: %w", err)
+		}
+		sb.WriteByte(base62Alphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// shortIDCounterName — имя счетчика коротких идентификаторов в хранилище
+const shortIDCounterName = "short_id"
+
+// counterBlockSize — количество значений счетчика, резервируемых в хранилище за одно обращение.
+// Неиспользованный остаток блока при перезапуске пропускается.
+const counterBlockSize = 100
+
+// counterSequence выдает последовательные значения счетчика, начиная со start.
+// Без хранилища счетчик живет в памяти процесса. С хранилищем значения резервируются
+// в нем блоками, поэтому счетчик продолжается после перезапуска, а реплики с общим
+// хранилищем не выдают одинаковых значений.
+type counterSequence struct {
+	mu    sync.Mutex
+	start uint64
+	next  uint64
+	limit uint64 // Конец зарезервированного блока
+	store storage.CounterStorage
+}
+
+// newCounterSequence создает счетчик в памяти процесса со стартовым значением start
+func newCounterSequence(start uint64) counterSequence {
+	return counterSequence{start: start, next: start, limit: start}
+}
+
+// take возвращает следующее значение счетчика, при необходимости резервируя новый блок
+func (c *counterSequence) take() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil && c.next == c.limit {
+		first, err := c.store.ReserveCounter(context.Background(), shortIDCounterName, c.start, counterBlockSize)
+		if err != nil {
+			return 0, fmt.Errorf("error reserving short ID counter: %w", err)
+		}
+		c.next, c.limit = first, first+counterBlockSize
+	}
+	n := c.next
+	c.next++
+	return n, nil
+}
+
+// useStorage переводит счетчик на хранилище; следующее значение будет взято из нового блока
+func (c *counterSequence) useStorage(store storage.CounterStorage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store = store
+	c.limit = c.next
+}
+
+// counterGenerator — генератор на основе счетчика, значения которого можно хранить в хранилище
+type counterGenerator interface {
+	useCounterStorage(store storage.CounterStorage)
+}
+
+// CounterIDGenerator формирует идентификаторы из монотонного счетчика в base62.
+// Дает самые короткие идентификаторы. Сервис хранит счетчик в хранилище ссылок,
+// поэтому после перезапуска идентификаторы продолжают последовательность.
+type CounterIDGenerator struct {
+	counter counterSequence
+}
+
+// NewCounterIDGenerator создает CounterIDGenerator, первый идентификатор которого соответствует start.
+func NewCounterIDGenerator(start uint64) *CounterIDGenerator {
+	return &CounterIDGenerator{counter: newCounterSequence(start)}
+}
+
+// Generate возвращает следующее значение счетчика в base62.
+func (g *CounterIDGenerator) Generate(_ string, _ int) (string, error) {
+	n, err := g.counter.take()
+	if err != nil {
+		return "", err
+	}
+	return encodeBase62(n, base62Alphabet), nil
+}
+
+// useCounterStorage хранит счетчик в store
+func (g *CounterIDGenerator) useCounterStorage(store storage.CounterStorage) {
+	g.counter.useStorage(store)
+}
+
+// HashidsGenerator формирует обфусцированные обратимые идентификаторы из счетчика
+// по мотивам алгоритма Hashids: алфавит перемешивается солью, а число кодируется
+// в алфавите, дополнительно перемешанном символом-«лотереей».
+// Идентификаторы выглядят случайными, но декодируются обратно в номер через Decode.
+type HashidsGenerator struct {
+	counter   counterSequence
+	salt      string
+	alphabet  string // Основной алфавит кодирования
+	guards    string // Символы для дополнения до минимальной длины
+	minLength int
+}
+
+// hashidsGuardCount — количество символов алфавита, зарезервированных для дополнения
+const hashidsGuardCount = 4
+
+// NewHashidsGenerator создает HashidsGenerator с солью, минимальной длиной идентификатора
+// и стартовым значением счетчика.
+func NewHashidsGenerator(salt string, minLength int, start uint64) *HashidsGenerator {
+	shuffled := consistentShuffle(base62Alphabet, salt)
+	return &HashidsGenerator{
+		counter:   newCounterSequence(start),
+		salt:      salt,
+		guards:    shuffled[:hashidsGuardCount],
+		alphabet:  shuffled[hashidsGuardCount:],
+		minLength: minLength,
+	}
+}
+
+// Generate возвращает следующее значение счетчика в обфусцированном виде.
+func (g *HashidsGenerator) Generate(_ string, _ int) (string, error) {
+	n, err := g.counter.take()
+	if err != nil {
+		return "", err
+	}
+	return g.Encode(n), nil
+}
+
+// useCounterStorage хранит счетчик в store
+func (g *HashidsGenerator) useCounterStorage(store storage.CounterStorage) {
+	g.counter.useStorage(store)
+}
+
+// Encode кодирует число в обфусцированный идентификатор.
+func (g *HashidsGenerator) Encode(n uint64) string {
+	lottery := g.alphabet[n%uint64(len(g.alphabet))]
+	alphabet := consistentShuffle(g.alphabet, string(lottery)+g.salt)
+
+	var sb strings.Builder
+	sb.WriteByte(lottery)
+	sb.WriteString(encodeBase62(n, alphabet))
+	for i := 0; sb.Len() < g.minLength; i++ {
+		sb.WriteByte(g.guards[(n+uint64(i))%uint64(len(g.guards))])
+	}
+	return sb.String()
+}
+
+// Decode восстанавливает число из идентификатора, сформированного Encode.
+// Возвращает ErrInvalidShortID, если идентификатор не мог быть получен этим генератором.
+func (g *HashidsGenerator) Decode(id string) (uint64, error) {
+	id = strings.TrimRight(id, g.guards)
+	if len(id) < 2 {
+		return 0, ErrInvalidShortID
+	}
+
+	lottery := id[0]
+	if strings.IndexByte(g.alphabet, lottery) < 0 {
+		return 0, ErrInvalidShortID
+	}
+	alphabet := consistentShuffle(g.alphabet, string(lottery)+g.salt)
+
+	n, err := decodeBase62(id[1:], alphabet)
+	if err != nil {
+		return 0, err
+	}
+	// Проверяем, что идентификатор канонический, а не подобран вручную
+	if strings.TrimRight(g.Encode(n), g.guards) != id {
+		return 0, ErrInvalidShortID
+	}
+	return n, nil
+}
+
+// encodeBase62 кодирует число в позиционной системе с основанием len(alphabet).
+func encodeBase62(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+	var buf []byte
+	for n > 0 {
+		buf = append(buf, alphabet[n%base])
+		n /= base
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// decodeBase62 декодирует строку, закодированную encodeBase62 с тем же алфавитом.
+func decodeBase62(s, alphabet string) (uint64, error) {
+	base := uint64(len(alphabet))
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		idx := strings.IndexByte(alphabet, s[i])
+		if idx < 0 {
+			return 0, ErrInvalidShortID
+		}
+		n = n*base + uint64(idx)
+	}
+	return n, nil
+}
+
+// consistentShuffle детерминированно перемешивает алфавит с использованием соли
+// (перестановка из алгоритма Hashids). Пустая соль оставляет алфавит без изменений.
+func consistentShuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+	result := []byte(alphabet)
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		integer := int(salt[v])
+		p += integer
+		j := (integer + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v++
+	}
+	return string(result)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/config"
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		want     IDGenerator
+		wantErr  bool
+	}{
+		{name: "Default strategy", strategy: "", want: &HashIDGenerator{}},
+		{name: "Hash", strategy: IDStrategyHash, want: &HashIDGenerator{}},
+		{name: "Random", strategy: IDStrategyRandom, want: &RandomIDGenerator{}},
+		{name: "Counter", strategy: IDStrategyCounter, want: &CounterIDGenerator{}},
+		{name: "Hashids", strategy: IDStrategyHashids, want: &HashidsGenerator{}},
+		{name: "Unknown", strategy: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewIDGenerator(&config.Config{ShortIDStrategy: tt.strategy})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, gen)
+		})
+	}
+}
+
+func TestHashIDGenerator(t *testing.T) {
+	gen := NewHashIDGenerator(8)
+
+	first, err := gen.Generate("https://example.com", 0)
+	require.NoError(t, err)
+	assert.Len(t, first, 8)
+
+	// Детерминированность: тот же URL и попытка дают тот же идентификатор
+	again, _ := gen.Generate("https://example.com", 0)
+	assert.Equal(t, first, again)
+
+	// Соль попытки дает другой идентификатор
+	salted, _ := gen.Generate("https://example.com", 1)
+	assert.NotEqual(t, first, salted)
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	gen := NewRandomIDGenerator(12)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := gen.Generate("https://example.com", 0)
+		require.NoError(t, err)
+		assert.Len(t, id, 12)
+		for _, c := range id {
+			assert.Contains(t, base62Alphabet, string(c))
+		}
+		assert.False(t, seen[id], "random IDs should not repeat")
+		seen[id] = true
+	}
+}
+
+func TestCounterIDGenerator(t *testing.T) {
+	gen := NewCounterIDGenerator(61)
+
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i], _ = gen.Generate("", 0)
+	}
+	assert.Equal(t, []string{"z", "10", "11"}, ids)
+}
+
+func TestCounterIDGeneratorSurvivesRestart(t *testing.T) {
+	hashids := NewHashidsGenerator("", defaultShortIDLength, 0)
+	tests := []struct {
+		strategy string
+		decode   func(id string) (uint64, error)
+	}{
+		{strategy: IDStrategyCounter, decode: func(id string) (uint64, error) { return decodeBase62(id, base62Alphabet) }},
+		{strategy: IDStrategyHashids, decode: hashids.Decode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			cfg := &config.Config{
+				BaseURL:             "http://localhost:8080",
+				FileStoragePath:     filepath.Join(t.TempDir(), "storage.json"),
+				ShortIDStrategy:     tt.strategy,
+				ShortIDCounterStart: 1000,
+			}
+			ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-counter")
+
+			for restart := 0; restart < 3; restart++ {
+				svc, err := NewURLService(cfg, zap.NewNop())
+				require.NoError(t, err)
+				for i := 0; i < 5; i++ {
+					shortURL, err := svc.CreateShortURL(ctx, fmt.Sprintf("https://counter.example.com/%d/%d", restart, i))
+					require.NoError(t, err)
+
+					// После перезапуска счетчик продолжается со следующего блока,
+					// а не со стартового значения
+					n, err := tt.decode(shortURL)
+					require.NoError(t, err)
+					assert.Equal(t, uint64(1000+restart*counterBlockSize+i), n)
+				}
+				require.NoError(t, svc.(*URLServiceImpl).Close(context.Background()))
+			}
+		})
+	}
+}
+
+func TestHashidsGenerator(t *testing.T) {
+	gen := NewHashidsGenerator("campaign-salt", 6, 0)
+
+	seen := make(map[string]bool)
+	for n := uint64(0); n < 1000; n++ {
+		id := gen.Encode(n)
+		assert.GreaterOrEqual(t, len(id), 6)
+		assert.False(t, seen[id], "IDs should be unique")
+		seen[id] = true
+
+		decoded, err := gen.Decode(id)
+		require.NoError(t, err)
+		assert.Equal(t, n, decoded)
+	}
+
+	// Генератор с другой солью выдает другие идентификаторы
+	other := NewHashidsGenerator("other-salt", 6, 0)
+	assert.NotEqual(t, gen.Encode(42), other.Encode(42))
+
+	// Generate использует последовательный счетчик
+	first, _ := gen.Generate("", 0)
+	decoded, err := gen.Decode(first)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), decoded)
+
+	_, err = gen.Decode("!")
+	assert.ErrorIs(t, err, ErrInvalidShortID)
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// Ограничения на длину заголовка и описания ссылки (в символах)
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 2000
+)
+
+// ErrInvalidMetadata возвращается, если заголовок или описание ссылки слишком длинные
+// или не являются корректной строкой UTF-8
+var ErrInvalidMetadata = errors.New("invalid link metadata")
+
+// ValidateMetadata проверяет заголовок и описание ссылки.
+// Пустые значения допустимы: метаданные необязательны.
+func ValidateMetadata(title, description string) error {
+	if !utf8.ValidString(title) || utf8.RuneCountInString(title) > maxTitleLength {
+		return ErrInvalidMetadata
+	}
+	if !utf8.ValidString(description) || utf8.RuneCountInString(description) > maxDescriptionLength {
+		return ErrInvalidMetadata
+	}
+	return nil
+}
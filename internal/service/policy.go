@@ -0,0 +1,494 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrURLPolicyViolation возвращается (через PolicyViolation), если URL назначения запрещен политикой
+var ErrURLPolicyViolation = errors.New("URL is rejected by policy")
+
+// Правила политики URL назначения, которые могут сработать при проверке
+const (
+	PolicyRuleInvalidURL    = "invalid_url"    // URL не удается разобрать или в нем нет хоста
+	PolicyRuleScheme        = "scheme"         // Схема не входит в список разрешенных
+	PolicyRuleSelfReference = "self_reference" // URL указывает на сам сервис и создал бы петлю перенаправлений
+	PolicyRuleDenyHost      = "deny_host"      // Хост совпал с шаблоном из списка запрещенных
+	PolicyRuleAllowHost     = "allow_host"     // Список разрешенных хостов задан, и хост в него не входит
+	PolicyRulePrivateIP     = "private_ip"     // Хост — IP адрес из частной, локальной или loopback сети или имя localhost
+)
+
+// defaultAllowedSchemes — схемы, разрешенные, если файл правил их не задает
+var defaultAllowedSchemes = []string{"http", "https"}
+
+// PolicyViolation описывает сработавшее правило политики URL назначения.
+// Реализует error; errors.Is(err, ErrURLPolicyViolation) для нее выполняется.
+type PolicyViolation struct {
+	Rule          string // Сработавшее правило (PolicyRule*)
+	Pattern       string // Шаблон из списка хостов, с которым совпал хост (для deny_host)
+	Message       string // Пояснение для пользователя
+	URL           string // Проверенный URL
+	CorrelationID string // Идентификатор записи пакетного запроса, в которой найден URL
+}
+
+// Error реализует интерфейс error
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s (rule %s)", ErrURLPolicyViolation, v.Message, v.Rule)
+}
+
+// Unwrap позволяет сравнивать нарушение с ErrURLPolicyViolation через errors.Is
+func (v *PolicyViolation) Unwrap() error {
+	return ErrURLPolicyViolation
+}
+
+// PolicyRules — правила политики URL назначения в формате файла правил (JSON).
+//
+// Шаблоны хостов в AllowHosts и DenyHosts: точное имя ("example.com"), любой поддомен
+// ("*.example.com", само example.com не совпадает), IP адрес или подсеть в нотации CIDR
+// ("10.0.0.0/8"). Подсети сравниваются только с IP адресами, записанными в URL:
+// имена хостов не разрешаются через DNS.
+type PolicyRules struct {
+	AllowedSchemes  []string `json:"allowed_schemes"`   // Разрешенные схемы; пусто — http и https
+	AllowHosts      []string `json:"allow_hosts"`       // Если задан, разрешены только совпавшие хосты
+	DenyHosts       []string `json:"deny_hosts"`        // Запрещенные хосты; проверяются раньше разрешенных
+	AllowPrivateIPs bool     `json:"allow_private_ips"` // Разрешить IP адреса частных и loopback сетей и localhost
+}
+
+// hostMatcher сопоставляет хост со списком шаблонов PolicyRules
+type hostMatcher struct {
+	patterns []hostPattern
+}
+
+// hostPattern — разобранный шаблон хоста
+type hostPattern struct {
+	raw    string       // Шаблон в том виде, в каком он записан в правилах
+	host   string       // Точное имя хоста (в канонической форме)
+	suffix string       // Суффикс ".example.com" для шаблона "*.example.com"
+	prefix netip.Prefix // Подсеть для шаблона IP или CIDR
+}
+
+// compiledPolicy — правила, подготовленные для проверки URL
+type compiledPolicy struct {
+	schemes         map[string]struct{}
+	allow           hostMatcher
+	deny            hostMatcher
+	allowPrivateIPs bool
+}
+
+// URLPolicy проверяет URL назначения перед сокращением: схему, списки разрешенных
+// и запрещенных хостов, IP адреса частных сетей и ссылки на сам сервис (BaseURL),
+// которые создали бы петлю перенаправлений.
+//
+// Правила загружаются из файла и могут быть перечитаны во время работы (см. Reload);
+// проверки, выполняемые одновременно с перезагрузкой, используют прежние или новые
+// правила целиком.
+type URLPolicy struct {
+	baseHost string // Хост BaseURL в канонической форме (пусто — проверка петель отключена)
+	basePort string // Порт BaseURL с учетом порта схемы по умолчанию
+	path     string // Файл правил (пусто — правила по умолчанию)
+
+	rules atomic.Pointer[compiledPolicy]
+
+	reloadMu sync.Mutex // Упорядочивает перезагрузки правил
+	modTime  time.Time  // Время изменения файла правил при последней загрузке
+}
+
+// NewURLPolicy создает политику для сервиса с адресом baseURL и загружает правила
+// из файла rulesPath. Если rulesPath пуст, разрешены схемы http и https, а IP адреса
+// частных сетей, localhost и ссылки на сам сервис запрещены.
+//
+// Возвращает ошибку, если файл правил не удается прочитать или он содержит неверные правила.
+func NewURLPolicy(baseURL, rulesPath string) (*URLPolicy, error) {
+	p := &URLPolicy{path: rulesPath}
+	if base, err := url.Parse(baseURL); err == nil && base.Host != "" {
+		if host, _, _, err := policyHost(base.Hostname()); err == nil {
+			p.baseHost = host
+			p.basePort = effectivePort(base)
+		}
+	}
+
+	if rulesPath == "" {
+		compiled, err := compilePolicy(PolicyRules{})
+		if err != nil {
+			return nil, err
+		}
+		p.rules.Store(compiled)
+		return p, nil
+	}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetRules заменяет правила политики; файл правил при этом не перечитывается
+func (p *URLPolicy) SetRules(rules PolicyRules) error {
+	compiled, err := compilePolicy(rules)
+	if err != nil {
+		return err
+	}
+	p.rules.Store(compiled)
+	return nil
+}
+
+// Reload перечитывает файл правил, если он изменился с последней загрузки.
+// При ошибке продолжают действовать прежние правила.
+//
+// Возвращает true, если правила были заменены.
+func (p *URLPolicy) Reload() (bool, error) {
+	if p.path == "" {
+		return false, nil
+	}
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, fmt.Errorf("error reading URL policy file: %w", err)
+	}
+	if p.rules.Load() != nil && info.ModTime().Equal(p.modTime) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("error reading URL policy file: %w", err)
+	}
+	// Неизвестное поле — скорее всего опечатка в имени правила, которое иначе молча не действовало бы
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var rules PolicyRules
+	if err := decoder.Decode(&rules); err != nil {
+		return false, fmt.Errorf("error parsing URL policy file %s: %w", p.path, err)
+	}
+	compiled, err := compilePolicy(rules)
+	if err != nil {
+		return false, fmt.Errorf("invalid URL policy file %s: %w", p.path, err)
+	}
+	p.rules.Store(compiled)
+	p.modTime = info.ModTime()
+	return true, nil
+}
+
+// Check проверяет URL назначения по правилам политики.
+// Возвращает *PolicyViolation с описанием сработавшего правила или nil.
+func (p *URLPolicy) Check(rawURL string) error {
+	if violation := p.check(rawURL); violation != nil {
+		return violation
+	}
+	return nil
+}
+
+// check проверяет rawURL; правила применяются в порядке: формат URL, схема,
+// ссылка на сам сервис, запрещенные хосты, разрешенные хосты, частные IP адреса.
+// Хост из списка разрешенных тоже проверяется на принадлежность частной сети:
+// ее адреса разрешает только AllowPrivateIPs.
+func (p *URLPolicy) check(rawURL string) *PolicyViolation {
+	rules := p.rules.Load()
+	violation := func(rule, pattern, format string, args ...any) *PolicyViolation {
+		return &PolicyViolation{Rule: rule, Pattern: pattern, Message: fmt.Sprintf(format, args...), URL: rawURL}
+	}
+
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return violation(PolicyRuleInvalidURL, "", "URL cannot be parsed")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if _, ok := rules.schemes[scheme]; !ok {
+		return violation(PolicyRuleScheme, "", "scheme %q is not allowed", scheme)
+	}
+	if u.Host == "" {
+		// Схемам без иерархической части (mailto:, tel:) хост не нужен
+		if u.Opaque == "" || defaultPorts[scheme] != "" {
+			return violation(PolicyRuleInvalidURL, "", "URL has no host")
+		}
+		return nil
+	}
+	host, addr, isIP, err := policyHost(u.Hostname())
+	if err != nil || host == "" {
+		return violation(PolicyRuleInvalidURL, "", "URL has an invalid host")
+	}
+
+	if p.isSelf(host, effectivePort(u)) {
+		return violation(PolicyRuleSelfReference, "", "URL points to this shortener and would create a redirect loop")
+	}
+
+	if pattern, ok := rules.deny.match(host, addr, isIP); ok {
+		return violation(PolicyRuleDenyHost, pattern, "host %q is denied", host)
+	}
+	if len(rules.allow.patterns) > 0 {
+		if _, ok := rules.allow.match(host, addr, isIP); !ok {
+			return violation(PolicyRuleAllowHost, "", "host %q is not in the allow list", host)
+		}
+	}
+	if !rules.allowPrivateIPs {
+		if isIP && isPrivateAddr(addr) {
+			return violation(PolicyRulePrivateIP, "", "host %q is a private, local or loopback address", host)
+		}
+		if !isIP && isLocalhostName(host) {
+			return violation(PolicyRulePrivateIP, "", "host %q is a loopback name", host)
+		}
+	}
+	return nil
+}
+
+// policyHost приводит хост URL к форме, с которой сравниваются правила: имя — к канонической
+// форме, IPv4 адрес в любой записи, которую принимают браузеры, — к десятичной с точками.
+// Возвращает также адрес для IP хостов.
+func policyHost(hostname string) (host string, addr netip.Addr, isIP bool, err error) {
+	host, err = canonicalHost(hostname)
+	if err != nil {
+		return "", netip.Addr{}, false, err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return host, addr.Unmap().WithZone(""), true, nil
+	}
+	addr, isIP, err = parseIPv4Host(host)
+	if err != nil || !isIP {
+		return host, netip.Addr{}, false, err
+	}
+	return addr.String(), addr, true, nil
+}
+
+// parseIPv4Host разбирает хост как IPv4 адрес по правилам стандарта URL (WHATWG).
+// Браузеры переходят по хостам вида 2130706433, 0x7f.0.0.1, 017700000001 или 127.1
+// как по 127.0.0.1, поэтому такие записи нельзя считать именами.
+// Возвращает isIPv4 == false для имени хоста и ошибку для хоста, который оканчивается
+// числом, но не является IPv4 адресом (браузер такой URL отвергает).
+func parseIPv4Host(host string) (addr netip.Addr, isIPv4 bool, err error) {
+	parts := strings.Split(host, ".")
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	last := parts[len(parts)-1]
+	if _, err := parseIPv4Number(last); err != nil && (last == "" || strings.Trim(last, "0123456789") != "") {
+		return netip.Addr{}, false, nil
+	}
+	if len(parts) > 4 {
+		return netip.Addr{}, true, fmt.Errorf("IPv4 host %q has more than 4 parts", host)
+	}
+
+	var value uint64
+	for i, part := range parts {
+		n, err := parseIPv4Number(part)
+		if err != nil {
+			return netip.Addr{}, true, fmt.Errorf("IPv4 host %q: %w", host, err)
+		}
+		if i < len(parts)-1 {
+			if n > 255 {
+				return netip.Addr{}, true, fmt.Errorf("IPv4 host %q: part %q is out of range", host, part)
+			}
+			value |= n << (8 * (3 - i))
+			continue
+		}
+		// Последняя часть занимает все оставшиеся байты адреса
+		if n >= 1<<(8*(5-len(parts))) {
+			return netip.Addr{}, true, fmt.Errorf("IPv4 host %q: part %q is out of range", host, part)
+		}
+		value |= n
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true, nil
+}
+
+// parseIPv4Number разбирает часть IPv4 адреса: шестнадцатеричную с префиксом 0x,
+// восьмеричную с ведущим нулем или десятичную. Слишком большое число возвращается
+// как math.MaxUint64, чтобы адрес был отвергнут проверкой диапазона.
+func parseIPv4Number(part string) (uint64, error) {
+	if part == "" {
+		return 0, errors.New("empty IPv4 part")
+	}
+	base := 10
+	switch {
+	case len(part) >= 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		part, base = part[2:], 16
+		if part == "" {
+			return 0, nil
+		}
+	case len(part) >= 2 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+	n, err := strconv.ParseUint(part, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return math.MaxUint64, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid IPv4 part %q", part)
+	}
+	return n, nil
+}
+
+// isLocalhostName сообщает, является ли имя хоста localhost или его поддоменом:
+// такие имена разрешаются в loopback адрес (RFC 6761)
+func isLocalhostName(host string) bool {
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+// isSelf сообщает, указывает ли хост с портом на сам сервис. Порты схем по умолчанию (80 и 443)
+// считаются одним адресом: HTTP версия сервиса обычно перенаправляет на HTTPS.
+func (p *URLPolicy) isSelf(host, port string) bool {
+	if p.baseHost == "" || host != p.baseHost {
+		return false
+	}
+	return port == p.basePort || (isDefaultPort(port) && isDefaultPort(p.basePort))
+}
+
+// compilePolicy проверяет правила и подготавливает их к сопоставлению
+func compilePolicy(rules PolicyRules) (*compiledPolicy, error) {
+	schemes := rules.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = defaultAllowedSchemes
+	}
+	compiled := &compiledPolicy{
+		schemes:         make(map[string]struct{}, len(schemes)),
+		allowPrivateIPs: rules.AllowPrivateIPs,
+	}
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme == "" {
+			return nil, errors.New("empty scheme in allowed_schemes")
+		}
+		compiled.schemes[scheme] = struct{}{}
+	}
+
+	var err error
+	if compiled.allow, err = newHostMatcher(rules.AllowHosts); err != nil {
+		return nil, fmt.Errorf("allow_hosts: %w", err)
+	}
+	if compiled.deny, err = newHostMatcher(rules.DenyHosts); err != nil {
+		return nil, fmt.Errorf("deny_hosts: %w", err)
+	}
+	return compiled, nil
+}
+
+// newHostMatcher разбирает шаблоны хостов: имя, "*.имя", IP адрес или подсеть CIDR
+func newHostMatcher(patterns []string) (hostMatcher, error) {
+	matcher := hostMatcher{patterns: make([]hostPattern, 0, len(patterns))}
+	for _, raw := range patterns {
+		pattern := strings.TrimSpace(raw)
+		parsed := hostPattern{raw: pattern}
+
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			parsed.prefix = prefix.Masked()
+		} else if addr, err := netip.ParseAddr(strings.Trim(pattern, "[]")); err == nil {
+			addr = addr.Unmap()
+			parsed.prefix = netip.PrefixFrom(addr, addr.BitLen())
+		} else {
+			name, wildcard := strings.CutPrefix(pattern, "*.")
+			if name == "" || strings.Contains(name, "*") {
+				return hostMatcher{}, fmt.Errorf("invalid host pattern %q", raw)
+			}
+			host, err := canonicalHost(name)
+			if err != nil {
+				return hostMatcher{}, fmt.Errorf("invalid host pattern %q: %w", raw, err)
+			}
+			if !isHostName(host) {
+				return hostMatcher{}, fmt.Errorf("invalid host pattern %q", raw)
+			}
+			if wildcard {
+				parsed.suffix = "." + host
+			} else {
+				parsed.host = host
+			}
+		}
+		matcher.patterns = append(matcher.patterns, parsed)
+	}
+	return matcher, nil
+}
+
+// match возвращает первый шаблон, с которым совпал хост; addr используется, если isIP
+func (m hostMatcher) match(host string, addr netip.Addr, isIP bool) (string, bool) {
+	for _, pattern := range m.patterns {
+		switch {
+		case pattern.prefix.IsValid():
+			if isIP && pattern.prefix.Contains(addr) {
+				return pattern.raw, true
+			}
+		case pattern.suffix != "":
+			if strings.HasSuffix(host, pattern.suffix) {
+				return pattern.raw, true
+			}
+		case host == pattern.host:
+			return pattern.raw, true
+		}
+	}
+	return "", false
+}
+
+// isHostName сообщает, состоит ли имя хоста в ASCII форме только из допустимых символов
+func isHostName(host string) bool {
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_') {
+			return false
+		}
+	}
+	return host != ""
+}
+
+// isPrivateAddr сообщает, относится ли адрес к частной, локальной или loopback сети,
+// куда сервису не следует перенаправлять пользователей
+func isPrivateAddr(addr netip.Addr) bool {
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified()
+}
+
+// effectivePort возвращает порт URL, а если он не указан — порт схемы по умолчанию
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	return defaultPorts[strings.ToLower(u.Scheme)]
+}
+
+// isDefaultPort сообщает, является ли port портом по умолчанию одной из схем
+func isDefaultPort(port string) bool {
+	for _, defaultPort := range defaultPorts {
+		if port == defaultPort {
+			return true
+		}
+	}
+	return false
+}
+
+// RunURLPolicyReloader периодически перечитывает файл правил политики URL, если он изменился.
+// Ошибки загрузки записываются в журнал, при этом продолжают действовать прежние правила.
+// Блокирует выполнение до отмены ctx. Если файл правил не задан или interval
+// неположителен, сразу возвращает управление.
+func RunURLPolicyReloader(ctx context.Context, policy *URLPolicy, interval time.Duration, logger *zap.Logger) {
+	if policy == nil || policy.path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := policy.Reload()
+			if err != nil {
+				logger.Error("Error reloading URL policy, keeping previous rules", zap.Error(err))
+				continue
+			}
+			if reloaded {
+				logger.Info("URL policy reloaded", zap.String("path", policy.path))
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       PolicyRules
+		rawURL      string
+		wantRule    string // пусто — URL разрешен
+		wantPattern string
+	}{
+		{name: "plain https", rawURL: "https://example.com/page"},
+		{name: "javascript", rawURL: "javascript:alert(1)", wantRule: PolicyRuleScheme},
+		{name: "file", rawURL: "file:///etc/passwd", wantRule: PolicyRuleScheme},
+		{name: "scheme case", rawURL: "HTTPS://example.com/"},
+		{name: "unparsable", rawURL: "not a url", wantRule: PolicyRuleInvalidURL},
+		{name: "no host", rawURL: "http:///path", wantRule: PolicyRuleInvalidURL},
+		{name: "opaque http", rawURL: "http:example.com", wantRule: PolicyRuleInvalidURL},
+		{name: "self reference", rawURL: "http://LOCALHOST:8080/abc", wantRule: PolicyRuleSelfReference},
+		{name: "self reference with trailing dot", rawURL: "http://localhost.:8080/", wantRule: PolicyRuleSelfReference},
+		{name: "localhost other port", rawURL: "http://localhost:3000/", wantRule: PolicyRulePrivateIP},
+		{name: "localhost subdomain", rawURL: "http://app.LOCALHOST./", wantRule: PolicyRulePrivateIP},
+		{name: "localhost lookalike", rawURL: "http://notlocalhost.example/"},
+		{name: "localhost allowed", rules: PolicyRules{AllowPrivateIPs: true}, rawURL: "http://localhost:3000/"},
+		{name: "decimal IPv4", rawURL: "http://2130706433/", wantRule: PolicyRulePrivateIP},
+		{name: "hex IPv4", rawURL: "http://0x7f.0.0.1/", wantRule: PolicyRulePrivateIP},
+		{name: "hex IPv4 upper case", rawURL: "http://0X7F000001/", wantRule: PolicyRulePrivateIP},
+		{name: "octal IPv4", rawURL: "http://017700000001/", wantRule: PolicyRulePrivateIP},
+		{name: "octal parts", rawURL: "http://0300.0250.01.012/", wantRule: PolicyRulePrivateIP},
+		{name: "short IPv4", rawURL: "http://127.1/", wantRule: PolicyRulePrivateIP},
+		{name: "short private IPv4", rawURL: "http://10.1.258/", wantRule: PolicyRulePrivateIP},
+		{name: "short public IPv4", rawURL: "http://198.51.25863/"},
+		{name: "invalid octal IPv4", rawURL: "http://1.2.3.09/", wantRule: PolicyRuleInvalidURL},
+		{name: "IPv4 part out of range", rawURL: "http://256.0.0.1/", wantRule: PolicyRuleInvalidURL},
+		{name: "IPv4 with too many parts", rawURL: "http://1.2.3.4.5/", wantRule: PolicyRuleInvalidURL},
+		{name: "name ending in letters", rawURL: "http://0x7f.example/"},
+		{name: "loopback IPv4", rawURL: "http://127.0.0.1/admin", wantRule: PolicyRulePrivateIP},
+		{name: "private IPv4", rawURL: "https://192.168.1.10:8443/", wantRule: PolicyRulePrivateIP},
+		{name: "loopback IPv6", rawURL: "http://[::1]/", wantRule: PolicyRulePrivateIP},
+		{name: "mapped IPv4", rawURL: "http://[::ffff:10.0.0.1]/", wantRule: PolicyRulePrivateIP},
+		{name: "link-local with zone", rawURL: "http://[fe80::1%25eth0]/", wantRule: PolicyRulePrivateIP},
+		{name: "unspecified", rawURL: "http://0.0.0.0/", wantRule: PolicyRulePrivateIP},
+		{name: "public IP", rawURL: "http://198.51.100.7/"},
+		{name: "private IP allowed", rules: PolicyRules{AllowPrivateIPs: true}, rawURL: "http://10.1.2.3/"},
+		{
+			name:   "extra scheme",
+			rules:  PolicyRules{AllowedSchemes: []string{"https", "mailto"}},
+			rawURL: "mailto:user@example.com",
+		},
+		{
+			name:     "scheme not in custom list",
+			rules:    PolicyRules{AllowedSchemes: []string{"https"}},
+			rawURL:   "http://example.com/",
+			wantRule: PolicyRuleScheme,
+		},
+		{
+			name:        "denied exact host",
+			rules:       PolicyRules{DenyHosts: []string{"evil.example"}},
+			rawURL:      "https://EVIL.example/x",
+			wantRule:    PolicyRuleDenyHost,
+			wantPattern: "evil.example",
+		},
+		{
+			name:        "denied subdomain",
+			rules:       PolicyRules{DenyHosts: []string{"*.evil.example"}},
+			rawURL:      "https://a.b.evil.example/",
+			wantRule:    PolicyRuleDenyHost,
+			wantPattern: "*.evil.example",
+		},
+		{name: "wildcard skips apex", rules: PolicyRules{DenyHosts: []string{"*.evil.example"}}, rawURL: "https://evil.example/"},
+		{name: "wildcard skips lookalike", rules: PolicyRules{DenyHosts: []string{"*.evil.example"}}, rawURL: "https://notevil.example/"},
+		{
+			name:        "denied CIDR",
+			rules:       PolicyRules{DenyHosts: []string{"198.51.100.0/24"}},
+			rawURL:      "http://198.51.100.7/",
+			wantRule:    PolicyRuleDenyHost,
+			wantPattern: "198.51.100.0/24",
+		},
+		{
+			name:        "denied IDNA host",
+			rules:       PolicyRules{DenyHosts: []string{"*.испытание"}},
+			rawURL:      "https://пример.испытание/",
+			wantRule:    PolicyRuleDenyHost,
+			wantPattern: "*.испытание",
+		},
+		{
+			name:     "not in allow list",
+			rules:    PolicyRules{AllowHosts: []string{"*.corp.example", "example.com"}},
+			rawURL:   "https://example.org/",
+			wantRule: PolicyRuleAllowHost,
+		},
+		{name: "in allow list", rules: PolicyRules{AllowHosts: []string{"*.corp.example", "example.com"}}, rawURL: "https://wiki.corp.example/"},
+		{
+			name:     "allow list does not admit private network",
+			rules:    PolicyRules{AllowHosts: []string{"10.0.0.0/8"}},
+			rawURL:   "http://10.1.2.3/",
+			wantRule: PolicyRulePrivateIP,
+		},
+		{
+			name:     "allow list does not admit localhost",
+			rules:    PolicyRules{AllowHosts: []string{"*.localhost"}},
+			rawURL:   "http://app.localhost/",
+			wantRule: PolicyRulePrivateIP,
+		},
+		{
+			name:   "private network allowed and in allow list",
+			rules:  PolicyRules{AllowHosts: []string{"10.0.0.0/8"}, AllowPrivateIPs: true},
+			rawURL: "http://10.1.2.3/",
+		},
+		{
+			name:        "denied CIDR matches decimal IPv4",
+			rules:       PolicyRules{DenyHosts: []string{"198.51.100.0/24"}},
+			rawURL:      "http://3325256711/",
+			wantRule:    PolicyRuleDenyHost,
+			wantPattern: "198.51.100.0/24",
+		},
+		{
+			name:     "deny wins over allow",
+			rules:    PolicyRules{AllowHosts: []string{"*.corp.example"}, DenyHosts: []string{"secret.corp.example"}},
+			rawURL:   "https://secret.corp.example/",
+			wantRule: PolicyRuleDenyHost, wantPattern: "secret.corp.example",
+		},
+		{
+			name:     "allow list does not admit self reference",
+			rules:    PolicyRules{AllowHosts: []string{"localhost"}},
+			rawURL:   "http://localhost:8080/abc",
+			wantRule: PolicyRuleSelfReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewURLPolicy("http://localhost:8080", "")
+			require.NoError(t, err)
+			require.NoError(t, policy.SetRules(tt.rules))
+
+			err = policy.Check(tt.rawURL)
+			if tt.wantRule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation *PolicyViolation
+			require.ErrorAs(t, err, &violation)
+			assert.ErrorIs(t, err, ErrURLPolicyViolation)
+			assert.Equal(t, tt.wantRule, violation.Rule)
+			assert.Equal(t, tt.wantPattern, violation.Pattern)
+			assert.Equal(t, tt.rawURL, violation.URL)
+			assert.NotEmpty(t, violation.Message)
+		})
+	}
+}
+
+func TestURLPolicySelfReferenceDefaultPorts(t *testing.T) {
+	policy, err := NewURLPolicy("https://sho.rt", "")
+	require.NoError(t, err)
+
+	for _, rawURL := range []string{"https://sho.rt/abc", "http://sho.rt/abc", "https://SHO.RT:443/"} {
+		assert.ErrorIs(t, policy.Check(rawURL), ErrURLPolicyViolation, rawURL)
+	}
+	assert.NoError(t, policy.Check("https://sho.rt:8443/abc"))
+	assert.NoError(t, policy.Check("https://www.sho.rt/abc"))
+
+	// Другая запись IP адреса сервиса — тоже ссылка на сам сервис
+	policy, err = NewURLPolicy("http://198.51.100.7:8080", "")
+	require.NoError(t, err)
+	for _, rawURL := range []string{"http://198.51.100.7:8080/", "http://3325256711:8080/", "http://0xc6.51.25607:8080/"} {
+		var violation *PolicyViolation
+		require.ErrorAs(t, policy.Check(rawURL), &violation, rawURL)
+		assert.Equal(t, PolicyRuleSelfReference, violation.Rule, rawURL)
+	}
+}
+
+func TestURLPolicyInvalidRules(t *testing.T) {
+	policy, err := NewURLPolicy("http://localhost:8080", "")
+	require.NoError(t, err)
+
+	for _, rules := range []PolicyRules{
+		{AllowedSchemes: []string{""}},
+		{DenyHosts: []string{"evil*.example"}},
+		{DenyHosts: []string{"*."}},
+		{AllowHosts: []string{"10.0.0.0/33"}},
+	} {
+		assert.Error(t, policy.SetRules(rules), rules)
+	}
+	// Прежние правила продолжают действовать
+	assert.ErrorIs(t, policy.Check("javascript:alert(1)"), ErrURLPolicyViolation)
+}
+
+func TestURLPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writeRules := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	start := time.Now().Add(-time.Hour)
+
+	writeRules(`{"deny_hosts": ["*.evil.example"]}`, start)
+	policy, err := NewURLPolicy("http://localhost:8080", path)
+	require.NoError(t, err)
+	assert.ErrorIs(t, policy.Check("https://www.evil.example/"), ErrURLPolicyViolation)
+	assert.NoError(t, policy.Check("https://www.other.example/"))
+
+	// Файл не изменился — правила не перечитываются
+	reloaded, err := policy.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeRules(`{"deny_hosts": ["*.other.example"]}`, start.Add(time.Minute))
+	reloaded, err = policy.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.NoError(t, policy.Check("https://www.evil.example/"))
+	assert.ErrorIs(t, policy.Check("https://www.other.example/"), ErrURLPolicyViolation)
+
+	// Файл с ошибкой не заменяет действующие правила
+	for i, content := range []string{`{"deny_hosts": `, `{"deny_host": ["*.evil.example"]}`, `{"deny_hosts": ["a*b"]}`} {
+		writeRules(content, start.Add(time.Duration(i+2)*time.Minute))
+		_, err = policy.Reload()
+		assert.Error(t, err, content)
+		assert.ErrorIs(t, policy.Check("https://www.other.example/"), ErrURLPolicyViolation)
+	}
+
+	_, err = NewURLPolicy("http://localhost:8080", filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestCreateShortURLRejectedByPolicy(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-policy")
+
+	_, err := service.CreateShortURL(ctx, "javascript:alert(1)")
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, PolicyRuleScheme, violation.Rule)
+
+	_, err = service.CreateShortURL(ctx, service.config.BaseURL+"/abc")
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, PolicyRuleSelfReference, violation.Rule)
+
+	t.Run("batch", func(t *testing.T) {
+		_, err := service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+			{CorrelationID: "1", OriginalURL: "https://batch-policy.example.com"},
+			{CorrelationID: "2", OriginalURL: "http://127.0.0.1:9000/"},
+		})
+		var violation *PolicyViolation
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, PolicyRulePrivateIP, violation.Rule)
+		assert.Equal(t, "2", violation.CorrelationID)
+
+		// Пакет отклоняется целиком
+		_, err = service.GetStorage().GetShortURLByOriginal(ctx, "https://batch-policy.example.com/")
+		assert.Error(t, err)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		shortURL, err := service.CreateShortURL(ctx, "https://policy-update.example.com")
+		require.NoError(t, err)
+
+		destination := "file:///etc/passwd"
+		_, err = service.UpdateUserURL(ctx, shortURL, "user-policy", models.URLUpdate{OriginalURL: &destination})
+		assert.True(t, errors.Is(err, ErrURLPolicyViolation), err)
+	})
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidRedirectStatus возвращается, если код перенаправления не входит в число
+// поддерживаемых: 301, 302, 307 или 308
+var ErrInvalidRedirectStatus = errors.New("invalid redirect status")
+
+// ValidateRedirectStatus проверяет код перенаправления ссылки.
+// Нулевое значение допустимо и означает код по умолчанию из конфигурации.
+func ValidateRedirectStatus(status int) error {
+	switch status {
+	case 0, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	default:
+		return ErrInvalidRedirectStatus
+	}
+}
+
+// IsPermanentRedirect сообщает, является ли код перенаправления постоянным (301 или 308)
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
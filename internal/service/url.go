@@ -3,30 +3,58 @@
 package service
 
 import (
+	"cmp"
 	"context"
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/config"
 	"github.com/InQaaaaGit/trunc_url.git/internal/middleware"
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
 	"go.uber.org/zap"
 )
 
+// ErrAnalyticsUnsupported возвращается, если хранилище не поддерживает аналитику переходов
+var ErrAnalyticsUnsupported = errors.New("analytics is not supported by storage")
+
+// ErrInvalidUserURLQuery возвращается при неверных параметрах выборки ссылок пользователя
+var ErrInvalidUserURLQuery = errors.New("invalid user URL query")
+
+// ErrInvalidURLUpdate возвращается, если изменение ссылки пустое или задает неверный URL
+var ErrInvalidURLUpdate = errors.New("invalid URL update")
+
+// Размер страницы списка ссылок пользователя
+const (
+	DefaultUserURLsPageSize = 100
+	MaxUserURLsPageSize     = 1000
+)
+
+// ErrDeleteJobsUnsupported возвращается, если хранилище не поддерживает задачи удаления
+var ErrDeleteJobsUnsupported = errors.New("delete jobs are not supported by storage")
+
+// maxShortIDAttempts ограничивает количество попыток генерации короткого идентификатора при коллизиях
+const maxShortIDAttempts = 5
+
 // URLService определяет интерфейс для бизнес-логики работы с URL.
 // Предоставляет методы высокого уровня для создания, получения и управления URL,
 // инкапсулируя логику валидации, генерации хешей и работы с хранилищем.
 type URLService interface {
 	// CreateShortURL создает сокращенный URL из оригинального с валидацией и проверкой дубликатов
 	CreateShortURL(ctx context.Context, originalURL string) (string, error)
+	// CreateShortURLWithOptions создает сокращенный URL с дополнительными параметрами (например, алиасом)
+	CreateShortURLWithOptions(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error)
 	// GetOriginalURL получает оригинальный URL по короткому идентификатору
 	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
+	// ResolveRedirect получает оригинальный URL и код перенаправления по короткому идентификатору
+	ResolveRedirect(ctx context.Context, shortURL string) (models.Redirect, error)
 	// GetStorage возвращает используемое хранилище (для интеграционных тестов)
 	GetStorage() storage.URLStorage
 	// CreateShortURLsBatch создает несколько сокращенных URL за один запрос
@@ -35,8 +63,32 @@ type URLService interface {
 	CheckConnection(ctx context.Context) error
 	// GetUserURLs получает все URL пользователя с формированием полных адресов
 	GetUserURLs(ctx context.Context, userID string) ([]models.UserURL, error)
+	// ListUserURLs получает страницу URL пользователя с фильтрами и сортировкой
+	ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error)
 	// BatchDeleteURLs выполняет массовое удаление URL с оптимизацией для больших объемов
 	BatchDeleteURLs(ctx context.Context, shortURLs []string, userID string) error
+	// RestoreURLs снимает пометку удаления с URL пользователя и возвращает восстановленные
+	RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error)
+	// RecordClick асинхронно регистрирует переход по короткой ссылке
+	RecordClick(shortURL string, info models.ClickInfo)
+	// UpdateUserURL изменяет оригинальный URL и описание ссылки, принадлежащей пользователю
+	UpdateUserURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error)
+	// GetURLRevisions возвращает историю версий ссылки, принадлежащей пользователю
+	GetURLRevisions(ctx context.Context, shortURL, userID string) ([]models.URLRevision, error)
+	// GetURLStats возвращает статистику переходов по ссылке, принадлежащей пользователю
+	GetURLStats(ctx context.Context, shortURL, userID string) (models.ClickStats, error)
+	// EnqueueDeletion ставит удаление URL пользователя в очередь и возвращает созданную задачу
+	EnqueueDeletion(ctx context.Context, shortURLs []string, userID string) (models.DeleteJob, error)
+	// GetDeletionJob возвращает задачу удаления, принадлежащую пользователю
+	GetDeletionJob(ctx context.Context, id, userID string) (models.DeleteJob, error)
+	// CreateAPIKey выпускает API ключ пользователя и возвращает его вместе с открытым значением
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (models.APIKey, string, error)
+	// ListAPIKeys возвращает API ключи пользователя
+	ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	// RevokeAPIKey отзывает API ключ пользователя
+	RevokeAPIKey(ctx context.Context, id, userID string) error
+	// AuthenticateAPIKey возвращает действующий API ключ по его открытому значению
+	AuthenticateAPIKey(ctx context.Context, token string) (models.APIKey, error)
 }
 
 // URLServiceImpl реализует интерфейс URLService.
@@ -45,6 +97,10 @@ type URLServiceImpl struct {
 	storage storage.URLStorage // Хранилище URL
 	config  *config.Config     // Конфигурация приложения
 	logger  *zap.Logger        // Логгер для записи событий
+	idGen   IDGenerator        // Генератор коротких идентификаторов
+	clicks  *ClickTracker      // Асинхронная запись переходов (nil, если хранилище не поддерживает аналитику)
+	deletes *DeletionQueue     // Очередь удаления (nil, если хранилище не поддерживает задачи удаления)
+	policy  *URLPolicy         // Политика URL назначения
 }
 
 // NewURLService создает новый экземпляр URLService с автоматическим выбором хранилища.
@@ -56,10 +112,25 @@ type URLServiceImpl struct {
 //   - cfg: конфигурация с настройками подключения к различным хранилищам
 //   - logger: логгер для записи событий инициализации и работы сервиса
 //
+// Стратегия генерации коротких идентификаторов выбирается по cfg.ShortIDStrategy.
+//
 // Возвращает URLService или ошибку при критических проблемах инициализации.
 func NewURLService(cfg *config.Config, logger *zap.Logger) (URLService, error) {
 	var store storage.URLStorage
-	var err error
+
+	idGen, err := NewIDGenerator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating short ID generator: %w", err)
+	}
+
+	if err := ValidateRedirectStatus(cfg.RedirectStatus); err != nil {
+		return nil, fmt.Errorf("%w: %d", err, cfg.RedirectStatus)
+	}
+
+	policy, err := NewURLPolicy(cfg.BaseURL, cfg.URLPolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading URL policy: %w", err)
+	}
 
 	// 1. Try to use PostgreSQL, if DSN is specified
 	if cfg.DatabaseDSN != "" {
@@ -70,11 +141,7 @@ func NewURLService(cfg *config.Config, logger *zap.Logger) (URLService, error) {
 			log.Printf("PostgreSQL storage initialization error: %v. Switching to file storage.", err)
 		} else {
 			// Successfully created PostgresStorage, use it
-			return &URLServiceImpl{
-				storage: store,
-				config:  cfg,
-				logger:  logger,
-			}, nil
+			return newURLServiceImpl(storage.NewInstrumentedStorage(store, "postgres"), cfg, logger, idGen, policy), nil
 		}
 	}
 
@@ -87,11 +154,7 @@ func NewURLService(cfg *config.Config, logger *zap.Logger) (URLService, error) {
 			log.Printf("File storage initialization error: %v. Switching to in-memory storage.", err)
 		} else {
 			// Successfully created FileStorage, use it
-			return &URLServiceImpl{
-				storage: store,
-				config:  cfg,
-				logger:  logger,
-			}, nil
+			return newURLServiceImpl(storage.NewInstrumentedStorage(store, "file"), cfg, logger, idGen, policy), nil
 		}
 	}
 
@@ -99,15 +162,51 @@ func NewURLService(cfg *config.Config, logger *zap.Logger) (URLService, error) {
 	log.Println("Using in-memory storage.")
 	store = storage.NewMemoryStorage(logger) // Передаем логгер в конструктор
 
-	return &URLServiceImpl{
+	return newURLServiceImpl(storage.NewInstrumentedStorage(store, "memory"), cfg, logger, idGen, policy), nil // There should be no errors here, as MemoryStorage always succeeds
+}
+
+// newURLServiceImpl собирает URLServiceImpl поверх выбранного хранилища.
+// Если хранилище поддерживает аналитику, запускает асинхронную запись переходов.
+// Счетчик генератора идентификаторов хранится в хранилище, если оно это поддерживает.
+func newURLServiceImpl(store storage.URLStorage, cfg *config.Config, logger *zap.Logger, idGen IDGenerator, policy *URLPolicy) *URLServiceImpl {
+	s := &URLServiceImpl{
 		storage: store,
 		config:  cfg,
 		logger:  logger,
-	}, nil // There should be no errors here, as MemoryStorage always succeeds
+		idGen:   idGen,
+		policy:  policy,
+	}
+	if gen, ok := idGen.(counterGenerator); ok {
+		if counters, ok := store.(storage.CounterStorage); ok {
+			gen.useCounterStorage(counters)
+		}
+	}
+	if analytics, ok := store.(storage.AnalyticsStorage); ok {
+		s.clicks = NewClickTracker(analytics, logger, cfg.ClickBufferSize, cfg.ClickFlushInterval)
+	}
+	if jobs, ok := store.(storage.DeleteJobStorage); ok {
+		s.deletes = NewDeletionQueue(jobs, s.BatchDeleteURLs, logger, cfg.DeleteJobWorkers, cfg.DeleteJobMaxAttempts, cfg.DeleteJobRetryBackoff)
+	}
+	return s
+}
+
+// URLPolicy returns the destination URL policy, for reloading its rule file at runtime.
+func (s *URLServiceImpl) URLPolicy() *URLPolicy {
+	return s.policy
 }
 
 // CreateShortURL creates a short URL from the original
 func (s *URLServiceImpl) CreateShortURL(ctx context.Context, originalURL string) (string, error) {
+	return s.CreateShortURLWithOptions(ctx, originalURL, models.ShortenOptions{})
+}
+
+// CreateShortURLWithOptions creates a short URL from the original using the given options.
+// If opts.Alias is set, it is used as the short URL instead of a generated one.
+// Returns a *PolicyViolation if the destination is rejected by the URL policy.
+func (s *URLServiceImpl) CreateShortURLWithOptions(ctx context.Context, originalURL string, opts models.ShortenOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateShortURLWithOptions")
+	defer span.End()
+
 	userID, ok := ctx.Value(middleware.ContextKeyUserID).(string)
 	if !ok || userID == "" {
 		// Если userID не найден в контексте, это может быть ошибкой или особенностью вызова.
@@ -130,9 +229,31 @@ func (s *URLServiceImpl) CreateShortURL(ctx context.Context, originalURL string)
 	if err != nil {
 		return "", fmt.Errorf("invalid URL format")
 	}
+	if err := s.policy.Check(originalURL); err != nil {
+		return "", err
+	}
+
+	if !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(time.Now()) {
+		return "", ErrInvalidExpiry
+	}
+
+	if err := ValidateMetadata(opts.Title, opts.Description); err != nil {
+		return "", err
+	}
+
+	if err := ValidateRedirectStatus(opts.RedirectStatus); err != nil {
+		return "", err
+	}
+
+	// Equivalent spellings of the same URL share one short URL
+	canonicalURL := s.canonicalURL(originalURL)
+
+	if opts.Alias != "" {
+		return s.createWithAlias(ctx, originalURL, canonicalURL, opts, userID)
+	}
 
 	// Check if URL already exists
-	existingShortURL, err := s.storage.GetShortURLByOriginal(ctx, originalURL)
+	existingShortURL, err := s.storage.GetShortURLByOriginal(ctx, canonicalURL)
 	if err == nil {
 		// URL already exists, return existing short URL
 		s.logger.Info("URL already exists, returning existing short URL",
@@ -141,24 +262,40 @@ func (s *URLServiceImpl) CreateShortURL(ctx context.Context, originalURL string)
 		return existingShortURL, storage.ErrOriginalURLConflict
 	}
 
-	hash := sha256.Sum256([]byte(originalURL))
-	shortURL := base64.URLEncoding.EncodeToString(hash[:])[:8]
+	// Generate short URL, retrying with a salted hash on collision with another original URL
+	for attempt := 0; attempt < maxShortIDAttempts; attempt++ {
+		shortURL, genErr := s.idGen.Generate(originalURL, attempt)
+		if genErr != nil {
+			return "", genErr
+		}
+
+		err = s.storage.SaveEntry(ctx, storage.BatchEntry{
+			ShortURL:    shortURL,
+			OriginalURL: originalURL,
+			UserID:      userID,
+			ExpiresAt:   opts.ExpiresAt,
+			Title:       opts.Title,
+			Description: opts.Description,
+
+			RedirectStatus: opts.RedirectStatus,
+			Interstitial:   opts.Interstitial,
+			CanonicalURL:   canonicalURL,
+		})
+		if err == nil {
+			return shortURL, nil
+		}
+
+		if errors.Is(err, storage.ErrShortURLCollision) {
+			s.logger.Warn("Short URL collision, retrying with salted ID",
+				zap.String("short_url", shortURL),
+				zap.String("original_url", originalURL),
+				zap.Int("attempt", attempt))
+			continue
+		}
 
-	err = s.storage.Save(ctx, shortURL, originalURL, userID)
-	if err != nil {
 		// Check if the error is due to a conflict with the original URL
 		if errors.Is(err, storage.ErrOriginalURLConflict) {
-			// If URL already exists, get existing shortURL
-			log.Printf("Conflict: Original URL '%s' already exists. Getting existing short URL.", originalURL)
-			existingShortURL, getErr := s.storage.GetShortURLByOriginal(ctx, originalURL)
-			if getErr != nil {
-				// This situation should not occur if Save returned a conflict,
-				// but we handle it on the safe side
-				log.Printf("Critical error: failed to get short URL for existing original URL '%s': %v", originalURL, getErr)
-				return "", fmt.Errorf("error getting existing short URL: %w", getErr)
-			}
-			// Return existing shortURL and conflict error for handling in the handler
-			return existingShortURL, storage.ErrOriginalURLConflict
+			return s.existingShortURLConflict(ctx, canonicalURL)
 		}
 
 		// For other saving errors, just log and return
@@ -166,12 +303,64 @@ func (s *URLServiceImpl) CreateShortURL(ctx context.Context, originalURL string)
 		return "", err
 	}
 
-	// If there's no error, return new shortURL
-	return shortURL, nil
+	return "", fmt.Errorf("could not generate unique short URL after %d attempts: %w", maxShortIDAttempts, storage.ErrShortURLCollision)
+}
+
+// createWithAlias saves the original URL under a user-chosen alias.
+// Unlike generated IDs, an alias is never retried: if it is occupied, ErrAliasTaken is returned.
+func (s *URLServiceImpl) createWithAlias(ctx context.Context, originalURL, canonicalURL string, opts models.ShortenOptions, userID string) (string, error) {
+	alias := opts.Alias
+	if err := ValidateAlias(alias); err != nil {
+		return "", err
+	}
+
+	err := s.storage.SaveEntry(ctx, storage.BatchEntry{
+		ShortURL:    alias,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		ExpiresAt:   opts.ExpiresAt,
+		Title:       opts.Title,
+		Description: opts.Description,
+
+		RedirectStatus: opts.RedirectStatus,
+		Interstitial:   opts.Interstitial,
+		CanonicalURL:   canonicalURL,
+	})
+	switch {
+	case err == nil:
+		return alias, nil
+	case errors.Is(err, storage.ErrShortURLCollision):
+		s.logger.Info("Alias already taken",
+			zap.String("alias", alias),
+			zap.String("original_url", originalURL))
+		return "", ErrAliasTaken
+	case errors.Is(err, storage.ErrOriginalURLConflict):
+		return s.existingShortURLConflict(ctx, canonicalURL)
+	default:
+		log.Printf("Error saving URL with alias: %v", err)
+		return "", err
+	}
+}
+
+// existingShortURLConflict returns the short URL already stored for a URL with the canonical form
+// canonicalURL together with ErrOriginalURLConflict for handling in the handler.
+func (s *URLServiceImpl) existingShortURLConflict(ctx context.Context, canonicalURL string) (string, error) {
+	log.Printf("Conflict: Original URL '%s' already exists. Getting existing short URL.", canonicalURL)
+	existingShortURL, err := s.storage.GetShortURLByOriginal(ctx, canonicalURL)
+	if err != nil {
+		// This situation should not occur if Save returned a conflict,
+		// but we handle it on the safe side
+		log.Printf("Critical error: failed to get short URL for existing original URL '%s': %v", canonicalURL, err)
+		return "", fmt.Errorf("error getting existing short URL: %w", err)
+	}
+	return existingShortURL, storage.ErrOriginalURLConflict
 }
 
 // GetOriginalURL gets the original URL from the short
 func (s *URLServiceImpl) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetOriginalURL")
+	defer span.End()
+
 	if shortURL == "" {
 		return "", fmt.Errorf("empty short URL")
 	}
@@ -188,8 +377,33 @@ func (s *URLServiceImpl) GetOriginalURL(ctx context.Context, shortURL string) (s
 	return originalURL, nil
 }
 
-// CreateShortURLsBatch creates short URLs for a batch and saves them
+// ResolveRedirect gets the original URL and the redirect status for the short URL.
+// Links created without a status use the configured default (307 if unset).
+func (s *URLServiceImpl) ResolveRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	ctx, span := tracing.Start(ctx, "URLService.ResolveRedirect")
+	defer span.End()
+
+	if shortURL == "" {
+		return models.Redirect{}, fmt.Errorf("empty short URL")
+	}
+
+	redirect, err := s.storage.GetRedirect(ctx, shortURL)
+	if err != nil {
+		return models.Redirect{}, err
+	}
+	if redirect.Status == 0 {
+		redirect.Status = cmp.Or(s.config.RedirectStatus, http.StatusTemporaryRedirect)
+	}
+	return redirect, nil
+}
+
+// CreateShortURLsBatch creates short URLs for a batch and saves them.
+// A destination rejected by the URL policy fails the whole batch with a *PolicyViolation
+// carrying the entry's correlation ID.
 func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []models.BatchRequestEntry) ([]models.BatchResponseEntry, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateShortURLsBatch")
+	defer span.End()
+
 	if len(reqBatch) == 0 {
 		return []models.BatchResponseEntry{}, nil // Return empty slice if input is empty
 	}
@@ -203,6 +417,10 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 
 	storageBatch := make([]storage.BatchEntry, 0, len(reqBatch))
 	respBatch := make([]models.BatchResponseEntry, 0, len(reqBatch))
+	reserved := make(map[string]string, len(reqBatch)) // shortURL -> originalURL внутри текущего пакета
+	created := make(map[string]string, len(reqBatch))  // canonicalURL -> shortURL ссылок, созданных пакетом
+	hasAliases := false
+	now := time.Now()
 
 	for _, reqEntry := range reqBatch {
 		originalURL := reqEntry.OriginalURL
@@ -213,12 +431,39 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 			log.Printf("Skipped empty URL in batch for correlation_id: %s", reqEntry.CorrelationID)
 			continue
 		}
-		// TODO: Add URL validation, like in CreateShortURL?
-		// _, err := url.ParseRequestURI(originalURL)
-		// if err != nil { ... }
+		if violation := s.policy.check(originalURL); violation != nil {
+			violation.CorrelationID = reqEntry.CorrelationID
+			return nil, violation
+		}
+
+		expiresAt, err := ResolveExpiry(reqEntry.ExpiresAt, reqEntry.TTL, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w for correlation_id %s", err, reqEntry.CorrelationID)
+		}
+		canonicalURL := s.canonicalURL(originalURL)
+
+		if reqEntry.Alias != "" {
+			if err := s.reserveBatchAlias(ctx, reqEntry.Alias, originalURL, reserved); err != nil {
+				return nil, err
+			}
+			hasAliases = true
+			storageBatch = append(storageBatch, storage.BatchEntry{
+				ShortURL:    reqEntry.Alias,
+				OriginalURL: originalURL,
+				UserID:      userID,
+				ExpiresAt:   expiresAt,
+
+				CanonicalURL: canonicalURL,
+			})
+			respBatch = append(respBatch, models.BatchResponseEntry{
+				CorrelationID: reqEntry.CorrelationID,
+				ShortURL:      s.config.BaseURL + "/" + reqEntry.Alias,
+			})
+			continue
+		}
 
 		// Check if URL already exists
-		existingShortURL, err := s.storage.GetShortURLByOriginal(ctx, originalURL)
+		existingShortURL, err := s.storage.GetShortURLByOriginal(ctx, canonicalURL)
 		if err == nil {
 			// URL already exists, use existing short URL
 			respBatch = append(respBatch, models.BatchResponseEntry{
@@ -228,9 +473,22 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 			continue
 		}
 
+		// Equivalent URL was already shortened earlier in this batch
+		if shortURL, ok := created[canonicalURL]; ok {
+			respBatch = append(respBatch, models.BatchResponseEntry{
+				CorrelationID: reqEntry.CorrelationID,
+				ShortURL:      s.config.BaseURL + "/" + shortURL,
+			})
+			continue
+		}
+
 		// Generate shortURL (same logic as in CreateShortURL)
-		hash := sha256.Sum256([]byte(originalURL))
-		shortURL := base64.URLEncoding.EncodeToString(hash[:])[:8]
+		shortURL, err := s.pickBatchShortID(ctx, originalURL, reserved)
+		if err != nil {
+			return nil, err
+		}
+		reserved[shortURL] = originalURL
+		created[canonicalURL] = shortURL
 		fullShortURL := s.config.BaseURL + "/" + shortURL // Form full URL for response
 
 		// Add to batch for saving to storage
@@ -238,6 +496,9 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 			ShortURL:    shortURL,
 			OriginalURL: originalURL,
 			UserID:      userID,
+			ExpiresAt:   expiresAt,
+
+			CanonicalURL: canonicalURL,
 		})
 
 		// Add to batch for response
@@ -258,6 +519,10 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 	err := s.storage.SaveBatch(ctx, storageBatch)
 	if err != nil {
 		log.Printf("Error saving URL batch: %v", err)
+		if hasAliases && errors.Is(err, storage.ErrShortURLCollision) {
+			// Алиас успели занять между проверкой и сохранением
+			return nil, fmt.Errorf("error saving batch: %w: %w", ErrAliasTaken, err)
+		}
 		return nil, fmt.Errorf("error saving batch: %w", err) // Return error
 	}
 
@@ -265,6 +530,61 @@ func (s *URLServiceImpl) CreateShortURLsBatch(ctx context.Context, reqBatch []mo
 	return respBatch, nil
 }
 
+// reserveBatchAlias проверяет алиас из пакета и резервирует его в рамках пакета.
+// Алиас не должен повторяться внутри пакета и не должен быть занят в хранилище.
+func (s *URLServiceImpl) reserveBatchAlias(ctx context.Context, alias, originalURL string, reserved map[string]string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return fmt.Errorf("%w: %s", err, alias)
+	}
+	if _, ok := reserved[alias]; ok {
+		return fmt.Errorf("%w: %s", ErrAliasTaken, alias)
+	}
+
+	_, err := s.storage.Get(ctx, alias)
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		reserved[alias] = originalURL
+		return nil
+	case err == nil, errors.Is(err, storage.ErrURLDeleted), errors.Is(err, storage.ErrURLExpired):
+		return fmt.Errorf("%w: %s", ErrAliasTaken, alias)
+	default:
+		return fmt.Errorf("error checking alias %s: %w", alias, err)
+	}
+}
+
+// pickBatchShortID подбирает короткий идентификатор для URL из пакета.
+// Идентификатор считается занятым, если он уже использован в этом пакете или в хранилище
+// для другого оригинального URL; в этом случае генерируется следующий вариант с солью.
+func (s *URLServiceImpl) pickBatchShortID(ctx context.Context, originalURL string, reserved map[string]string) (string, error) {
+	for attempt := 0; attempt < maxShortIDAttempts; attempt++ {
+		shortURL, err := s.idGen.Generate(originalURL, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		if reservedOriginal, ok := reserved[shortURL]; ok && reservedOriginal != originalURL {
+			continue
+		}
+
+		existingOriginal, err := s.storage.Get(ctx, shortURL)
+		switch {
+		case errors.Is(err, storage.ErrURLNotFound):
+			return shortURL, nil
+		case err == nil && existingOriginal == originalURL:
+			return shortURL, nil
+		case err == nil, errors.Is(err, storage.ErrURLDeleted), errors.Is(err, storage.ErrURLExpired):
+			s.logger.Warn("Short URL collision in batch, retrying with salted ID",
+				zap.String("short_url", shortURL),
+				zap.String("original_url", originalURL),
+				zap.Int("attempt", attempt))
+		default:
+			return "", fmt.Errorf("error checking short URL %s: %w", shortURL, err)
+		}
+	}
+
+	return "", fmt.Errorf("could not generate unique short URL after %d attempts: %w", maxShortIDAttempts, storage.ErrShortURLCollision)
+}
+
 // GetStorage returns the URL storage
 func (s *URLServiceImpl) GetStorage() storage.URLStorage {
 	return s.storage
@@ -272,6 +592,9 @@ func (s *URLServiceImpl) GetStorage() storage.URLStorage {
 
 // CheckConnection проверяет соединение с хранилищем
 func (s *URLServiceImpl) CheckConnection(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "URLService.CheckConnection")
+	defer span.End()
+
 	// Проверяем, реализует ли хранилище интерфейс DatabaseChecker
 	if checker, ok := s.storage.(storage.DatabaseChecker); ok {
 		return checker.CheckConnection(ctx)
@@ -282,6 +605,9 @@ func (s *URLServiceImpl) CheckConnection(ctx context.Context) error {
 
 // GetUserURLs получает все URL, сокращенные пользователем
 func (s *URLServiceImpl) GetUserURLs(ctx context.Context, userID string) ([]models.UserURL, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetUserURLs")
+	defer span.End()
+
 	userURLs, err := s.storage.GetUserURLs(ctx, userID)
 	if err != nil {
 		s.logger.Error("Error getting user URLs from storage in service", zap.String("userID", userID), zap.Error(err))
@@ -290,16 +616,51 @@ func (s *URLServiceImpl) GetUserURLs(ctx context.Context, userID string) ([]mode
 	// Важно: здесь shortURL из хранилища это только ID. Нужно его дополнить BaseURL.
 	fullUserURLs := make([]models.UserURL, len(userURLs))
 	for i, u := range userURLs {
-		fullUserURLs[i] = models.UserURL{
-			ShortURL:    s.config.BaseURL + "/" + u.ShortURL,
-			OriginalURL: u.OriginalURL,
-		}
+		u.ShortURL = s.config.BaseURL + "/" + u.ShortURL
+		fullUserURLs[i] = u
 	}
 	return fullUserURLs, nil
 }
 
+// ListUserURLs returns a page of the user's URLs matching query.
+// A zero limit selects DefaultUserURLsPageSize; limits above MaxUserURLsPageSize,
+// unknown sort fields and malformed cursors yield ErrInvalidUserURLQuery.
+func (s *URLServiceImpl) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	ctx, span := tracing.Start(ctx, "URLService.ListUserURLs")
+	defer span.End()
+
+	switch query.Sort {
+	case "", models.SortCreatedAt, models.SortShortURL, models.SortOriginalURL:
+	default:
+		return models.UserURLPage{}, fmt.Errorf("%w: unknown sort field %q", ErrInvalidUserURLQuery, query.Sort)
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultUserURLsPageSize
+	case query.Limit < 0 || query.Limit > MaxUserURLsPageSize:
+		return models.UserURLPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidUserURLQuery, MaxUserURLsPageSize)
+	}
+
+	page, err := s.storage.ListUserURLs(ctx, userID, query)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		return models.UserURLPage{}, fmt.Errorf("%w: %w", ErrInvalidUserURLQuery, err)
+	}
+	if err != nil {
+		s.logger.Error("Error listing user URLs", zap.String("userID", userID), zap.Error(err))
+		return models.UserURLPage{}, fmt.Errorf("service: could not list URLs for user %s: %w", userID, err)
+	}
+
+	for i := range page.URLs {
+		page.URLs[i].ShortURL = s.config.BaseURL + "/" + page.URLs[i].ShortURL
+	}
+	return page, nil
+}
+
 // BatchDeleteURLs deletes multiple URLs using fan-in pattern
 func (s *URLServiceImpl) BatchDeleteURLs(ctx context.Context, shortURLs []string, userID string) error {
+	ctx, span := tracing.Start(ctx, "URLService.BatchDeleteURLs")
+	defer span.End()
+
 	if len(shortURLs) == 0 {
 		return nil
 	}
@@ -423,3 +784,200 @@ func (s *URLServiceImpl) BatchDeleteURLs(ctx context.Context, shortURLs []string
 
 	return nil
 }
+
+// RecordClick registers a redirect for analytics without blocking the caller.
+// The client IP is stored only as a keyed hash.
+func (s *URLServiceImpl) RecordClick(shortURL string, info models.ClickInfo) {
+	if s.clicks == nil {
+		return
+	}
+	s.clicks.Track(models.ClickEvent{
+		ShortURL:  shortURL,
+		Timestamp: time.Now().UTC(),
+		Referrer:  info.Referrer,
+		UserAgent: info.UserAgent,
+		IPHash:    hashClientIP(info.ClientIP, s.config.SecretKey),
+	})
+}
+
+// GetURLStats returns click statistics for a short URL owned by userID.
+// Returns storage.ErrURLNotFound if the URL does not exist or belongs to another user.
+func (s *URLServiceImpl) GetURLStats(ctx context.Context, shortURL, userID string) (models.ClickStats, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLStats")
+	defer span.End()
+
+	analytics, ok := s.storage.(storage.AnalyticsStorage)
+	if !ok {
+		return models.ClickStats{}, ErrAnalyticsUnsupported
+	}
+
+	owned, err := s.isOwnedBy(ctx, shortURL, userID)
+	if err != nil {
+		return models.ClickStats{}, err
+	}
+	if !owned {
+		return models.ClickStats{}, storage.ErrURLNotFound
+	}
+
+	stats, err := analytics.GetClickStats(ctx, shortURL)
+	if err != nil {
+		s.logger.Error("Error getting click stats", zap.String("short_url", shortURL), zap.Error(err))
+		return models.ClickStats{}, fmt.Errorf("service: could not retrieve stats for %s: %w", shortURL, err)
+	}
+	return stats, nil
+}
+
+// RestoreURLs undeletes soft-deleted URLs owned by userID and returns the IDs that were restored.
+// URLs that are missing, not deleted, owned by another user or already purged are skipped,
+// as are URLs whose destination the user has shortened again since the deletion.
+func (s *URLServiceImpl) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "URLService.RestoreURLs")
+	defer span.End()
+
+	restored, err := s.storage.RestoreURLs(ctx, shortURLs, userID)
+	if err != nil {
+		s.logger.Error("Error restoring URLs", zap.String("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("service: could not restore URLs for user %s: %w", userID, err)
+	}
+	s.logger.Info("URLs restored",
+		zap.String("userID", userID),
+		zap.Int("requested", len(shortURLs)),
+		zap.Int("restored", len(restored)))
+	return restored, nil
+}
+
+// UpdateUserURL changes the destination, title or description of a short URL owned by userID
+// and records the replaced version in the URL's revision history.
+// Returns ErrInvalidURLUpdate if the update sets no fields or an invalid destination,
+// a *PolicyViolation if the new destination is rejected by the URL policy,
+// and storage.ErrURLNotFound if the URL does not exist or belongs to another user.
+func (s *URLServiceImpl) UpdateUserURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	ctx, span := tracing.Start(ctx, "URLService.UpdateUserURL")
+	defer span.End()
+
+	if update.OriginalURL == nil && update.Title == nil && update.Description == nil {
+		return models.UserURL{}, fmt.Errorf("%w: no fields to update", ErrInvalidURLUpdate)
+	}
+	if update.OriginalURL != nil {
+		if _, err := url.ParseRequestURI(*update.OriginalURL); err != nil {
+			return models.UserURL{}, fmt.Errorf("%w: invalid URL format", ErrInvalidURLUpdate)
+		}
+		if err := s.policy.Check(*update.OriginalURL); err != nil {
+			return models.UserURL{}, err
+		}
+		update.CanonicalURL = s.canonicalURL(*update.OriginalURL)
+	}
+	var title, description string
+	if update.Title != nil {
+		title = *update.Title
+	}
+	if update.Description != nil {
+		description = *update.Description
+	}
+	if err := ValidateMetadata(title, description); err != nil {
+		return models.UserURL{}, err
+	}
+
+	updated, err := s.storage.UpdateURL(ctx, shortURL, userID, update)
+	if err != nil {
+		return models.UserURL{}, err
+	}
+	s.logger.Info("Short URL updated",
+		zap.String("userID", userID),
+		zap.String("short_url", shortURL),
+		zap.String("original_url", updated.OriginalURL))
+
+	updated.ShortURL = s.config.BaseURL + "/" + updated.ShortURL
+	return updated, nil
+}
+
+// GetURLRevisions returns the previous versions of a short URL owned by userID, oldest first.
+// Returns storage.ErrURLNotFound if the URL does not exist or belongs to another user.
+func (s *URLServiceImpl) GetURLRevisions(ctx context.Context, shortURL, userID string) ([]models.URLRevision, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLRevisions")
+	defer span.End()
+
+	owned, err := s.isOwnedBy(ctx, shortURL, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, storage.ErrURLNotFound
+	}
+
+	revisions, err := s.storage.GetURLRevisions(ctx, shortURL)
+	if err != nil {
+		s.logger.Error("Error getting URL revisions", zap.String("short_url", shortURL), zap.Error(err))
+		return nil, fmt.Errorf("service: could not retrieve revisions for %s: %w", shortURL, err)
+	}
+	return revisions, nil
+}
+
+// isOwnedBy checks whether the short URL belongs to the user.
+// Deleted URLs are not owned by anyone.
+func (s *URLServiceImpl) isOwnedBy(ctx context.Context, shortURL, userID string) (bool, error) {
+	owner, err := s.storage.GetURLOwner(ctx, shortURL)
+	if errors.Is(err, storage.ErrURLNotFound) || errors.Is(err, storage.ErrURLDeleted) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("service: could not retrieve owner of %s: %w", shortURL, err)
+	}
+	return owner == userID, nil
+}
+
+// EnqueueDeletion persists a deletion job for the user's URLs and schedules it
+// for asynchronous processing.
+func (s *URLServiceImpl) EnqueueDeletion(ctx context.Context, shortURLs []string, userID string) (models.DeleteJob, error) {
+	ctx, span := tracing.Start(ctx, "URLService.EnqueueDeletion")
+	defer span.End()
+
+	if s.deletes == nil {
+		return models.DeleteJob{}, ErrDeleteJobsUnsupported
+	}
+	return s.deletes.Enqueue(ctx, userID, shortURLs)
+}
+
+// GetDeletionJob returns a deletion job owned by userID.
+// Returns storage.ErrDeleteJobNotFound if the job does not exist or belongs to another user.
+func (s *URLServiceImpl) GetDeletionJob(ctx context.Context, id, userID string) (models.DeleteJob, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetDeletionJob")
+	defer span.End()
+
+	jobs, ok := s.storage.(storage.DeleteJobStorage)
+	if !ok {
+		return models.DeleteJob{}, ErrDeleteJobsUnsupported
+	}
+
+	job, err := jobs.GetDeleteJob(ctx, id)
+	if err != nil {
+		return models.DeleteJob{}, err
+	}
+	if job.UserID != userID {
+		return models.DeleteJob{}, storage.ErrDeleteJobNotFound
+	}
+	return job, nil
+}
+
+// Close waits for in-flight deletion jobs, flushes pending click events and closes
+// the underlying storage if it holds resources (files, database connections).
+// The service must not be used after Close.
+func (s *URLServiceImpl) Close(ctx context.Context) error {
+	var errs []error
+	if s.deletes != nil {
+		if err := s.deletes.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error stopping deletion queue: %w", err))
+		}
+	}
+	if s.clicks != nil {
+		if err := s.clicks.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error flushing click events: %w", err))
+		}
+	}
+	if closer, ok := s.storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing storage: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
-	"os"
+	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"github.com/InQaaaaGit/trunc_url.git/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -425,6 +429,384 @@ func TestURLConflict(t *testing.T) {
 	assert.True(t, errors.Is(err, storage.ErrOriginalURLConflict), "Ожидалась ошибка конфликта URL")
 }
 
+// hashShortID возвращает идентификатор, который стратегия по умолчанию выдаст на попытке attempt
+func hashShortID(t *testing.T, originalURL string, attempt int) string {
+	id, err := NewHashIDGenerator(defaultShortIDLength).Generate(originalURL, attempt)
+	if err != nil {
+		t.Fatalf("Error generating short ID: %v", err)
+	}
+	return id
+}
+
+func TestShortURLCollisionRetry(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-collision")
+	originalURL := "https://collision.example.com"
+
+	// Занимаем первый кандидат другим URL, имитируя коллизию префикса хеша
+	occupied := hashShortID(t, originalURL, 0)
+	err := service.GetStorage().Save(ctx, occupied, "https://someone-else.example.com", "other-user")
+	assert.NoError(t, err)
+
+	shortURL, err := service.CreateShortURL(ctx, originalURL)
+	assert.NoError(t, err)
+	assert.NotEqual(t, occupied, shortURL)
+	assert.Equal(t, hashShortID(t, originalURL, 1), shortURL)
+
+	got, err := service.GetOriginalURL(ctx, shortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, originalURL, got)
+
+	// Пакетное создание также должно обходить занятый идентификатор
+	batchURL := "https://batch-collision.example.com"
+	err = service.GetStorage().Save(ctx, hashShortID(t, batchURL, 0), "https://another.example.com", "other-user")
+	assert.NoError(t, err)
+
+	resp, err := service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+		{CorrelationID: "1", OriginalURL: batchURL},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp, 1)
+	assert.Equal(t, service.config.BaseURL+"/"+hashShortID(t, batchURL, 1), resp[0].ShortURL)
+}
+
+func TestCreateShortURLWithAlias(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-alias")
+	otherCtx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-other")
+
+	shortURL, err := service.CreateShortURLWithOptions(ctx, "https://alias.example.com", models.ShortenOptions{Alias: "my-promo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "my-promo", shortURL)
+
+	originalURL, err := service.GetOriginalURL(ctx, "my-promo")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://alias.example.com", originalURL)
+
+	// Тот же алиас другим пользователем — занят, даже для того же URL
+	_, err = service.CreateShortURLWithOptions(otherCtx, "https://alias.example.com", models.ShortenOptions{Alias: "my-promo"})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	_, err = service.CreateShortURLWithOptions(otherCtx, "https://other.example.com", models.ShortenOptions{Alias: "my-promo"})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	_, err = service.CreateShortURLWithOptions(ctx, "https://alias.example.com/2", models.ShortenOptions{Alias: "PING"})
+	assert.ErrorIs(t, err, ErrReservedAlias)
+
+	_, err = service.CreateShortURLWithOptions(ctx, "https://alias.example.com/3", models.ShortenOptions{Alias: "no/slash"})
+	assert.ErrorIs(t, err, ErrInvalidAlias)
+
+	// Пакетное создание с алиасами
+	resp, err := service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+		{CorrelationID: "1", OriginalURL: "https://batch-alias.example.com", Alias: "batch-one"},
+		{CorrelationID: "2", OriginalURL: "https://batch-plain.example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp, 2)
+	assert.Equal(t, service.config.BaseURL+"/batch-one", resp[0].ShortURL)
+
+	_, err = service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+		{CorrelationID: "1", OriginalURL: "https://batch-alias2.example.com", Alias: "batch-one"},
+	})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  error
+	}{
+		{alias: "promo_2024", want: nil},
+		{alias: "Sale-Summer", want: nil},
+		{alias: "ab", want: ErrInvalidAlias},
+		{alias: strings.Repeat("a", maxAliasLength+1), want: ErrInvalidAlias},
+		{alias: "has space", want: ErrInvalidAlias},
+		{alias: "юникод", want: ErrInvalidAlias},
+		{alias: "api", want: ErrReservedAlias},
+		{alias: "Metrics", want: ErrReservedAlias},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			assert.ErrorIs(t, ValidateAlias(tt.alias), tt.want)
+		})
+	}
+}
+
+func TestResolveExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		ttl       int64
+		want      time.Time
+		wantErr   bool
+	}{
+		{name: "No expiry", want: time.Time{}},
+		{name: "TTL", ttl: 3600, want: now.Add(time.Hour)},
+		{name: "Absolute", expiresAt: &future, want: future},
+		{name: "Absolute in the past", expiresAt: &past, wantErr: true},
+		{name: "Negative TTL", ttl: -1, wantErr: true},
+		{name: "Both set", expiresAt: &future, ttl: 60, wantErr: true},
+		{name: "Absolute equal to now", expiresAt: &now, wantErr: true},
+		{name: "Max TTL", ttl: MaxTTLSeconds, want: now.Add(MaxTTLSeconds * time.Second)},
+		{name: "TTL above max", ttl: MaxTTLSeconds + 1, wantErr: true},
+		// Без ограничения now + ttl переполнил бы time.Duration и дал бы момент в прошлом
+		{name: "Overflowing TTL", ttl: math.MaxInt64 / int64(time.Second) * 2, wantErr: true},
+		{name: "Max int64 TTL", ttl: math.MaxInt64, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveExpiry(tt.expiresAt, tt.ttl, now)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidExpiry)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "expected %v, got %v", tt.want, got)
+		})
+	}
+}
+
+func TestCreateShortURLWithExpiry(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-expiry")
+
+	shortURL, err := service.CreateShortURLWithOptions(ctx, "https://expiry.example.com", models.ShortenOptions{
+		ExpiresAt: time.Now().Add(50 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+
+	_, err = service.GetOriginalURL(ctx, shortURL)
+	assert.NoError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = service.GetOriginalURL(ctx, shortURL)
+	assert.ErrorIs(t, err, storage.ErrURLExpired)
+
+	_, err = service.CreateShortURLWithOptions(ctx, "https://expiry2.example.com", models.ShortenOptions{
+		ExpiresAt: time.Now().Add(-time.Second),
+	})
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+}
+
+func TestCreateShortURLsBatchExpiry(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-batch-expiry")
+	past := time.Now().Add(-time.Minute)
+
+	for _, entry := range []models.BatchRequestEntry{
+		{CorrelationID: "past", OriginalURL: "https://batch-expiry.example.com/past", ExpiresAt: &past},
+		{CorrelationID: "huge-ttl", OriginalURL: "https://batch-expiry.example.com/ttl", TTL: math.MaxInt64},
+	} {
+		_, err := service.CreateShortURLsBatch(ctx, []models.BatchRequestEntry{
+			{CorrelationID: "ok", OriginalURL: "https://batch-expiry.example.com/ok"},
+			entry,
+		})
+		assert.ErrorIs(t, err, ErrInvalidExpiry, entry.CorrelationID)
+		assert.ErrorContains(t, err, entry.CorrelationID)
+
+		// Пакет отклоняется целиком
+		_, err = service.GetStorage().GetShortURLByOriginal(ctx, "https://batch-expiry.example.com/ok")
+		assert.Error(t, err)
+	}
+}
+
+func TestCreateShortURLWithMetadata(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-metadata")
+
+	_, err := service.CreateShortURLWithOptions(ctx, "https://metadata.example.com", models.ShortenOptions{
+		Title:       "Заголовок",
+		Description: "Описание ссылки",
+	})
+	require.NoError(t, err)
+
+	urls, err := service.GetUserURLs(ctx, "user-metadata")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "Заголовок", urls[0].Title)
+	assert.Equal(t, "Описание ссылки", urls[0].Description)
+
+	_, err = service.CreateShortURLWithOptions(ctx, "https://metadata.example.com/long", models.ShortenOptions{
+		Title: strings.Repeat("я", maxTitleLength+1),
+	})
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
+
+func TestUpdateUserURL(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ownerCtx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-editor")
+	shortURL, err := service.CreateShortURL(ownerCtx, "https://edit.example.com/v1")
+	require.NoError(t, err)
+
+	destination := "https://edit.example.com/v2"
+	updated, err := service.UpdateUserURL(ownerCtx, shortURL, "user-editor", models.URLUpdate{OriginalURL: &destination})
+	require.NoError(t, err)
+	assert.Equal(t, service.config.BaseURL+"/"+shortURL, updated.ShortURL)
+	assert.Equal(t, destination, updated.OriginalURL)
+
+	originalURL, err := service.GetOriginalURL(ownerCtx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, destination, originalURL)
+
+	revisions, err := service.GetURLRevisions(ownerCtx, shortURL, "user-editor")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://edit.example.com/v1", revisions[0].OriginalURL)
+
+	// Чужую ссылку нельзя изменить или посмотреть ее историю
+	_, err = service.UpdateUserURL(ownerCtx, shortURL, "user-other", models.URLUpdate{OriginalURL: &destination})
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = service.GetURLRevisions(ownerCtx, shortURL, "user-other")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = service.UpdateUserURL(ownerCtx, shortURL, "user-editor", models.URLUpdate{})
+	assert.ErrorIs(t, err, ErrInvalidURLUpdate)
+	invalid := "not a url"
+	_, err = service.UpdateUserURL(ownerCtx, shortURL, "user-editor", models.URLUpdate{OriginalURL: &invalid})
+	assert.ErrorIs(t, err, ErrInvalidURLUpdate)
+	title := strings.Repeat("a", maxTitleLength+1)
+	_, err = service.UpdateUserURL(ownerCtx, shortURL, "user-editor", models.URLUpdate{Title: &title})
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
+
+func TestRestoreURLs(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-restore")
+	shortURL, err := service.CreateShortURL(ctx, "https://restore.example.com")
+	require.NoError(t, err)
+	require.NoError(t, service.BatchDeleteURLs(ctx, []string{shortURL}, "user-restore"))
+
+	_, err = service.GetOriginalURL(ctx, shortURL)
+	assert.ErrorIs(t, err, storage.ErrURLDeleted)
+
+	// Чужую ссылку восстановить нельзя
+	restored, err := service.RestoreURLs(ctx, []string{shortURL}, "user-other")
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	restored, err = service.RestoreURLs(ctx, []string{shortURL, "missing"}, "user-restore")
+	require.NoError(t, err)
+	assert.Equal(t, []string{shortURL}, restored)
+
+	originalURL, err := service.GetOriginalURL(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://restore.example.com", originalURL)
+}
+
+func TestResolveRedirect(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-redirect")
+	plain, err := service.CreateShortURL(ctx, "https://redirect.example.com/plain")
+	require.NoError(t, err)
+	permanent, err := service.CreateShortURLWithOptions(ctx, "https://redirect.example.com/permanent",
+		models.ShortenOptions{RedirectStatus: http.StatusMovedPermanently, Interstitial: true, Title: "Акция"})
+	require.NoError(t, err)
+
+	// Без настройки используется 307
+	redirect, err := service.ResolveRedirect(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, "https://redirect.example.com/plain", redirect.OriginalURL)
+	assert.Equal(t, http.StatusTemporaryRedirect, redirect.Status)
+	assert.False(t, redirect.Interstitial)
+
+	service.config.RedirectStatus = http.StatusPermanentRedirect
+	redirect, err = service.ResolveRedirect(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPermanentRedirect, redirect.Status)
+
+	// Код ссылки важнее кода по умолчанию
+	redirect, err = service.ResolveRedirect(ctx, permanent)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, redirect.Status)
+	assert.True(t, redirect.Interstitial)
+	assert.Equal(t, "Акция", redirect.Title)
+	assert.False(t, redirect.CreatedAt.IsZero())
+
+	_, err = service.CreateShortURLWithOptions(ctx, "https://redirect.example.com/see-other",
+		models.ShortenOptions{RedirectStatus: http.StatusSeeOther})
+	assert.ErrorIs(t, err, ErrInvalidRedirectStatus)
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		want        error
+	}{
+		{name: "Empty"},
+		{name: "Max length in runes", title: strings.Repeat("я", maxTitleLength), description: strings.Repeat("я", maxDescriptionLength)},
+		{name: "Title too long", title: strings.Repeat("a", maxTitleLength+1), want: ErrInvalidMetadata},
+		{name: "Description too long", description: strings.Repeat("a", maxDescriptionLength+1), want: ErrInvalidMetadata},
+		{name: "Invalid UTF-8", title: "\xff", want: ErrInvalidMetadata},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, ValidateMetadata(tt.title, tt.description), tt.want)
+		})
+	}
+}
+
+func TestClickAnalytics(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+
+	ownerCtx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user-owner")
+	shortURL, err := service.CreateShortURL(ownerCtx, "https://analytics.example.com")
+	assert.NoError(t, err)
+
+	service.RecordClick(shortURL, models.ClickInfo{Referrer: "https://ref.example.com/page", UserAgent: "test", ClientIP: "10.0.0.1"})
+	service.RecordClick(shortURL, models.ClickInfo{UserAgent: "test", ClientIP: "10.0.0.1"})
+
+	// Закрытие трекера гарантирует запись накопленных событий
+	closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, service.clicks.Close(closeCtx))
+
+	stats, err := service.GetURLStats(ownerCtx, shortURL, "user-owner")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Equal(t, int64(1), stats.UniqueVisitors)
+	assert.Equal(t, int64(1), stats.Referrers["ref.example.com"])
+
+	// Статистика чужой ссылки недоступна
+	_, err = service.GetURLStats(ownerCtx, shortURL, "user-stranger")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// После остановки трекера новые события отбрасываются без паники
+	assert.False(t, service.clicks.Track(models.ClickEvent{ShortURL: shortURL}))
+}
+
+func TestHashClientIP(t *testing.T) {
+	assert.Equal(t, hashClientIP("10.0.0.1", "secret"), hashClientIP("10.0.0.1", "secret"))
+	assert.NotEqual(t, hashClientIP("10.0.0.1", "secret"), hashClientIP("10.0.0.1", "other"))
+	assert.NotContains(t, hashClientIP("10.0.0.1", "secret"), "10.0.0.1")
+	assert.Empty(t, hashClientIP("", "secret"))
+}
+
 func TestGetStorage(t *testing.T) {
 	service, cleanup := setupTestService(t)
 	defer cleanup()
@@ -479,8 +861,7 @@ func TestMemoryStorage(t *testing.T) {
 }
 
 func TestFileStorage(t *testing.T) {
-	filePath := "test_file_storage.json"
-	defer os.Remove(filePath) // Очистка после теста
+	filePath := filepath.Join(t.TempDir(), "test_file_storage.json")
 
 	logger, _ := zap.NewDevelopment()
 	store, err := storage.NewFileStorage(filePath, logger)
@@ -793,3 +1174,41 @@ func BenchmarkURLService_BatchDeleteURLs(b *testing.B) {
 		})
 	}
 }
+
+func TestListUserURLs(t *testing.T) {
+	service, cleanup := setupTestService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		shortID := fmt.Sprintf("list%d", i)
+		err := service.storage.Save(ctx, shortID, "https://example.com/"+shortID, "list-user")
+		assert.NoError(t, err)
+	}
+
+	page, err := service.ListUserURLs(ctx, "list-user", models.UserURLQuery{Sort: models.SortShortURL, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	require.Len(t, page.URLs, 2)
+	assert.Equal(t, "http://localhost:8080/list0", page.URLs[0].ShortURL)
+	assert.Equal(t, "https://example.com/list0", page.URLs[0].OriginalURL)
+	assert.Equal(t, "http://localhost:8080/list1", page.URLs[1].ShortURL)
+	assert.NotNil(t, page.URLs[0].CreatedAt)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// Лимит по умолчанию
+	page, err = service.ListUserURLs(ctx, "list-user", models.UserURLQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 3)
+	assert.Empty(t, page.NextCursor)
+
+	for _, query := range []models.UserURLQuery{
+		{Limit: MaxUserURLsPageSize + 1},
+		{Limit: -1},
+		{Sort: "user_id"},
+		{Cursor: "not-a-cursor"},
+	} {
+		_, err = service.ListUserURLs(ctx, "list-user", query)
+		assert.ErrorIs(t, err, ErrInvalidUserURLQuery)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"maps"
+	"net/url"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+)
+
+// directReferrer — ключ статистики для переходов без заголовка Referer
+const directReferrer = "direct"
+
+// dailyStatsLayout — формат ключа дневной статистики переходов
+const dailyStatsLayout = "2006-01-02"
+
+// referrerKey возвращает ключ статистики источника перехода: хост из Referer или directReferrer.
+func referrerKey(referrer string) string {
+	if referrer == "" {
+		return directReferrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return referrer
+	}
+	return u.Host
+}
+
+// newClickStats создает пустую статистику переходов для shortURL
+func newClickStats(shortURL string) models.ClickStats {
+	return models.ClickStats{
+		ShortURL:  shortURL,
+		Referrers: make(map[string]int64),
+		Daily:     make(map[string]int64),
+	}
+}
+
+// clickAggregator накапливает статистику переходов по отдельным событиям.
+// Используется хранилищами, которые не могут агрегировать данные на своей стороне.
+// Хранит только счетчики и множество хешей посетителей, а не сами события.
+type clickAggregator struct {
+	stats    models.ClickStats
+	visitors map[string]struct{}
+}
+
+// newClickAggregator создает агрегатор статистики для shortURL
+func newClickAggregator(shortURL string) *clickAggregator {
+	return &clickAggregator{
+		stats:    newClickStats(shortURL),
+		visitors: make(map[string]struct{}),
+	}
+}
+
+// add учитывает событие в статистике
+func (a *clickAggregator) add(event models.ClickEvent) {
+	a.stats.TotalClicks++
+	if event.IPHash != "" {
+		a.visitors[event.IPHash] = struct{}{}
+	}
+	if a.stats.LastClickAt == nil || event.Timestamp.After(*a.stats.LastClickAt) {
+		ts := event.Timestamp
+		a.stats.LastClickAt = &ts
+	}
+	a.stats.Referrers[referrerKey(event.Referrer)]++
+	a.stats.Daily[event.Timestamp.UTC().Format(dailyStatsLayout)]++
+}
+
+// result возвращает копию накопленной статистики: агрегатор продолжает
+// учитывать события, поэтому вызывающему нельзя отдавать его карты
+func (a *clickAggregator) result() models.ClickStats {
+	stats := a.stats
+	stats.UniqueVisitors = int64(len(a.visitors))
+	stats.Referrers = maps.Clone(a.stats.Referrers)
+	stats.Daily = maps.Clone(a.stats.Daily)
+	if a.stats.LastClickAt != nil {
+		ts := *a.stats.LastClickAt
+		stats.LastClickAt = &ts
+	}
+	return stats
+}
+
+// clickIndex хранит статистику переходов по коротким ссылкам, обновляемую
+// по мере поступления событий, чтобы запрос статистики не перебирал все события
+type clickIndex map[string]*clickAggregator
+
+// add учитывает событие в статистике его ссылки
+func (idx clickIndex) add(event models.ClickEvent) {
+	agg, exists := idx[event.ShortURL]
+	if !exists {
+		agg = newClickAggregator(event.ShortURL)
+		idx[event.ShortURL] = agg
+	}
+	agg.add(event)
+}
+
+// stats возвращает статистику переходов по shortURL
+func (idx clickIndex) stats(shortURL string) models.ClickStats {
+	agg, exists := idx[shortURL]
+	if !exists {
+		return newClickStats(shortURL)
+	}
+	return agg.result()
+}
+
+// lastClickTime возвращает указатель на время последнего перехода или nil, если переходов не было
+func lastClickTime(t time.Time, valid bool) *time.Time {
+	if !valid {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+)
+
+// userAPIKeys возвращает ключи пользователя из keys (по хешу) в порядке создания.
+// Используется хранилищами, держащими ключи в памяти.
+func userAPIKeys(keys map[string]models.APIKey, userID string) []models.APIKey {
+	result := make([]models.APIKey, 0)
+	for _, key := range keys {
+		if key.UserID == userID {
+			result = append(result, key)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// revokeAPIKey находит ключ пользователя по ID и возвращает его отозванное состояние.
+// Уже отозванный ключ возвращается без изменений.
+func revokeAPIKey(keys map[string]models.APIKey, id, userID string, at time.Time) (models.APIKey, error) {
+	for _, key := range keys {
+		if key.ID != id || key.UserID != userID {
+			continue
+		}
+		if !key.Revoked() {
+			revokedAt := at.UTC()
+			key.RevokedAt = &revokedAt
+		}
+		return key, nil
+	}
+	return models.APIKey{}, ErrAPIKeyNotFound
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testCanonicalURLs проверяет поиск дубликатов по канонической форме URL хранилищем store
+func testCanonicalURLs(t *testing.T, store URLStorage) {
+	ctx := context.Background()
+	const canonical = "http://example.com/"
+
+	require.NoError(t, store.SaveEntry(ctx, BatchEntry{
+		ShortURL: "typed", OriginalURL: "http://Example.com:80", CanonicalURL: canonical, UserID: "user1",
+	}))
+
+	shortURL, err := store.GetShortURLByOriginal(ctx, canonical)
+	require.NoError(t, err)
+	assert.Equal(t, "typed", shortURL)
+	_, err = store.GetShortURLByOriginal(ctx, "http://Example.com:80")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	// Переход выполняется по URL в том виде, в каком его ввел пользователь
+	originalURL, err := store.Get(ctx, "typed")
+	require.NoError(t, err)
+	assert.Equal(t, "http://Example.com:80", originalURL)
+
+	err = store.SaveEntry(ctx, BatchEntry{ShortURL: "dup", OriginalURL: "http://example.com", CanonicalURL: canonical, UserID: "user1"})
+	assert.ErrorIs(t, err, ErrOriginalURLConflict)
+	assert.NoError(t, store.SaveEntry(ctx, BatchEntry{ShortURL: "other", OriginalURL: "http://example.com", CanonicalURL: canonical, UserID: "user2"}))
+
+	// Без канонической формы ключом служит сам оригинальный URL
+	require.NoError(t, store.Save(ctx, "plain", "https://plain.example.com", "user1"))
+	shortURL, err = store.GetShortURLByOriginal(ctx, "https://plain.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", shortURL)
+
+	destination := "HTTP://example.com"
+	_, err = store.UpdateURL(ctx, "plain", "user1", models.URLUpdate{OriginalURL: &destination, CanonicalURL: canonical})
+	assert.ErrorIs(t, err, ErrOriginalURLConflict)
+
+	destination = "https://Plain.example.com/v2"
+	_, err = store.UpdateURL(ctx, "plain", "user1", models.URLUpdate{OriginalURL: &destination, CanonicalURL: "https://plain.example.com/v2"})
+	require.NoError(t, err)
+	shortURL, err = store.GetShortURLByOriginal(ctx, "https://plain.example.com/v2")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", shortURL)
+	_, err = store.GetShortURLByOriginal(ctx, "https://plain.example.com")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestMemoryStorage_CanonicalURLs(t *testing.T) {
+	testCanonicalURLs(t, NewMemoryStorage(zap.NewNop()))
+}
+
+func TestFileStorage_CanonicalURLs(t *testing.T) {
+	path := createTempFile(t)
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	testCanonicalURLs(t, store)
+	require.NoError(t, store.Close())
+
+	// Каноническая форма восстанавливается из журнала
+	reopened, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	shortURL, err := reopened.GetShortURLByOriginal(context.Background(), "http://example.com/")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"typed", "other"}, shortURL)
+	shortURL, err = reopened.GetShortURLByOriginal(context.Background(), "https://plain.example.com/v2")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", shortURL)
+}
+
+func TestFileStorage_LegacyRecordsWithoutCanonicalURL(t *testing.T) {
+	path := createTempFile(t)
+	legacy := `{"uuid":"1","short_url":"old","original_url":"https://example.com/old","user_id":"user1"}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+
+	shortURL, err := store.GetShortURLByOriginal(context.Background(), "https://example.com/old")
+	require.NoError(t, err)
+	assert.Equal(t, "old", shortURL)
+}
+
+// testDeletedURLDedup проверяет, что удаленные ссылки не участвуют в поиске дубликатов хранилищем store.
+// Идентификаторы начинаются с prefix, чтобы тест можно было повторять на общей базе данных.
+func testDeletedURLDedup(t *testing.T, store URLStorage, prefix string) {
+	ctx := context.Background()
+	user, original := prefix+"user", "https://"+prefix+".example.com/"
+	oldURL, newURL := prefix+"old", prefix+"new"
+
+	require.NoError(t, store.Save(ctx, oldURL, original, user))
+	require.NoError(t, store.BatchDelete(ctx, []string{oldURL}, user))
+
+	// Удаленная ссылка не возвращается как дубликат
+	_, err := store.GetShortURLByOriginal(ctx, original)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	// и не мешает снова сократить тот же URL
+	require.NoError(t, store.Save(ctx, newURL, original, user))
+	shortURL, err := store.GetShortURLByOriginal(ctx, original)
+	require.NoError(t, err)
+	assert.Equal(t, newURL, shortURL)
+	assert.ErrorIs(t, store.Save(ctx, prefix+"dup", original, user), ErrOriginalURLConflict)
+
+	// Пока действует новая ссылка, прежнюю восстановить нельзя
+	restored, err := store.RestoreURLs(ctx, []string{oldURL}, user)
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	require.NoError(t, store.BatchDelete(ctx, []string{newURL}, user))
+	restored, err = store.RestoreURLs(ctx, []string{oldURL, newURL}, user)
+	require.NoError(t, err)
+	assert.Len(t, restored, 1, "only one of the URLs with the same destination can be restored")
+	shortURL, err = store.GetShortURLByOriginal(ctx, original)
+	require.NoError(t, err)
+	assert.Equal(t, restored[0], shortURL)
+
+	// Повторное сохранение удаленной ссылки с тем же идентификатором восстанавливает ее
+	sameURL, sameOriginal := prefix+"same", "https://"+prefix+".example.com/same"
+	require.NoError(t, store.Save(ctx, sameURL, sameOriginal, user))
+	require.NoError(t, store.BatchDelete(ctx, []string{sameURL}, user))
+	require.NoError(t, store.Save(ctx, sameURL, sameOriginal, user))
+	got, err := store.Get(ctx, sameURL)
+	require.NoError(t, err)
+	assert.Equal(t, sameOriginal, got)
+	shortURL, err = store.GetShortURLByOriginal(ctx, sameOriginal)
+	require.NoError(t, err)
+	assert.Equal(t, sameURL, shortURL)
+}
+
+func TestDeletedURLDedup(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testDeletedURLDedup(t, NewMemoryStorage(zap.NewNop()), "m")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		store, err := NewFileStorage(createTempFile(t), zap.NewNop())
+		require.NoError(t, err)
+		defer store.Close()
+		testDeletedURLDedup(t, store, "f")
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("TEST_DATABASE_DSN")
+		if dsn == "" {
+			t.Skip("TEST_DATABASE_DSN is not set")
+		}
+		store, err := NewPostgresStorage(dsn, zap.NewNop())
+		require.NoError(t, err)
+		defer store.Close()
+		testDeletedURLDedup(t, store, uuid.NewString()[:8])
+	})
+}
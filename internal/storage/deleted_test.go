@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testRestoreURLs проверяет восстановление и окончательное удаление ссылок хранилищем store
+func testRestoreURLs(t *testing.T, store URLStorage) {
+	ctx := context.Background()
+	purger, ok := store.(DeletedURLPurger)
+	require.True(t, ok)
+
+	require.NoError(t, store.Save(ctx, "keep", "https://example.com/keep", "user1"))
+	require.NoError(t, store.Save(ctx, "gone1", "https://example.com/1", "user1"))
+	require.NoError(t, store.Save(ctx, "gone2", "https://example.com/2", "user1"))
+	require.NoError(t, store.Save(ctx, "gone3", "https://example.com/3", "user1"))
+	require.NoError(t, store.BatchDelete(ctx, []string{"gone1", "gone2", "gone3"}, "user1"))
+
+	t.Run("list deleted", func(t *testing.T) {
+		page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Deleted: true})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 3)
+		for _, u := range page.URLs {
+			assert.NotNil(t, u.DeletedAt, u.ShortURL)
+		}
+
+		page, err = store.ListUserURLs(ctx, "user1", models.UserURLQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 1)
+		assert.Equal(t, "keep", page.URLs[0].ShortURL)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		restored, err := store.RestoreURLs(ctx, []string{"gone1", "keep", "missing"}, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gone1"}, restored)
+
+		restored, err = store.RestoreURLs(ctx, []string{"gone2"}, "user2")
+		require.NoError(t, err)
+		assert.Empty(t, restored)
+
+		original, err := store.Get(ctx, "gone1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/1", original)
+
+		_, err = store.Get(ctx, "gone2")
+		assert.ErrorIs(t, err, ErrURLDeleted)
+
+		page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 2)
+		for _, u := range page.URLs {
+			assert.Nil(t, u.DeletedAt, u.ShortURL)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		purged, err := purger.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		purged, err = purger.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), purged)
+
+		_, err = store.Get(ctx, "gone2")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+
+		restored, err := store.RestoreURLs(ctx, []string{"gone2", "gone3"}, "user1")
+		require.NoError(t, err)
+		assert.Empty(t, restored)
+
+		page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Deleted: true})
+		require.NoError(t, err)
+		assert.Empty(t, page.URLs)
+
+		// Восстановленная ссылка не удаляется окончательно
+		original, err := store.Get(ctx, "gone1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/1", original)
+	})
+}
+
+func TestMemoryStorage_RestoreURLs(t *testing.T) {
+	testRestoreURLs(t, NewMemoryStorage(zap.NewNop()))
+}
+
+func TestFileStorage_RestoreURLs(t *testing.T) {
+	path := createTempFile(t)
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	testRestoreURLs(t, store)
+	require.NoError(t, store.Close())
+
+	// Восстановление и окончательное удаление сохраняются после перезапуска
+	reopened, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	original, err := reopened.Get(context.Background(), "gone1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", original)
+
+	_, err = reopened.Get(context.Background(), "gone3")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestRestoreURLs_OriginalURLConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStorage(zap.NewNop())
+
+	require.NoError(t, store.Save(ctx, "old", "https://example.com", "user1"))
+	require.NoError(t, store.BatchDelete(ctx, []string{"old"}, "user1"))
+	require.NoError(t, store.Save(ctx, "new", "https://example.com", "user1"))
+
+	// У пользователя уже есть действующая ссылка на тот же адрес
+	restored, err := store.RestoreURLs(ctx, []string{"old"}, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
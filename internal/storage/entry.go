@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+)
+
+// canonicalURL возвращает ключ поиска дубликатов записи: каноническую форму URL,
+// а если она не задана — сам оригинальный URL
+func (e BatchEntry) canonicalURL() string {
+	return cmp.Or(e.CanonicalURL, e.OriginalURL)
+}
+
+// isCollision сообщает, занят ли shortURL записью existing для другой пары originalURL/userID.
+// Повторное сохранение той же пары коллизией не считается.
+func isCollision(existing BatchEntry, originalURL, userID string) bool {
+	return existing.OriginalURL != originalURL || existing.UserID != userID
+}
+
+// checkBatchCollisions проверяет, что ни один shortURL из пакета не занят другой записью
+// ни в хранилище (через lookup), ни внутри самого пакета.
+func checkBatchCollisions(batch []BatchEntry, lookup func(shortURL string) (BatchEntry, bool)) error {
+	seen := make(map[string]BatchEntry, len(batch))
+	for _, entry := range batch {
+		if prev, ok := seen[entry.ShortURL]; ok && isCollision(prev, entry.OriginalURL, entry.UserID) {
+			return fmt.Errorf("%w: %s", ErrShortURLCollision, entry.ShortURL)
+		}
+		seen[entry.ShortURL] = entry
+
+		if existing, exists := lookup(entry.ShortURL); exists && isCollision(existing, entry.OriginalURL, entry.UserID) {
+			return fmt.Errorf("%w: %s", ErrShortURLCollision, entry.ShortURL)
+		}
+	}
+	return nil
+}
+
+// isExpired сообщает, истек ли к моменту now срок действия, заданный expiresAt.
+// Нулевое значение expiresAt означает бессрочную ссылку.
+func isExpired(expiresAt, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
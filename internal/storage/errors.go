@@ -10,3 +10,21 @@ var ErrOriginalURLConflict = errors.New("original URL conflict")
 
 // ErrURLDeleted возвращается, когда URL помечен как удаленный
 var ErrURLDeleted = errors.New("URL is deleted")
+
+// ErrShortURLCollision возвращается, когда short_url уже занят другим original_url
+var ErrShortURLCollision = errors.New("short URL collision")
+
+// ErrURLExpired возвращается, когда истек срок действия ссылки
+var ErrURLExpired = errors.New("URL has expired")
+
+// ErrDeleteJobNotFound возвращается, когда задача удаления не найдена
+var ErrDeleteJobNotFound = errors.New("delete job not found")
+
+// ErrAPIKeyNotFound возвращается, когда API ключ не найден
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrInvalidCursor возвращается, когда курсор страницы поврежден или получен при другой сортировке
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// ErrNotSupported возвращается, когда хранилище не поддерживает запрошенную операцию
+var ErrNotSupported = errors.New("operation is not supported by storage")
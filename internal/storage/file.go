@@ -1,11 +1,18 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"go.uber.org/zap"
@@ -18,17 +25,148 @@ type URLRecord struct {
 	OriginalURL string `json:"original_url"`
 	UserID      string `json:"user_id,omitempty"`
 	IsDeleted   bool   `json:"is_deleted,omitempty"`
+	// CanonicalURL — каноническая форма OriginalURL; отсутствует, если совпадает с ним
+	// или запись сохранена до ее появления
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	// ExpiresAt — момент истечения срока действия; nil для бессрочных ссылок
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// CreatedAt — момент создания; отсутствует в записях, сохраненных до его появления
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// UpdatedAt — момент последнего изменения; как и CreatedAt, может отсутствовать
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// DeletedAt — момент удаления; в tombstone записи удаления хранит время удаления
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Title и Description — заголовок и описание, заданные пользователем
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// RedirectStatus — HTTP код перенаправления; отсутствует для кода по умолчанию
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// Interstitial — переход всегда выполняется через страницу предпросмотра
+	Interstitial bool `json:"interstitial,omitempty"`
+	// Op — тип записи журнала: пустой для сохранения URL или одна из recordOp* для tombstone
+	Op string `json:"op,omitempty"`
 }
 
-// FileStorage implements URLStorage using a file
+// Типы tombstone записей журнала
+const (
+	recordOpDelete = "delete" // Пометка ссылки пользователя удаленной
+	recordOpPurge  = "purge"  // Окончательное удаление ссылки
+)
+
+// tombstoneRecord — запись журнала об удалении ссылки
+type tombstoneRecord struct {
+	Op        string     `json:"op"`
+	ShortURL  string     `json:"short_url"`
+	UserID    string     `json:"user_id,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// expiresAt возвращает срок действия записи (нулевое значение для бессрочных ссылок)
+func (r URLRecord) expiresAt() time.Time {
+	if r.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return *r.ExpiresAt
+}
+
+// canonicalURL возвращает ключ поиска дубликатов записи
+func (r URLRecord) canonicalURL() string {
+	return cmp.Or(r.CanonicalURL, r.OriginalURL)
+}
+
+// row возвращает запись в виде строки списка ссылок пользователя.
+// У записей, сохраненных до появления временных меток, они нулевые.
+func (r URLRecord) row() userURLRow {
+	row := userURLRow{
+		ShortURL:    r.ShortURL,
+		OriginalURL: r.OriginalURL,
+		Title:       r.Title,
+		Description: r.Description,
+
+		RedirectStatus: r.RedirectStatus,
+		Interstitial:   r.Interstitial,
+	}
+	if r.CreatedAt != nil {
+		row.CreatedAt = *r.CreatedAt
+	}
+	if r.UpdatedAt != nil {
+		row.UpdatedAt = *r.UpdatedAt
+	}
+	if r.DeletedAt != nil {
+		row.DeletedAt = *r.DeletedAt
+	}
+	return row
+}
+
+// newURLRecord создает запись файлового хранилища из BatchEntry,
+// созданную в момент createdAt и измененную в момент updatedAt
+func newURLRecord(entry BatchEntry, createdAt, updatedAt time.Time) URLRecord {
+	record := URLRecord{
+		ShortURL:    entry.ShortURL,
+		OriginalURL: entry.OriginalURL,
+		UserID:      entry.UserID,
+		IsDeleted:   false,
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &updatedAt,
+		Title:       entry.Title,
+		Description: entry.Description,
+
+		RedirectStatus: entry.RedirectStatus,
+		Interstitial:   entry.Interstitial,
+		CanonicalURL:   entry.CanonicalURL,
+	}
+	if !entry.ExpiresAt.IsZero() {
+		expiresAt := entry.ExpiresAt
+		record.ExpiresAt = &expiresAt
+	}
+	return record
+}
+
+// FileStorage implements URLStorage using a file.
+// Файл является журналом только для дозаписи: сохранения и удаления дописываются
+// в конец, а устаревшие записи периодически удаляются компактизацией.
 type FileStorage struct {
-	filePath string
-	urls     map[string]URLRecord
-	mutex    sync.RWMutex
-	file     *os.File
-	logger   *zap.Logger
+	filePath   string
+	urls       map[string]URLRecord
+	index      urlIndex // Индексы по оригинальному URL и пользователю
+	mutex      sync.RWMutex
+	file       *os.File
+	clicksFile *os.File // Файл событий переходов (filePath + clicksFileSuffix)
+	jobsFile   *os.File // Журнал задач удаления (filePath + jobsFileSuffix)
+	jobs       map[string]models.DeleteJob
+	keysFile   *os.File                 // Журнал API ключей (filePath + keysFileSuffix)
+	keys       map[string]models.APIKey // API ключи по хешу
+	// Журнал версий ссылок (filePath + revisionsFileSuffix)
+	revisionsFile *os.File
+	revisions     map[string][]models.URLRevision // Предыдущие версии ссылок из журнала версий
+	// Журнал счетчиков идентификаторов (filePath + countersFileSuffix)
+	countersFile *os.File
+	counters     map[string]uint64 // Следующие значения счетчиков
+	logger       *zap.Logger
+
+	// Переходы записываются на каждый редирект, поэтому файл событий и статистика
+	// защищены отдельным мьютексом и не блокируют операции со ссылками
+	clicksMu sync.Mutex
+	clicks   clickIndex // Статистика переходов, обновляемая при записи событий
+
+	staleRecords    int // Количество записей журнала, не отражающих текущее состояние
+	compactMinStale int // Минимальное количество устаревших записей для автоматической компактизации
 }
 
+// Суффиксы вспомогательных файлов хранилища
+const (
+	clicksFileSuffix = ".clicks" // События переходов
+	jobsFileSuffix   = ".jobs"   // Журнал состояний задач удаления
+	keysFileSuffix   = ".keys"   // Журнал API ключей
+
+	revisionsFileSuffix = ".revisions" // Журнал предыдущих версий ссылок
+	countersFileSuffix  = ".counters"  // Журнал резервирования счетчиков идентификаторов
+)
+
+// defaultCompactMinStale — порог устаревших записей, после которого журнал компактизируется,
+// если устаревших записей также не меньше, чем актуальных
+const defaultCompactMinStale = 1000
+
 // NewFileStorage creates a new FileStorage instance
 func NewFileStorage(filePath string, logger *zap.Logger) (*FileStorage, error) {
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
@@ -36,11 +174,65 @@ func NewFileStorage(filePath string, logger *zap.Logger) (*FileStorage, error) {
 		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 
+	clicksFile, err := os.OpenFile(filePath+clicksFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error opening clicks file: %w", err)
+	}
+
+	jobsFile, err := os.OpenFile(filePath+jobsFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		file.Close()
+		clicksFile.Close()
+		return nil, fmt.Errorf("error opening delete jobs file: %w", err)
+	}
+
+	keysFile, err := os.OpenFile(filePath+keysFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		file.Close()
+		clicksFile.Close()
+		jobsFile.Close()
+		return nil, fmt.Errorf("error opening API keys file: %w", err)
+	}
+
+	revisionsFile, err := os.OpenFile(filePath+revisionsFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		file.Close()
+		clicksFile.Close()
+		jobsFile.Close()
+		keysFile.Close()
+		return nil, fmt.Errorf("error opening revisions file: %w", err)
+	}
+
+	countersFile, err := os.OpenFile(filePath+countersFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		file.Close()
+		clicksFile.Close()
+		jobsFile.Close()
+		keysFile.Close()
+		revisionsFile.Close()
+		return nil, fmt.Errorf("error opening counters file: %w", err)
+	}
+
 	fs := &FileStorage{
-		filePath: filePath,
-		file:     file,
-		urls:     make(map[string]URLRecord),
-		logger:   logger,
+		filePath:   filePath,
+		file:       file,
+		clicksFile: clicksFile,
+		jobsFile:   jobsFile,
+		jobs:       make(map[string]models.DeleteJob),
+		keysFile:   keysFile,
+		keys:       make(map[string]models.APIKey),
+		urls:       make(map[string]URLRecord),
+		index:      newURLIndex(),
+		logger:     logger,
+
+		revisionsFile: revisionsFile,
+		revisions:     make(map[string][]models.URLRevision),
+		countersFile:  countersFile,
+		counters:      make(map[string]uint64),
+		clicks:        make(clickIndex),
+
+		compactMinStale: defaultCompactMinStale,
 	}
 
 	// Load existing data from file
@@ -48,114 +240,251 @@ func NewFileStorage(filePath string, logger *zap.Logger) (*FileStorage, error) {
 		logger.Error("Error loading data from file", zap.Error(err))
 		// Не возвращаем ошибку, так как файл может быть пустым
 	}
+	if err := fs.loadDeleteJobs(); err != nil {
+		logger.Error("Error loading delete jobs from file", zap.Error(err))
+	}
+	if err := fs.loadAPIKeys(); err != nil {
+		logger.Error("Error loading API keys from file", zap.Error(err))
+	}
+	if err := fs.loadClicks(); err != nil {
+		logger.Error("Error loading click events from file", zap.Error(err))
+	}
+	if err := fs.loadRevisions(); err != nil {
+		logger.Error("Error loading URL revisions from file", zap.Error(err))
+	}
+	if err := fs.loadCounters(); err != nil {
+		// Без журнала счетчиков идентификаторы начали бы повторяться
+		fs.Close()
+		return nil, fmt.Errorf("error loading counters: %w", err)
+	}
 
 	return fs, nil
 }
 
-// loadFromFile loads data from the file
+// loadFromFile загружает данные из журнала.
+// Поврежденная последняя строка (результат прерванной записи) отбрасывается,
+// а файл обрезается до последней целой записи, чтобы новые записи не склеивались с ней.
+// Поврежденные строки в середине файла пропускаются с записью в лог.
 func (fs *FileStorage) loadFromFile() error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
 	// Перемещаем указатель в начало файла
-	if _, err := fs.file.Seek(0, 0); err != nil {
+	if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
 		return fmt.Errorf("error seeking to file start: %w", err)
 	}
 
-	decoder := json.NewDecoder(fs.file)
-	for decoder.More() {
-		var record URLRecord
-		if err := decoder.Decode(&record); err != nil {
-			return fmt.Errorf("error decoding record: %w", err)
+	reader := bufio.NewReader(fs.file)
+	var offset int64 // Конец последней целой записи
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("error reading file: %w", readErr)
+		}
+		lastLine := errors.Is(readErr, io.EOF)
+
+		if data := bytes.TrimSpace(line); len(data) > 0 {
+			var record URLRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				if lastLine {
+					fs.logger.Warn("Discarding torn last record", zap.Int("line", lineNo), zap.Error(err))
+					if err := fs.file.Truncate(offset); err != nil {
+						return fmt.Errorf("error truncating torn record: %w", err)
+					}
+					return nil
+				}
+				fs.logger.Error("Skipping corrupted record", zap.Int("line", lineNo), zap.Error(err))
+			} else {
+				fs.applyRecord(record)
+			}
+
+			// Целая запись без завершающего перевода строки: дописываем его,
+			// чтобы следующая запись начиналась с новой строки
+			if lastLine && line[len(line)-1] != '\n' {
+				if _, err := fs.file.Write([]byte{'\n'}); err != nil {
+					return fmt.Errorf("error terminating last record: %w", err)
+				}
+			}
+		}
+		offset += int64(len(line))
+
+		if lastLine {
+			return nil
+		}
+	}
+}
+
+// applyRecord применяет запись журнала к состоянию в памяти.
+// Ссылкам, удаленным до появления времени удаления, оно назначается в момент загрузки:
+// с него отсчитывается срок, в течение которого ссылку можно восстановить.
+func (fs *FileStorage) applyRecord(record URLRecord) {
+	if (record.Op == recordOpDelete || record.IsDeleted) && record.DeletedAt == nil {
+		now := time.Now().UTC()
+		record.DeletedAt = &now
+	}
+
+	switch record.Op {
+	case recordOpDelete:
+		fs.staleRecords++
+		if existing, exists := fs.urls[record.ShortURL]; exists && existing.UserID == record.UserID {
+			existing.IsDeleted = true
+			existing.DeletedAt = record.DeletedAt
+			existing.UpdatedAt = record.DeletedAt
+			fs.urls[record.ShortURL] = existing
+		}
+	case recordOpPurge:
+		fs.staleRecords++
+		if existing, exists := fs.urls[record.ShortURL]; exists {
+			delete(fs.urls, record.ShortURL)
+			delete(fs.revisions, record.ShortURL)
+			fs.index.remove(record.ShortURL, existing.canonicalURL(), existing.UserID)
+			fs.staleRecords++
+		}
+	default:
+		if existing, exists := fs.urls[record.ShortURL]; exists {
+			fs.index.remove(record.ShortURL, existing.canonicalURL(), existing.UserID)
+			fs.staleRecords++
 		}
 		fs.urls[record.ShortURL] = record
+		fs.index.add(record.ShortURL, record.canonicalURL(), record.UserID)
+	}
+}
+
+// appendRecords дописывает записи в журнал одной операцией записи
+func (fs *FileStorage) appendRecords(records ...any) error {
+	var buf []byte
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("error marshaling record: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
 	}
 
+	if _, err := fs.file.Write(buf); err != nil {
+		return fmt.Errorf("error writing to file: %w", err)
+	}
 	return nil
 }
 
+// newRecord создает запись журнала для entry. Повторное сохранение существующей
+// записи сохраняет время ее создания; вызывающий должен удерживать fs.mutex.
+func (fs *FileStorage) newRecord(entry BatchEntry) URLRecord {
+	now := time.Now().UTC()
+	createdAt := now
+	if existing, exists := fs.urls[entry.ShortURL]; exists && existing.CreatedAt != nil {
+		createdAt = *existing.CreatedAt
+	}
+	return newURLRecord(entry, createdAt, now)
+}
+
 // Save сохраняет URL в файл, связывая его с userID
 func (fs *FileStorage) Save(ctx context.Context, shortURL, originalURL, userID string) error {
+	return fs.SaveEntry(ctx, BatchEntry{ShortURL: shortURL, OriginalURL: originalURL, UserID: userID})
+}
+
+// SaveEntry сохраняет URL со всеми атрибутами записи в файл
+func (fs *FileStorage) SaveEntry(ctx context.Context, entry BatchEntry) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
-	// Проверка на конфликт по originalURL для данного userID
-	for existingShort, record := range fs.urls {
-		if record.OriginalURL == originalURL && record.UserID == userID && !record.IsDeleted && existingShort != shortURL {
-			return ErrOriginalURLConflict
-		}
-	}
-
-	record := URLRecord{
-		ShortURL:    shortURL,
-		OriginalURL: originalURL,
-		UserID:      userID,
-		IsDeleted:   false,
+	// Проверка на коллизию: shortURL уже занят другим originalURL или другим пользователем
+	if existing, exists := fs.urls[entry.ShortURL]; exists && isCollision(BatchEntry{OriginalURL: existing.OriginalURL, UserID: existing.UserID}, entry.OriginalURL, entry.UserID) {
+		return ErrShortURLCollision
 	}
 
-	data, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("error marshaling URL record: %w", err)
+	// Проверка на конфликт по originalURL для данного userID
+	if fs.hasOriginalConflict(entry.ShortURL, entry.canonicalURL(), entry.UserID, time.Now()) {
+		return ErrOriginalURLConflict
 	}
 
-	if _, err := fs.file.Write(append(data, '\n')); err != nil {
-		return fmt.Errorf("error writing to file: %w", err)
+	record := fs.newRecord(entry)
+	if err := fs.appendRecords(record); err != nil {
+		return err
 	}
 
-	fs.urls[shortURL] = record
+	fs.applyRecord(record)
 	return nil
 }
 
+// hasOriginalConflict сообщает, есть ли у пользователя другая действующая ссылка
+// на URL с канонической формой canonicalURL, кроме shortURL; вызывающий должен удерживать fs.mutex
+func (fs *FileStorage) hasOriginalConflict(shortURL, canonicalURL, userID string, now time.Time) bool {
+	for existingShort := range fs.index.shortURLsByCanonical(canonicalURL) {
+		record := fs.urls[existingShort]
+		if record.UserID == userID && !record.IsDeleted &&
+			!isExpired(record.expiresAt(), now) && existingShort != shortURL {
+			return true
+		}
+	}
+	return false
+}
+
 // Get получает оригинальный URL по короткому
 func (fs *FileStorage) Get(ctx context.Context, shortURL string) (string, error) {
+	redirect, err := fs.GetRedirect(ctx, shortURL)
+	return redirect.OriginalURL, err
+}
+
+// GetRedirect получает оригинальный URL и параметры перехода по короткому URL
+func (fs *FileStorage) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
 	if record, exists := fs.urls[shortURL]; exists {
 		if record.IsDeleted {
-			return "", ErrURLDeleted
+			return models.Redirect{}, ErrURLDeleted
+		}
+		if isExpired(record.expiresAt(), time.Now()) {
+			return models.Redirect{}, ErrURLExpired
 		}
-		return record.OriginalURL, nil
+		redirect := record.row().redirect()
+		redirect.ExpiresAt = record.expiresAt()
+		redirect.Editable = record.UserID != ""
+		return redirect, nil
 	}
 
-	return "", ErrURLNotFound
+	return models.Redirect{}, ErrURLNotFound
 }
 
-// SaveBatch сохраняет пакет URL
+// SaveBatch сохраняет пакет URL.
+// Если хотя бы один shortURL занят другим originalURL, пакет не сохраняется
+// и возвращается ErrShortURLCollision.
 func (fs *FileStorage) SaveBatch(ctx context.Context, batch []BatchEntry) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
-	for _, entry := range batch {
-		record := URLRecord{
-			ShortURL:    entry.ShortURL,
-			OriginalURL: entry.OriginalURL,
-			UserID:      entry.UserID,
-			IsDeleted:   false,
-		}
-
-		data, err := json.Marshal(record)
-		if err != nil {
-			return fmt.Errorf("error marshaling URL record: %w", err)
-		}
+	if err := checkBatchCollisions(batch, func(shortURL string) (BatchEntry, bool) {
+		record, exists := fs.urls[shortURL]
+		return BatchEntry{ShortURL: shortURL, OriginalURL: record.OriginalURL, UserID: record.UserID}, exists
+	}); err != nil {
+		return err
+	}
 
-		if _, err := fs.file.Write(append(data, '\n')); err != nil {
-			return fmt.Errorf("error writing to file: %w", err)
-		}
+	records := make([]any, 0, len(batch))
+	for _, entry := range batch {
+		records = append(records, fs.newRecord(entry))
+	}
+	if err := fs.appendRecords(records...); err != nil {
+		return err
+	}
 
-		fs.urls[entry.ShortURL] = record
+	for _, record := range records {
+		fs.applyRecord(record.(URLRecord))
 	}
 
 	return nil
 }
 
-// GetShortURLByOriginal получает короткий URL по оригинальному
-func (fs *FileStorage) GetShortURLByOriginal(ctx context.Context, originalURL string) (string, error) {
+// GetShortURLByOriginal получает короткий URL по канонической форме оригинального URL
+func (fs *FileStorage) GetShortURLByOriginal(ctx context.Context, canonicalURL string) (string, error) {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
-	for short, record := range fs.urls {
-		if record.OriginalURL == originalURL && !record.IsDeleted {
+	now := time.Now()
+	for short := range fs.index.shortURLsByCanonical(canonicalURL) {
+		if record := fs.urls[short]; !record.IsDeleted && !isExpired(record.expiresAt(), now) {
 			return short, nil
 		}
 	}
@@ -163,47 +492,147 @@ func (fs *FileStorage) GetShortURLByOriginal(ctx context.Context, originalURL st
 	return "", ErrURLNotFound
 }
 
-// GetUserURLs получает все URL, сохраненные пользователем, из файла
+// GetUserURLs получает все URL, сохраненные пользователем
 func (fs *FileStorage) GetUserURLs(ctx context.Context, userID string) ([]models.UserURL, error) {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
-	var userURLs []models.UserURL
-	seenURLs := make(map[string]bool) // Для избежания дублирования
+	userURLs := []models.UserURL{}
+	for shortURL := range fs.index.shortURLsByUser(userID) {
+		if record := fs.urls[shortURL]; !record.IsDeleted {
+			userURLs = append(userURLs, record.row().toModel())
+		}
+	}
+
+	return userURLs, nil
+}
+
+// ListUserURLs возвращает страницу URL пользователя
+func (fs *FileStorage) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	shortURLs := fs.index.shortURLsByUser(userID)
+	rows := make([]userURLRow, 0, len(shortURLs))
+	for shortURL := range shortURLs {
+		if record := fs.urls[shortURL]; record.IsDeleted == query.Deleted {
+			rows = append(rows, record.row())
+		}
+	}
+	return pageUserURLs(rows, query)
+}
+
+// urlRevisionRecord — запись журнала версий ссылок
+type urlRevisionRecord struct {
+	ShortURL string `json:"short_url"`
+	models.URLRevision
+}
+
+// UpdateURL изменяет ссылку пользователя: предыдущая версия дописывается в журнал версий,
+// а новое состояние ссылки — в основной журнал
+func (fs *FileStorage) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	record, exists := fs.urls[shortURL]
+	if !exists || record.UserID != userID {
+		return models.UserURL{}, ErrURLNotFound
+	}
+	if record.IsDeleted {
+		return models.UserURL{}, ErrURLDeleted
+	}
+	now := time.Now().UTC()
+	if isExpired(record.expiresAt(), now) {
+		return models.UserURL{}, ErrURLExpired
+	}
+
+	previous := record.row()
+	row := previous
+	if !applyURLUpdate(&row, update) {
+		return row.toModel(), nil
+	}
+	canonicalURL := record.CanonicalURL
+	if row.OriginalURL != previous.OriginalURL {
+		canonicalURL = update.CanonicalURL
+		if fs.hasOriginalConflict(shortURL, cmp.Or(canonicalURL, row.OriginalURL), userID, now) {
+			return models.UserURL{}, ErrOriginalURLConflict
+		}
+	}
 
-	// Необходимо переоткрыть файл для чтения с начала, так как fs.file используется для дозаписи
-	file, err := os.OpenFile(fs.filePath, os.O_RDONLY, 0644)
+	// Версия пишется первой: после сбоя между записями в истории останется лишняя версия,
+	// но изменение ссылки не окажется без записи о предыдущем URL
+	revision := revisionOf(previous, now)
+	data, err := json.Marshal(urlRevisionRecord{ShortURL: shortURL, URLRevision: revision})
 	if err != nil {
-		fs.logger.Error("Error opening file for reading user URLs", zap.Error(err))
-		return nil, fmt.Errorf("error opening file for reading: %w", err)
+		return models.UserURL{}, fmt.Errorf("error marshaling revision: %w", err)
+	}
+	if _, err := fs.revisionsFile.Write(append(data, '\n')); err != nil {
+		return models.UserURL{}, fmt.Errorf("error writing revisions file: %w", err)
+	}
+	fs.revisions[shortURL] = append(fs.revisions[shortURL], revision)
+
+	record.OriginalURL, record.CanonicalURL = row.OriginalURL, canonicalURL
+	record.Title, record.Description = row.Title, row.Description
+	record.UpdatedAt = &now
+	if err := fs.appendRecords(record); err != nil {
+		return models.UserURL{}, err
+	}
+	fs.applyRecord(record)
+
+	fs.maybeCompact()
+	return record.row().toModel(), nil
+}
+
+// GetURLOwner возвращает владельца ссылки
+func (fs *FileStorage) GetURLOwner(ctx context.Context, shortURL string) (string, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	record, exists := fs.urls[shortURL]
+	if !exists {
+		return "", ErrURLNotFound
+	}
+	if record.IsDeleted {
+		return "", ErrURLDeleted
+	}
+	return record.UserID, nil
+}
+
+// loadRevisions загружает журнал версий ссылок.
+// Версии, замененные до создания текущей записи (например, у окончательно удаленной
+// ссылки с тем же идентификатором), и версии отсутствующих ссылок пропускаются.
+// Должен вызываться после loadFromFile.
+func (fs *FileStorage) loadRevisions() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.revisionsFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to revisions file start: %w", err)
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(bufio.NewReader(fs.revisionsFile))
 	for decoder.More() {
-		var record URLRecord
-		if err := decoder.Decode(&record); err != nil {
-			// Можно логировать ошибку и продолжать, если это не критично
-			fs.logger.Error("Error decoding record for user URLs", zap.Error(err))
-			continue
+		var revision urlRevisionRecord
+		if err := decoder.Decode(&revision); err != nil {
+			// Обрывок последней записи после сбоя
+			fs.logger.Warn("Error decoding revision record", zap.Error(err))
+			break
 		}
-		// Проверяем уникальность и добавляем только неудаленные URL
-		if record.UserID == userID && !record.IsDeleted && !seenURLs[record.ShortURL] {
-			userURLs = append(userURLs, models.UserURL{
-				ShortURL:    record.ShortURL,
-				OriginalURL: record.OriginalURL,
-			})
-			seenURLs[record.ShortURL] = true
+		record, exists := fs.urls[revision.ShortURL]
+		if exists && !revision.ReplacedAt.Before(record.row().CreatedAt) {
+			fs.revisions[revision.ShortURL] = append(fs.revisions[revision.ShortURL], revision.URLRevision)
 		}
 	}
 
-	if len(userURLs) == 0 {
-		// Если URL-ов нет, можно вернуть пустой слайс и nil ошибку,
-		// или специальную ошибку вроде ErrNoURLsFoundForUser
-		return []models.UserURL{}, nil
-	}
+	return nil
+}
 
-	return userURLs, nil
+// GetURLRevisions возвращает историю ссылки
+func (fs *FileStorage) GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	return append([]models.URLRevision{}, fs.revisions[shortURL]...), nil
 }
 
 // CheckConnection проверяет доступность файла
@@ -218,65 +647,233 @@ func (fs *FileStorage) CheckConnection(ctx context.Context) error {
 	return nil
 }
 
-// BatchDelete помечает URL как удаленные для указанного пользователя
+// BatchDelete помечает URL как удаленные для указанного пользователя,
+// дописывая tombstone записи в журнал
 func (fs *FileStorage) BatchDelete(ctx context.Context, shortURLs []string, userID string) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
-	// Обновляем записи в памяти
+	now := time.Now().UTC()
+	var tombstones []any
 	for _, shortURL := range shortURLs {
-		if record, exists := fs.urls[shortURL]; exists && record.UserID == userID {
-			record.IsDeleted = true
-			fs.urls[shortURL] = record
+		if record, exists := fs.urls[shortURL]; exists && record.UserID == userID && !record.IsDeleted {
+			tombstones = append(tombstones, tombstoneRecord{Op: recordOpDelete, ShortURL: shortURL, UserID: userID, DeletedAt: &now})
 		}
 	}
+	if len(tombstones) == 0 {
+		return nil
+	}
 
-	// Перезаписываем весь файл с обновленными данными
-	// Это не самый эффективный способ, но простой для реализации
-	if err := fs.rewriteFile(); err != nil {
-		return fmt.Errorf("error rewriting file after batch delete: %w", err)
+	if err := fs.appendRecords(tombstones...); err != nil {
+		return fmt.Errorf("error writing delete records: %w", err)
+	}
+	for _, tombstone := range tombstones {
+		t := tombstone.(tombstoneRecord)
+		fs.applyRecord(URLRecord{Op: t.Op, ShortURL: t.ShortURL, UserID: t.UserID, DeletedAt: t.DeletedAt})
 	}
 
+	fs.maybeCompact()
 	return nil
 }
 
-// rewriteFile перезаписывает файл с текущими данными из памяти
-func (fs *FileStorage) rewriteFile() error {
-	// Закрываем текущий файл
-	if err := fs.file.Close(); err != nil {
-		return fmt.Errorf("error closing file: %w", err)
+// RestoreURLs снимает пометку удаления с URL пользователя, дописывая
+// восстановленные записи в журнал
+func (fs *FileStorage) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	now := time.Now().UTC()
+	var records []any
+	restored := []string{}
+	pending := make(map[string]bool) // URL назначения ссылок, восстанавливаемых этим вызовом
+	for _, shortURL := range shortURLs {
+		record, exists := fs.urls[shortURL]
+		if !exists || record.UserID != userID || !record.IsDeleted || pending[record.canonicalURL()] ||
+			fs.hasOriginalConflict(shortURL, record.canonicalURL(), userID, now) {
+			continue
+		}
+		pending[record.canonicalURL()] = true
+		record.IsDeleted = false
+		record.DeletedAt = nil
+		record.UpdatedAt = &now
+		records = append(records, record)
+		restored = append(restored, shortURL)
+	}
+	if len(records) == 0 {
+		return restored, nil
+	}
+
+	if err := fs.appendRecords(records...); err != nil {
+		return nil, fmt.Errorf("error writing restore records: %w", err)
+	}
+	for _, record := range records {
+		fs.applyRecord(record.(URLRecord))
+	}
+
+	fs.maybeCompact()
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, помеченные удаленными раньше before
+func (fs *FileStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var tombstones []any
+	for shortURL, record := range fs.urls {
+		if record.IsDeleted && record.DeletedAt != nil && record.DeletedAt.Before(before) {
+			tombstones = append(tombstones, tombstoneRecord{Op: recordOpPurge, ShortURL: shortURL})
+		}
+	}
+	if len(tombstones) == 0 {
+		return 0, nil
+	}
+
+	if err := fs.appendRecords(tombstones...); err != nil {
+		return 0, fmt.Errorf("error writing purge records: %w", err)
+	}
+	fs.applyPurges(tombstones)
+
+	fs.maybeCompact()
+	return int64(len(tombstones)), nil
+}
+
+// applyPurges применяет записи окончательного удаления и удаляет статистику переходов
+// удаленных ссылок, чтобы ее не унаследовала новая ссылка с тем же идентификатором.
+// Вызывающий должен удерживать fs.mutex.
+func (fs *FileStorage) applyPurges(tombstones []any) {
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+
+	for _, tombstone := range tombstones {
+		shortURL := tombstone.(tombstoneRecord).ShortURL
+		fs.applyRecord(URLRecord{Op: recordOpPurge, ShortURL: shortURL})
+		delete(fs.clicks, shortURL)
+	}
+}
+
+// PurgeExpired удаляет ссылки с истекшим сроком действия
+func (fs *FileStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var tombstones []any
+	for shortURL, record := range fs.urls {
+		if isExpired(record.expiresAt(), now) {
+			tombstones = append(tombstones, tombstoneRecord{Op: recordOpPurge, ShortURL: shortURL})
+		}
 	}
+	if len(tombstones) == 0 {
+		return 0, nil
+	}
+
+	if err := fs.appendRecords(tombstones...); err != nil {
+		return 0, fmt.Errorf("error writing purge records: %w", err)
+	}
+	fs.applyPurges(tombstones)
+
+	fs.maybeCompact()
+	return int64(len(tombstones)), nil
+}
 
-	// Открываем файл для перезаписи
-	file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+// Compact перезаписывает журнал, оставляя только актуальные записи.
+// Новый журнал пишется во временный файл, сбрасывается на диск и атомарно
+// заменяет текущий, поэтому сбой во время компактизации не приводит к потере данных.
+func (fs *FileStorage) Compact(ctx context.Context) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.compact()
+}
+
+// maybeCompact компактизирует журнал, если устаревших записей накопилось не меньше
+// compactMinStale и не меньше, чем актуальных. Ошибка компактизации не является
+// ошибкой операции: tombstone записи уже сохранены, поэтому она только логируется.
+func (fs *FileStorage) maybeCompact() {
+	if fs.staleRecords < fs.compactMinStale || fs.staleRecords < len(fs.urls) {
+		return
+	}
+	if err := fs.compact(); err != nil {
+		fs.logger.Error("Error compacting storage file", zap.Error(err))
+	}
+}
+
+// compact выполняет компактизацию журнала; вызывающий должен удерживать fs.mutex.
+// Дескриптор нового журнала открывается до переименования: после замены файла
+// не остается шага, который мог бы завершиться ошибкой и оставить хранилище
+// с дескриптором уже замененного журнала.
+func (fs *FileStorage) compact() error {
+	dir := filepath.Dir(fs.filePath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(fs.filePath)+".compact-*")
+	if err != nil {
+		return fmt.Errorf("error creating compaction file: %w", err)
+	}
+	// После успешного переименования временного файла уже нет, и Remove ничего не делает
+	defer os.Remove(tmp.Name())
+
+	if err := fs.writeSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing compaction file: %w", err)
+	}
+
+	file, err := os.OpenFile(tmp.Name(), os.O_RDWR|os.O_APPEND, 0644)
 	if err != nil {
-		return fmt.Errorf("error opening file for rewrite: %w", err)
+		return fmt.Errorf("error opening compaction file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fs.filePath); err != nil {
+		file.Close()
+		return fmt.Errorf("error replacing storage file: %w", err)
+	}
+	// Сбрасываем каталог, чтобы переименование пережило сбой питания
+	if err := syncDir(dir); err != nil {
+		fs.logger.Warn("Error syncing storage directory", zap.Error(err))
 	}
 
-	// Записываем все данные
+	if err := fs.file.Close(); err != nil {
+		fs.logger.Warn("Error closing old storage file", zap.Error(err))
+	}
+	fs.file = file
+	fs.staleRecords = 0
+
+	return nil
+}
+
+// writeSnapshot записывает актуальное состояние в file и сбрасывает его на диск
+func (fs *FileStorage) writeSnapshot(file *os.File) error {
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("error setting compaction file mode: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
 	for _, record := range fs.urls {
 		data, err := json.Marshal(record)
 		if err != nil {
-			file.Close()
 			return fmt.Errorf("error marshaling record: %w", err)
 		}
-		if _, err := file.Write(append(data, '\n')); err != nil {
-			file.Close()
+		if _, err := w.Write(append(data, '\n')); err != nil {
 			return fmt.Errorf("error writing record: %w", err)
 		}
 	}
-
-	// Переоткрываем файл в режиме append для дальнейшей работы
-	if err := file.Close(); err != nil {
-		return fmt.Errorf("error closing rewritten file: %w", err)
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error flushing compaction file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error syncing compaction file: %w", err)
 	}
+	return nil
+}
 
-	fs.file, err = os.OpenFile(fs.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+// syncDir сбрасывает на диск изменения записей каталога
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
-		return fmt.Errorf("error reopening file: %w", err)
+		return err
 	}
-
-	return nil
+	defer d.Close()
+	return d.Sync()
 }
 
 // Close закрывает файл
@@ -284,6 +881,43 @@ func (fs *FileStorage) Close() error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
+	if fs.countersFile != nil {
+		if err := fs.countersFile.Close(); err != nil {
+			return fmt.Errorf("error closing counters file: %w", err)
+		}
+		fs.countersFile = nil
+	}
+
+	if fs.revisionsFile != nil {
+		if err := fs.revisionsFile.Close(); err != nil {
+			return fmt.Errorf("error closing revisions file: %w", err)
+		}
+		fs.revisionsFile = nil
+	}
+
+	if fs.keysFile != nil {
+		if err := fs.keysFile.Close(); err != nil {
+			return fmt.Errorf("error closing API keys file: %w", err)
+		}
+		fs.keysFile = nil
+	}
+
+	if fs.jobsFile != nil {
+		if err := fs.jobsFile.Close(); err != nil {
+			return fmt.Errorf("error closing delete jobs file: %w", err)
+		}
+		fs.jobsFile = nil
+	}
+
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+	if fs.clicksFile != nil {
+		if err := fs.clicksFile.Close(); err != nil {
+			return fmt.Errorf("error closing clicks file: %w", err)
+		}
+		fs.clicksFile = nil
+	}
+
 	if fs.file != nil {
 		if err := fs.file.Close(); err != nil {
 			return fmt.Errorf("error closing file: %w", err)
@@ -293,3 +927,300 @@ func (fs *FileStorage) Close() error {
 
 	return nil
 }
+
+// loadClicks восстанавливает статистику переходов из файла событий.
+// Обрывок последней записи после сбоя пропускается, как и события окончательно
+// удаленных ссылок: ссылки, которой больше нет, или прежней ссылки с тем же
+// идентификатором (переход раньше создания текущей ссылки).
+// Должен вызываться после loadFromFile.
+func (fs *FileStorage) loadClicks() error {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+
+	if _, err := fs.clicksFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to clicks file start: %w", err)
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(fs.clicksFile))
+	for decoder.More() {
+		var event models.ClickEvent
+		if err := decoder.Decode(&event); err != nil {
+			fs.logger.Warn("Error decoding click event", zap.Error(err))
+			break
+		}
+		record, exists := fs.urls[event.ShortURL]
+		if !exists || event.Timestamp.Before(record.row().CreatedAt) {
+			continue
+		}
+		fs.clicks.add(event)
+	}
+
+	return nil
+}
+
+// SaveClicks дописывает события переходов в файл событий и учитывает их в статистике
+func (fs *FileStorage) SaveClicks(ctx context.Context, events []models.ClickEvent) error {
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+
+	var buf []byte
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error marshaling click event: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	if _, err := fs.clicksFile.Write(buf); err != nil {
+		return fmt.Errorf("error writing clicks file: %w", err)
+	}
+
+	for _, event := range events {
+		fs.clicks.add(event)
+	}
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов по короткой ссылке
+func (fs *FileStorage) GetClickStats(ctx context.Context, shortURL string) (models.ClickStats, error) {
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+
+	return fs.clicks.stats(shortURL), nil
+}
+
+// loadDeleteJobs восстанавливает задачи удаления из журнала: каждая строка содержит
+// полное состояние задачи, поэтому побеждает последняя запись с тем же ID
+func (fs *FileStorage) loadDeleteJobs() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.jobsFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to delete jobs file start: %w", err)
+	}
+
+	decoder := json.NewDecoder(fs.jobsFile)
+	for decoder.More() {
+		var record deleteJobRecord
+		if err := decoder.Decode(&record); err != nil {
+			// Обрывок последней записи после сбоя: задача останется в предыдущем состоянии
+			fs.logger.Warn("Error decoding delete job record", zap.Error(err))
+			break
+		}
+		fs.jobs[record.ID] = record.toModel()
+	}
+
+	return nil
+}
+
+// deleteJobRecord — запись журнала задач удаления; в отличие от models.DeleteJob
+// сохраняет владельца задачи
+type deleteJobRecord struct {
+	models.DeleteJob
+	UserID string `json:"user_id"`
+}
+
+// toModel возвращает задачу с заполненным владельцем
+func (r deleteJobRecord) toModel() models.DeleteJob {
+	job := r.DeleteJob
+	job.UserID = r.UserID
+	return job
+}
+
+// SaveDeleteJob дописывает новое состояние задачи удаления в журнал
+func (fs *FileStorage) SaveDeleteJob(ctx context.Context, job models.DeleteJob) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, err := json.Marshal(deleteJobRecord{DeleteJob: job, UserID: job.UserID})
+	if err != nil {
+		return fmt.Errorf("error marshaling delete job: %w", err)
+	}
+	if _, err := fs.jobsFile.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing delete jobs file: %w", err)
+	}
+
+	job.ShortURLs = append([]string(nil), job.ShortURLs...)
+	fs.jobs[job.ID] = job
+	return nil
+}
+
+// GetDeleteJob возвращает задачу удаления по ID
+func (fs *FileStorage) GetDeleteJob(ctx context.Context, id string) (models.DeleteJob, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	job, exists := fs.jobs[id]
+	if !exists {
+		return models.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	return job, nil
+}
+
+// ListUnfinishedDeleteJobs возвращает незавершенные задачи удаления
+func (fs *FileStorage) ListUnfinishedDeleteJobs(ctx context.Context) ([]models.DeleteJob, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	var jobs []models.DeleteJob
+	for _, job := range fs.jobs {
+		if !job.Finished() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// loadAPIKeys восстанавливает API ключи из журнала: каждая строка содержит
+// полное состояние ключа, поэтому побеждает последняя запись с тем же хешем
+func (fs *FileStorage) loadAPIKeys() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.keysFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to API keys file start: %w", err)
+	}
+
+	decoder := json.NewDecoder(fs.keysFile)
+	for decoder.More() {
+		var record apiKeyRecord
+		if err := decoder.Decode(&record); err != nil {
+			// Обрывок последней записи после сбоя: ключ останется в предыдущем состоянии
+			fs.logger.Warn("Error decoding API key record", zap.Error(err))
+			break
+		}
+		key := record.toModel()
+		fs.keys[key.Hash] = key
+	}
+
+	return nil
+}
+
+// apiKeyRecord — запись журнала API ключей; в отличие от models.APIKey
+// сохраняет владельца и хеш ключа
+type apiKeyRecord struct {
+	models.APIKey
+	UserID string `json:"user_id"`
+	Hash   string `json:"hash"`
+}
+
+// toModel возвращает ключ с заполненными владельцем и хешем
+func (r apiKeyRecord) toModel() models.APIKey {
+	key := r.APIKey
+	key.UserID = r.UserID
+	key.Hash = r.Hash
+	return key
+}
+
+// appendAPIKey дописывает состояние ключа в журнал и обновляет его в памяти.
+// Вызывается с захваченной блокировкой на запись.
+func (fs *FileStorage) appendAPIKey(key models.APIKey) error {
+	data, err := json.Marshal(apiKeyRecord{APIKey: key, UserID: key.UserID, Hash: key.Hash})
+	if err != nil {
+		return fmt.Errorf("error marshaling API key: %w", err)
+	}
+	if _, err := fs.keysFile.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing API keys file: %w", err)
+	}
+
+	key.Scopes = append([]string(nil), key.Scopes...)
+	fs.keys[key.Hash] = key
+	return nil
+}
+
+// SaveAPIKey дописывает новый API ключ в журнал
+func (fs *FileStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.appendAPIKey(key)
+}
+
+// GetAPIKeyByHash возвращает API ключ по хешу
+func (fs *FileStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	key, exists := fs.keys[hash]
+	if !exists {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает API ключи пользователя в порядке создания
+func (fs *FileStorage) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	return userAPIKeys(fs.keys, userID), nil
+}
+
+// RevokeAPIKey отзывает API ключ пользователя и дописывает новое состояние в журнал
+func (fs *FileStorage) RevokeAPIKey(ctx context.Context, id, userID string, at time.Time) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	key, err := revokeAPIKey(fs.keys, id, userID, at)
+	if err != nil {
+		return err
+	}
+	return fs.appendAPIKey(key)
+}
+
+// counterRecord — запись журнала счетчиков: следующее незарезервированное значение
+type counterRecord struct {
+	Name string `json:"name"`
+	Next uint64 `json:"next"`
+}
+
+// loadCounters восстанавливает счетчики из журнала. Значения только растут,
+// поэтому для каждого счетчика берется наибольшее записанное значение.
+// Обрывок последней записи отбрасывается: диапазон из него еще не был выдан.
+func (fs *FileStorage) loadCounters() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.countersFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to counters file start: %w", err)
+	}
+
+	decoder := json.NewDecoder(fs.countersFile)
+	for decoder.More() {
+		var record counterRecord
+		if err := decoder.Decode(&record); err != nil {
+			fs.logger.Warn("Error decoding counter record", zap.Error(err))
+			break
+		}
+		fs.counters[record.Name] = max(fs.counters[record.Name], record.Next)
+	}
+
+	return nil
+}
+
+// ReserveCounter резервирует n значений счетчика name. Новое значение счетчика
+// сбрасывается на диск до того, как диапазон будет выдан, поэтому после сбоя
+// или перезапуска выданные значения не повторяются.
+func (fs *FileStorage) ReserveCounter(ctx context.Context, name string, start, n uint64) (uint64, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	first := max(fs.counters[name], start)
+	data, err := json.Marshal(counterRecord{Name: name, Next: first + n})
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling counter record: %w", err)
+	}
+	if _, err := fs.countersFile.Write(append(data, '\n')); err != nil {
+		return 0, fmt.Errorf("error writing counters file: %w", err)
+	}
+	if err := fs.countersFile.Sync(); err != nil {
+		return 0, fmt.Errorf("error syncing counters file: %w", err)
+	}
+
+	fs.counters[name] = first + n
+	return first, nil
+}
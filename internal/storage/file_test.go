@@ -3,9 +3,13 @@ package storage
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -204,6 +208,432 @@ func TestFileStorage_ConflictDetection(t *testing.T) {
 	// Try to save same original URL with different short URL
 	err = storage.Save(ctx, "xyz456", "https://example.com", "user1")
 	assert.ErrorIs(t, err, ErrOriginalURLConflict)
+
+	// Try to save different original URL with same short URL
+	err = storage.Save(ctx, "abc123", "https://different.com", "user1")
+	assert.ErrorIs(t, err, ErrShortURLCollision)
+
+	err = storage.SaveBatch(ctx, []BatchEntry{
+		{ShortURL: "abc123", OriginalURL: "https://other.com", UserID: "user1"},
+	})
+	assert.ErrorIs(t, err, ErrShortURLCollision)
+
+	originalURL, err := storage.Get(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestFileStorage_Expiration(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	err = storage.SaveEntry(ctx, BatchEntry{ShortURL: "camp1", OriginalURL: "https://campaign.com", UserID: "user1", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	err = storage.SaveEntry(ctx, BatchEntry{ShortURL: "old", OriginalURL: "https://old.com", UserID: "user1", ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	// Срок действия сохраняется между перезапусками
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NotNil(t, storage.urls["camp1"].ExpiresAt)
+	assert.True(t, expiresAt.Equal(*storage.urls["camp1"].ExpiresAt))
+
+	_, err = storage.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrURLExpired)
+
+	purged, err := storage.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = storage.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	originalURL, err := storage.Get(ctx, "camp1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://campaign.com", originalURL)
+}
+
+func TestFileStorage_ClickStats(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, storage.Save(ctx, "abc", "https://example.com", "user1"))
+	now := time.Now().UTC()
+	err = storage.SaveClicks(ctx, []models.ClickEvent{
+		{ShortURL: "abc", Timestamp: now, Referrer: "https://news.example.org/a", IPHash: "ip1"},
+		{ShortURL: "abc", Timestamp: now, IPHash: "ip2"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	// События переживают перезапуск и не мешают загрузке URL
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	stats, err := storage.GetClickStats(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, int64(1), stats.Referrers["news.example.org"])
+
+	// Статистика обновляется при записи событий, а возвращаемые карты — копии
+	stats.Referrers["news.example.org"] = 100
+	require.NoError(t, storage.SaveClicks(ctx, []models.ClickEvent{{ShortURL: "abc", Timestamp: now, IPHash: "ip1"}}))
+	stats, err = storage.GetClickStats(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, map[string]int64{"news.example.org": 1, "direct": 2}, stats.Referrers)
+}
+
+func TestFileStorage_PurgedURLClicks(t *testing.T) {
+	tempFile := createTempFile(t)
+	storage, err := NewFileStorage(tempFile, zap.NewNop())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, storage.Save(ctx, "gone", "https://example.com/gone", "user1"))
+	require.NoError(t, storage.SaveEntry(ctx, BatchEntry{
+		ShortURL: "expired", OriginalURL: "https://example.com/expired", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	clicks := []models.ClickEvent{
+		{ShortURL: "gone", Timestamp: time.Now().UTC(), IPHash: "ip1"},
+		{ShortURL: "expired", Timestamp: time.Now().UTC(), IPHash: "ip1"},
+	}
+	require.NoError(t, storage.SaveClicks(ctx, clicks))
+
+	require.NoError(t, storage.BatchDelete(ctx, []string{"gone"}, "user1"))
+	purged, err := storage.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	purged, err = storage.PurgeExpired(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	// Новая ссылка с тем же идентификатором не наследует статистику прежней
+	time.Sleep(time.Millisecond)
+	require.NoError(t, storage.Save(ctx, "gone", "https://example.com/new", "user2"))
+	for _, shortURL := range []string{"gone", "expired"} {
+		stats, err := storage.GetClickStats(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Zero(t, stats.TotalClicks, shortURL)
+	}
+	require.NoError(t, storage.SaveClicks(ctx, []models.ClickEvent{{ShortURL: "gone", Timestamp: time.Now().UTC(), IPHash: "ip2"}}))
+	require.NoError(t, storage.Close())
+
+	// События удаленных ссылок не загружаются после перезапуска
+	storage, err = NewFileStorage(tempFile, zap.NewNop())
+	require.NoError(t, err)
+	defer storage.Close()
+	stats, err := storage.GetClickStats(ctx, "gone")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+	stats, err = storage.GetClickStats(ctx, "expired")
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+}
+
+func TestFileStorage_ClicksDoNotWaitForURLLock(t *testing.T) {
+	storage, err := NewFileStorage(createTempFile(t), zap.NewNop())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	// Пока удерживается блокировка ссылок (например, идет компактизация),
+	// переходы продолжают записываться
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- storage.SaveClicks(context.Background(), []models.ClickEvent{{ShortURL: "abc", Timestamp: time.Now()}})
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("SaveClicks blocked on the URL lock")
+	}
+}
+
+func TestFileStorage_DeleteTombstones(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, storage.Save(ctx, "abc1", "https://example1.com", "user1"))
+	require.NoError(t, storage.Save(ctx, "abc2", "https://example2.com", "user1"))
+
+	sizeBefore := fileSize(t, tempFile)
+	require.NoError(t, storage.BatchDelete(ctx, []string{"abc1"}, "user1"))
+	// Удаление дописывает tombstone, не переписывая существующие записи
+	assert.Greater(t, fileSize(t, tempFile), sizeBefore)
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.Get(ctx, "abc1")
+	assert.ErrorIs(t, err, ErrURLDeleted)
+	originalURL, err := storage.Get(ctx, "abc2")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example2.com", originalURL)
+}
+
+func TestFileStorage_TornLastLine(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, storage.Save(ctx, "abc1", "https://example1.com", "user1"))
+	require.NoError(t, storage.Close())
+
+	// Имитируем сбой посреди записи следующей строки
+	f, err := os.OpenFile(tempFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"uuid":"","short_url":"abc2","orig`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	originalURL, err := storage.Get(ctx, "abc1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example1.com", originalURL)
+	_, err = storage.Get(ctx, "abc2")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	// Новые записи не склеиваются с отброшенной строкой
+	require.NoError(t, storage.Save(ctx, "abc3", "https://example3.com", "user1"))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	originalURL, err = storage.Get(ctx, "abc3")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example3.com", originalURL)
+}
+
+func TestFileStorage_Compact(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	storage.compactMinStale = 2
+
+	ctx := context.Background()
+	require.NoError(t, storage.Save(ctx, "abc1", "https://example1.com", "user1"))
+	require.NoError(t, storage.Save(ctx, "abc2", "https://example2.com", "user1"))
+	require.NoError(t, storage.Save(ctx, "old", "https://old.com", "user1"))
+	require.NoError(t, storage.SaveEntry(ctx, BatchEntry{ShortURL: "exp", OriginalURL: "https://exp.com", UserID: "user1", ExpiresAt: time.Now().Add(-time.Minute)}))
+
+	require.NoError(t, storage.BatchDelete(ctx, []string{"old"}, "user1"))
+	assert.Equal(t, 1, storage.staleRecords)
+
+	// Purge дает еще две устаревшие записи (tombstone и исходную запись) и запускает компактизацию
+	purged, err := storage.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, 0, storage.staleRecords)
+	assert.Equal(t, 3, countLines(t, tempFile))
+
+	// После компактизации запись продолжается в новый файл
+	require.NoError(t, storage.Save(ctx, "abc4", "https://example4.com", "user1"))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrURLDeleted)
+	_, err = storage.Get(ctx, "exp")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	for _, shortURL := range []string{"abc1", "abc2", "abc4"} {
+		_, err = storage.Get(ctx, shortURL)
+		assert.NoError(t, err, shortURL)
+	}
+
+	// Временные файлы компактизации не остаются в каталоге
+	matches, err := filepath.Glob(tempFile + ".compact-*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestFileStorage_CompactRenameFailure(t *testing.T) {
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, zap.NewNop())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	ctx := context.Background()
+	require.NoError(t, storage.Save(ctx, "abc1", "https://example1.com", "user1"))
+
+	// Журнал подменен непустым каталогом: переименование при компактизации не удастся
+	require.NoError(t, os.Remove(tempFile))
+	require.NoError(t, os.Mkdir(tempFile, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempFile, "keep"), nil, 0644))
+
+	require.Error(t, storage.Compact(ctx))
+
+	// Хранилище продолжает писать в прежний журнал
+	require.NoError(t, storage.Save(ctx, "abc2", "https://example2.com", "user1"))
+	_, err = storage.Get(ctx, "abc2")
+	assert.NoError(t, err)
+	matches, err := filepath.Glob(tempFile + ".compact-*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestFileStorage_DeleteJobs(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	job := models.DeleteJob{ID: "job1", UserID: "user1", ShortURLs: []string{"abc"}, Status: models.DeleteJobPending}
+	require.NoError(t, storage.SaveDeleteJob(ctx, job))
+	require.NoError(t, storage.SaveDeleteJob(ctx, models.DeleteJob{ID: "job2", UserID: "user1", Status: models.DeleteJobCompleted}))
+
+	job.Status = models.DeleteJobRunning
+	job.Attempts = 1
+	require.NoError(t, storage.SaveDeleteJob(ctx, job))
+	require.NoError(t, storage.Close())
+
+	// Последнее состояние задачи восстанавливается после перезапуска
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	got, err := storage.GetDeleteJob(ctx, "job1")
+	require.NoError(t, err)
+	assert.Equal(t, job, got)
+
+	unfinished, err := storage.ListUnfinishedDeleteJobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, unfinished, 1)
+	assert.Equal(t, "job1", unfinished[0].ID)
+
+	_, err = storage.GetDeleteJob(ctx, "missing")
+	assert.ErrorIs(t, err, ErrDeleteJobNotFound)
+}
+
+func TestFileStorage_APIKeys(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := models.APIKey{ID: "k1", UserID: "user1", Name: "ci", Prefix: "tu_abc", Hash: "hash1", Scopes: []string{models.ScopeRead}, CreatedAt: createdAt}
+	require.NoError(t, storage.SaveAPIKey(ctx, key))
+	require.NoError(t, storage.SaveAPIKey(ctx, models.APIKey{ID: "k2", UserID: "user2", Hash: "hash2", CreatedAt: createdAt}))
+
+	revokedAt := createdAt.Add(time.Hour)
+	assert.ErrorIs(t, storage.RevokeAPIKey(ctx, "k1", "user2", revokedAt), ErrAPIKeyNotFound)
+	require.NoError(t, storage.RevokeAPIKey(ctx, "k1", "user1", revokedAt))
+	// Повторный отзыв не меняет время отзыва
+	require.NoError(t, storage.RevokeAPIKey(ctx, "k1", "user1", revokedAt.Add(time.Hour)))
+	require.NoError(t, storage.Close())
+
+	// Ключи и их отзыв восстанавливаются после перезапуска
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	got, err := storage.GetAPIKeyByHash(ctx, "hash1")
+	require.NoError(t, err)
+	key.RevokedAt = &revokedAt
+	assert.Equal(t, key, got)
+
+	keys, err := storage.ListAPIKeys(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "k1", keys[0].ID)
+
+	_, err = storage.GetAPIKeyByHash(ctx, "missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	// Открытое значение ключа в файл не попадает, только хеш
+	data, err := os.ReadFile(tempFile + keysFileSuffix)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"hash":"hash1"`)
+}
+
+func TestFileStorage_ReserveCounter(t *testing.T) {
+	logger := zap.NewNop()
+	tempFile := createTempFile(t)
+
+	storage, err := NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	first, err := storage.ReserveCounter(ctx, "short_id", 100, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), first)
+	first, err = storage.ReserveCounter(ctx, "short_id", 100, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(110), first)
+	// Счетчики независимы
+	first, err = storage.ReserveCounter(ctx, "other", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), first)
+	require.NoError(t, storage.Close())
+
+	// После перезапуска счетчик продолжается с незарезервированного значения
+	storage, err = NewFileStorage(tempFile, logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	first, err = storage.ReserveCounter(ctx, "short_id", 100, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(120), first)
+	// Увеличенное стартовое значение пропускает остаток диапазона
+	first, err = storage.ReserveCounter(ctx, "short_id", 1000, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), first)
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Size()
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(data), "\n")
 }
 
 func TestFileStorage_NewFileStorageErrors(t *testing.T) {
@@ -344,3 +774,14 @@ func BenchmarkFileStorage_SaveBatch(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkFileStorage_SaveAtScale(b *testing.B) {
+	benchmarkSaveAtScale(b, func(b *testing.B) URLStorage {
+		storage, err := NewFileStorage(createTempFile(b), zap.NewNop())
+		if err != nil {
+			b.Fatalf("Failed to create FileStorage: %v", err)
+		}
+		b.Cleanup(func() { storage.Close() })
+		return storage
+	})
+}
@@ -0,0 +1,61 @@
+package storage
+
+// urlIndex — вторичные индексы хранилищ в памяти: короткие ссылки по канонической форме
+// оригинального URL и по пользователю. Индекс отражает множество записей хранилища, включая удаленные
+// и истекшие; такие записи отфильтровываются при обращении, как и при полном обходе.
+type urlIndex struct {
+	byCanonical map[string]map[string]struct{} // canonicalURL -> множество shortURL
+	byUser      map[string]map[string]struct{} // userID -> множество shortURL
+}
+
+// newURLIndex создает пустой индекс
+func newURLIndex() urlIndex {
+	return urlIndex{
+		byCanonical: make(map[string]map[string]struct{}),
+		byUser:      make(map[string]map[string]struct{}),
+	}
+}
+
+// add добавляет запись в индекс
+func (idx urlIndex) add(shortURL, canonicalURL, userID string) {
+	addToSet(idx.byCanonical, canonicalURL, shortURL)
+	addToSet(idx.byUser, userID, shortURL)
+}
+
+// remove удаляет запись из индекса
+func (idx urlIndex) remove(shortURL, canonicalURL, userID string) {
+	removeFromSet(idx.byCanonical, canonicalURL, shortURL)
+	removeFromSet(idx.byUser, userID, shortURL)
+}
+
+// shortURLsByCanonical возвращает короткие ссылки с указанной канонической формой
+// оригинального URL. Результат нельзя изменять.
+func (idx urlIndex) shortURLsByCanonical(canonicalURL string) map[string]struct{} {
+	return idx.byCanonical[canonicalURL]
+}
+
+// shortURLsByUser возвращает короткие ссылки пользователя. Результат нельзя изменять.
+func (idx urlIndex) shortURLsByUser(userID string) map[string]struct{} {
+	return idx.byUser[userID]
+}
+
+func addToSet(sets map[string]map[string]struct{}, key, value string) {
+	set, ok := sets[key]
+	if !ok {
+		set = make(map[string]struct{}, 1)
+		sets[key] = set
+	}
+	set[value] = struct{}{}
+}
+
+func removeFromSet(sets map[string]map[string]struct{}, key, value string) {
+	set, ok := sets[key]
+	if !ok {
+		return
+	}
+	delete(set, value)
+	// Удаляем пустые множества, чтобы индекс не рос после очистки истекших ссылок
+	if len(set) == 0 {
+		delete(sets, key)
+	}
+}
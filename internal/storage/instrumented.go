@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+)
+
+// InstrumentedStorage оборачивает URLStorage и учитывает длительность и ошибки
+// каждой операции в метриках storage_operation_duration_seconds и
+// storage_operation_errors_total с метками backend и method, а также создает
+// для операции спан трассировки storage.<method>.
+// Дополнительные интерфейсы (DatabaseChecker, ExpiredURLPurger, AnalyticsStorage,
+// DeleteJobStorage, APIKeyStorage, RateLimitStorage, CounterStorage, io.Closer) делегируются обернутому хранилищу.
+type InstrumentedStorage struct {
+	inner   URLStorage
+	backend string
+}
+
+// NewInstrumentedStorage оборачивает хранилище inner; backend — значение метки backend
+// (например, "memory", "file" или "postgres").
+func NewInstrumentedStorage(inner URLStorage, backend string) *InstrumentedStorage {
+	return &InstrumentedStorage{inner: inner, backend: backend}
+}
+
+// Unwrap возвращает обернутое хранилище
+func (s *InstrumentedStorage) Unwrap() URLStorage {
+	return s.inner
+}
+
+// Unwrap снимает с хранилища все обертки (например, InstrumentedStorage).
+// Обертка реализует все дополнительные интерфейсы, поэтому проверять, что
+// хранилище действительно поддерживает интерфейс, нужно на результате Unwrap.
+func Unwrap(s URLStorage) URLStorage {
+	for {
+		wrapper, ok := s.(interface{ Unwrap() URLStorage })
+		if !ok {
+			return s
+		}
+		s = wrapper.Unwrap()
+	}
+}
+
+// start начинает операцию method: создает спан и возвращает функцию,
+// которая записывает длительность и ошибку операции и завершает спан
+func (s *InstrumentedStorage) start(ctx context.Context, method string) (context.Context, func(err error)) {
+	begin := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+method, tracing.String("storage.backend", s.backend))
+	return ctx, func(err error) {
+		metrics.StorageOperationDuration.Observe(time.Since(begin).Seconds(), s.backend, method)
+		if err != nil && !isExpectedError(err) {
+			metrics.StorageOperationErrors.Inc(s.backend, method)
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+// isExpectedError сообщает, является ли ошибка штатным результатом операции
+// (ссылка не найдена, удалена, конфликт), а не сбоем хранилища
+func isExpectedError(err error) bool {
+	return errors.Is(err, ErrURLNotFound) ||
+		errors.Is(err, ErrURLDeleted) ||
+		errors.Is(err, ErrURLExpired) ||
+		errors.Is(err, ErrOriginalURLConflict) ||
+		errors.Is(err, ErrShortURLCollision) ||
+		errors.Is(err, ErrDeleteJobNotFound) ||
+		errors.Is(err, ErrAPIKeyNotFound) ||
+		errors.Is(err, ErrInvalidCursor)
+}
+
+// Save сохраняет URL в обернутом хранилище
+func (s *InstrumentedStorage) Save(ctx context.Context, shortURL, originalURL, userID string) (err error) {
+	ctx, done := s.start(ctx, "Save")
+	defer func() { done(err) }()
+	return s.inner.Save(ctx, shortURL, originalURL, userID)
+}
+
+// SaveEntry сохраняет URL со всеми атрибутами в обернутом хранилище
+func (s *InstrumentedStorage) SaveEntry(ctx context.Context, entry BatchEntry) (err error) {
+	ctx, done := s.start(ctx, "SaveEntry")
+	defer func() { done(err) }()
+	return s.inner.SaveEntry(ctx, entry)
+}
+
+// Get получает оригинальный URL из обернутого хранилища
+func (s *InstrumentedStorage) Get(ctx context.Context, shortURL string) (_ string, err error) {
+	ctx, done := s.start(ctx, "Get")
+	defer func() { done(err) }()
+	return s.inner.Get(ctx, shortURL)
+}
+
+// GetRedirect получает параметры перехода по короткому URL из обернутого хранилища
+func (s *InstrumentedStorage) GetRedirect(ctx context.Context, shortURL string) (_ models.Redirect, err error) {
+	ctx, done := s.start(ctx, "GetRedirect")
+	defer func() { done(err) }()
+	return s.inner.GetRedirect(ctx, shortURL)
+}
+
+// GetShortURLByOriginal получает короткий URL по оригинальному из обернутого хранилища
+func (s *InstrumentedStorage) GetShortURLByOriginal(ctx context.Context, originalURL string) (_ string, err error) {
+	ctx, done := s.start(ctx, "GetShortURLByOriginal")
+	defer func() { done(err) }()
+	return s.inner.GetShortURLByOriginal(ctx, originalURL)
+}
+
+// SaveBatch сохраняет пакет URL в обернутом хранилище
+func (s *InstrumentedStorage) SaveBatch(ctx context.Context, batch []BatchEntry) (err error) {
+	ctx, done := s.start(ctx, "SaveBatch")
+	defer func() { done(err) }()
+	return s.inner.SaveBatch(ctx, batch)
+}
+
+// ListUserURLs получает страницу URL пользователя из обернутого хранилища
+func (s *InstrumentedStorage) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (_ models.UserURLPage, err error) {
+	ctx, done := s.start(ctx, "ListUserURLs")
+	defer func() { done(err) }()
+	return s.inner.ListUserURLs(ctx, userID, query)
+}
+
+// UpdateURL изменяет ссылку пользователя в обернутом хранилище
+func (s *InstrumentedStorage) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (_ models.UserURL, err error) {
+	ctx, done := s.start(ctx, "UpdateURL")
+	defer func() { done(err) }()
+	return s.inner.UpdateURL(ctx, shortURL, userID, update)
+}
+
+// GetURLOwner получает владельца ссылки из обернутого хранилища
+func (s *InstrumentedStorage) GetURLOwner(ctx context.Context, shortURL string) (_ string, err error) {
+	ctx, done := s.start(ctx, "GetURLOwner")
+	defer func() { done(err) }()
+	return s.inner.GetURLOwner(ctx, shortURL)
+}
+
+// GetURLRevisions получает историю версий ссылки из обернутого хранилища
+func (s *InstrumentedStorage) GetURLRevisions(ctx context.Context, shortURL string) (_ []models.URLRevision, err error) {
+	ctx, done := s.start(ctx, "GetURLRevisions")
+	defer func() { done(err) }()
+	return s.inner.GetURLRevisions(ctx, shortURL)
+}
+
+// RestoreURLs восстанавливает удаленные URL пользователя в обернутом хранилище
+func (s *InstrumentedStorage) RestoreURLs(ctx context.Context, shortURLs []string, userID string) (_ []string, err error) {
+	ctx, done := s.start(ctx, "RestoreURLs")
+	defer func() { done(err) }()
+	return s.inner.RestoreURLs(ctx, shortURLs, userID)
+}
+
+// GetUserURLs получает URL пользователя из обернутого хранилища
+func (s *InstrumentedStorage) GetUserURLs(ctx context.Context, userID string) (_ []models.UserURL, err error) {
+	ctx, done := s.start(ctx, "GetUserURLs")
+	defer func() { done(err) }()
+	return s.inner.GetUserURLs(ctx, userID)
+}
+
+// BatchDelete помечает URL как удаленные в обернутом хранилище
+func (s *InstrumentedStorage) BatchDelete(ctx context.Context, shortURLs []string, userID string) (err error) {
+	ctx, done := s.start(ctx, "BatchDelete")
+	defer func() { done(err) }()
+	return s.inner.BatchDelete(ctx, shortURLs, userID)
+}
+
+// CheckConnection проверяет доступность обернутого хранилища
+func (s *InstrumentedStorage) CheckConnection(ctx context.Context) (err error) {
+	checker, ok := s.inner.(DatabaseChecker)
+	if !ok {
+		return ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "CheckConnection")
+	defer func() { done(err) }()
+	return checker.CheckConnection(ctx)
+}
+
+// PurgeExpired удаляет ссылки с истекшим сроком действия из обернутого хранилища
+func (s *InstrumentedStorage) PurgeExpired(ctx context.Context, now time.Time) (_ int64, err error) {
+	purger, ok := s.inner.(ExpiredURLPurger)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "PurgeExpired")
+	defer func() { done(err) }()
+	return purger.PurgeExpired(ctx, now)
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные пользователями, из обернутого хранилища
+func (s *InstrumentedStorage) PurgeDeleted(ctx context.Context, before time.Time) (_ int64, err error) {
+	purger, ok := s.inner.(DeletedURLPurger)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "PurgeDeleted")
+	defer func() { done(err) }()
+	return purger.PurgeDeleted(ctx, before)
+}
+
+// SaveClicks сохраняет события переходов в обернутом хранилище
+func (s *InstrumentedStorage) SaveClicks(ctx context.Context, events []models.ClickEvent) (err error) {
+	analytics, ok := s.inner.(AnalyticsStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "SaveClicks")
+	defer func() { done(err) }()
+	return analytics.SaveClicks(ctx, events)
+}
+
+// GetClickStats возвращает статистику переходов из обернутого хранилища
+func (s *InstrumentedStorage) GetClickStats(ctx context.Context, shortURL string) (_ models.ClickStats, err error) {
+	analytics, ok := s.inner.(AnalyticsStorage)
+	if !ok {
+		return models.ClickStats{}, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "GetClickStats")
+	defer func() { done(err) }()
+	return analytics.GetClickStats(ctx, shortURL)
+}
+
+// SaveDeleteJob сохраняет задачу удаления в обернутом хранилище
+func (s *InstrumentedStorage) SaveDeleteJob(ctx context.Context, job models.DeleteJob) (err error) {
+	jobs, ok := s.inner.(DeleteJobStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "SaveDeleteJob")
+	defer func() { done(err) }()
+	return jobs.SaveDeleteJob(ctx, job)
+}
+
+// GetDeleteJob возвращает задачу удаления из обернутого хранилища
+func (s *InstrumentedStorage) GetDeleteJob(ctx context.Context, id string) (_ models.DeleteJob, err error) {
+	jobs, ok := s.inner.(DeleteJobStorage)
+	if !ok {
+		return models.DeleteJob{}, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "GetDeleteJob")
+	defer func() { done(err) }()
+	return jobs.GetDeleteJob(ctx, id)
+}
+
+// ListUnfinishedDeleteJobs возвращает незавершенные задачи удаления из обернутого хранилища
+func (s *InstrumentedStorage) ListUnfinishedDeleteJobs(ctx context.Context) (_ []models.DeleteJob, err error) {
+	jobs, ok := s.inner.(DeleteJobStorage)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "ListUnfinishedDeleteJobs")
+	defer func() { done(err) }()
+	return jobs.ListUnfinishedDeleteJobs(ctx)
+}
+
+// SaveAPIKey сохраняет API ключ в обернутом хранилище
+func (s *InstrumentedStorage) SaveAPIKey(ctx context.Context, key models.APIKey) (err error) {
+	keys, ok := s.inner.(APIKeyStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "SaveAPIKey")
+	defer func() { done(err) }()
+	return keys.SaveAPIKey(ctx, key)
+}
+
+// GetAPIKeyByHash возвращает API ключ из обернутого хранилища
+func (s *InstrumentedStorage) GetAPIKeyByHash(ctx context.Context, hash string) (_ models.APIKey, err error) {
+	keys, ok := s.inner.(APIKeyStorage)
+	if !ok {
+		return models.APIKey{}, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "GetAPIKeyByHash")
+	defer func() { done(err) }()
+	return keys.GetAPIKeyByHash(ctx, hash)
+}
+
+// ListAPIKeys возвращает API ключи пользователя из обернутого хранилища
+func (s *InstrumentedStorage) ListAPIKeys(ctx context.Context, userID string) (_ []models.APIKey, err error) {
+	keys, ok := s.inner.(APIKeyStorage)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "ListAPIKeys")
+	defer func() { done(err) }()
+	return keys.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey отзывает API ключ в обернутом хранилище
+func (s *InstrumentedStorage) RevokeAPIKey(ctx context.Context, id, userID string, at time.Time) (err error) {
+	keys, ok := s.inner.(APIKeyStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "RevokeAPIKey")
+	defer func() { done(err) }()
+	return keys.RevokeAPIKey(ctx, id, userID, at)
+}
+
+// TakeRateLimitToken берет токен из корзины в обернутом хранилище
+func (s *InstrumentedStorage) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (_ ratelimit.Decision, err error) {
+	limits, ok := s.inner.(RateLimitStorage)
+	if !ok {
+		return ratelimit.Decision{}, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "TakeRateLimitToken")
+	defer func() { done(err) }()
+	return limits.TakeRateLimitToken(ctx, key, limit, now)
+}
+
+// PurgeRateLimits удаляет корзины в обернутом хранилище
+func (s *InstrumentedStorage) PurgeRateLimits(ctx context.Context, before time.Time) (_ int64, err error) {
+	limits, ok := s.inner.(RateLimitStorage)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "PurgeRateLimits")
+	defer func() { done(err) }()
+	return limits.PurgeRateLimits(ctx, before)
+}
+
+// ReserveCounter резервирует значения счетчика в обернутом хранилище
+func (s *InstrumentedStorage) ReserveCounter(ctx context.Context, name string, start, n uint64) (_ uint64, err error) {
+	counters, ok := s.inner.(CounterStorage)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	ctx, done := s.start(ctx, "ReserveCounter")
+	defer func() { done(err) }()
+	return counters.ReserveCounter(ctx, name, start, n)
+}
+
+// Close закрывает обернутое хранилище, если оно владеет ресурсами
+func (s *InstrumentedStorage) Close() error {
+	if closer, ok := s.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestInstrumentedStorage(t *testing.T) {
+	const backend = "instrumented-test"
+	store := NewInstrumentedStorage(NewMemoryStorage(zap.NewNop()), backend)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "abc", "https://example.com", "user1"))
+	got, err := store.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got)
+
+	// Отсутствующая ссылка — штатный результат, а не ошибка хранилища
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	require.NoError(t, store.SaveBatch(ctx, []BatchEntry{{ShortURL: "def", OriginalURL: "https://example.org", UserID: "user1"}}))
+	require.NoError(t, store.BatchDelete(ctx, []string{"abc"}, "user1"))
+
+	assert.Equal(t, uint64(1), metrics.StorageOperationDuration.Count(backend, "Save"))
+	assert.Equal(t, uint64(2), metrics.StorageOperationDuration.Count(backend, "Get"))
+	assert.Equal(t, uint64(1), metrics.StorageOperationDuration.Count(backend, "SaveBatch"))
+	assert.Equal(t, uint64(1), metrics.StorageOperationDuration.Count(backend, "BatchDelete"))
+	assert.Zero(t, metrics.StorageOperationErrors.Value(backend, "Get"))
+
+	// Дополнительные интерфейсы делегируются обернутому хранилищу
+	var _ DeleteJobStorage = store
+	var _ AnalyticsStorage = store
+	var _ ExpiredURLPurger = store
+	var _ DeletedURLPurger = store
+	var _ APIKeyStorage = store
+	var _ RateLimitStorage = store
+
+	// Поддержку интерфейса нужно проверять на обернутом хранилище
+	inner := Unwrap(store)
+	assert.IsType(t, &MemoryStorage{}, inner)
+	_, ok := inner.(RateLimitStorage)
+	assert.False(t, ok, "memory storage does not keep rate limit buckets")
+	assert.Same(t, inner, Unwrap(inner))
+	assert.NoError(t, store.CheckConnection(ctx))
+	assert.NoError(t, store.Close())
+}
@@ -4,16 +4,26 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
 )
 
 // BatchEntry используется для передачи данных при пакетном сохранении URL.
 // Содержит минимальную информацию, необходимую для сохранения одного URL в батче.
 type BatchEntry struct {
-	ShortURL    string // Короткий идентификатор URL
-	OriginalURL string // Оригинальный полный URL
-	UserID      string // Идентификатор пользователя, который создал URL
+	ShortURL    string    // Короткий идентификатор URL
+	OriginalURL string    // Оригинальный полный URL в том виде, в каком его ввел пользователь
+	UserID      string    // Идентификатор пользователя, который создал URL
+	ExpiresAt   time.Time // Момент истечения срока действия ссылки (нулевое значение — бессрочно)
+	Title       string    // Заголовок ссылки, заданный пользователем
+	Description string    // Описание ссылки, заданное пользователем
+	// HTTP код перенаправления (0 — код по умолчанию из конфигурации)
+	RedirectStatus int
+	Interstitial   bool // Всегда показывать страницу предпросмотра перед переходом
+	// Каноническая форма OriginalURL, по которой ищутся дубликаты (пусто — сам OriginalURL)
+	CanonicalURL string
 }
 
 // URLStorage определяет интерфейс для хранилища URL.
@@ -25,27 +35,68 @@ type URLStorage interface {
 	// при попытке сохранить дублирующийся оригинальный URL.
 	Save(ctx context.Context, shortURL, originalURL, userID string) error
 
+	// SaveEntry сохраняет URL со всеми атрибутами записи (например, сроком действия).
+	// Семантика конфликтов совпадает с Save. Повторное сохранение существующей записи
+	// сохраняет время ее создания и обновляет время изменения.
+	SaveEntry(ctx context.Context, entry BatchEntry) error
+
 	// Get получает оригинальный URL по короткому идентификатору.
-	// Возвращает ErrURLNotFound, если URL не найден, или ErrURLDeleted,
-	// если URL был помечен как удаленный.
+	// Возвращает ErrURLNotFound, если URL не найден, ErrURLDeleted,
+	// если URL был помечен как удаленный, или ErrURLExpired, если истек срок действия ссылки.
 	Get(ctx context.Context, shortURL string) (string, error)
 
-	// GetShortURLByOriginal получает короткий URL по оригинальному.
-	// Возвращает ErrURLNotFound, если оригинальный URL не найден в хранилище.
+	// GetRedirect получает оригинальный URL вместе с параметрами перехода по ссылке.
+	// Возвращает те же ошибки, что и Get.
+	GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error)
+
+	// GetShortURLByOriginal получает короткий URL по канонической форме оригинального URL
+	// (см. BatchEntry.CanonicalURL). Возвращает ErrURLNotFound, если такой URL не найден в хранилище.
+	// Ссылки с истекшим сроком действия не учитываются.
 	// Используется для проверки дубликатов при создании новых URL.
-	GetShortURLByOriginal(ctx context.Context, originalURL string) (string, error)
+	GetShortURLByOriginal(ctx context.Context, canonicalURL string) (string, error)
 
 	// SaveBatch сохраняет пакет URL за одну операцию для повышения производительности.
 	// Каждый элемент BatchEntry должен содержать корректные shortURL и originalURL.
 	SaveBatch(ctx context.Context, batch []BatchEntry) error
 
 	// GetUserURLs получает все URL, сохраненные указанным пользователем.
-	// Возвращает слайс UserURL с парами короткий/оригинальный URL.
+	// Возвращает слайс UserURL с парами короткий/оригинальный URL и их метаданными.
 	// Возвращает пустой слайс, если у пользователя нет сохраненных URL.
 	GetUserURLs(ctx context.Context, userID string) ([]models.UserURL, error)
 
-	// BatchDelete помечает указанные URL как удаленные для конкретного пользователя.
-	// Удаленные URL перестают быть доступными через Get, но остаются в хранилище.
+	// ListUserURLs возвращает страницу неудаленных URL пользователя, удовлетворяющих
+	// фильтрам query, в порядке query.Sort, и общее количество таких URL.
+	// Страницы продолжаются по курсору (keyset), поэтому добавление и удаление ссылок
+	// между запросами не приводит к пропускам и повторам. Поврежденный курсор или курсор
+	// другой сортировки отклоняется с ErrInvalidCursor.
+	ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error)
+
+	// UpdateURL изменяет оригинальный URL и описание ссылки пользователя и сохраняет
+	// ее предыдущую версию в истории. Как и в BatchDelete, изменить можно только ссылку
+	// указанного пользователя: чужая ссылка не отличается от несуществующей (ErrURLNotFound).
+	// Возвращает ErrURLDeleted или ErrURLExpired для удаленной или истекшей ссылки и
+	// ErrOriginalURLConflict, если у пользователя уже есть другая ссылка на новый URL.
+	// Изменение, не меняющее ни одного поля, не создает версию в истории.
+	UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error)
+
+	// GetURLOwner возвращает идентификатор пользователя, создавшего ссылку.
+	// Возвращает ErrURLNotFound, если ссылка не найдена, и ErrURLDeleted, если она удалена.
+	// Срок действия ссылки не проверяется.
+	GetURLOwner(ctx context.Context, shortURL string) (string, error)
+
+	// GetURLRevisions возвращает предыдущие версии ссылки в порядке их замены.
+	// Для ссылки, которая не изменялась, возвращается пустой слайс.
+	GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error)
+
+	// RestoreURLs снимает пометку удаления с указанных URL пользователя и возвращает
+	// идентификаторы восстановленных ссылок. Как и в BatchDelete, ссылки других
+	// пользователей, а также несуществующие и не удаленные ссылки пропускаются.
+	// Ссылка не восстанавливается, если у пользователя уже есть другая действующая
+	// ссылка на тот же оригинальный URL.
+	RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error)
+
+	// BatchDelete помечает указанные URL как удаленные для конкретного пользователя
+	// и запоминает время удаления. Удаленные URL перестают быть доступными через Get, но остаются в хранилище.
 	// Операция выполняется асинхронно и может обрабатывать большие объемы данных.
 	BatchDelete(ctx context.Context, shortURLs []string, userID string) error
 }
@@ -58,3 +109,84 @@ type DatabaseChecker interface {
 	// Используется в health check эндпоинте /ping.
 	CheckConnection(ctx context.Context) error
 }
+
+// ExpiredURLPurger определяет интерфейс для удаления ссылок с истекшим сроком действия.
+// Реализуется хранилищами, поддерживающими фоновую очистку.
+type ExpiredURLPurger interface {
+	// PurgeExpired безвозвратно удаляет ссылки, срок действия которых истек к моменту now.
+	// Возвращает количество удаленных записей.
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// DeletedURLPurger определяет интерфейс для окончательного удаления ссылок,
+// помеченных удаленными пользователем. Реализуется хранилищами, поддерживающими фоновую очистку.
+type DeletedURLPurger interface {
+	// PurgeDeleted безвозвратно удаляет ссылки, помеченные удаленными раньше момента before.
+	// Возвращает количество удаленных записей.
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AnalyticsStorage определяет интерфейс хранилища событий переходов по коротким ссылкам.
+// Реализуется хранилищами, поддерживающими аналитику.
+type AnalyticsStorage interface {
+	// SaveClicks сохраняет пакет событий переходов.
+	SaveClicks(ctx context.Context, events []models.ClickEvent) error
+
+	// GetClickStats возвращает агрегированную статистику переходов по короткой ссылке.
+	// Для ссылки без переходов возвращается статистика с нулевыми счетчиками.
+	GetClickStats(ctx context.Context, shortURL string) (models.ClickStats, error)
+}
+
+// DeleteJobStorage определяет интерфейс хранилища задач асинхронного удаления URL.
+// Задачи хранятся в том же хранилище, что и URL, поэтому переживают перезапуск сервиса.
+type DeleteJobStorage interface {
+	// SaveDeleteJob сохраняет задачу или обновляет существующую с тем же ID.
+	SaveDeleteJob(ctx context.Context, job models.DeleteJob) error
+
+	// GetDeleteJob возвращает задачу по ID или ErrDeleteJobNotFound.
+	GetDeleteJob(ctx context.Context, id string) (models.DeleteJob, error)
+
+	// ListUnfinishedDeleteJobs возвращает незавершенные задачи (ожидающие и выполняющиеся)
+	// для возобновления обработки после перезапуска.
+	ListUnfinishedDeleteJobs(ctx context.Context) ([]models.DeleteJob, error)
+}
+
+// APIKeyStorage определяет интерфейс хранилища API ключей.
+// Ключи хранятся только в виде хеша, поэтому поиск выполняется по хешу.
+type APIKeyStorage interface {
+	// SaveAPIKey сохраняет новый API ключ.
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+
+	// GetAPIKeyByHash возвращает ключ по хешу или ErrAPIKeyNotFound.
+	// Отозванные ключи также возвращаются: проверка отзыва выполняется вызывающей стороной.
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+
+	// ListAPIKeys возвращает ключи пользователя, включая отозванные, в порядке создания.
+	ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+
+	// RevokeAPIKey отзывает ключ пользователя в момент at.
+	// Возвращает ErrAPIKeyNotFound, если ключ не существует или принадлежит другому пользователю.
+	// Повторный отзыв не изменяет время первого отзыва.
+	RevokeAPIKey(ctx context.Context, id, userID string, at time.Time) error
+}
+
+// RateLimitStorage определяет интерфейс хранилища корзин ограничения частоты запросов.
+// Реализуется хранилищами, общими для всех реплик сервиса (PostgreSQL), чтобы
+// ограничения действовали независимо от того, какая реплика обработала запрос.
+type RateLimitStorage interface {
+	// TakeRateLimitToken атомарно пополняет корзину key и пытается взять из нее токен.
+	TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error)
+
+	// PurgeRateLimits удаляет корзины, не обновлявшиеся с момента before.
+	PurgeRateLimits(ctx context.Context, before time.Time) (int64, error)
+}
+
+// CounterStorage определяет интерфейс хранилища именованных счетчиков, из которых
+// генераторы выдают короткие идентификаторы. Значения счетчика переживают перезапуск
+// сервиса, поэтому идентификаторы не повторяются.
+type CounterStorage interface {
+	// ReserveCounter резервирует n последовательных значений счетчика name и возвращает
+	// первое из них. Значения, меньшие start, пропускаются: счетчик продолжается
+	// с max(start, следующее незарезервированное значение).
+	ReserveCounter(ctx context.Context, name string, start, n uint64) (uint64, error)
+}
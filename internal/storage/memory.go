@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"go.uber.org/zap"
@@ -14,6 +16,33 @@ type URLEntry struct {
 	OriginalURL string
 	UserID      string
 	IsDeleted   bool
+	ExpiresAt   time.Time // Нулевое значение — бессрочная ссылка
+	Title       string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   time.Time // Нулевое значение — ссылка не удалена
+	// HTTP код перенаправления (0 — код по умолчанию)
+	RedirectStatus int
+	Interstitial   bool
+	// Каноническая форма OriginalURL для поиска дубликатов
+	CanonicalURL string
+}
+
+// row возвращает запись в виде строки списка ссылок пользователя
+func (e URLEntry) row(shortURL string) userURLRow {
+	return userURLRow{
+		ShortURL:    shortURL,
+		OriginalURL: e.OriginalURL,
+		Title:       e.Title,
+		Description: e.Description,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		DeletedAt:   e.DeletedAt,
+
+		RedirectStatus: e.RedirectStatus,
+		Interstitial:   e.Interstitial,
+	}
 }
 
 // MemoryStorage реализует URLStorage с использованием памяти
@@ -21,63 +50,134 @@ type MemoryStorage struct {
 	mu sync.RWMutex
 	// Изменяем структуру: map[shortURL]URLEntry
 	urls   map[string]URLEntry
-	logger *zap.Logger
+	index  urlIndex                    // Индексы по оригинальному URL и пользователю
+	clicks clickIndex                  // Статистика переходов по shortURL
+	jobs   map[string]models.DeleteJob // Задачи удаления по ID
+	keys   map[string]models.APIKey    // API ключи по хешу
+	// История версий по shortURL
+	revisions map[string][]models.URLRevision
+	counters  map[string]uint64 // Следующие значения счетчиков идентификаторов
+	logger    *zap.Logger
 }
 
 // NewMemoryStorage создает новый экземпляр MemoryStorage
 func NewMemoryStorage(logger *zap.Logger) *MemoryStorage {
 	return &MemoryStorage{
 		urls:   make(map[string]URLEntry),
-		logger: logger,
+		index:  newURLIndex(),
+		clicks: make(clickIndex),
+		jobs:   make(map[string]models.DeleteJob),
+		keys:   make(map[string]models.APIKey),
+
+		revisions: make(map[string][]models.URLRevision),
+		counters:  make(map[string]uint64),
+		logger:    logger,
 	}
 }
 
 // Save сохраняет URL в памяти, связывая его с userID
 func (ms *MemoryStorage) Save(ctx context.Context, shortURL, originalURL, userID string) error {
+	return ms.SaveEntry(ctx, BatchEntry{ShortURL: shortURL, OriginalURL: originalURL, UserID: userID})
+}
+
+// SaveEntry сохраняет URL со всеми атрибутами записи
+func (ms *MemoryStorage) SaveEntry(ctx context.Context, newEntry BatchEntry) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	// Проверка на коллизию: shortURL уже занят другим originalURL или другим пользователем
+	if existing, exists := ms.urls[newEntry.ShortURL]; exists && isCollision(BatchEntry{OriginalURL: existing.OriginalURL, UserID: existing.UserID}, newEntry.OriginalURL, newEntry.UserID) {
+		return ErrShortURLCollision
+	}
+
 	// Проверка на конфликт по originalURL для данного userID
-	for existingShort, entry := range ms.urls {
-		if entry.OriginalURL == originalURL && entry.UserID == userID && !entry.IsDeleted && existingShort != shortURL {
-			return ErrOriginalURLConflict
+	if ms.hasOriginalConflict(newEntry.ShortURL, newEntry.canonicalURL(), newEntry.UserID, time.Now()) {
+		return ErrOriginalURLConflict
+	}
+
+	ms.put(newEntry)
+	return nil
+}
+
+// hasOriginalConflict сообщает, есть ли у пользователя другая действующая ссылка
+// на URL с канонической формой canonicalURL, кроме shortURL; вызывающий должен удерживать ms.mu
+func (ms *MemoryStorage) hasOriginalConflict(shortURL, canonicalURL, userID string, now time.Time) bool {
+	for existingShort := range ms.index.shortURLsByCanonical(canonicalURL) {
+		entry := ms.urls[existingShort]
+		if entry.UserID == userID && !entry.IsDeleted &&
+			!isExpired(entry.ExpiresAt, now) && existingShort != shortURL {
+			return true
 		}
 	}
+	return false
+}
 
-	ms.urls[shortURL] = URLEntry{
-		OriginalURL: originalURL,
-		UserID:      userID,
+// put сохраняет запись и обновляет индексы; вызывающий должен удерживать ms.mu.
+// Повторное сохранение записи сохраняет время ее создания.
+func (ms *MemoryStorage) put(newEntry BatchEntry) {
+	now := time.Now().UTC()
+	createdAt := now
+	if existing, exists := ms.urls[newEntry.ShortURL]; exists {
+		ms.index.remove(newEntry.ShortURL, existing.CanonicalURL, existing.UserID)
+		createdAt = existing.CreatedAt
+	}
+	ms.urls[newEntry.ShortURL] = URLEntry{
+		OriginalURL: newEntry.OriginalURL,
+		UserID:      newEntry.UserID,
 		IsDeleted:   false,
+		ExpiresAt:   newEntry.ExpiresAt,
+		Title:       newEntry.Title,
+		Description: newEntry.Description,
+		CreatedAt:   createdAt,
+		UpdatedAt:   now,
+
+		RedirectStatus: newEntry.RedirectStatus,
+		Interstitial:   newEntry.Interstitial,
+		CanonicalURL:   newEntry.canonicalURL(),
 	}
-	return nil
+	ms.index.add(newEntry.ShortURL, newEntry.canonicalURL(), newEntry.UserID)
 }
 
 // Get получает оригинальный URL по короткому
 func (ms *MemoryStorage) Get(ctx context.Context, shortURL string) (string, error) {
+	redirect, err := ms.GetRedirect(ctx, shortURL)
+	return redirect.OriginalURL, err
+}
+
+// GetRedirect получает оригинальный URL и параметры перехода по короткому URL
+func (ms *MemoryStorage) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	entry, exists := ms.urls[shortURL]
 	if !exists {
-		return "", ErrURLNotFound
+		return models.Redirect{}, ErrURLNotFound
 	}
 
 	if entry.IsDeleted {
-		return "", ErrURLDeleted
+		return models.Redirect{}, ErrURLDeleted
 	}
 
-	return entry.OriginalURL, nil
+	if isExpired(entry.ExpiresAt, time.Now()) {
+		return models.Redirect{}, ErrURLExpired
+	}
+
+	redirect := entry.row(shortURL).redirect()
+	redirect.ExpiresAt = entry.ExpiresAt
+	redirect.Editable = entry.UserID != ""
+	return redirect, nil
 }
 
-// GetShortURLByOriginal получает короткий URL по оригинальному
+// GetShortURLByOriginal получает короткий URL по канонической форме оригинального URL
 // Эта функция также должна учитывать userID или быть глобальной.
 // Пока оставим глобальной для совместимости.
-func (ms *MemoryStorage) GetShortURLByOriginal(ctx context.Context, originalURL string) (string, error) {
+func (ms *MemoryStorage) GetShortURLByOriginal(ctx context.Context, canonicalURL string) (string, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	for short, entry := range ms.urls {
-		if entry.OriginalURL == originalURL {
+	now := time.Now()
+	for short := range ms.index.shortURLsByCanonical(canonicalURL) {
+		if entry := ms.urls[short]; !entry.IsDeleted && !isExpired(entry.ExpiresAt, now) {
 			return short, nil
 		}
 	}
@@ -85,17 +185,22 @@ func (ms *MemoryStorage) GetShortURLByOriginal(ctx context.Context, originalURL
 	return "", ErrURLNotFound
 }
 
-// SaveBatch сохраняет пакет URL с поддержкой пользователей
+// SaveBatch сохраняет пакет URL с поддержкой пользователей.
+// Если хотя бы один shortURL занят другим originalURL, пакет не сохраняется
+// и возвращается ErrShortURLCollision.
 func (ms *MemoryStorage) SaveBatch(ctx context.Context, batch []BatchEntry) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	if err := checkBatchCollisions(batch, func(shortURL string) (BatchEntry, bool) {
+		entry, exists := ms.urls[shortURL]
+		return BatchEntry{ShortURL: shortURL, OriginalURL: entry.OriginalURL, UserID: entry.UserID}, exists
+	}); err != nil {
+		return err
+	}
+
 	for _, entry := range batch {
-		ms.urls[entry.ShortURL] = URLEntry{
-			OriginalURL: entry.OriginalURL,
-			UserID:      entry.UserID,
-			IsDeleted:   false,
-		}
+		ms.put(entry)
 	}
 
 	return nil
@@ -107,18 +212,93 @@ func (ms *MemoryStorage) GetUserURLs(ctx context.Context, userID string) ([]mode
 	defer ms.mu.RUnlock()
 
 	var result []models.UserURL
-	for shortURL, entry := range ms.urls {
-		if entry.UserID == userID && !entry.IsDeleted {
-			result = append(result, models.UserURL{
-				ShortURL:    shortURL,
-				OriginalURL: entry.OriginalURL,
-			})
+	for shortURL := range ms.index.shortURLsByUser(userID) {
+		if entry := ms.urls[shortURL]; !entry.IsDeleted {
+			result = append(result, entry.row(shortURL).toModel())
 		}
 	}
 
 	return result, nil
 }
 
+// ListUserURLs возвращает страницу URL пользователя из памяти
+func (ms *MemoryStorage) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	shortURLs := ms.index.shortURLsByUser(userID)
+	rows := make([]userURLRow, 0, len(shortURLs))
+	for shortURL := range shortURLs {
+		if entry := ms.urls[shortURL]; entry.IsDeleted == query.Deleted {
+			rows = append(rows, entry.row(shortURL))
+		}
+	}
+	return pageUserURLs(rows, query)
+}
+
+// UpdateURL изменяет ссылку пользователя и сохраняет ее предыдущую версию
+func (ms *MemoryStorage) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry, exists := ms.urls[shortURL]
+	if !exists || entry.UserID != userID {
+		return models.UserURL{}, ErrURLNotFound
+	}
+	if entry.IsDeleted {
+		return models.UserURL{}, ErrURLDeleted
+	}
+	now := time.Now().UTC()
+	if isExpired(entry.ExpiresAt, now) {
+		return models.UserURL{}, ErrURLExpired
+	}
+
+	previous := entry.row(shortURL)
+	row := previous
+	if !applyURLUpdate(&row, update) {
+		return row.toModel(), nil
+	}
+	canonicalURL := entry.CanonicalURL
+	if row.OriginalURL != previous.OriginalURL {
+		canonicalURL = cmp.Or(update.CanonicalURL, row.OriginalURL)
+		if ms.hasOriginalConflict(shortURL, canonicalURL, userID, now) {
+			return models.UserURL{}, ErrOriginalURLConflict
+		}
+	}
+
+	ms.revisions[shortURL] = append(ms.revisions[shortURL], revisionOf(previous, now))
+	ms.index.remove(shortURL, entry.CanonicalURL, entry.UserID)
+	entry.OriginalURL, entry.CanonicalURL = row.OriginalURL, canonicalURL
+	entry.Title, entry.Description = row.Title, row.Description
+	entry.UpdatedAt = now
+	ms.urls[shortURL] = entry
+	ms.index.add(shortURL, entry.CanonicalURL, entry.UserID)
+	return entry.row(shortURL).toModel(), nil
+}
+
+// GetURLOwner возвращает владельца ссылки
+func (ms *MemoryStorage) GetURLOwner(ctx context.Context, shortURL string) (string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	entry, exists := ms.urls[shortURL]
+	if !exists {
+		return "", ErrURLNotFound
+	}
+	if entry.IsDeleted {
+		return "", ErrURLDeleted
+	}
+	return entry.UserID, nil
+}
+
+// GetURLRevisions возвращает историю версий ссылки
+func (ms *MemoryStorage) GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return append([]models.URLRevision{}, ms.revisions[shortURL]...), nil
+}
+
 // CheckConnection проверяет доступность хранилища
 func (ms *MemoryStorage) CheckConnection(ctx context.Context) error {
 	ms.mu.RLock()
@@ -136,12 +316,186 @@ func (ms *MemoryStorage) BatchDelete(ctx context.Context, shortURLs []string, us
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	now := time.Now().UTC()
 	for _, shortURL := range shortURLs {
-		if entry, exists := ms.urls[shortURL]; exists && entry.UserID == userID {
+		if entry, exists := ms.urls[shortURL]; exists && entry.UserID == userID && !entry.IsDeleted {
 			entry.IsDeleted = true
+			entry.DeletedAt = now
+			entry.UpdatedAt = now
 			ms.urls[shortURL] = entry
 		}
 	}
 
 	return nil
 }
+
+// RestoreURLs снимает пометку удаления с URL пользователя
+func (ms *MemoryStorage) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now().UTC()
+	restored := []string{}
+	for _, shortURL := range shortURLs {
+		entry, exists := ms.urls[shortURL]
+		if !exists || entry.UserID != userID || !entry.IsDeleted ||
+			ms.hasOriginalConflict(shortURL, entry.CanonicalURL, userID, now) {
+			continue
+		}
+		entry.IsDeleted = false
+		entry.DeletedAt = time.Time{}
+		entry.UpdatedAt = now
+		ms.urls[shortURL] = entry
+		restored = append(restored, shortURL)
+	}
+
+	return restored, nil
+}
+
+// PurgeDeleted удаляет из памяти ссылки, помеченные удаленными раньше before
+func (ms *MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var purged int64
+	for shortURL, entry := range ms.urls {
+		if entry.IsDeleted && entry.DeletedAt.Before(before) {
+			delete(ms.urls, shortURL)
+			delete(ms.revisions, shortURL)
+			delete(ms.clicks, shortURL)
+			ms.index.remove(shortURL, entry.CanonicalURL, entry.UserID)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeExpired удаляет из памяти ссылки с истекшим сроком действия
+func (ms *MemoryStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var purged int64
+	for shortURL, entry := range ms.urls {
+		if isExpired(entry.ExpiresAt, now) {
+			delete(ms.urls, shortURL)
+			delete(ms.revisions, shortURL)
+			delete(ms.clicks, shortURL)
+			ms.index.remove(shortURL, entry.CanonicalURL, entry.UserID)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// SaveClicks учитывает события переходов в статистике. Сами события не хранятся,
+// поэтому память растет с количеством ссылок и посетителей, а не переходов.
+func (ms *MemoryStorage) SaveClicks(ctx context.Context, events []models.ClickEvent) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, event := range events {
+		ms.clicks.add(event)
+	}
+
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов по короткой ссылке
+func (ms *MemoryStorage) GetClickStats(ctx context.Context, shortURL string) (models.ClickStats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.clicks.stats(shortURL), nil
+}
+
+// SaveDeleteJob сохраняет или обновляет задачу удаления
+func (ms *MemoryStorage) SaveDeleteJob(ctx context.Context, job models.DeleteJob) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	job.ShortURLs = append([]string(nil), job.ShortURLs...)
+	ms.jobs[job.ID] = job
+	return nil
+}
+
+// GetDeleteJob возвращает задачу удаления по ID
+func (ms *MemoryStorage) GetDeleteJob(ctx context.Context, id string) (models.DeleteJob, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	job, exists := ms.jobs[id]
+	if !exists {
+		return models.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	return job, nil
+}
+
+// ListUnfinishedDeleteJobs возвращает незавершенные задачи удаления
+func (ms *MemoryStorage) ListUnfinishedDeleteJobs(ctx context.Context) ([]models.DeleteJob, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var jobs []models.DeleteJob
+	for _, job := range ms.jobs {
+		if !job.Finished() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// SaveAPIKey сохраняет API ключ
+func (ms *MemoryStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key.Scopes = append([]string(nil), key.Scopes...)
+	ms.keys[key.Hash] = key
+	return nil
+}
+
+// GetAPIKeyByHash возвращает API ключ по хешу
+func (ms *MemoryStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	key, exists := ms.keys[hash]
+	if !exists {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает API ключи пользователя в порядке создания
+func (ms *MemoryStorage) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return userAPIKeys(ms.keys, userID), nil
+}
+
+// RevokeAPIKey отзывает API ключ пользователя
+func (ms *MemoryStorage) RevokeAPIKey(ctx context.Context, id, userID string, at time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key, err := revokeAPIKey(ms.keys, id, userID, at)
+	if err != nil {
+		return err
+	}
+	ms.keys[key.Hash] = key
+	return nil
+}
+
+// ReserveCounter резервирует n значений счетчика name
+func (ms *MemoryStorage) ReserveCounter(ctx context.Context, name string, start, n uint64) (uint64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	first := max(ms.counters[name], start)
+	ms.counters[name] = first + n
+	return first, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -171,13 +174,213 @@ func TestMemoryStorage_ConflictDetection(t *testing.T) {
 	err = storage.Save(ctx, "xyz456", "https://example.com", "user1")
 	assert.ErrorIs(t, err, ErrOriginalURLConflict)
 
-	// Try to save different original URL with same short URL (should be allowed in current implementation)
+	// Try to save different original URL with same short URL
 	err = storage.Save(ctx, "abc123", "https://different.com", "user1")
-	assert.NoError(t, err) // This overwrites the previous entry
+	assert.ErrorIs(t, err, ErrShortURLCollision)
+
+	originalURL, err := storage.Get(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestMemoryStorage_SaveBatchCollision(t *testing.T) {
+	logger := zap.NewNop()
+	storage := NewMemoryStorage(logger)
+
+	ctx := context.Background()
+
+	err := storage.Save(ctx, "abc123", "https://example.com", "user1")
+	assert.NoError(t, err)
+
+	// Коллизия с уже сохраненной записью
+	err = storage.SaveBatch(ctx, []BatchEntry{
+		{ShortURL: "new1", OriginalURL: "https://new1.com", UserID: "user1"},
+		{ShortURL: "abc123", OriginalURL: "https://other.com", UserID: "user1"},
+	})
+	assert.ErrorIs(t, err, ErrShortURLCollision)
+
+	// Пакет не должен быть сохранен частично
+	_, err = storage.Get(ctx, "new1")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	// Коллизия внутри самого пакета
+	err = storage.SaveBatch(ctx, []BatchEntry{
+		{ShortURL: "dup", OriginalURL: "https://a.com", UserID: "user1"},
+		{ShortURL: "dup", OriginalURL: "https://b.com", UserID: "user1"},
+	})
+	assert.ErrorIs(t, err, ErrShortURLCollision)
+}
+
+func TestMemoryStorage_Expiration(t *testing.T) {
+	logger := zap.NewNop()
+	storage := NewMemoryStorage(logger)
+
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	err := storage.SaveEntry(ctx, BatchEntry{ShortURL: "old", OriginalURL: "https://expired.com", UserID: "user1", ExpiresAt: past})
+	assert.NoError(t, err)
+	err = storage.SaveEntry(ctx, BatchEntry{ShortURL: "live", OriginalURL: "https://live.com", UserID: "user1", ExpiresAt: future})
+	assert.NoError(t, err)
+
+	_, err = storage.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrURLExpired)
+
+	originalURL, err := storage.Get(ctx, "live")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://live.com", originalURL)
+
+	// Истекшая ссылка не участвует в поиске дубликатов и не блокирует создание новой
+	_, err = storage.GetShortURLByOriginal(ctx, "https://expired.com")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	err = storage.Save(ctx, "new", "https://expired.com", "user1")
+	assert.NoError(t, err)
+
+	purged, err := storage.PurgeExpired(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = storage.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestMemoryStorage_ClickStats(t *testing.T) {
+	logger := zap.NewNop()
+	storage := NewMemoryStorage(logger)
+
+	ctx := context.Background()
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	err := storage.SaveClicks(ctx, []models.ClickEvent{
+		{ShortURL: "abc", Timestamp: day1, Referrer: "https://google.com/search?q=x", IPHash: "ip1"},
+		{ShortURL: "abc", Timestamp: day1.Add(time.Hour), IPHash: "ip1"},
+		{ShortURL: "abc", Timestamp: day2, Referrer: "https://google.com/", IPHash: "ip2"},
+		{ShortURL: "other", Timestamp: day2, IPHash: "ip3"},
+	})
+	assert.NoError(t, err)
+
+	stats, err := storage.GetClickStats(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, map[string]int64{"google.com": 2, "direct": 1}, stats.Referrers)
+	assert.Equal(t, map[string]int64{"2024-03-01": 2, "2024-03-02": 1}, stats.Daily)
+	if assert.NotNil(t, stats.LastClickAt) {
+		assert.True(t, day2.Equal(*stats.LastClickAt))
+	}
+
+	empty, err := storage.GetClickStats(ctx, "none")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), empty.TotalClicks)
+	assert.Nil(t, empty.LastClickAt)
+
+	// Статистика окончательно удаленной ссылки не хранится
+	require.NoError(t, storage.Save(ctx, "abc", "https://clicks.example.com", "user1"))
+	require.NoError(t, storage.BatchDelete(ctx, []string{"abc"}, "user1"))
+	_, err = storage.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	stats, err = storage.GetClickStats(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.TotalClicks)
+}
+
+func TestMemoryStorage_IndexConsistency(t *testing.T) {
+	logger := zap.NewNop()
+	storage := NewMemoryStorage(logger)
+	ctx := context.Background()
+
+	require.NoError(t, storage.Save(ctx, "abc1", "https://example.com", "user1"))
+	require.NoError(t, storage.SaveBatch(ctx, []BatchEntry{
+		{ShortURL: "abc2", OriginalURL: "https://example.com", UserID: "user2"},
+		{ShortURL: "abc3", OriginalURL: "https://other.com", UserID: "user1"},
+	}))
+	require.NoError(t, storage.SaveEntry(ctx, BatchEntry{ShortURL: "old", OriginalURL: "https://old.com", UserID: "user1", ExpiresAt: time.Now().Add(-time.Minute)}))
+
+	assert.Len(t, storage.index.shortURLsByCanonical("https://example.com"), 2)
+	assert.Len(t, storage.index.shortURLsByUser("user1"), 3)
+
+	// Удаленная ссылка остается в индексе, но не учитывается при поиске
+	require.NoError(t, storage.BatchDelete(ctx, []string{"abc3"}, "user1"))
+	urls, err := storage.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	var originals []string
+	for _, u := range urls {
+		originals = append(originals, u.ShortURL+" "+u.OriginalURL)
+		assert.NotNil(t, u.CreatedAt)
+		assert.Nil(t, u.DeletedAt)
+	}
+	assert.ElementsMatch(t, []string{"abc1 https://example.com", "old https://old.com"}, originals)
+
+	// Очистка истекших ссылок удаляет их из индексов
+	_, err = storage.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, storage.index.shortURLsByCanonical("https://old.com"))
+	assert.NotContains(t, storage.index.byCanonical, "https://old.com")
+
+	_, err = storage.GetShortURLByOriginal(ctx, "https://old.com")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	err = storage.Save(ctx, "abc4", "https://example.com", "user1")
+	assert.ErrorIs(t, err, ErrOriginalURLConflict)
 }
 
 // Benchmarks
 
+// scaleBenchmarkSizes — размеры хранилища, на которых проверяется, что время создания
+// ссылки не зависит от количества уже сохраненных записей
+var scaleBenchmarkSizes = []int{1_000, 100_000, 1_000_000}
+
+// benchmarkSaveAtScale измеряет Save и GetShortURLByOriginal в хранилище,
+// предварительно заполненном size записями
+func benchmarkSaveAtScale(b *testing.B, newStorage func(b *testing.B) URLStorage) {
+	ctx := context.Background()
+	for _, size := range scaleBenchmarkSizes {
+		storage := newStorage(b)
+
+		const chunk = 10_000
+		batch := make([]BatchEntry, 0, chunk)
+		for i := 0; i < size; i++ {
+			batch = append(batch, BatchEntry{
+				ShortURL:    fmt.Sprintf("pre%d", i),
+				OriginalURL: fmt.Sprintf("https://example%d.com", i),
+				UserID:      fmt.Sprintf("user%d", i%100),
+			})
+			if len(batch) == chunk || i == size-1 {
+				if err := storage.SaveBatch(ctx, batch); err != nil {
+					b.Fatalf("SaveBatch failed: %v", err)
+				}
+				batch = batch[:0]
+			}
+		}
+
+		next := 0
+		b.Run(fmt.Sprintf("Save/Entries_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				next++
+				err := storage.Save(ctx, fmt.Sprintf("new%d", next), fmt.Sprintf("https://new%d.com", next), fmt.Sprintf("user%d", next%100))
+				if err != nil {
+					b.Fatalf("Save failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("GetShortURLByOriginal/Entries_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.GetShortURLByOriginal(ctx, fmt.Sprintf("https://example%d.com", i%size)); err != nil {
+					b.Fatalf("GetShortURLByOriginal failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryStorage_SaveAtScale(b *testing.B) {
+	benchmarkSaveAtScale(b, func(b *testing.B) URLStorage {
+		return NewMemoryStorage(zap.NewNop())
+	})
+}
+
 func BenchmarkMemoryStorage_Save(b *testing.B) {
 	logger := zap.NewNop()
 	storage := NewMemoryStorage(logger)
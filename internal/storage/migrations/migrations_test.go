@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(sqlFiles, "sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions must be sequential")
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int64
+		wantErr bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"sql/0002_second.up.sql":   {Data: []byte("SELECT 2")},
+				"sql/0002_second.down.sql": {Data: []byte("SELECT -2")},
+				"sql/0001_first.up.sql":    {Data: []byte("SELECT 1")},
+				"sql/0001_first.down.sql":  {Data: []byte("SELECT -1")},
+				"sql/README.md":            {Data: []byte("ignored")},
+			},
+			want: []int64{1, 2},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid version",
+			files: fstest.MapFS{
+				"sql/first_table.up.sql":   {Data: []byte("SELECT 1")},
+				"sql/first_table.down.sql": {Data: []byte("SELECT -1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files, "sql")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			versions := make([]int64, 0, len(migrations))
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tt.want, versions)
+		})
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS urls (
+    short_url VARCHAR(255) PRIMARY KEY,
+    original_url TEXT NOT NULL,
+    user_id VARCHAR(255),
+    CONSTRAINT unique_original_url_per_user UNIQUE (original_url, user_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_urls_user_created_at;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS created_at;
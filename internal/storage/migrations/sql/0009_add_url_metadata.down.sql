@@ -0,0 +1,4 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS description;
+ALTER TABLE urls DROP COLUMN IF EXISTS title;
+ALTER TABLE urls DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE urls DROP COLUMN IF EXISTS updated_at;
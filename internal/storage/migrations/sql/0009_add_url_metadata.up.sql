@@ -0,0 +1,8 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+UPDATE urls SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE urls ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE urls ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
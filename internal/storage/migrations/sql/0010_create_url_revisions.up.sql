@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS url_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    original_url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    replaced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_revisions_short_url ON url_revisions (short_url, replaced_at);
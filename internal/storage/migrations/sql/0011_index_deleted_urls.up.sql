@@ -0,0 +1,3 @@
+UPDATE urls SET deleted_at = updated_at WHERE is_deleted = TRUE AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_urls_deleted_at ON urls (deleted_at) WHERE is_deleted = TRUE;
@@ -0,0 +1,3 @@
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS unique_canonical_url_per_user;
+ALTER TABLE urls ADD CONSTRAINT unique_original_url_per_user UNIQUE (original_url, user_id);
+ALTER TABLE urls DROP COLUMN IF EXISTS canonical_url;
@@ -0,0 +1,7 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+UPDATE urls SET canonical_url = original_url WHERE canonical_url IS NULL;
+ALTER TABLE urls ALTER COLUMN canonical_url SET NOT NULL;
+
+-- Дубликаты ищутся по канонической форме URL, а не по строке, введенной пользователем
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS unique_original_url_per_user;
+ALTER TABLE urls ADD CONSTRAINT unique_canonical_url_per_user UNIQUE (canonical_url, user_id);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS id_counters (
+    name VARCHAR(64) PRIMARY KEY,
+    next_value BIGINT NOT NULL
+);
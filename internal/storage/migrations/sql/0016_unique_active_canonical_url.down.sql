@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS unique_canonical_url_per_user;
+-- Удаленные ссылки, для которых пользователь создал новую ссылку на тот же URL, нарушили бы ограничение
+DELETE FROM urls d USING urls a
+WHERE d.is_deleted AND d.canonical_url = a.canonical_url AND d.user_id = a.user_id AND d.short_url <> a.short_url;
+ALTER TABLE urls ADD CONSTRAINT unique_canonical_url_per_user UNIQUE (canonical_url, user_id);
@@ -0,0 +1,3 @@
+-- Удаленная ссылка не мешает пользователю снова сократить тот же URL
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS unique_canonical_url_per_user;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_per_user ON urls (canonical_url, user_id) WHERE NOT is_deleted;
@@ -1,14 +1,19 @@
 package storage
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/InQaaaaGit/trunc_url.git/internal/ratelimit"
+	"github.com/InQaaaaGit/trunc_url.git/internal/storage/migrations"
+	"github.com/InQaaaaGit/trunc_url.git/internal/tracing"
 	"github.com/lib/pq" // Используем pq для проверки ошибки
 	"go.uber.org/zap"
 )
@@ -22,11 +27,12 @@ type PostgresStorage struct {
 
 // NewPostgresStorage создает новый экземпляр PostgresStorage
 func NewPostgresStorage(dsn string, logger *zap.Logger) (*PostgresStorage, error) {
-	// Подключение к базе данных
-	db, err := sql.Open("postgres", dsn)
+	// Подключение к базе данных; каждый SQL запрос создает спан трассировки
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка подключения к БД: %w", err)
 	}
+	db := sql.OpenDB(tracing.WrapConnector(connector, "postgresql"))
 
 	// Настройка пула соединений
 	// Максимальное количество открытых соединений
@@ -56,29 +62,16 @@ func NewPostgresStorage(dsn string, logger *zap.Logger) (*PostgresStorage, error
 		return nil, fmt.Errorf("ошибка проверки соединения с БД: %w", err)
 	}
 
-	// Создание таблицы urls, если её ещё нет
-	// Используем конкатенацию строк для читаемости SQL
-	createTableSQL := `CREATE TABLE IF NOT EXISTS urls (` +
-		`short_url VARCHAR(255) PRIMARY KEY,` +
-		`original_url TEXT NOT NULL,` +
-		`user_id VARCHAR(255),` +
-		`is_deleted BOOLEAN DEFAULT FALSE,` +
-		`CONSTRAINT unique_original_url_per_user UNIQUE (original_url, user_id)` +
-		`)`
-	_, err = db.ExecContext(ctx, createTableSQL)
+	// Приводим схему к актуальной версии
+	migrator, err := migrations.NewMigrator(db, logger)
+	if err == nil {
+		_, err = migrator.Up(ctx)
+	}
 	if err != nil {
-		// Закрываем соединение, если не удалось создать таблицу
 		if closeErr := db.Close(); closeErr != nil {
-			log.Printf("Failed to close DB connection after table creation error: %v", closeErr)
+			log.Printf("Failed to close DB connection after migration error: %v", closeErr)
 		}
-		return nil, fmt.Errorf("table creation error: %w", err)
-	}
-
-	// Добавляем поле is_deleted, если его нет (для существующих таблиц)
-	alterTableSQL := `ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_deleted BOOLEAN DEFAULT FALSE`
-	_, err = db.ExecContext(ctx, alterTableSQL)
-	if err != nil {
-		logger.Warn("Failed to add is_deleted column (may already exist)", zap.Error(err))
+		return nil, fmt.Errorf("schema migration error: %w", err)
 	}
 
 	return &PostgresStorage{
@@ -89,41 +82,96 @@ func NewPostgresStorage(dsn string, logger *zap.Logger) (*PostgresStorage, error
 
 // Save сохраняет URL в хранилище, связывая его с userID
 func (ps *PostgresStorage) Save(ctx context.Context, shortURL, originalURL, userID string) error {
-	_, err := ps.db.ExecContext(ctx, "INSERT INTO urls (short_url, original_url, user_id) VALUES ($1, $2, $3)", shortURL, originalURL, userID)
+	return ps.SaveEntry(ctx, BatchEntry{ShortURL: shortURL, OriginalURL: originalURL, UserID: userID})
+}
+
+// SaveEntry сохраняет URL со всеми атрибутами записи.
+// Удаленная ссылка пользователя с тем же shortURL и originalURL восстанавливается,
+// как в memory и file хранилищах.
+func (ps *PostgresStorage) SaveEntry(ctx context.Context, entry BatchEntry) error {
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction start error: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // Вызов Rollback на завершенной транзакции безопасен
+
+	// Истекшая ссылка того же пользователя на тот же URL не должна блокировать создание новой
+	// через индекс unique_canonical_url_per_user, поэтому удаляем ее заранее
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM urls WHERE canonical_url = $1 AND user_id = $2 AND expires_at <= NOW()",
+		entry.canonicalURL(), entry.UserID)
+	if err != nil {
+		return fmt.Errorf("delete expired URL error: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO urls (short_url, original_url, canonical_url, user_id, expires_at, title, description, redirect_status, interstitial)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (short_url) DO NOTHING`,
+		entry.ShortURL, entry.OriginalURL, entry.canonicalURL(), entry.UserID, nullTime(entry.ExpiresAt), entry.Title, entry.Description,
+		entry.RedirectStatus, entry.Interstitial)
 	if err != nil {
-		// Проверяем, является ли ошибка ошибкой нарушения уникальности от lib/pq
+		// Нарушение уникальности (23505) здесь возможно только по индексу canonical_url + user_id:
+		// у пользователя уже есть действующая ссылка на этот URL
 		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // 23505 = unique_violation
-			// Если это конфликт уникальности (либо short_url, либо original_url + user_id),
-			// возвращаем нашу специальную ошибку
-			// Здесь может потребоваться более точная проверка, какой именно constraint вызвал конфликт,
-			// если original_url должен быть глобально уникальным, а не только для пользователя.
-			// В текущей постановке задачи - original_url может повторяться у разных пользователей.
-			return ErrOriginalURLConflict // Или другая специфичная ошибка
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrOriginalURLConflict
 		}
-		// Для всех других ошибок возвращаем их обернутыми
 		return fmt.Errorf("save URL error: %w", err)
 	}
+
+	// Если строка не вставлена, shortURL уже занят
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	if rowsAffected == 0 {
+		if err := ps.resolveShortURLConflict(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("transaction commit error: %w", err)
+	}
 	return nil
 }
 
 // Get получает оригинальный URL по короткому
 func (ps *PostgresStorage) Get(ctx context.Context, shortURL string) (string, error) {
-	var originalURL string
+	redirect, err := ps.GetRedirect(ctx, shortURL)
+	return redirect.OriginalURL, err
+}
+
+// GetRedirect получает оригинальный URL и параметры перехода по короткому URL
+func (ps *PostgresStorage) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	var redirect models.Redirect
 	var isDeleted bool
-	err := ps.db.QueryRowContext(ctx, "SELECT original_url, is_deleted FROM urls WHERE short_url = $1", shortURL).Scan(&originalURL, &isDeleted)
+	var expiresAt sql.NullTime
+	err := ps.db.QueryRowContext(ctx,
+		`SELECT original_url, redirect_status, interstitial, title, description, created_at, is_deleted, expires_at,
+			COALESCE(user_id, '') <> ''
+		FROM urls WHERE short_url = $1`,
+		shortURL).Scan(&redirect.OriginalURL, &redirect.Status, &redirect.Interstitial, &redirect.Title,
+		&redirect.Description, &redirect.CreatedAt, &isDeleted, &expiresAt, &redirect.Editable)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) { // Убедимся, что используется errors.Is
-			return "", ErrURLNotFound
+			return models.Redirect{}, ErrURLNotFound
 		}
-		return "", fmt.Errorf("get URL error: %w", err)
+		return models.Redirect{}, fmt.Errorf("get URL error: %w", err)
 	}
 
 	if isDeleted {
-		return "", ErrURLDeleted
+		return models.Redirect{}, ErrURLDeleted
+	}
+
+	if expiresAt.Valid {
+		if isExpired(expiresAt.Time, time.Now()) {
+			return models.Redirect{}, ErrURLExpired
+		}
+		redirect.ExpiresAt = expiresAt.Time
 	}
 
-	return originalURL, nil
+	return redirect, nil
 }
 
 // SaveBatch сохраняет пакет URL в PostgreSQL с использованием транзакции
@@ -142,12 +190,25 @@ func (ps *PostgresStorage) SaveBatch(ctx context.Context, batch []BatchEntry) er
 
 	// Выполняем вставку для каждой записи в пакете
 	for _, entry := range batch {
-		_, err := tx.ExecContext(ctx,
-			"INSERT INTO urls (short_url, original_url, user_id) VALUES ($1, $2, $3) ON CONFLICT (short_url) DO NOTHING",
-			entry.ShortURL, entry.OriginalURL, entry.UserID)
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO urls (short_url, original_url, canonical_url, user_id, expires_at, title, description, redirect_status, interstitial)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (short_url) DO NOTHING`,
+			entry.ShortURL, entry.OriginalURL, entry.canonicalURL(), entry.UserID, nullTime(entry.ExpiresAt), entry.Title, entry.Description,
+			entry.RedirectStatus, entry.Interstitial)
 		if err != nil {
 			return fmt.Errorf("insert query execution error for shortURL %s: %w", entry.ShortURL, err)
 		}
+
+		// Если строка не вставлена, shortURL уже существует: допустимо только для того же originalURL
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("rows affected error for shortURL %s: %w", entry.ShortURL, err)
+		}
+		if rowsAffected == 0 {
+			if err := ps.resolveShortURLConflict(ctx, tx, entry); err != nil && !errors.Is(err, ErrOriginalURLConflict) {
+				return err
+			}
+		}
 	}
 
 	// Если все вставки прошли успешно, коммитим транзакцию
@@ -158,22 +219,67 @@ func (ps *PostgresStorage) SaveBatch(ctx context.Context, batch []BatchEntry) er
 	return nil
 }
 
-// GetShortURLByOriginal получает короткий URL по оригинальному из PostgreSQL
-func (ps *PostgresStorage) GetShortURLByOriginal(ctx context.Context, originalURL string) (string, error) {
+// resolveShortURLConflict разрешает конфликт по short_url внутри транзакции tx.
+// Если shortURL занят удаленной ссылкой того же пользователя на тот же originalURL,
+// ссылка восстанавливается с атрибутами entry, и возвращается nil.
+// Возвращает ErrOriginalURLConflict, если shortURL уже указывает на тот же originalURL того же
+// пользователя (или у пользователя есть другая действующая ссылка на этот URL),
+// и ErrShortURLCollision, если shortURL занят другой записью.
+func (ps *PostgresStorage) resolveShortURLConflict(ctx context.Context, tx *sql.Tx, entry BatchEntry) error {
+	var existing BatchEntry
+	var existingUserID sql.NullString
+	var isDeleted bool
+	err := tx.QueryRowContext(ctx, "SELECT original_url, user_id, is_deleted FROM urls WHERE short_url = $1 FOR UPDATE",
+		entry.ShortURL).Scan(&existing.OriginalURL, &existingUserID, &isDeleted)
+	if err != nil {
+		return fmt.Errorf("error checking short_url conflict: %w", err)
+	}
+	existing.UserID = existingUserID.String
+	if isCollision(existing, entry.OriginalURL, entry.UserID) {
+		return fmt.Errorf("%w: %s", ErrShortURLCollision, entry.ShortURL)
+	}
+	if !isDeleted {
+		return ErrOriginalURLConflict
+	}
+
+	// Восстановление не должно нарушать индекс unique_canonical_url_per_user:
+	// ошибка прервала бы транзакцию пакетного сохранения
+	result, err := tx.ExecContext(ctx,
+		`UPDATE urls SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW(),
+			canonical_url = $2, expires_at = $3, title = $4, description = $5, redirect_status = $6, interstitial = $7
+		WHERE short_url = $1 AND NOT EXISTS (
+			SELECT 1 FROM urls a WHERE a.canonical_url = $2 AND a.user_id = $8 AND a.is_deleted = FALSE)`,
+		entry.ShortURL, entry.canonicalURL(), nullTime(entry.ExpiresAt), entry.Title, entry.Description,
+		entry.RedirectStatus, entry.Interstitial, entry.UserID)
+	if err != nil {
+		return fmt.Errorf("restore URL error: %w", err)
+	}
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	if restored == 0 {
+		return ErrOriginalURLConflict
+	}
+	return nil
+}
+
+// GetShortURLByOriginal получает короткий URL по канонической форме оригинального URL из PostgreSQL
+func (ps *PostgresStorage) GetShortURLByOriginal(ctx context.Context, canonicalURL string) (string, error) {
 	var shortURL string
-	err := ps.db.QueryRowContext(ctx, "SELECT short_url FROM urls WHERE original_url = $1", originalURL).Scan(&shortURL)
+	err := ps.db.QueryRowContext(ctx, "SELECT short_url FROM urls WHERE canonical_url = $1 AND is_deleted = FALSE AND (expires_at IS NULL OR expires_at > NOW())", canonicalURL).Scan(&shortURL)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", ErrURLNotFound
 		}
-		return "", fmt.Errorf("error getting short_url by original_url: %w", err)
+		return "", fmt.Errorf("error getting short_url by canonical_url: %w", err)
 	}
 	return shortURL, nil
 }
 
 // GetUserURLs получает все URL, сохраненные пользователем, из PostgreSQL
 func (ps *PostgresStorage) GetUserURLs(ctx context.Context, userID string) ([]models.UserURL, error) {
-	rows, err := ps.db.QueryContext(ctx, "SELECT short_url, original_url FROM urls WHERE user_id = $1 AND is_deleted = FALSE", userID)
+	rows, err := ps.db.QueryContext(ctx, "SELECT "+userURLColumns+" FROM urls WHERE user_id = $1 AND is_deleted = FALSE", userID)
 	if err != nil {
 		return nil, fmt.Errorf("query user URLs error: %w", err)
 	}
@@ -181,11 +287,11 @@ func (ps *PostgresStorage) GetUserURLs(ctx context.Context, userID string) ([]mo
 
 	var userURLs []models.UserURL
 	for rows.Next() {
-		var u models.UserURL
-		if err := rows.Scan(&u.ShortURL, &u.OriginalURL); err != nil {
-			return nil, fmt.Errorf("scan user URL error: %w", err)
+		row, err := scanUserURLRow(rows)
+		if err != nil {
+			return nil, err
 		}
-		userURLs = append(userURLs, u)
+		userURLs = append(userURLs, row.toModel())
 	}
 
 	if err := rows.Err(); err != nil {
@@ -195,6 +301,320 @@ func (ps *PostgresStorage) GetUserURLs(ctx context.Context, userID string) ([]mo
 	return userURLs, nil
 }
 
+// userURLColumns — колонки ссылки пользователя в порядке сканирования scanUserURLRow
+const userURLColumns = "short_url, original_url, title, description, created_at, updated_at, deleted_at, redirect_status, interstitial"
+
+// scanUserURLRow сканирует строку, выбранную по userURLColumns
+func scanUserURLRow(rows *sql.Rows) (userURLRow, error) {
+	var row userURLRow
+	var deletedAt sql.NullTime
+	if err := rows.Scan(&row.ShortURL, &row.OriginalURL, &row.Title, &row.Description,
+		&row.CreatedAt, &row.UpdatedAt, &deletedAt, &row.RedirectStatus, &row.Interstitial); err != nil {
+		return userURLRow{}, fmt.Errorf("scan user URL error: %w", err)
+	}
+	row.DeletedAt = deletedAt.Time
+	return row, nil
+}
+
+// userURLSortColumns — колонки сортировки списка ссылок пользователя
+var userURLSortColumns = map[string]string{
+	models.SortCreatedAt:   "created_at",
+	models.SortShortURL:    "short_url",
+	models.SortOriginalURL: "original_url",
+}
+
+// urlHostExpr извлекает хост (в нижнем регистре) из original_url
+const urlHostExpr = `lower(substring(original_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^@/?#]*@)?([^/?#:]+)'))`
+
+// ListUserURLs возвращает страницу URL пользователя из PostgreSQL.
+// Фильтры, сортировка и переход по курсору выполняются в базе данных.
+func (ps *PostgresStorage) ListUserURLs(ctx context.Context, userID string, query models.UserURLQuery) (models.UserURLPage, error) {
+	cursor, err := decodeCursor(query)
+	if err != nil {
+		return models.UserURLPage{}, err
+	}
+	sort := userURLSort(query)
+	column, ok := userURLSortColumns[sort]
+	if !ok {
+		return models.UserURLPage{}, fmt.Errorf("unknown sort field %q", sort)
+	}
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{"user_id = $1", "is_deleted = " + arg(query.Deleted)}
+	if query.Search != "" {
+		p := arg("%" + escapeLike(query.Search) + "%")
+		where = append(where, fmt.Sprintf("(original_url ILIKE %[1]s OR short_url ILIKE %[1]s)", p))
+	}
+	if query.Domain != "" {
+		domain := strings.ToLower(query.Domain)
+		where = append(where, fmt.Sprintf("(%[1]s = %[2]s OR %[1]s LIKE %[3]s)",
+			urlHostExpr, arg(domain), arg("%."+escapeLike(domain))))
+	}
+	if !query.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(query.CreatedAfter))
+	}
+	if !query.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(query.CreatedBefore))
+	}
+	filter := strings.Join(where, " AND ")
+
+	var page models.UserURLPage
+	if err := ps.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM urls WHERE "+filter, args...).Scan(&page.Total); err != nil {
+		return models.UserURLPage{}, fmt.Errorf("count user URLs error: %w", err)
+	}
+
+	direction, op := "ASC", ">"
+	if query.Desc {
+		direction, op = "DESC", "<"
+	}
+	if cursor != nil {
+		var value any = cursor.Value
+		if sort == models.SortCreatedAt {
+			value, _ = time.Parse(time.RFC3339Nano, cursor.Value)
+		}
+		filter += fmt.Sprintf(" AND (%s, short_url) %s (%s, %s)", column, op, arg(value), arg(cursor.ShortURL))
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM urls WHERE %s ORDER BY %s %s, short_url %s",
+		userURLColumns, filter, column, direction, direction)
+	if query.Limit > 0 {
+		// Лишняя строка показывает, есть ли следующая страница
+		stmt += " LIMIT " + arg(query.Limit+1)
+	}
+
+	rows, err := ps.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return models.UserURLPage{}, fmt.Errorf("query user URLs error: %w", err)
+	}
+	defer rows.Close()
+
+	var last userURLRow
+	page.URLs = []models.UserURL{}
+	for rows.Next() {
+		row, err := scanUserURLRow(rows)
+		if err != nil {
+			return models.UserURLPage{}, err
+		}
+		if query.Limit > 0 && len(page.URLs) == query.Limit {
+			page.NextCursor = encodeCursor(pageCursor{Sort: sort, Desc: query.Desc, Value: last.cursorValue(sort), ShortURL: last.ShortURL})
+			break
+		}
+		page.URLs = append(page.URLs, row.toModel())
+		last = row
+	}
+	if err := rows.Err(); err != nil {
+		return models.UserURLPage{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return page, nil
+}
+
+// UpdateURL изменяет ссылку пользователя в PostgreSQL. Строка ссылки блокируется
+// на время транзакции, поэтому версия в истории соответствует замененному состоянию.
+func (ps *PostgresStorage) UpdateURL(ctx context.Context, shortURL, userID string, update models.URLUpdate) (models.UserURL, error) {
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.UserURL{}, fmt.Errorf("transaction start error: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // Вызов Rollback на завершенной транзакции безопасен
+
+	var previous userURLRow
+	var canonicalURL string
+	var isDeleted bool
+	var expiresAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT short_url, original_url, canonical_url, title, description, created_at, updated_at, redirect_status, interstitial,
+		is_deleted, expires_at
+		FROM urls WHERE short_url = $1 AND user_id = $2 FOR UPDATE`,
+		shortURL, userID).Scan(&previous.ShortURL, &previous.OriginalURL, &canonicalURL, &previous.Title, &previous.Description,
+		&previous.CreatedAt, &previous.UpdatedAt, &previous.RedirectStatus, &previous.Interstitial, &isDeleted, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserURL{}, ErrURLNotFound
+	}
+	if err != nil {
+		return models.UserURL{}, fmt.Errorf("select URL for update error: %w", err)
+	}
+	if isDeleted {
+		return models.UserURL{}, ErrURLDeleted
+	}
+	now := time.Now().UTC()
+	if expiresAt.Valid && isExpired(expiresAt.Time, now) {
+		return models.UserURL{}, ErrURLExpired
+	}
+
+	row := previous
+	if !applyURLUpdate(&row, update) {
+		return row.toModel(), nil
+	}
+
+	if row.OriginalURL != previous.OriginalURL {
+		canonicalURL = cmp.Or(update.CanonicalURL, row.OriginalURL)
+		// Как и в SaveEntry, истекшая ссылка пользователя на новый URL не должна вызывать конфликт
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM urls WHERE canonical_url = $1 AND user_id = $2 AND expires_at <= NOW()",
+			canonicalURL, userID); err != nil {
+			return models.UserURL{}, fmt.Errorf("delete expired URL error: %w", err)
+		}
+	}
+
+	revision := revisionOf(previous, now)
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO url_revisions (short_url, original_url, title, description, replaced_at) VALUES ($1, $2, $3, $4, $5)",
+		shortURL, revision.OriginalURL, revision.Title, revision.Description, revision.ReplacedAt); err != nil {
+		return models.UserURL{}, fmt.Errorf("insert URL revision error: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE urls SET original_url = $1, canonical_url = $2, title = $3, description = $4, updated_at = $5 WHERE short_url = $6",
+		row.OriginalURL, canonicalURL, row.Title, row.Description, now, shortURL)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.UserURL{}, ErrOriginalURLConflict
+		}
+		return models.UserURL{}, fmt.Errorf("update URL error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.UserURL{}, fmt.Errorf("transaction commit error: %w", err)
+	}
+	row.UpdatedAt = now
+	return row.toModel(), nil
+}
+
+// GetURLOwner возвращает владельца ссылки из PostgreSQL
+func (ps *PostgresStorage) GetURLOwner(ctx context.Context, shortURL string) (string, error) {
+	var userID sql.NullString
+	var isDeleted bool
+	err := ps.db.QueryRowContext(ctx, "SELECT user_id, is_deleted FROM urls WHERE short_url = $1", shortURL).Scan(&userID, &isDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrURLNotFound
+		}
+		return "", fmt.Errorf("query URL owner error: %w", err)
+	}
+	if isDeleted {
+		return "", ErrURLDeleted
+	}
+	return userID.String, nil
+}
+
+// GetURLRevisions возвращает историю версий ссылки из PostgreSQL
+func (ps *PostgresStorage) GetURLRevisions(ctx context.Context, shortURL string) ([]models.URLRevision, error) {
+	rows, err := ps.db.QueryContext(ctx,
+		"SELECT original_url, title, description, replaced_at FROM url_revisions WHERE short_url = $1 ORDER BY replaced_at, id",
+		shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("query URL revisions error: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.URLRevision{}
+	for rows.Next() {
+		var r models.URLRevision
+		if err := rows.Scan(&r.OriginalURL, &r.Title, &r.Description, &r.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("scan URL revision error: %w", err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return revisions, nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// PurgeExpired удаляет из PostgreSQL ссылки с истекшим сроком действия
+func (ps *PostgresStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := ps.db.ExecContext(ctx, "DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("purge expired URLs error: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected error: %w", err)
+	}
+	return purged, nil
+}
+
+// RestoreURLs снимает пометку удаления с URL пользователя в PostgreSQL.
+// С миграции 0016 индекс unique_canonical_url_per_user частичный (WHERE NOT is_deleted),
+// поэтому дубликаты предотвращает условие NOT EXISTS: восстанавливается не более одной
+// ссылки на каждый URL, и только если у пользователя нет действующей.
+func (ps *PostgresStorage) RestoreURLs(ctx context.Context, shortURLs []string, userID string) ([]string, error) {
+	restored := []string{}
+	if len(shortURLs) == 0 {
+		return restored, nil
+	}
+
+	// Истекшая действующая ссылка на тот же URL не должна блокировать восстановление
+	// через индекс unique_canonical_url_per_user, поэтому удаляем ее заранее
+	_, err := ps.db.ExecContext(ctx,
+		`DELETE FROM urls e USING urls d
+		 WHERE d.short_url = ANY($1) AND d.user_id = $2 AND d.is_deleted = TRUE
+		   AND e.canonical_url = d.canonical_url AND e.user_id = d.user_id
+		   AND e.is_deleted = FALSE AND e.expires_at <= NOW()`,
+		pq.Array(shortURLs), userID)
+	if err != nil {
+		return nil, fmt.Errorf("delete expired URLs error: %w", err)
+	}
+
+	// Ссылка не восстанавливается, если у пользователя есть действующая ссылка на тот же URL;
+	// из нескольких удаленных ссылок на один URL восстанавливается одна
+	rows, err := ps.db.QueryContext(ctx,
+		`UPDATE urls SET is_deleted = FALSE, deleted_at = NULL, updated_at = NOW()
+		 WHERE short_url IN (
+			SELECT DISTINCT ON (d.canonical_url) d.short_url FROM urls d
+			WHERE d.short_url = ANY($1) AND d.user_id = $2 AND d.is_deleted = TRUE
+			  AND NOT EXISTS (
+				SELECT 1 FROM urls a
+				WHERE a.canonical_url = d.canonical_url AND a.user_id = d.user_id AND a.is_deleted = FALSE)
+			ORDER BY d.canonical_url, d.short_url)
+		 RETURNING short_url`,
+		pq.Array(shortURLs), userID)
+	if err != nil {
+		return nil, fmt.Errorf("restore URLs error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortURL string
+		if err := rows.Scan(&shortURL); err != nil {
+			return nil, fmt.Errorf("scan restored URL error: %w", err)
+		}
+		restored = append(restored, shortURL)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет из PostgreSQL ссылки, помеченные удаленными раньше before
+func (ps *PostgresStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	result, err := ps.db.ExecContext(ctx, "DELETE FROM urls WHERE is_deleted = TRUE AND deleted_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted URLs error: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected error: %w", err)
+	}
+	return purged, nil
+}
+
+// nullTime преобразует нулевое время в NULL для записи в базу данных
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 // Close закрывает соединение с базой данных
 func (ps *PostgresStorage) Close() error {
 	return ps.db.Close()
@@ -248,7 +668,7 @@ func (ps *PostgresStorage) batchDeleteChunk(ctx context.Context, shortURLs []str
 
 	// Используем массовый UPDATE с ANY для обновления всех URL за один запрос
 	// Это значительно эффективнее чем цикл отдельных UPDATE'ов
-	query := "UPDATE urls SET is_deleted = TRUE WHERE short_url = ANY($1) AND user_id = $2 AND is_deleted = FALSE"
+	query := "UPDATE urls SET is_deleted = TRUE, deleted_at = NOW(), updated_at = NOW() WHERE short_url = ANY($1) AND user_id = $2 AND is_deleted = FALSE"
 
 	// Преобразуем slice в PostgreSQL array
 	result, err := tx.ExecContext(ctx, query, pq.Array(shortURLs), userID)
@@ -271,3 +691,316 @@ func (ps *PostgresStorage) batchDeleteChunk(ctx context.Context, shortURLs []str
 
 	return nil
 }
+
+// SaveClicks сохраняет пакет событий переходов в PostgreSQL в одной транзакции
+func (ps *PostgresStorage) SaveClicks(ctx context.Context, events []models.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction start error: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash) VALUES ($1, $2, $3, $4, $5)")
+	if err != nil {
+		return fmt.Errorf("prepare insert clicks error: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		if _, err := stmt.ExecContext(ctx, event.ShortURL, event.Timestamp, event.Referrer, event.UserAgent, event.IPHash); err != nil {
+			return fmt.Errorf("insert click error for shortURL %s: %w", event.ShortURL, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов по короткой ссылке, агрегированную в PostgreSQL
+func (ps *PostgresStorage) GetClickStats(ctx context.Context, shortURL string) (models.ClickStats, error) {
+	stats := newClickStats(shortURL)
+
+	var lastClick sql.NullTime
+	err := ps.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT NULLIF(ip_hash, '')), MAX(clicked_at) FROM clicks WHERE short_url = $1",
+		shortURL).Scan(&stats.TotalClicks, &stats.UniqueVisitors, &lastClick)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("query click totals error: %w", err)
+	}
+	stats.LastClickAt = lastClickTime(lastClick.Time, lastClick.Valid)
+
+	if err := ps.scanClickGroups(ctx,
+		"SELECT referrer, COUNT(*) FROM clicks WHERE short_url = $1 GROUP BY referrer",
+		shortURL, func(key string, count int64) { stats.Referrers[referrerKey(key)] += count }); err != nil {
+		return models.ClickStats{}, err
+	}
+
+	if err := ps.scanClickGroups(ctx,
+		"SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'), COUNT(*) FROM clicks WHERE short_url = $1 GROUP BY 1",
+		shortURL, func(key string, count int64) { stats.Daily[key] += count }); err != nil {
+		return models.ClickStats{}, err
+	}
+
+	return stats, nil
+}
+
+// scanClickGroups выполняет запрос группировки переходов и передает каждую пару ключ/количество в add
+func (ps *PostgresStorage) scanClickGroups(ctx context.Context, query, shortURL string, add func(key string, count int64)) error {
+	rows, err := ps.db.QueryContext(ctx, query, shortURL)
+	if err != nil {
+		return fmt.Errorf("query click groups error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("scan click group error: %w", err)
+		}
+		add(key, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return nil
+}
+
+// SaveDeleteJob сохраняет задачу удаления или обновляет ее состояние
+func (ps *PostgresStorage) SaveDeleteJob(ctx context.Context, job models.DeleteJob) error {
+	var nextAttemptAt sql.NullTime
+	if job.NextAttemptAt != nil {
+		nextAttemptAt = nullTime(*job.NextAttemptAt)
+	}
+
+	_, err := ps.db.ExecContext(ctx,
+		`INSERT INTO delete_jobs (id, user_id, short_urls, status, attempts, last_error, next_attempt_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (id) DO UPDATE SET
+		 status = EXCLUDED.status, attempts = EXCLUDED.attempts, last_error = EXCLUDED.last_error,
+		 next_attempt_at = EXCLUDED.next_attempt_at, updated_at = EXCLUDED.updated_at`,
+		job.ID, job.UserID, pq.Array(job.ShortURLs), job.Status, job.Attempts, job.LastError,
+		nextAttemptAt, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("save delete job error: %w", err)
+	}
+	return nil
+}
+
+// deleteJobColumns — список колонок для scanDeleteJob
+const deleteJobColumns = "id, user_id, short_urls, status, attempts, last_error, next_attempt_at, created_at, updated_at"
+
+// GetDeleteJob возвращает задачу удаления по ID
+func (ps *PostgresStorage) GetDeleteJob(ctx context.Context, id string) (models.DeleteJob, error) {
+	row := ps.db.QueryRowContext(ctx, "SELECT "+deleteJobColumns+" FROM delete_jobs WHERE id = $1", id)
+	job, err := scanDeleteJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	if err != nil {
+		return models.DeleteJob{}, fmt.Errorf("get delete job error: %w", err)
+	}
+	return job, nil
+}
+
+// ListUnfinishedDeleteJobs возвращает незавершенные задачи удаления
+func (ps *PostgresStorage) ListUnfinishedDeleteJobs(ctx context.Context) ([]models.DeleteJob, error) {
+	rows, err := ps.db.QueryContext(ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE status IN ($1, $2) ORDER BY created_at",
+		models.DeleteJobPending, models.DeleteJobRunning)
+	if err != nil {
+		return nil, fmt.Errorf("list delete jobs error: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.DeleteJob
+	for rows.Next() {
+		job, err := scanDeleteJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan delete job error: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return jobs, nil
+}
+
+// rowScanner объединяет *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDeleteJob читает задачу удаления из строки результата с колонками deleteJobColumns
+func scanDeleteJob(row rowScanner) (models.DeleteJob, error) {
+	var job models.DeleteJob
+	var nextAttemptAt sql.NullTime
+	err := row.Scan(&job.ID, &job.UserID, pq.Array(&job.ShortURLs), &job.Status, &job.Attempts,
+		&job.LastError, &nextAttemptAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return models.DeleteJob{}, err
+	}
+	if nextAttemptAt.Valid {
+		job.NextAttemptAt = &nextAttemptAt.Time
+	}
+	return job, nil
+}
+
+// SaveAPIKey сохраняет новый API ключ
+func (ps *PostgresStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := ps.db.ExecContext(ctx,
+		`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("save API key error: %w", err)
+	}
+	return nil
+}
+
+// apiKeyColumns — список колонок для scanAPIKey
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, created_at, revoked_at"
+
+// GetAPIKeyByHash возвращает API ключ по хешу
+func (ps *PostgresStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	row := ps.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("get API key error: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает API ключи пользователя в порядке создания
+func (ps *PostgresStorage) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	rows, err := ps.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("list API keys error: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan API key error: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API ключ пользователя; время первого отзыва сохраняется
+func (ps *PostgresStorage) RevokeAPIKey(ctx context.Context, id, userID string, at time.Time) error {
+	result, err := ps.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3) WHERE id = $1 AND user_id = $2",
+		id, userID, at.UTC())
+	if err != nil {
+		return fmt.Errorf("revoke API key error: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoke API key error: %w", err)
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// scanAPIKey читает API ключ из строки результата с колонками apiKeyColumns
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes),
+		&key.CreatedAt, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// TakeRateLimitToken пополняет корзину key и пытается взять из нее токен.
+// Строка корзины блокируется до конца транзакции, поэтому одновременные запросы
+// разных реплик к одной корзине выполняются последовательно.
+func (ps *PostgresStorage) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error) {
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Decision{}, fmt.Errorf("rate limit transaction error: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	initial := ratelimit.NewBucket(limit, now)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, initial.Tokens, initial.UpdatedAt.UTC())
+	if err != nil {
+		return ratelimit.Decision{}, fmt.Errorf("rate limit insert error: %w", err)
+	}
+
+	var bucket ratelimit.Bucket
+	err = tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key).
+		Scan(&bucket.Tokens, &bucket.UpdatedAt)
+	if err != nil {
+		return ratelimit.Decision{}, fmt.Errorf("rate limit select error: %w", err)
+	}
+
+	bucket, decision := bucket.Take(limit, now)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1",
+		key, bucket.Tokens, bucket.UpdatedAt.UTC())
+	if err != nil {
+		return ratelimit.Decision{}, fmt.Errorf("rate limit update error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ratelimit.Decision{}, fmt.Errorf("rate limit commit error: %w", err)
+	}
+	return decision, nil
+}
+
+// PurgeRateLimits удаляет корзины, не обновлявшиеся с момента before
+func (ps *PostgresStorage) PurgeRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	result, err := ps.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purge rate limits error: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ReserveCounter резервирует n значений счетчика name одним атомарным запросом,
+// поэтому реплики сервиса получают непересекающиеся диапазоны
+func (ps *PostgresStorage) ReserveCounter(ctx context.Context, name string, start, n uint64) (uint64, error) {
+	var first int64
+	err := ps.db.QueryRowContext(ctx,
+		`INSERT INTO id_counters (name, next_value) VALUES ($1, $2::BIGINT + $3::BIGINT)
+		ON CONFLICT (name) DO UPDATE SET next_value = GREATEST(id_counters.next_value, $2::BIGINT) + $3::BIGINT
+		RETURNING next_value - $3::BIGINT`,
+		name, int64(start), int64(n)).Scan(&first)
+	if err != nil {
+		return 0, fmt.Errorf("reserve counter error: %w", err)
+	}
+	return uint64(first), nil
+}
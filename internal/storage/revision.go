@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+)
+
+// applyURLUpdate применяет изменение update к ссылке row и сообщает, изменилось ли хотя бы одно поле
+func applyURLUpdate(row *userURLRow, update models.URLUpdate) bool {
+	changed := false
+	set := func(field *string, value *string) {
+		if value != nil && *field != *value {
+			*field = *value
+			changed = true
+		}
+	}
+	set(&row.OriginalURL, update.OriginalURL)
+	set(&row.Title, update.Title)
+	set(&row.Description, update.Description)
+	return changed
+}
+
+// revisionOf возвращает версию ссылки row, замененную в момент replacedAt
+func revisionOf(row userURLRow, replacedAt time.Time) models.URLRevision {
+	return models.URLRevision{
+		OriginalURL: row.OriginalURL,
+		Title:       row.Title,
+		Description: row.Description,
+		ReplacedAt:  replacedAt,
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// testUpdateURL проверяет изменение ссылок и историю версий хранилищем store
+func testUpdateURL(t *testing.T, store URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveEntry(ctx, BatchEntry{ShortURL: "promo", OriginalURL: "https://example.com/v1", UserID: "user1", Title: "Акция"}))
+	require.NoError(t, store.Save(ctx, "other", "https://example.com/other", "user1"))
+	require.NoError(t, store.Save(ctx, "gone", "https://example.com/gone", "user1"))
+	require.NoError(t, store.BatchDelete(ctx, []string{"gone"}, "user1"))
+	require.NoError(t, store.SaveEntry(ctx, BatchEntry{ShortURL: "expired", OriginalURL: "https://example.com/expired", UserID: "user1", ExpiresAt: time.Now().Add(-time.Minute)}))
+
+	t.Run("ownership", func(t *testing.T) {
+		_, err := store.UpdateURL(ctx, "promo", "user2", models.URLUpdate{OriginalURL: strPtr("https://evil.com")})
+		assert.ErrorIs(t, err, ErrURLNotFound)
+		_, err = store.UpdateURL(ctx, "missing", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com")})
+		assert.ErrorIs(t, err, ErrURLNotFound)
+
+		original, err := store.Get(ctx, "promo")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/v1", original)
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		owner, err := store.GetURLOwner(ctx, "promo")
+		require.NoError(t, err)
+		assert.Equal(t, "user1", owner)
+		owner, err = store.GetURLOwner(ctx, "expired")
+		require.NoError(t, err)
+		assert.Equal(t, "user1", owner)
+
+		_, err = store.GetURLOwner(ctx, "missing")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+		_, err = store.GetURLOwner(ctx, "gone")
+		assert.ErrorIs(t, err, ErrURLDeleted)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		_, err := store.UpdateURL(ctx, "gone", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/new")})
+		assert.ErrorIs(t, err, ErrURLDeleted)
+		_, err = store.UpdateURL(ctx, "expired", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/new")})
+		assert.ErrorIs(t, err, ErrURLExpired)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := store.UpdateURL(ctx, "promo", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/other")})
+		assert.ErrorIs(t, err, ErrOriginalURLConflict)
+	})
+
+	t.Run("update and history", func(t *testing.T) {
+		updated, err := store.UpdateURL(ctx, "promo", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/v2")})
+		require.NoError(t, err)
+		assert.Equal(t, "promo", updated.ShortURL)
+		assert.Equal(t, "https://example.com/v2", updated.OriginalURL)
+		assert.Equal(t, "Акция", updated.Title)
+		require.NotNil(t, updated.UpdatedAt)
+		assert.True(t, updated.UpdatedAt.After(*updated.CreatedAt))
+
+		updated, err = store.UpdateURL(ctx, "promo", "user1", models.URLUpdate{Title: strPtr(""), Description: strPtr("Летняя акция")})
+		require.NoError(t, err)
+		assert.Empty(t, updated.Title)
+		assert.Equal(t, "Летняя акция", updated.Description)
+
+		// Изменение без новых значений не создает версию
+		_, err = store.UpdateURL(ctx, "promo", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/v2")})
+		require.NoError(t, err)
+
+		original, err := store.Get(ctx, "promo")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/v2", original)
+
+		// Поиск по оригинальному URL учитывает изменение
+		shortURL, err := store.GetShortURLByOriginal(ctx, "https://example.com/v2")
+		require.NoError(t, err)
+		assert.Equal(t, "promo", shortURL)
+		_, err = store.GetShortURLByOriginal(ctx, "https://example.com/v1")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+
+		revisions, err := store.GetURLRevisions(ctx, "promo")
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		assert.Equal(t, "https://example.com/v1", revisions[0].OriginalURL)
+		assert.Equal(t, "Акция", revisions[0].Title)
+		assert.Equal(t, "https://example.com/v2", revisions[1].OriginalURL)
+		assert.Equal(t, "Акция", revisions[1].Title)
+		assert.False(t, revisions[1].ReplacedAt.Before(revisions[0].ReplacedAt))
+
+		revisions, err = store.GetURLRevisions(ctx, "other")
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+}
+
+func TestMemoryStorage_UpdateURL(t *testing.T) {
+	testUpdateURL(t, NewMemoryStorage(zap.NewNop()))
+}
+
+func TestFileStorage_UpdateURL(t *testing.T) {
+	path := createTempFile(t)
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	testUpdateURL(t, store)
+	require.NoError(t, store.Close())
+
+	// Изменения и история восстанавливаются после перезапуска
+	reopened, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	original, err := reopened.Get(context.Background(), "promo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/v2", original)
+
+	// История загружается при открытии и не перечитывается из файла при каждом запросе
+	require.NoError(t, os.Remove(path+revisionsFileSuffix))
+	revisions, err := reopened.GetURLRevisions(context.Background(), "promo")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestFileStorage_RevisionsOfPurgedURL(t *testing.T) {
+	path := createTempFile(t)
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "reused", "https://example.com/old", "user1"))
+	_, err = store.UpdateURL(ctx, "reused", "user1", models.URLUpdate{OriginalURL: strPtr("https://example.com/old2")})
+	require.NoError(t, err)
+	require.NoError(t, store.BatchDelete(ctx, []string{"reused"}, "user1"))
+	_, err = store.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	// Идентификатор занят новой ссылкой: история прежней ссылки ей не принадлежит
+	time.Sleep(time.Millisecond)
+	require.NoError(t, store.Save(ctx, "reused", "https://example.com/new", "user2"))
+	revisions, err := store.GetURLRevisions(ctx, "reused")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+	require.NoError(t, store.Close())
+
+	reopened, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+	revisions, err = reopened.GetURLRevisions(ctx, "reused")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
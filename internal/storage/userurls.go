@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+)
+
+// pageCursor — позиция в списке ссылок пользователя: значение поля сортировки
+// и короткий идентификатор последней ссылки предыдущей страницы
+type pageCursor struct {
+	Sort     string `json:"s"`
+	Desc     bool   `json:"d,omitempty"`
+	Value    string `json:"v"`
+	ShortURL string `json:"id"`
+}
+
+// encodeCursor кодирует курсор в непрозрачную строку
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor декодирует курсор запроса q. Пустой курсор означает первую страницу.
+// Курсор, полученный при другой сортировке, отклоняется с ErrInvalidCursor.
+func decodeCursor(q models.UserURLQuery) (*pageCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ShortURL == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != userURLSort(q) || c.Desc != q.Desc {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort == models.SortCreatedAt {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// userURLSort возвращает поле сортировки запроса с учетом значения по умолчанию
+func userURLSort(q models.UserURLQuery) string {
+	if q.Sort == "" {
+		return models.SortCreatedAt
+	}
+	return q.Sort
+}
+
+// userURLRow — ссылка пользователя с полями, по которым выполняется выборка
+type userURLRow struct {
+	ShortURL    string
+	OriginalURL string
+	Title       string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   time.Time // Нулевое значение — ссылка не удалена
+	// HTTP код перенаправления (0 — код по умолчанию)
+	RedirectStatus int
+	Interstitial   bool
+}
+
+// toModel возвращает ссылку в виде ответа API; нулевые моменты времени опускаются
+func (r userURLRow) toModel() models.UserURL {
+	return models.UserURL{
+		ShortURL:    r.ShortURL,
+		OriginalURL: r.OriginalURL,
+		Title:       r.Title,
+		Description: r.Description,
+		CreatedAt:   timePtr(r.CreatedAt),
+		UpdatedAt:   timePtr(r.UpdatedAt),
+		DeletedAt:   timePtr(r.DeletedAt),
+
+		RedirectStatus: r.RedirectStatus,
+		Interstitial:   r.Interstitial,
+	}
+}
+
+// redirect возвращает параметры перехода по ссылке
+func (r userURLRow) redirect() models.Redirect {
+	return models.Redirect{
+		OriginalURL:  r.OriginalURL,
+		Status:       r.RedirectStatus,
+		Interstitial: r.Interstitial,
+		Title:        r.Title,
+		Description:  r.Description,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+// timePtr возвращает указатель на t или nil для нулевого времени
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// cursorValue возвращает значение поля сортировки строки для курсора
+func (r userURLRow) cursorValue(sort string) string {
+	switch sort {
+	case models.SortShortURL:
+		return r.ShortURL
+	case models.SortOriginalURL:
+		return r.OriginalURL
+	default:
+		return r.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// compareUserURLRows сравнивает строки по полю сортировки, а при равенстве — по ShortURL
+func compareUserURLRows(a, b userURLRow, sort string) int {
+	var c int
+	switch sort {
+	case models.SortShortURL:
+	case models.SortOriginalURL:
+		c = strings.Compare(a.OriginalURL, b.OriginalURL)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	return cmp.Or(c, strings.Compare(a.ShortURL, b.ShortURL))
+}
+
+// matchesUserURLQuery сообщает, удовлетворяет ли строка фильтрам запроса
+func matchesUserURLQuery(r userURLRow, q models.UserURLQuery) bool {
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(r.OriginalURL), search) &&
+			!strings.Contains(strings.ToLower(r.ShortURL), search) {
+			return false
+		}
+	}
+	if q.Domain != "" && !matchesDomain(r.OriginalURL, q.Domain) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && r.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !r.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// matchesDomain сообщает, относится ли originalURL к домену domain или его поддомену
+func matchesDomain(originalURL, domain string) bool {
+	parsed, err := url.Parse(originalURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	domain = strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pageUserURLs выполняет выборку страницы из всех ссылок пользователя.
+// Используется хранилищами, держащими ссылки в памяти.
+func pageUserURLs(rows []userURLRow, q models.UserURLQuery) (models.UserURLPage, error) {
+	cursor, err := decodeCursor(q)
+	if err != nil {
+		return models.UserURLPage{}, err
+	}
+	sort := userURLSort(q)
+
+	matched := make([]userURLRow, 0, len(rows))
+	for _, row := range rows {
+		if matchesUserURLQuery(row, q) {
+			matched = append(matched, row)
+		}
+	}
+	compare := func(a, b userURLRow) int {
+		if q.Desc {
+			return compareUserURLRows(b, a, sort)
+		}
+		return compareUserURLRows(a, b, sort)
+	}
+	slices.SortFunc(matched, compare)
+
+	page := models.UserURLPage{Total: len(matched), URLs: []models.UserURL{}}
+	start := 0
+	if cursor != nil {
+		last := userURLRow{ShortURL: cursor.ShortURL}
+		switch sort {
+		case models.SortShortURL:
+		case models.SortOriginalURL:
+			last.OriginalURL = cursor.Value
+		default:
+			last.CreatedAt, _ = time.Parse(time.RFC3339Nano, cursor.Value)
+		}
+		start, _ = slices.BinarySearchFunc(matched, last, compare)
+		if start < len(matched) && compare(matched[start], last) == 0 {
+			start++
+		}
+	}
+
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+	for _, row := range matched[start:end] {
+		page.URLs = append(page.URLs, row.toModel())
+	}
+	if end < len(matched) {
+		last := matched[end-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: sort, Desc: q.Desc, Value: last.cursorValue(sort), ShortURL: last.ShortURL})
+	}
+	return page, nil
+}
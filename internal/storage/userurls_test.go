@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/InQaaaaGit/trunc_url.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testListUserURLs проверяет выборку ссылок пользователя хранилищем store
+func testListUserURLs(t *testing.T, store URLStorage) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Second)
+
+	originals := []string{
+		"https://example.com/a",
+		"https://blog.example.com/b",
+		"https://other.org/example",
+		"https://other.org/c",
+		"https://notexample.com/d",
+	}
+	for i, original := range originals {
+		require.NoError(t, store.Save(ctx, fmt.Sprintf("id%d", i), original, "user1"))
+	}
+	require.NoError(t, store.Save(ctx, "foreign", "https://example.com/x", "user2"))
+	require.NoError(t, store.Save(ctx, "gone", "https://example.com/gone", "user1"))
+	require.NoError(t, store.BatchDelete(ctx, []string{"gone"}, "user1"))
+
+	shortURLs := func(page models.UserURLPage) []string {
+		ids := make([]string, 0, len(page.URLs))
+		for _, u := range page.URLs {
+			ids = append(ids, u.ShortURL)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name  string
+		query models.UserURLQuery
+		want  []string
+	}{
+		{name: "all by short URL", query: models.UserURLQuery{Sort: models.SortShortURL}, want: []string{"id0", "id1", "id2", "id3", "id4"}},
+		{name: "descending", query: models.UserURLQuery{Sort: models.SortShortURL, Desc: true}, want: []string{"id4", "id3", "id2", "id1", "id0"}},
+		{name: "by original URL", query: models.UserURLQuery{Sort: models.SortOriginalURL}, want: []string{"id1", "id0", "id4", "id3", "id2"}},
+		{name: "substring", query: models.UserURLQuery{Search: "EXAMPLE", Sort: models.SortShortURL}, want: []string{"id0", "id1", "id2", "id4"}},
+		{name: "domain with subdomains", query: models.UserURLQuery{Domain: "example.com", Sort: models.SortShortURL}, want: []string{"id0", "id1"}},
+		{name: "created range", query: models.UserURLQuery{CreatedAfter: before, CreatedBefore: time.Now().Add(time.Second), Sort: models.SortShortURL}, want: []string{"id0", "id1", "id2", "id3", "id4"}},
+		{name: "created in the future", query: models.UserURLQuery{CreatedAfter: time.Now().Add(time.Hour)}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ListUserURLs(ctx, "user1", tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, shortURLs(page))
+			assert.Equal(t, len(tt.want), page.Total)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+
+	t.Run("cursor pagination", func(t *testing.T) {
+		query := models.UserURLQuery{Sort: models.SortOriginalURL, Desc: true, Limit: 2}
+		var got []string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5)
+			page, err := store.ListUserURLs(ctx, "user1", query)
+			require.NoError(t, err)
+			assert.Equal(t, 5, page.Total)
+			got = append(got, shortURLs(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"id2", "id3", "id4", "id0", "id1"}, got)
+	})
+
+	t.Run("cursor survives inserts", func(t *testing.T) {
+		page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Limit: 3})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 3)
+
+		// Новая ссылка создана позже всех и попадает в конец, не сдвигая страницы
+		require.NoError(t, store.Save(ctx, "id9", "https://example.com/new", "user1"))
+		next, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Limit: 3, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, 6, next.Total)
+		assert.Len(t, next.URLs, 3)
+		assert.Equal(t, "id9", next.URLs[2].ShortURL)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Limit: 1})
+		require.NoError(t, err)
+
+		_, err = store.ListUserURLs(ctx, "user1", models.UserURLQuery{Limit: 1, Cursor: "garbage"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		_, err = store.ListUserURLs(ctx, "user1", models.UserURLQuery{Limit: 1, Cursor: page.NextCursor, Sort: models.SortShortURL})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestMemoryStorage_ListUserURLs(t *testing.T) {
+	testListUserURLs(t, NewMemoryStorage(zap.NewNop()))
+}
+
+func TestFileStorage_ListUserURLs(t *testing.T) {
+	store, err := NewFileStorage(createTempFile(t), zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+	testListUserURLs(t, store)
+}
+
+func TestFileStorage_LegacyRecordsWithoutCreatedAt(t *testing.T) {
+	path := createTempFile(t)
+	legacy := `{"uuid":"1","short_url":"old","original_url":"https://example.com/old","user_id":"user1"}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Save(context.Background(), "new", "https://example.com/new", "user1"))
+
+	// Записи без времени создания считаются самыми старыми
+	page, err := store.ListUserURLs(context.Background(), "user1", models.UserURLQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+	assert.Equal(t, "old", page.URLs[0].ShortURL)
+	assert.Nil(t, page.URLs[0].CreatedAt)
+	assert.Nil(t, page.URLs[0].UpdatedAt)
+	assert.NotNil(t, page.URLs[1].CreatedAt)
+
+	page, err = store.ListUserURLs(context.Background(), "user1", models.UserURLQuery{CreatedAfter: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+}
+
+// testURLMetadata проверяет сохранение временных меток и описания ссылок хранилищем store
+func testURLMetadata(t *testing.T, store URLStorage) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Second)
+
+	require.NoError(t, store.SaveEntry(ctx, BatchEntry{
+		ShortURL: "docs", OriginalURL: "https://example.com/docs", UserID: "user1",
+		Title: "Документация", Description: "Справочник по API", RedirectStatus: 301, Interstitial: true,
+	}))
+	require.NoError(t, store.SaveBatch(ctx, []BatchEntry{
+		{ShortURL: "plain", OriginalURL: "https://example.com/plain", UserID: "user1"},
+		{ShortURL: "gone", OriginalURL: "https://example.com/gone", UserID: "user1", Title: "Удаляемая"},
+	}))
+	require.NoError(t, store.BatchDelete(ctx, []string{"gone"}, "user1"))
+
+	page, err := store.ListUserURLs(ctx, "user1", models.UserURLQuery{Sort: models.SortShortURL})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+
+	docs, plain := page.URLs[0], page.URLs[1]
+	assert.Equal(t, "Документация", docs.Title)
+	assert.Equal(t, "Справочник по API", docs.Description)
+	assert.Empty(t, plain.Title)
+	assert.Equal(t, 301, docs.RedirectStatus)
+	assert.True(t, docs.Interstitial)
+	assert.Zero(t, plain.RedirectStatus)
+	assert.False(t, plain.Interstitial)
+	for _, u := range page.URLs {
+		require.NotNil(t, u.CreatedAt)
+		require.NotNil(t, u.UpdatedAt)
+		assert.True(t, u.CreatedAt.After(before))
+		assert.False(t, u.UpdatedAt.Before(*u.CreatedAt))
+		assert.Nil(t, u.DeletedAt)
+	}
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, page.URLs, urls)
+
+	redirect, err := store.GetRedirect(ctx, "docs")
+	require.NoError(t, err)
+	assert.Equal(t, models.Redirect{
+		OriginalURL: "https://example.com/docs", Status: 301, Interstitial: true,
+		Title: "Документация", Description: "Справочник по API", CreatedAt: *docs.CreatedAt,
+		Editable: true,
+	}, redirect)
+
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	require.NoError(t, store.SaveEntry(ctx, BatchEntry{ShortURL: "promo", OriginalURL: "https://example.com/promo", ExpiresAt: expiresAt}))
+	redirect, err = store.GetRedirect(ctx, "promo")
+	require.NoError(t, err)
+	assert.True(t, expiresAt.Equal(redirect.ExpiresAt), redirect.ExpiresAt)
+	assert.False(t, redirect.Editable, "a URL without an owner cannot be edited")
+	_, err = store.GetRedirect(ctx, "gone")
+	assert.ErrorIs(t, err, ErrURLDeleted)
+}
+
+func TestMemoryStorage_URLMetadata(t *testing.T) {
+	store := NewMemoryStorage(zap.NewNop())
+	testURLMetadata(t, store)
+
+	gone := store.urls["gone"]
+	assert.False(t, gone.DeletedAt.IsZero())
+	assert.Equal(t, gone.DeletedAt, gone.UpdatedAt)
+}
+
+func TestFileStorage_URLMetadata(t *testing.T) {
+	path := createTempFile(t)
+	store, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	testURLMetadata(t, store)
+	before, err := store.GetUserURLs(context.Background(), "user1")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// Метаданные и время удаления восстанавливаются из журнала
+	reopened, err := NewFileStorage(path, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	after, err := reopened.GetUserURLs(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, before, after)
+
+	gone := reopened.urls["gone"]
+	require.NotNil(t, gone.DeletedAt)
+	assert.Equal(t, gone.DeletedAt, gone.UpdatedAt)
+	assert.Equal(t, "Удаляемая", gone.Title)
+}
@@ -0,0 +1,431 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры пакетной отправки спанов
+const (
+	batchQueueSize     = 2048
+	batchMaxSize       = 512
+	batchFlushInterval = 5 * time.Second
+	exportTimeout      = 10 * time.Second
+)
+
+// Exporter отправляет завершенные спаны во внешнюю систему.
+type Exporter interface {
+	// ExportSpans отправляет пакет спанов.
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown освобождает ресурсы экспортера.
+	Shutdown(ctx context.Context) error
+}
+
+// Поддерживаемые значения Options.Exporter
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Options описывает настройки трассировки.
+type Options struct {
+	Exporter     string  // none, stdout, file или otlp
+	ServiceName  string  // Значение атрибута service.name
+	FilePath     string  // Файл для экспортера file
+	OTLPEndpoint string  // Базовый адрес OTLP/HTTP коллектора (например, http://localhost:4318)
+	SampleRatio  float64 // Доля записываемых новых трасс
+}
+
+// New создает трассировщик по настройкам opts.
+// Для экспортера none (или пустого значения) возвращает nil — трассировка отключена.
+func New(opts Options) (*Tracer, error) {
+	var exporter Exporter
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		exporter = NewWriterExporter(os.Stdout, opts.ServiceName)
+	case ExporterFile:
+		f, err := os.OpenFile(opts.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening trace file: %w", err)
+		}
+		exporter = NewWriterExporter(f, opts.ServiceName)
+	case ExporterOTLP:
+		exporter = NewOTLPExporter(opts.OTLPEndpoint, opts.ServiceName)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", opts.Exporter)
+	}
+	return NewTracer(exporter, opts.SampleRatio), nil
+}
+
+// batcher накапливает завершенные спаны и отправляет их экспортеру пакетами
+// в фоновой горутине, чтобы экспорт не задерживал обработку запросов.
+// При переполнении очереди спаны отбрасываются.
+type batcher struct {
+	exporter Exporter
+	queue    chan SpanData
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newBatcher(exporter Exporter) *batcher {
+	b := &batcher{
+		exporter: exporter,
+		queue:    make(chan SpanData, batchQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// enqueue ставит спан в очередь отправки без блокировки
+func (b *batcher) enqueue(span SpanData) {
+	select {
+	case <-b.stop:
+		return
+	default:
+	}
+	select {
+	case b.queue <- span:
+	default:
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(batchFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchMaxSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		_ = b.exporter.ExportSpans(ctx, batch) // Потеря трасс не должна влиять на работу сервиса
+		cancel()
+		batch = make([]SpanData, 0, batchMaxSize)
+	}
+
+	for {
+		select {
+		case span := <-b.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchMaxSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-b.stop:
+			// Забираем спаны, завершенные до остановки
+			for {
+				select {
+				case span := <-b.queue:
+					batch = append(batch, span)
+					if len(batch) >= batchMaxSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown отправляет оставшиеся спаны и закрывает экспортер
+func (b *batcher) shutdown(ctx context.Context) error {
+	b.once.Do(func() { close(b.stop) })
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.exporter.Shutdown(ctx)
+}
+
+// WriterExporter пишет спаны в io.Writer в виде JSON, по одному спану на строку.
+// Используется для локальной отладки (stdout) и записи трасс в файл.
+type WriterExporter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	serviceName string
+}
+
+// NewWriterExporter создает экспортер в w; если w реализует io.Closer
+// (кроме os.Stdout и os.Stderr), он закрывается при Shutdown.
+func NewWriterExporter(w io.Writer, serviceName string) *WriterExporter {
+	return &WriterExporter{w: w, serviceName: serviceName}
+}
+
+// writerSpan — представление спана в выводе WriterExporter
+type writerSpan struct {
+	Service      string         `json:"service,omitempty"`
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Start        time.Time      `json:"start"`
+	Duration     string         `json:"duration"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// ExportSpans записывает пакет спанов
+func (e *WriterExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		out := writerSpan{
+			Service:  e.serviceName,
+			Name:     s.Name,
+			Kind:     kindName(s.Kind),
+			TraceID:  s.TraceID.String(),
+			SpanID:   s.SpanID.String(),
+			Start:    s.StartTime.UTC(),
+			Duration: s.EndTime.Sub(s.StartTime).String(),
+		}
+		if s.ParentSpanID.IsValid() {
+			out.ParentSpanID = s.ParentSpanID.String()
+		}
+		if len(s.Attributes) > 0 {
+			out.Attributes = make(map[string]any, len(s.Attributes))
+			for _, attr := range s.Attributes {
+				out.Attributes[attr.Key] = attr.Value
+			}
+		}
+		if s.Error {
+			out.Error = s.StatusMessage
+			if out.Error == "" {
+				out.Error = "error"
+			}
+		}
+		if err := enc.Encode(out); err != nil {
+			return fmt.Errorf("error encoding span: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown закрывает файл экспортера
+func (e *WriterExporter) Shutdown(context.Context) error {
+	if e.w == os.Stdout || e.w == os.Stderr {
+		return nil
+	}
+	if closer, ok := e.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// kindName возвращает название роли спана
+func kindName(kind SpanKind) string {
+	switch kind {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// otlpTracesPath — путь приема трасс OTLP/HTTP
+const otlpTracesPath = "/v1/traces"
+
+// instrumentationScope — имя библиотеки инструментирования в OTLP
+const instrumentationScope = "github.com/InQaaaaGit/trunc_url.git/internal/tracing"
+
+// OTLPExporter отправляет спаны OTLP/HTTP коллектору в JSON кодировке.
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter создает экспортер для коллектора с базовым адресом endpoint
+// (например, http://localhost:4318); путь /v1/traces добавляется автоматически.
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, otlpTracesPath) {
+		endpoint += otlpTracesPath
+	}
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// Структуры запроса ExportTraceServiceRequest в JSON кодировке OTLP
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 0 — не задан, 2 — ошибка
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	// otlpResponse — ответ ExportTraceServiceResponse; partialSuccess заполняется,
+	// если коллектор принял запрос, но отклонил часть спанов
+	otlpResponse struct {
+		PartialSuccess *struct {
+			RejectedSpans json.Number `json:"rejectedSpans"`
+			ErrorMessage  string      `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}
+)
+
+// otlpStatusError — код статуса ошибки в OTLP
+const otlpStatusError = 2
+
+// ExportSpans отправляет пакет спанов коллектору
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.ParentSpanID.IsValid() {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+		if s.Error {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.StatusMessage}
+		}
+		out = append(out, span)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", e.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: out}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("error encoding OTLP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending spans: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP collector responded with status %d", resp.StatusCode)
+	}
+	// Частичный успех не повторяется (так требует спецификация OTLP), но сообщается как ошибка
+	var result otlpResponse
+	if json.Unmarshal(respBody, &result) == nil && result.PartialSuccess != nil {
+		if rejected, _ := result.PartialSuccess.RejectedSpans.Int64(); rejected > 0 {
+			return fmt.Errorf("OTLP collector rejected %d spans: %s", rejected, result.PartialSuccess.ErrorMessage)
+		}
+	}
+	return nil
+}
+
+// Shutdown ничего не делает: экспортер не владеет ресурсами
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// otlpAttributes преобразует атрибуты в формат OTLP AnyValue
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]any
+		switch v := attr.Value.(type) {
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": otlpDouble(v)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return out
+}
+
+// otlpDouble возвращает значение double для JSON кодировки OTLP: бесконечности и NaN
+// кодируются строками, как в отображении proto3 в JSON, иначе json.Marshal не смог бы
+// закодировать весь пакет
+func otlpDouble(v float64) any {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return v
+}
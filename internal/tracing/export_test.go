@@ -0,0 +1,310 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSpan() SpanData {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return SpanData{
+		Name:       "GET /{id}",
+		Kind:       SpanKindServer,
+		TraceID:    TraceID{1},
+		SpanID:     SpanID{2},
+		StartTime:  start,
+		EndTime:    start.Add(time.Millisecond),
+		Attributes: []Attribute{String("http.route", "/{id}"), Int("http.status_code", 500)},
+		Error:      true,
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, otlpTracesPath, r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &got))
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, "shortener")
+	require.NoError(t, exporter.ExportSpans(context.Background(), []SpanData{testSpan()}))
+
+	require.Len(t, got.ResourceSpans, 1)
+	rs := got.ResourceSpans[0]
+	assert.Equal(t, "service.name", rs.Resource.Attributes[0].Key)
+	assert.Equal(t, "shortener", rs.Resource.Attributes[0].Value["stringValue"])
+
+	require.Len(t, rs.ScopeSpans, 1)
+	require.Len(t, rs.ScopeSpans[0].Spans, 1)
+	span := rs.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "01000000000000000000000000000000", span.TraceID)
+	assert.Equal(t, "0200000000000000", span.SpanID)
+	assert.Empty(t, span.ParentSpanID)
+	assert.Equal(t, SpanKindServer, span.Kind)
+	assert.Equal(t, "1704067200000000000", span.StartTimeUnixNano)
+	assert.Equal(t, otlpStatusError, span.Status.Code)
+	assert.Equal(t, "500", span.Attributes[1].Value["intValue"])
+}
+
+func TestOTLPExporterErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL+otlpTracesPath, "shortener")
+	assert.Error(t, exporter.ExportSpans(context.Background(), []SpanData{testSpan()}))
+}
+
+// Схема ExportTraceServiceRequest в JSON кодировке OTLP (opentelemetry-proto,
+// trace/v1/trace.proto и common/v1/common.proto), описанная независимо от экспортера.
+// Коллектор в тесте разбирает запрос строго, как protojson: неизвестные поля — ошибка.
+type (
+	collectorRequest struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []collectorKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Scope struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				} `json:"scope"`
+				Spans     []collectorSpan `json:"spans"`
+				SchemaURL string          `json:"schemaUrl"`
+			} `json:"scopeSpans"`
+			SchemaURL string `json:"schemaUrl"`
+		} `json:"resourceSpans"`
+	}
+	collectorSpan struct {
+		TraceID           string              `json:"traceId"`
+		SpanID            string              `json:"spanId"`
+		TraceState        string              `json:"traceState"`
+		ParentSpanID      string              `json:"parentSpanId"`
+		Flags             uint32              `json:"flags"`
+		Name              string              `json:"name"`
+		Kind              int                 `json:"kind"`
+		StartTimeUnixNano string              `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string              `json:"endTimeUnixNano"`
+		Attributes        []collectorKeyValue `json:"attributes"`
+		Status            struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"status"`
+	}
+	collectorKeyValue struct {
+		Key   string                     `json:"key"`
+		Value map[string]json.RawMessage `json:"value"`
+	}
+)
+
+var (
+	traceIDRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	spanIDRe  = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// newCollector запускает OTLP/HTTP коллектор, проверяющий запросы по спецификации,
+// и возвращает принятые спаны по имени
+func newCollector(t *testing.T, respond func(w http.ResponseWriter)) (*httptest.Server, map[string]collectorSpan) {
+	spans := make(map[string]collectorSpan)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		var req collectorRequest
+		if !assert.NoError(t, decoder.Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, rs := range req.ResourceSpans {
+			checkCollectorAttributes(t, rs.Resource.Attributes)
+			for _, ss := range rs.ScopeSpans {
+				assert.NotEmpty(t, ss.Scope.Name)
+				for _, span := range ss.Spans {
+					checkCollectorSpan(t, span)
+					spans[span.Name] = span
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		respond(w)
+	}))
+	t.Cleanup(server.Close)
+	return server, spans
+}
+
+// checkCollectorSpan проверяет поля спана по правилам кодировки OTLP/JSON
+func checkCollectorSpan(t *testing.T, span collectorSpan) {
+	t.Helper()
+	// Идентификаторы кодируются hex строкой (а не base64, как в общем отображении proto3)
+	assert.Regexp(t, traceIDRe, span.TraceID)
+	assert.NotEqual(t, "00000000000000000000000000000000", span.TraceID)
+	assert.Regexp(t, spanIDRe, span.SpanID)
+	if span.ParentSpanID != "" {
+		assert.Regexp(t, spanIDRe, span.ParentSpanID)
+	}
+	assert.NotEmpty(t, span.Name)
+	// SPAN_KIND_INTERNAL..SPAN_KIND_CONSUMER; перечисления кодируются числами
+	assert.True(t, span.Kind >= 1 && span.Kind <= 5, "kind %d", span.Kind)
+	// fixed64 кодируется строкой десятичных цифр
+	start, err := strconv.ParseUint(span.StartTimeUnixNano, 10, 64)
+	assert.NoError(t, err)
+	end, err := strconv.ParseUint(span.EndTimeUnixNano, 10, 64)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, end, start)
+	// STATUS_CODE_UNSET, STATUS_CODE_OK, STATUS_CODE_ERROR
+	assert.True(t, span.Status.Code >= 0 && span.Status.Code <= 2, "status code %d", span.Status.Code)
+	checkCollectorAttributes(t, span.Attributes)
+}
+
+// checkCollectorAttributes проверяет, что каждое значение AnyValue задано ровно одним
+// полем и закодировано по отображению proto3 в JSON
+func checkCollectorAttributes(t *testing.T, attrs []collectorKeyValue) {
+	t.Helper()
+	for _, attr := range attrs {
+		assert.NotEmpty(t, attr.Key)
+		if !assert.Len(t, attr.Value, 1, attr.Key) {
+			continue
+		}
+		for kind, raw := range attr.Value {
+			switch kind {
+			case "stringValue":
+				var v string
+				assert.NoError(t, json.Unmarshal(raw, &v), attr.Key)
+			case "boolValue":
+				var v bool
+				assert.NoError(t, json.Unmarshal(raw, &v), attr.Key)
+			case "intValue":
+				// int64 кодируется строкой
+				var v string
+				if assert.NoError(t, json.Unmarshal(raw, &v), attr.Key) {
+					_, err := strconv.ParseInt(v, 10, 64)
+					assert.NoError(t, err, attr.Key)
+				}
+			case "doubleValue":
+				var number float64
+				var special string
+				if json.Unmarshal(raw, &number) != nil {
+					assert.NoError(t, json.Unmarshal(raw, &special), attr.Key)
+					assert.Contains(t, []string{"NaN", "Infinity", "-Infinity"}, special, attr.Key)
+				}
+			default:
+				assert.Failf(t, "unexpected AnyValue field", "%s: %s", attr.Key, kind)
+			}
+		}
+	}
+}
+
+func TestOTLPExporterConformance(t *testing.T) {
+	server, spans := newCollector(t, func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	child := testSpan()
+	child.Name = "storage.Save"
+	child.Kind = SpanKindInternal
+	child.SpanID = SpanID{3}
+	child.ParentSpanID = SpanID{2}
+	child.Error = false
+	child.Attributes = []Attribute{
+		String("db.system", "postgresql"),
+		Bool("cache.hit", false),
+		{Key: "ratio", Value: 0.25},
+		{Key: "nan", Value: math.NaN()},
+		{Key: "inf", Value: math.Inf(-1)},
+	}
+	client := testSpan()
+	client.Name = "SELECT"
+	client.Kind = SpanKindClient
+	client.SpanID = SpanID{0xab, 0xcd}
+
+	exporter := NewOTLPExporter(server.URL, "shortener")
+	require.NoError(t, exporter.ExportSpans(context.Background(), []SpanData{testSpan(), child, client}))
+
+	require.Len(t, spans, 3)
+	assert.Equal(t, "0200000000000000", spans["storage.Save"].ParentSpanID)
+	assert.Equal(t, 1, spans["storage.Save"].Kind)
+	assert.Equal(t, 3, spans["SELECT"].Kind)
+	assert.Equal(t, "abcd000000000000", spans["SELECT"].SpanID)
+	assert.Equal(t, 0, spans["storage.Save"].Status.Code)
+	assert.Equal(t, 2, spans["GET /{id}"].Status.Code)
+}
+
+func TestOTLPExporterPartialSuccess(t *testing.T) {
+	server, _ := newCollector(t, func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedSpans":"1","errorMessage":"span too large"}}`))
+	})
+
+	exporter := NewOTLPExporter(server.URL, "shortener")
+	err := exporter.ExportSpans(context.Background(), []SpanData{testSpan()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "span too large")
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewWriterExporter(&buf, "shortener")
+	require.NoError(t, exporter.ExportSpans(context.Background(), []SpanData{testSpan(), testSpan()}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var span writerSpan
+	require.NoError(t, json.Unmarshal(lines[0], &span))
+	assert.Equal(t, "shortener", span.Service)
+	assert.Equal(t, "GET /{id}", span.Name)
+	assert.Equal(t, "server", span.Kind)
+	assert.Equal(t, "1ms", span.Duration)
+	assert.Equal(t, "error", span.Error)
+	assert.Equal(t, float64(500), span.Attributes["http.status_code"])
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		wantTracer bool
+		wantErr    bool
+	}{
+		{name: "Disabled by default", opts: Options{}},
+		{name: "None", opts: Options{Exporter: ExporterNone}},
+		{name: "Stdout", opts: Options{Exporter: ExporterStdout}, wantTracer: true},
+		{name: "File", opts: Options{Exporter: ExporterFile, FilePath: t.TempDir() + "/traces.jsonl"}, wantTracer: true},
+		{name: "OTLP", opts: Options{Exporter: ExporterOTLP, OTLPEndpoint: "http://localhost:4318"}, wantTracer: true},
+		{name: "Unknown exporter", opts: Options{Exporter: "zipkin"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, err := New(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTracer, tracer != nil)
+			if tracer != nil {
+				assert.NoError(t, tracer.Shutdown(context.Background()))
+			}
+		})
+	}
+}
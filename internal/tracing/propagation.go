@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// TraceparentHeader — заголовок W3C Trace Context
+const TraceparentHeader = "traceparent"
+
+// Extract возвращает ctx с родительским контекстом из заголовка traceparent;
+// при отсутствии или некорректном заголовке ctx возвращается без изменений.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	value := header.Get(TraceparentHeader)
+	if value == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject записывает контекст текущего спана из ctx в заголовок traceparent
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
@@ -0,0 +1,239 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// WrapConnector оборачивает коннектор database/sql так, что каждый SQL запрос,
+// подготовка выражения и завершение транзакции создают клиентский спан
+// с атрибутами db.system и db.statement.
+// Использование: sql.OpenDB(tracing.WrapConnector(connector, "postgresql")).
+func WrapConnector(c driver.Connector, system string) driver.Connector {
+	return &tracedConnector{inner: c, system: system}
+}
+
+type tracedConnector struct {
+	inner  driver.Connector
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{inner: conn, system: c.system}, nil
+}
+
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.inner.Driver()
+}
+
+// startSQLSpan начинает спан SQL операции op
+func startSQLSpan(ctx context.Context, system, op, query string) (context.Context, *Span) {
+	attrs := []Attribute{String("db.system", system), String("db.operation", op)}
+	if query != "" {
+		attrs = append(attrs, String("db.statement", query))
+	}
+	return StartWithKind(ctx, "sql."+op, SpanKindClient, attrs...)
+}
+
+// endSQLSpan завершает спан; driver.ErrSkip не является ошибкой запроса
+func endSQLSpan(span *Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// tracedConn реализует расширенные интерфейсы драйвера, делегируя их
+// исходному соединению; если соединение их не поддерживает, возвращается
+// driver.ErrSkip, и database/sql использует запасной путь.
+type tracedConn struct {
+	inner  driver.Conn
+	system string
+}
+
+var (
+	_ driver.Connector          = (*tracedConnector)(nil)
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+	_ driver.SessionResetter    = (*tracedConn)(nil)
+	_ driver.Validator          = (*tracedConn)(nil)
+	_ driver.NamedValueChecker  = (*tracedConn)(nil)
+	_ driver.StmtExecContext    = (*tracedStmt)(nil)
+	_ driver.StmtQueryContext   = (*tracedStmt)(nil)
+)
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (_ driver.Stmt, err error) {
+	ctx, span := startSQLSpan(ctx, c.system, "prepare", query)
+	defer func() { endSQLSpan(span, err) }()
+
+	var stmt driver.Stmt
+	if preparer, ok := c.inner.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.inner.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{inner: stmt, query: query, system: c.system}, nil
+}
+
+func (c *tracedConn) Close() error {
+	return c.inner.Close()
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	spanCtx, span := startSQLSpan(ctx, c.system, "begin", "")
+	defer func() { endSQLSpan(span, err) }()
+
+	var tx driver.Tx
+	if beginner, ok := c.inner.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.inner.Begin() //nolint:staticcheck // Запасной путь для драйверов без BeginTx
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{inner: tx, ctx: ctx, system: c.system}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	execer, ok := c.inner.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQLSpan(ctx, c.system, "exec", query)
+	defer func() { endSQLSpan(span, err) }()
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	queryer, ok := c.inner.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQLSpan(ctx, c.system, "query", query)
+	defer func() { endSQLSpan(span, err) }()
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.inner.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.inner.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.inner.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.inner.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tracedStmt трассирует выполнение подготовленного выражения
+type tracedStmt struct {
+	inner  driver.Stmt
+	query  string
+	system string
+}
+
+func (s *tracedStmt) Close() error  { return s.inner.Close() }
+func (s *tracedStmt) NumInput() int { return s.inner.NumInput() }
+
+func (s *tracedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.inner.Exec(args) //nolint:staticcheck // Вызывается database/sql только для драйверов без StmtExecContext
+}
+
+func (s *tracedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.inner.Query(args) //nolint:staticcheck // Вызывается database/sql только для драйверов без StmtQueryContext
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	ctx, span := startSQLSpan(ctx, s.system, "exec", s.query)
+	defer func() { endSQLSpan(span, err) }()
+
+	if execer, ok := s.inner.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.inner.Exec(values) //nolint:staticcheck // Запасной путь для драйверов без StmtExecContext
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	ctx, span := startSQLSpan(ctx, s.system, "query", s.query)
+	defer func() { endSQLSpan(span, err) }()
+
+	if queryer, ok := s.inner.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.inner.Query(values) //nolint:staticcheck // Запасной путь для драйверов без StmtQueryContext
+}
+
+// namedValuesToValues преобразует именованные аргументы для драйверов без поддержки контекста
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// tracedTx трассирует завершение транзакции
+type tracedTx struct {
+	inner  driver.Tx
+	ctx    context.Context // Контекст BeginTx: спаны завершения относятся к тому же родителю, что и транзакция
+	system string
+}
+
+func (t *tracedTx) Commit() (err error) {
+	_, span := startSQLSpan(t.ctx, t.system, "commit", "")
+	defer func() { endSQLSpan(span, err) }()
+	return t.inner.Commit()
+}
+
+func (t *tracedTx) Rollback() (err error) {
+	_, span := startSQLSpan(t.ctx, t.system, "rollback", "")
+	defer func() { endSQLSpan(span, err) }()
+	return t.inner.Rollback()
+}
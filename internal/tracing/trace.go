@@ -0,0 +1,349 @@
+// Package tracing реализует распределенную трассировку запросов в модели OpenTelemetry:
+// спаны с атрибутами и статусом, распространение контекста через заголовок
+// W3C traceparent и пакетную отправку завершенных спанов в экспортер
+// (OTLP/HTTP, stdout или файл).
+//
+// Пакет не зависит от OpenTelemetry SDK: формат идентификаторов, заголовков
+// и OTLP совместим со стандартом, поэтому спаны принимаются любым
+// OTLP-совместимым коллектором (OpenTelemetry Collector, Jaeger, Tempo).
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID — 16-байтовый идентификатор трассы
+type TraceID [16]byte
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid сообщает, что идентификатор не нулевой
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID — 8-байтовый идентификатор спана
+type SpanID [8]byte
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid сообщает, что идентификатор не нулевой
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext — часть спана, распространяемая между сервисами.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // Спан записывается и экспортируется
+	Remote  bool // Контекст получен из входящего запроса
+}
+
+// IsValid сообщает, что контекст содержит идентификаторы трассы и спана
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind — роль спана в трассе
+type SpanKind int
+
+// Значения SpanKind совпадают с перечислением OTLP
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute — атрибут спана
+type Attribute struct {
+	Key   string
+	Value any // string, int64, float64 или bool
+}
+
+// String создает строковый атрибут
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int создает целочисленный атрибут
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool создает логический атрибут
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData — снимок завершенного спана, передаваемый экспортеру
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	Error         bool   // Операция завершилась ошибкой
+	StatusMessage string // Описание ошибки
+}
+
+// Span — выполняемая операция трассы. Методы безопасны для nil и
+// невыбранных (non-sampled) спанов: в этом случае они ничего не делают.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext возвращает контекст спана для распространения
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// IsRecording сообщает, что спан будет экспортирован
+func (s *Span) IsRecording() bool {
+	return s != nil && s.tracer != nil
+}
+
+// SetName заменяет имя спана (например, после определения шаблона маршрута)
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes добавляет атрибуты спана
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError помечает спан как завершившийся ошибкой err; nil игнорируется
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = true
+	s.data.StatusMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End завершает спан и передает его экспортеру; повторные вызовы игнорируются
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.batcher.enqueue(data)
+}
+
+// Tracer создает спаны и отправляет завершенные спаны в экспортер.
+type Tracer struct {
+	batcher     *batcher
+	sampleBound uint64 // Трассы с младшими 8 байтами TraceID меньше границы выбираются
+}
+
+// NewTracer создает Tracer, экспортирующий спаны через exporter.
+// sampleRatio — доля новых трасс, которые записываются (от 0 до 1);
+// для продолжения входящей трассы используется решение вызывающего сервиса.
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	var bound uint64
+	switch {
+	case sampleRatio >= 1:
+		bound = math.MaxUint64
+	case sampleRatio > 0:
+		bound = uint64(sampleRatio * math.MaxUint64)
+	}
+	return &Tracer{
+		batcher:     newBatcher(exporter),
+		sampleBound: bound,
+	}
+}
+
+// Start начинает спан name с родителем из ctx и возвращает контекст, содержащий новый спан
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.shouldSample(sc.TraceID)
+	}
+
+	span := &Span{sc: sc}
+	if sc.Sampled {
+		span.tracer = t
+		span.data = SpanData{
+			Name:         name,
+			Kind:         kind,
+			TraceID:      sc.TraceID,
+			SpanID:       sc.SpanID,
+			ParentSpanID: parent.SpanID,
+			StartTime:    time.Now(),
+			Attributes:   attrs,
+		}
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// Shutdown отправляет накопленные спаны и останавливает экспортер
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.batcher.shutdown(ctx)
+}
+
+// shouldSample принимает решение о записи новой трассы по ее идентификатору
+func (t *Tracer) shouldSample(id TraceID) bool {
+	if t.sampleBound == math.MaxUint64 {
+		return true
+	}
+	return binary.BigEndian.Uint64(id[8:]) < t.sampleBound
+}
+
+// global — трассировщик приложения; nil — трассировка отключена
+var global atomic.Pointer[Tracer]
+
+// SetTracer устанавливает трассировщик приложения; nil отключает трассировку
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Start начинает внутренний спан трассировщиком приложения.
+// Если трассировка отключена, возвращает невыбранный спан, сохраняющий
+// родительский контекст для распространения.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartWithKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+// StartWithKind начинает спан указанной роли трассировщиком приложения
+func StartWithKind(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if t := global.Load(); t != nil {
+		return t.Start(ctx, name, kind, attrs...)
+	}
+	return ctx, &Span{sc: SpanContextFromContext(ctx)}
+}
+
+// spanContextKey — ключ контекста для текущего спана
+type spanContextKey struct{}
+
+// SpanFromContext возвращает текущий спан или nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext возвращает контекст текущего спана (в том числе удаленного родителя)
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return SpanFromContext(ctx).SpanContext()
+}
+
+// ContextWithRemoteSpanContext сохраняет в ctx родителя, полученного от вызывающего сервиса.
+// Родитель представлен незаписываемым спаном и используется только для продолжения трассы.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, spanContextKey{}, &Span{sc: sc})
+}
+
+// traceparentVersion — поддерживаемая версия формата W3C Trace Context
+const traceparentVersion = "00"
+
+// ErrInvalidTraceparent возвращается при разборе некорректного заголовка traceparent
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent разбирает значение заголовка W3C traceparent
+// вида 00-<trace-id>-<parent-id>-<flags>.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	// Версия 00 содержит ровно четыре поля; будущие версии могут добавлять поля в конец
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return SpanContext{}, err
+	}
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return SpanContext{}, err
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, nil
+}
+
+// Traceparent форматирует контекст как значение заголовка W3C traceparent
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// decodeHex декодирует строчную шестнадцатеричную строку точной длины в dst
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return ErrInvalidTraceparent
+	}
+	return nil
+}
+
+// newTraceID генерирует случайный идентификатор трассы
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID генерирует случайный идентификатор спана
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExporter сохраняет экспортированные спаны в памяти
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *recordingExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error { return nil }
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{name: "Sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{name: "Not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "Future version with extra field", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantSampled: true},
+		{name: "Version 00 with extra field", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "Invalid version ff", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Zero trace ID", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Zero span ID", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "Uppercase hex", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Short trace ID", value: "00-4bf92f35-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Garbage", value: "not-a-traceparent", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTraceparent)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+			assert.Equal(t, tt.wantSampled, sc.Sampled)
+		})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, 1)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+
+	ctx, parent := tracer.Start(ctx, "parent", SpanKindServer, String("http.method", "GET"))
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+	parent.End() // Повторное завершение игнорируется
+
+	out := http.Header{}
+	Inject(ctx, out)
+	assert.Equal(t, parent.SpanContext().Traceparent(), out.Get(TraceparentHeader))
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	require.Len(t, exporter.spans, 2)
+
+	gotChild, gotParent := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", gotParent.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", gotParent.ParentSpanID.String())
+	assert.Equal(t, SpanKindServer, gotParent.Kind)
+	assert.Equal(t, gotParent.TraceID, gotChild.TraceID)
+	assert.Equal(t, gotParent.SpanID, gotChild.ParentSpanID)
+	assert.True(t, gotChild.Error)
+	assert.Equal(t, "boom", gotChild.StatusMessage)
+}
+
+func TestTracerSampling(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, 0)
+
+	// Новые трассы не выбираются, но контекст все равно распространяется
+	ctx, span := tracer.Start(context.Background(), "dropped", SpanKindInternal)
+	assert.False(t, span.IsRecording())
+	assert.True(t, span.SpanContext().IsValid())
+	span.End()
+
+	// Решение вызывающего сервиса имеет приоритет над долей выборки
+	sampled := ContextWithRemoteSpanContext(ctx, SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true})
+	_, span = tracer.Start(sampled, "kept", SpanKindServer)
+	assert.True(t, span.IsRecording())
+	span.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	require.Len(t, exporter.spans, 1)
+	assert.Equal(t, "kept", exporter.spans[0].Name)
+}
+
+func TestStartWithoutTracer(t *testing.T) {
+	SetTracer(nil)
+
+	remote := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+
+	ctx, span := Start(ctx, "noop")
+	assert.False(t, span.IsRecording())
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+
+	// Без трассировщика входящий контекст передается дальше без изменений
+	out := http.Header{}
+	Inject(ctx, out)
+	assert.Equal(t, remote.Traceparent(), out.Get(TraceparentHeader))
+}